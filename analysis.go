@@ -0,0 +1,53 @@
+package main
+
+import "math"
+
+// Analysis bundles everything we compute about one subject, for outputs that want it all at once
+// rather than printing as they go
+type Analysis struct {
+	Subject      string           `json:"subject"`
+	Commits      int              `json:"commits"`
+	Hours        []int            `json:"hours"`
+	Stats        Stats            `json:"stats"`
+	Window       SleepWindow      `json:"window"`
+	Significance Significance     `json:"significance"`
+	PhaseJumps   []PhaseJump      `json:"phase_jumps,omitempty"`
+	SocialJetlag *SocialJetlag    `json:"social_jetlag,omitempty"`
+	Seasons      []SeasonWindow   `json:"seasons,omitempty"`
+	Nights       []Night          `json:"nights,omitempty"`
+	Gaps         GapStats         `json:"gaps"`
+	Timezone     string           `json:"timezone,omitempty"`
+	TimeBasis    string           `json:"time_basis"`
+	Repos        []RepoResolution `json:"repos,omitempty"`
+}
+
+func analyze(subject *Subject) Analysis {
+	times := subject.times()
+	a := Analysis{
+		Subject: subject.Name,
+		Commits: len(subject.Commits),
+		Hours:   hourCounts(times),
+		Stats:   computeStats(times),
+		Window:  estimateSleepWindow(times),
+	}
+	a.Significance = testUniform(a.Hours)
+	a.PhaseJumps = detectJumps(trendWeeks(subject))
+	a.SocialJetlag = socialJetlag(times)
+	a.Seasons = compareSeasons(times)
+	a.Gaps = gapStats(commitGaps(subject))
+	if a.Window.Found {
+		a.Window.Uncertainty = bootstrapWindow(a.Hours)
+		a.Window.Nap = detectNap(a.Hours, a.Window)
+		a.Nights = inferNights(times, a.Window)
+	}
+	// json can't encode the infinite spread of perfectly uniform activity
+	if math.IsInf(a.Stats.StdDev, 0) {
+		a.Stats.StdDev = -1
+	}
+	if subject.Location != nil {
+		a.Timezone = subject.Location.String()
+	}
+	a.TimeBasis = timeBasis(subject)
+	a.Repos = subject.resolutions()
+	return a
+}
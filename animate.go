@@ -0,0 +1,137 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/color/palette"
+	stddraw "image/draw"
+	"image/gif"
+	"os"
+	"time"
+
+	"gonum.org/v1/plot"
+	"gonum.org/v1/plot/plotter"
+	"gonum.org/v1/plot/vg"
+	"gonum.org/v1/plot/vg/draw"
+	"gonum.org/v1/plot/vg/vgimg"
+)
+
+// --plot-animate: the hourly histogram as a gif, one frame per rolling four weeks stepped a week at
+// a time, so a schedule drifting over the months can be watched rather than read off the monthly
+// grid. every frame shares the y scale and shades its own sleep window
+
+const (
+	animateSpan = 28 * 24 * time.Hour
+	animateStep = 7 * 24 * time.Hour
+	// hundredths of a second per frame, the last one lingers
+	animateDelay     = 50
+	animateLastDelay = 200
+)
+
+type animationFrame struct {
+	from, to time.Time
+	times    []time.Time
+}
+
+func animationFrames(times []time.Time) []animationFrame {
+	if len(times) == 0 {
+		return nil
+	}
+	var frames []animationFrame
+	first, last := times[0], times[len(times)-1]
+	for from := first; ; from = from.Add(animateStep) {
+		frame := animationFrame{from: from, to: from.Add(animateSpan)}
+		for _, t := range times {
+			if !t.Before(frame.from) && t.Before(frame.to) {
+				frame.times = append(frame.times, t)
+			}
+		}
+		frames = append(frames, frame)
+		if !frame.to.Before(last) {
+			break
+		}
+	}
+	return frames
+}
+
+func plotAnimation(subject *Subject, outputPath string) error {
+	frames := animationFrames(subject.times())
+	if len(frames) < 2 {
+		return fmt.Errorf("less than %s of commits, nothing to animate", animateSpan+animateStep)
+	}
+
+	var ymax float64
+	for _, frame := range frames {
+		for _, count := range hourCounts(frame.times) {
+			ymax = max(ymax, float64(count))
+		}
+	}
+
+	labels := make([]string, 24)
+	for h := range labels {
+		labels[h] = shortHour(h)
+	}
+
+	width, height := plotSize(8*vg.Inch, 5*vg.Inch)
+	anim := &gif.GIF{}
+	for i, frame := range frames {
+		values := make(plotter.Values, 24)
+		for hour, count := range hourCounts(frame.times) {
+			values[hour] = float64(count)
+		}
+		window := estimateSleepWindow(frame.times)
+
+		p := newPlot(fmt.Sprintf("Commit Distribution: %s\n%s to %s, %s", subject.Name,
+			frame.from.Format(time.DateOnly), frame.to.Add(-time.Second).Format(time.DateOnly), window), "Hour of Day", "Number of Commits")
+		p.Y.Min, p.Y.Max = 0, ymax
+		if err := addWindowBand(p, window, 1, -0.5, 0, ymax, false); err != nil {
+			return err
+		}
+		bars, err := plotter.NewBarChart(values, vg.Points(14))
+		if err != nil {
+			return fmt.Errorf("could not create bar chart: %v", err)
+		}
+		bars.Color = theme.Data
+		bars.LineStyle.Color = theme.Data
+		p.Add(bars)
+		p.NominalX(labels...)
+
+		anim.Image = append(anim.Image, renderFrame(p, width, height, metaFor(subject)))
+		delay := animateDelay
+		if i == len(frames)-1 {
+			delay = animateLastDelay
+		}
+		anim.Delay = append(anim.Delay, delay)
+	}
+
+	f, err := os.Create(outputPath)
+	if err != nil {
+		return fmt.Errorf("could not create %s: %v", outputPath, err)
+	}
+	defer f.Close()
+	if err := gif.EncodeAll(f, anim); err != nil {
+		return fmt.Errorf("could not save animation: %v", err)
+	}
+	return nil
+}
+
+// renderFrame draws p and maps it onto gif's 256 colors. plots are mostly flat fills of the theme's
+// colors, so those go in the palette exactly and plan9's fill the rest for antialiased edges
+func renderFrame(p *plot.Plot, width, height vg.Length, m plotMeta) *image.Paletted {
+	img := vgimg.New(width, height)
+	p.Draw(drawFooter(draw.New(img), m))
+	rgba := img.Image()
+	frame := image.NewPaletted(rgba.Bounds(), framePalette())
+	stddraw.Draw(frame, frame.Rect, rgba, rgba.Bounds().Min, stddraw.Src)
+	return frame
+}
+
+func framePalette() color.Palette {
+	// the window band is translucent, what shows is it over the background
+	band := image.NewRGBA(image.Rect(0, 0, 1, 1))
+	band.Set(0, 0, theme.Background)
+	stddraw.Draw(band, band.Rect, image.NewUniform(theme.Window), image.Point{}, stddraw.Over)
+	exact := color.Palette{theme.Background, theme.Foreground, theme.Data, band.At(0, 0)}
+	return append(exact, palette.Plan9[:256-len(exact)]...)
+}
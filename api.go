@@ -1,6 +1,7 @@
 package main
 
 import (
+	"cmp"
 	"strings"
 	"time"
 	"fmt"
@@ -11,30 +12,56 @@ import (
 	"net/http"
 )
 
-type fetchFunc func(host, user string, flags Flags) ([]string, error)
+// what the forges tell us about a repo before we clone it
+type RepoInfo struct {
+	CloneURL string
+	Size     int64 // bytes as reported by the forge, 0 if unknown
+	// empty if unknown, in which case HEAD is used
+	DefaultBranch string
+	// if set, only these branches are fetched instead of a full clone, see branches.go
+	Branches []string
+	// the forge says it's a fork, for --resolution
+	Fork bool
+	// the superproject, for repos only read as its submodule (--submodules)
+	SubmoduleOf string
+}
 
-func detectAPI(host string) fetchFunc {
-	host = strings.ToLower(host)
+// what a forge tells us about an account: its repos, and anything public that helps match its commits
+type Account struct {
+	Repos  []RepoInfo
+	Emails []string
+	Orgs   []string
+	// repos listed but left out, and why, see resolution.go
+	Skipped []RepoResolution
+}
 
-	// try to match against a known host first
-	switch {
-	case strings.HasSuffix(host, "github.com"):
-		return fetchGitHubRepoURLs
+type fetchFunc func(host, user string, flags Flags) (Account, error)
 
-	case strings.HasSuffix(host, "gitlab.com"):
+func detectAPI(host string) fetchFunc {
+	switch detectForge(host) {
+	case "github":
+		return fetchGitHubRepoURLs
+	case "gitlab":
 		return fetchGitLabRepoURLs
-
-	case strings.HasSuffix(host, "gitea.com"),
-		strings.HasSuffix(host, "codeberg.org"),
-		strings.HasSuffix(host, "forgejo.org"):
+	case "gitea":
 		return fetchGiteaRepoURLs
+	default:
+		return nil
 	}
+}
 
-	client := &http.Client{
-		Timeout: 3 * time.Second,
-		CheckRedirect: func(req *http.Request, via []*http.Request) error {
-			return http.ErrUseLastResponse
-		},
+// detectForge says which api host speaks: github, gitlab, gitea, or "" if none we know
+func detectForge(host string) string {
+	host = strings.ToLower(host)
+
+	// try to match against a known host first
+	if forge := knownForge(host); forge != "" {
+		return forge
+	}
+
+	client := newHTTPClient(3 * time.Second)
+	client.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+		return http.ErrUseLastResponse
 	}
 
 	// fallback effort: manually probe URL for api using hacky string matching
@@ -52,25 +79,34 @@ func detectAPI(host string) fetchFunc {
 
 	switch {
 		case check("/api/v3"):
-			return fetchGitHubRepoURLs
+			return "github"
 		case check("/api/v4/version"):
-			return fetchGitLabRepoURLs
+			return "gitlab"
 		case check("/api/v1/version"):
-			return fetchGiteaRepoURLs
+			return "gitea"
 		default:
-			return nil
+			return ""
 	}
 }
 
 // TODO: github does expose an events API to get recent events, awkward to fit into the architecture though
-func fetchGitHubRepoURLs(host string, username string, flags Flags) ([]string, error) {
+func fetchGitHubRepoURLs(host string, username string, flags Flags) (Account, error) {
 	log.Printf("matched host %s to github API, attempting to fetch repos...", host)
 
+	// graphql gets everything in one round trip, but needs a token and only knows users, not orgs
+	if os.Getenv("GITHUB_TOKEN") != "" {
+		account, err := fetchGitHubGraphQL(username, flags)
+		if err == nil {
+			return account, nil
+		}
+		log.Printf("GitHub GraphQL failed for %s, falling back to REST: %v", username, err)
+	}
+
 	apiURL := fmt.Sprintf("https://api.github.com/users/%s/repos?type=public&sort=pushed&direction=desc&per_page=100", username)
 
 	req, err := http.NewRequest("GET", apiURL, nil)
 	if err != nil {
-		return nil, err
+		return Account{}, err
 	}
 	req.Header.Set("User-Agent", "go-commit-plotter")
 	req.Header.Set("Accept", "application/vnd.github.v3+json")
@@ -79,45 +115,49 @@ func fetchGitHubRepoURLs(host string, username string, flags Flags) ([]string, e
 		req.Header.Set("Authorization", "token "+token)
 	}
 
-	client := &http.Client{}
-	resp, err := client.Do(req)
+	client := newHTTPClient(0)
+	resp, err := getWithETag(client, req)
 	if err != nil {
-		return nil, err
+		return Account{}, err
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("GitHub API request failed: %s", resp.Status)
+		return Account{}, apiStatusError("GitHub", resp)
 	}
 
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return nil, err
+		return Account{}, err
 	}
 
 	var repos []struct {
 		CloneURL string `json:"clone_url"`
-		UpdatedAt string `json:"updated_at"`
+		PushedAt time.Time `json:"pushed_at"`
+		Size     int64  `json:"size"` // KB
+		DefaultBranch string `json:"default_branch"`
+		Fork     bool   `json:"fork"`
+		Archived bool   `json:"archived"`
 	}
 
 	if err := json.Unmarshal(body, &repos); err != nil {
-		return nil, fmt.Errorf("failed to parse JSON response: %v", err)
+		return Account{}, fmt.Errorf("failed to parse JSON response: %v", err)
 	}
 
-	var urls []string
+	var account Account
 	for _, repo := range repos {
-		t, err := time.Parse(time.RFC3339, repo.UpdatedAt)
-		if err != nil {
-			fmt.Errorf("failed to parse time %s via RFC3339", repo.UpdatedAt)
-		} else if t.After(flags.Since) {
-			urls = append(urls, repo.CloneURL)
+		if stale(repo.PushedAt, flags) {
+			account.Skipped = append(account.Skipped, staleRepo(repo.CloneURL, repo.PushedAt, repo.Archived, flags.Since))
+			continue
 		}
+		account.Repos = append(account.Repos, RepoInfo{CloneURL: repo.CloneURL, Size: repo.Size * 1024, DefaultBranch: repo.DefaultBranch, Fork: repo.Fork})
 	}
-	return urls, nil
+	logStale(len(account.Skipped), username, flags)
+	return account, nil
 }
 
 // TODO: untested
-func fetchGitLabRepoURLs(host, username string, flags Flags) ([]string, error) {
+func fetchGitLabRepoURLs(host, username string, flags Flags) (Account, error) {
 	log.Printf("matched host %s to gitlab API, attempting to fetch repos...", host)
 
 	var apiBase string
@@ -127,14 +167,18 @@ func fetchGitLabRepoURLs(host, username string, flags Flags) ([]string, error) {
 	case strings.Contains(host, "gitea"):
 		apiBase = fmt.Sprintf("https://%s/api/v1/users/%s/repos", host, username)
 	default:
-		return nil, fmt.Errorf("unsupported GitLab/Gitea host: %s", host)
+		return Account{}, fmt.Errorf("unsupported GitLab/Gitea host: %s", host)
 	}
 
 	apiURL := apiBase + "?order_by=last_activity_at&sort=desc&per_page=100"
+	if os.Getenv("GITLAB_TOKEN") != "" && strings.Contains(host, "gitlab") {
+		// sizes, for --max-repo-size and --max-mem-per-repo; only reporters and up get them
+		apiURL += "&statistics=true"
+	}
 
 	req, err := http.NewRequest("GET", apiURL, nil)
 	if err != nil {
-		return nil, err
+		return Account{}, err
 	}
 
 	req.Header.Set("User-Agent", "go-commit-plotter")
@@ -146,91 +190,163 @@ func fetchGitLabRepoURLs(host, username string, flags Flags) ([]string, error) {
 		req.Header.Set("Authorization", "token "+token)
 	}
 
-	client := &http.Client{Timeout: 10 * time.Second}
-	resp, err := client.Do(req)
+	client := newHTTPClient(10 * time.Second)
+	resp, err := getWithETag(client, req)
 	if err != nil {
-		return nil, err
+		return Account{}, err
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
 		body, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("API request failed (%s): %s", resp.Status, string(body))
+		return Account{}, fmt.Errorf("%w, %s", apiStatusError("GitLab", resp), string(body))
 	}
 
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return nil, err
+		return Account{}, err
 	}
 
 	var repos []map[string]any
 	if err := json.Unmarshal(body, &repos); err != nil {
-		return nil, fmt.Errorf("failed to parse JSON response: %w", err)
+		return Account{}, fmt.Errorf("failed to parse JSON response: %w", err)
 	}
 
-	var urls []string
+	var account Account
+	var staleCount int
 	for _, repo := range repos {
+		var info RepoInfo
 		switch {
 		case repo["http_url_to_repo"] != nil:
-			urls = append(urls, repo["http_url_to_repo"].(string))
+			info.CloneURL = repo["http_url_to_repo"].(string)
 		case repo["clone_url"] != nil:
-			urls = append(urls, repo["clone_url"].(string))
+			info.CloneURL = repo["clone_url"].(string)
 		case repo["ssh_url_to_repo"] != nil:
-			urls = append(urls, repo["ssh_url_to_repo"].(string))
+			info.CloneURL = repo["ssh_url_to_repo"].(string)
+		}
+		// gitlab says forked_from_project, gitea fork
+		info.Fork = repo["forked_from_project"] != nil || repo["fork"] == true
+		// gitlab says statistics.repository_size in bytes, gitea size in KB
+		if stats, ok := repo["statistics"].(map[string]any); ok {
+			size, _ := stats["repository_size"].(float64)
+			info.Size = int64(size)
+		} else if size, ok := repo["size"].(float64); ok {
+			info.Size = int64(size) * 1024
+		}
+		archived := repo["archived"] == true
+		// gitlab says last_activity_at, gitea updated_at; both move on pushes
+		activity, _ := repo["last_activity_at"].(string)
+		if activity == "" {
+			activity, _ = repo["updated_at"].(string)
 		}
+		if info.CloneURL == "" {
+			name, _ := repo["path_with_namespace"].(string)
+			account.Skipped = append(account.Skipped, RepoResolution{Repo: cmp.Or(name, "?"), Status: repoSkipped, Reason: "no clone url"})
+			continue
+		}
+		if pushed, err := time.Parse(time.RFC3339, activity); err == nil && stale(pushed, flags) {
+			account.Skipped = append(account.Skipped, staleRepo(info.CloneURL, pushed, archived, flags.Since))
+			staleCount++
+			continue
+		}
+		account.Repos = append(account.Repos, info)
 	}
-	return urls, nil
+	logStale(staleCount, username, flags)
+	return account, nil
 }
 
-func fetchGiteaRepoURLs(host, username string, flags Flags) ([]string, error) {
+func fetchGiteaRepoURLs(host, username string, flags Flags) (Account, error) {
 	log.Printf("matched host %s to gitea API, attempting to fetch repos...", host)
 
 	apiURL := fmt.Sprintf("https://%s/api/v1/users/%s/repos?sort=updated&limit=100", host, username)
 	req, err := http.NewRequest("GET", apiURL, nil)
 	if err != nil {
-		return nil, err
+		return Account{}, err
 	}
 	req.Header.Set("User-Agent", "go-commit-plotter")
 	if token := os.Getenv("GITEA_TOKEN"); token != "" {
 		req.Header.Set("Authorization", "token "+token)
 	}
 
-	client := &http.Client{Timeout: 10 * time.Second}
-	resp, err := client.Do(req)
+	client := newHTTPClient(10 * time.Second)
+	resp, err := getWithETag(client, req)
 	if err != nil {
-		return nil, err
+		return Account{}, err
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
 		body, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("gitea API request failed: %s, %s", resp.Status, string(body))
+		return Account{}, fmt.Errorf("%w, %s", apiStatusError("gitea", resp), string(body))
 	}
 
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return nil, err
+		return Account{}, err
 	}
 
 	var repos []struct {
 		CloneURL string `json:"clone_url"`
 		SSHURL   string `json:"ssh_url"`
 		FullName string `json:"full_name"`
+		Size     int64  `json:"size"` // KB
+		// gitea has no pushed_at, but pushes bump this
+		UpdatedAt time.Time `json:"updated_at"`
+		Fork      bool      `json:"fork"`
+		Archived  bool      `json:"archived"`
 	}
 	if err := json.Unmarshal(body, &repos); err != nil {
-		return nil, fmt.Errorf("failed to parse JSON: %w", err)
+		return Account{}, fmt.Errorf("failed to parse JSON: %w", err)
 	}
 
-	var urls []string
+	var account Account
 	for _, r := range repos {
+		info := RepoInfo{Size: r.Size * 1024, Fork: r.Fork}
 		if r.CloneURL != "" {
-			urls = append(urls, r.CloneURL)
+			info.CloneURL = r.CloneURL
 		} else if r.SSHURL != "" {
-			urls = append(urls, r.SSHURL)
+			info.CloneURL = r.SSHURL
 		} else if r.FullName != "" {
-			urls = append(urls, fmt.Sprintf("https://%s/%s.git", host, r.FullName))
+			info.CloneURL = fmt.Sprintf("https://%s/%s.git", host, r.FullName)
+		} else {
+			continue
+		}
+		if stale(r.UpdatedAt, flags) {
+			account.Skipped = append(account.Skipped, staleRepo(info.CloneURL, r.UpdatedAt, r.Archived, flags.Since))
+			continue
 		}
+		account.Repos = append(account.Repos, info)
 	}
-	return urls, nil
+	logStale(len(account.Skipped), username, flags)
+	return account, nil
 }
 
+// stale is whether a repo last pushed at pushed has nothing to find since --since. nothing pushed
+// also drops archived repos, which can't be pushed to, without losing ones archived recently.
+// forges that don't say (zero time) get the benefit of the doubt
+func stale(pushed time.Time, flags Flags) bool {
+	return !pushed.IsZero() && !pushed.After(flags.Since)
+}
+
+func logStale(skipped int, username string, flags Flags) {
+	if skipped > 0 {
+		log.Printf("Skipping %d repos of %s with no pushes since %s", skipped, username, flags.Since.Format(time.DateOnly))
+	}
+}
+
+// knownForge is detectForge without the network: which api a host speaks, if it's one of the big ones
+func knownForge(host string) string {
+	switch host = strings.ToLower(host); {
+	case strings.HasSuffix(host, "github.com"):
+		return "github"
+
+	case strings.HasSuffix(host, "gitlab.com"):
+		return "gitlab"
+
+	case strings.HasSuffix(host, "gitea.com"),
+		strings.HasSuffix(host, "codeberg.org"),
+		strings.HasSuffix(host, "forgejo.org"):
+		return "gitea"
+	}
+	return ""
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"slices"
+	"time"
+
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+// --mode api lists a github user's commits through the api (GET /repos/o/r/commits?author=&since=)
+// instead of cloning. no packfiles, a page per 100 commits, and the window cuts the listing off
+// server side, so a big repo costs a few requests instead of minutes of fetching. what it gives up:
+//   - only the default branch
+//   - github decides authorship by the emails linked to the account, no name or email guessing, and
+//     no mailmap
+//   - no trees, so --dedup and --weight-by files leave these commits alone
+//   - dates come back in utc, without the offset they were made with. give the subject a timezone
+//     or every hour is utc
+//
+// a single source can pick its own with a fragment, e.g. "github.com/alice#api". other forges are
+// always cloned. --mode auto chooses per repo, see hybrid.go
+
+var sourceModes = []string{"clone", "api", "auto"}
+
+// ruleAPIAuthor is the audit rule for commits the forge listed under the user
+const ruleAPIAuthor = "api author"
+
+// sourceMode is the fragment on a source's url if it names a mode, else --mode
+func sourceMode(parsed *url.URL) string {
+	if parsed.Fragment == "" {
+		return flags.Mode
+	}
+	if !slices.Contains(sourceModes, parsed.Fragment) {
+		log.Printf("Unknown mode #%s on %s, using --mode %s", parsed.Fragment, parsed.Host+parsed.Path, flags.Mode)
+		return flags.Mode
+	}
+	return parsed.Fragment
+}
+
+// listCommitsAPI is getRepo for --mode api: the commits in info's default branch that github
+// attributes to user, within --since
+func listCommitsAPI(info RepoInfo, user, subjectName string, since time.Time) ([]*object.Commit, error) {
+	repoPath, ok := githubRepoPath(info.CloneURL)
+	if !ok {
+		return nil, fmt.Errorf("not a github repo: %s", info.CloneURL)
+	}
+	match := audited(subjectName, info.CloneURL, func(*object.Commit) (bool, string) { return true, ruleAPIAuthor })
+
+	defer timed("api", info.CloneURL)()
+	var commits []*object.Commit
+	for page := 1; ; page++ {
+		resp, body, err := githubGet(fmt.Sprintf("https://api.github.com/repos/%s/commits?author=%s&since=%s&per_page=100&page=%d",
+			repoPath, url.QueryEscape(user), since.UTC().Format(time.RFC3339), page))
+		// an empty repo answers 409
+		if resp != nil && resp.StatusCode == http.StatusConflict {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		var listed []struct {
+			SHA    string `json:"sha"`
+			Commit struct {
+				Author    apiSignature `json:"author"`
+				Committer apiSignature `json:"committer"`
+			} `json:"commit"`
+			Parents []struct {
+				SHA string `json:"sha"`
+			} `json:"parents"`
+		}
+		if err := json.Unmarshal(body, &listed); err != nil {
+			return nil, fmt.Errorf("failed to parse JSON response: %w", err)
+		}
+		for _, l := range listed {
+			c := &object.Commit{
+				Hash:      plumbing.NewHash(l.SHA),
+				Author:    l.Commit.Author.signature(),
+				Committer: l.Commit.Committer.signature(),
+			}
+			for _, p := range l.Parents {
+				c.ParentHashes = append(c.ParentHashes, plumbing.NewHash(p.SHA))
+			}
+			if match(c) {
+				commits = append(commits, c)
+			}
+		}
+		if limit := flags.MaxCommitsPerRepo; limit > 0 && len(commits) >= limit {
+			commits = commits[:limit]
+			log.Printf("  Stopped at %d commits in %s (--max-commits-per-repo)", limit, info.CloneURL)
+			break
+		}
+		if len(listed) < 100 {
+			break
+		}
+	}
+	if flags.Tags {
+		releases, err := releaseEvents(repoPath, user, since)
+		if err != nil {
+			log.Printf("  Failed to list releases of %s: %v", info.CloneURL, err)
+		}
+		for _, r := range releases {
+			if match(r) {
+				commits = append(commits, r)
+			}
+		}
+	}
+	log.Printf("  Found %d commits in repo %s (listed through the api)\n", len(commits), info.CloneURL)
+	return commits, nil
+}
+
+type apiSignature struct {
+	Name  string    `json:"name"`
+	Email string    `json:"email"`
+	Date  time.Time `json:"date"`
+}
+
+func (s apiSignature) signature() object.Signature {
+	return object.Signature{Name: s.Name, Email: s.Email, When: s.Date}
+}
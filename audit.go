@@ -0,0 +1,71 @@
+package main
+
+import (
+	"encoding/csv"
+	"log"
+	"os"
+	"time"
+
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+// --audit writes one tab-separated line per commit considered: who it was considered for, where,
+// and which rule kept or dropped it. for figuring out why a count looks wrong.
+// commits past the walk's cutoff are never looked at, so they aren't listed
+
+// rules, as written to the audit file
+const (
+	ruleTooOld         = "too old"
+	ruleBot            = "bot"
+	ruleEmailListed    = "email listed"
+	ruleNameIsUsername = "name is username"
+	ruleNameSubject    = "name contains subject"
+	ruleNameUsername   = "name contains username"
+	ruleNoreply        = "noreply email"
+	ruleEmailPrefix    = "email prefix"
+	ruleNoMatch        = "no rule matched"
+	ruleInWindow       = "in window"
+)
+
+var auditWriter *csv.Writer
+
+// startAudit opens the audit file and returns a func that flushes and closes it
+func startAudit(path string) func() {
+	if path == "" {
+		return func() {}
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		log.Fatalf("could not create audit file %s: %v", path, err)
+	}
+	auditWriter = csv.NewWriter(f)
+	auditWriter.Comma = '\t'
+	auditWriter.Write([]string{"subject", "repo", "commit", "committed", "author", "email", "verdict", "rule"})
+	return func() {
+		auditWriter.Flush()
+		if err := auditWriter.Error(); err != nil {
+			log.Printf("could not write audit file %s: %v", path, err)
+		}
+		f.Close()
+		auditWriter = nil
+		log.Printf("Wrote audit to %s", path)
+	}
+}
+
+// audited turns a rule-reporting matcher into the plain one getRepo wants, logging each verdict
+func audited(subjectName, repoURL string, match func(*object.Commit) (bool, string)) func(*object.Commit) bool {
+	return func(c *object.Commit) bool {
+		ok, rule := match(c)
+		if auditWriter != nil {
+			verdict := "rejected"
+			if ok {
+				verdict = "matched"
+			}
+			auditWriter.Write([]string{
+				subjectName, repoURL, c.Hash.String(), c.Committer.When.Format(time.RFC3339),
+				c.Author.Name, c.Author.Email, verdict, rule,
+			})
+		}
+		return ok
+	}
+}
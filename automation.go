@@ -0,0 +1,116 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"slices"
+	"sort"
+	"time"
+
+	"github.com/go-git/go-git/v5/plumbing"
+)
+
+// cron jobs committing as the subject (backups, dotfile syncs, status pages) commit around the clock,
+// which makes them look like someone who never sleeps. a repo's commits are called automated when
+// they're too regular for a person: nearly all at the same minute past the hour, or nearly all the same
+// distance apart. --automation decides whether they're only flagged or dropped
+
+var automationModes = []string{"flag", "exclude", "off"}
+
+const (
+	// fewer commits than this in a repo can't establish a pattern
+	minAutomationCommits = 12
+	// share of commits that must fit the pattern
+	automationShare = 0.8
+	// how far from the typical gap a gap may be and still count as regular
+	gapTolerance = 2 * time.Minute
+)
+
+type automatedStream struct {
+	repo   string
+	hashes []plumbing.Hash
+	reason string
+}
+
+func detectAutomation(subject *Subject) []automatedStream {
+	byRepo := make(map[string][]plumbing.Hash)
+	for hash := range subject.Commits {
+		origins := subject.Origins[hash]
+		if len(origins) == 0 {
+			continue
+		}
+		byRepo[origins[0]] = append(byRepo[origins[0]], hash)
+	}
+
+	var found []automatedStream
+	for repo, hashes := range byRepo {
+		if len(hashes) < minAutomationCommits {
+			continue
+		}
+		times := make([]time.Time, len(hashes))
+		for i, hash := range hashes {
+			times[i] = subject.Commits[hash].Author.When
+		}
+		if reason := automationReason(times); reason != "" {
+			found = append(found, automatedStream{repo, hashes, reason})
+		}
+	}
+	sort.Slice(found, func(i, j int) bool { return found[i].repo < found[j].repo })
+	return found
+}
+
+// automationReason says why the times look machine-made, or "" if they don't
+func automationReason(times []time.Time) string {
+	minutes := make(map[int]int)
+	for _, t := range times {
+		minutes[t.Minute()]++
+	}
+	for minute, count := range minutes {
+		if share := float64(count) / float64(len(times)); share >= automationShare {
+			return fmt.Sprintf("%.0f%% of commits at :%02d", share*100, minute)
+		}
+	}
+
+	sorted := slices.Clone(times)
+	slices.SortFunc(sorted, func(a, b time.Time) int { return a.Compare(b) })
+	gaps := make([]time.Duration, 0, len(sorted)-1)
+	for i := 1; i < len(sorted); i++ {
+		gaps = append(gaps, sorted[i].Sub(sorted[i-1]))
+	}
+	median := slices.Clone(gaps)
+	slices.Sort(median)
+	typical := median[len(median)/2]
+	if typical < time.Minute {
+		// bursts of a rebase or a scripted import, not a schedule
+		return ""
+	}
+	var regular int
+	for _, gap := range gaps {
+		if (gap - typical).Abs() <= gapTolerance {
+			regular++
+		}
+	}
+	if share := float64(regular) / float64(len(gaps)); share >= automationShare {
+		return fmt.Sprintf("%.0f%% of commits ~%s apart", share*100, typical.Round(time.Minute))
+	}
+	return ""
+}
+
+func handleAutomation(subject *Subject, mode string) {
+	if mode == "off" {
+		return
+	}
+	for _, stream := range detectAutomation(subject) {
+		if mode == "flag" {
+			log.Printf("%s: %d commits in %s look automated (%s); --automation exclude drops them",
+				subject.Name, len(stream.hashes), stream.repo, stream.reason)
+			continue
+		}
+		for _, hash := range stream.hashes {
+			delete(subject.Commits, hash)
+			delete(subject.Origins, hash)
+		}
+		log.Printf("%s: dropped %d automated-looking commits in %s (%s)",
+			subject.Name, len(stream.hashes), stream.repo, stream.reason)
+	}
+}
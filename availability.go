@@ -0,0 +1,170 @@
+package main
+
+import (
+	"fmt"
+	"image/color"
+	"log"
+	"strings"
+
+	"gonum.org/v1/plot"
+	"gonum.org/v1/plot/plotter"
+	"gonum.org/v1/plot/vg"
+)
+
+// --availability: one row per subject, one column per UTC hour, marking when each is active, awake or
+// asleep (same definitions as `sleep overlap`). every group gets a matrix of its members, and there's
+// one of all individual subjects. --plot-availability draws the same thing as a png
+
+const (
+	asleep = iota
+	awake
+	active
+)
+
+type availabilityRow struct {
+	name  string
+	hours [24]int
+}
+
+func availability(subjects []*Subject) []availabilityRow {
+	var rows []availabilityRow
+	for _, s := range subjects {
+		if len(s.Commits) == 0 {
+			continue
+		}
+		times := utcTimes(s)
+		awakeSet, activeSet := awakeHours(times), activeHours(times)
+		row := availabilityRow{name: s.Name}
+		for h := range row.hours {
+			switch {
+			case activeSet[h]:
+				row.hours[h] = active
+			case awakeSet[h]:
+				row.hours[h] = awake
+			}
+		}
+		rows = append(rows, row)
+	}
+	return rows
+}
+
+func reportAvailability(subjects []Subject) {
+	var individuals []*Subject
+	for i := range subjects {
+		if len(subjects[i].Members) == 0 {
+			individuals = append(individuals, &subjects[i])
+		}
+	}
+	matrices := map[string][]*Subject{"everyone": individuals}
+	order := []string{"everyone"}
+	for i := range subjects {
+		group := &subjects[i]
+		if len(group.Members) == 0 {
+			continue
+		}
+		var members []*Subject
+		for _, s := range individuals {
+			for _, m := range group.Members {
+				if s.Name == m {
+					members = append(members, s)
+				}
+			}
+		}
+		matrices[group.Name] = members
+		order = append(order, group.Name)
+	}
+
+	for _, name := range order {
+		rows := availability(matrices[name])
+		if len(rows) == 0 {
+			continue
+		}
+		if flags.Availability {
+			printAvailability(name, rows)
+		}
+		if flags.PlotAvailability {
+			outputFilename := plotPath(name, "availability")
+			if err := plotAvailability(name, rows, outputFilename); err != nil {
+				log.Printf("Failed to save availability plot for %s: %v", name, err)
+			} else {
+				fmt.Printf("Saved availability plot to %s\n", outputFilename)
+			}
+		}
+	}
+}
+
+func printAvailability(name string, rows []availabilityRow) {
+	width := len("all awake")
+	for _, row := range rows {
+		width = max(width, len(row.name))
+	}
+	marks := map[int]string{asleep: "·", awake: "░", active: "█"}
+
+	fmt.Printf("\n=== Availability: %s (UTC, █ active ░ awake · asleep) ===\n", name)
+	fmt.Printf("%-*s  %s\n", width, "", "00    06    12    18")
+	var allAwake [24]bool
+	for h := range allAwake {
+		allAwake[h] = true
+	}
+	for _, row := range rows {
+		var b strings.Builder
+		for h, state := range row.hours {
+			b.WriteString(marks[state])
+			allAwake[h] = allAwake[h] && state != asleep
+		}
+		fmt.Printf("%-*s  %s\n", width, row.name, b.String())
+	}
+	var b strings.Builder
+	for _, ok := range allAwake {
+		if ok {
+			b.WriteString("█")
+		} else {
+			b.WriteString(" ")
+		}
+	}
+	fmt.Printf("%-*s  %s\n", width, "all awake", b.String())
+}
+
+// availabilityGrid is rows top to bottom in the order given
+type availabilityGrid []availabilityRow
+
+func (g availabilityGrid) Dims() (c, r int)   { return 24, len(g) }
+func (g availabilityGrid) Z(c, r int) float64 { return float64(g[len(g)-1-r].hours[c]) }
+func (g availabilityGrid) X(c int) float64    { return float64(c) }
+func (g availabilityGrid) Y(r int) float64    { return float64(r) }
+
+type availabilityPalette struct{}
+
+func (availabilityPalette) Colors() []color.Color {
+	return []color.Color{theme.Background, withAlpha(theme.Data, 0.35), theme.Data}
+}
+
+type nameTicks []string
+
+func (names nameTicks) Ticks(min, max float64) []plot.Tick {
+	var ticks []plot.Tick
+	for i, name := range names {
+		ticks = append(ticks, plot.Tick{Value: float64(len(names) - 1 - i), Label: name})
+	}
+	return ticks
+}
+
+func plotAvailability(name string, rows []availabilityRow, outputPath string) error {
+	names := make(nameTicks, len(rows))
+	for i, row := range rows {
+		names[i] = row.name
+	}
+
+	p := newPlot(fmt.Sprintf("Availability: %s\nbright = active, dim = awake, dark = asleep", name), "Hour of Day (UTC)", "")
+	p.X.Tick.Marker = heatHourTicks{}
+	p.Y.Tick.Marker = names
+	heat := plotter.NewHeatMap(availabilityGrid(rows), availabilityPalette{})
+	heat.Min, heat.Max = asleep, active
+	p.Add(heat)
+
+	width, height := plotSize(10*vg.Inch, vg.Length(max(len(rows), 3))*0.5*vg.Inch+1.5*vg.Inch)
+	if err := savePlot(p, width, height, outputPath, plotMeta{Subject: name, Since: flags.Since}); err != nil {
+		return fmt.Errorf("could not save plot: %v", err)
+	}
+	return nil
+}
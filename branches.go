@@ -0,0 +1,249 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	gitconfig "github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/protocol/packp"
+	"github.com/go-git/go-git/v5/storage"
+	"github.com/go-git/go-git/v5/storage/memory"
+)
+
+// --pushed-branches: for big shared repos most refs are other people's. github's repo activity API
+// says which branches the subject pushed to, so only those (plus the default branch, where their
+// merged work lands) are fetched. github only; other forges clone as usual
+
+// pushedBranches asks github which branches user pushed to in the repo since --since.
+// nil with no error means the window is too long for the API to answer, so fetch everything
+func pushedBranches(repoURL, user string, since time.Time) ([]string, error) {
+	u, err := url.Parse(repoURL)
+	if err != nil || !strings.HasSuffix(strings.ToLower(u.Hostname()), "github.com") {
+		return nil, fmt.Errorf("not a github repo: %s", repoURL)
+	}
+	project := strings.TrimSuffix(strings.Trim(u.Path, "/"), ".git")
+
+	var period string
+	switch age := time.Since(since); {
+	case age <= 24*time.Hour:
+		period = "day"
+	case age <= 7*24*time.Hour:
+		period = "week"
+	case age <= 31*24*time.Hour:
+		period = "month"
+	case age <= 92*24*time.Hour:
+		period = "quarter"
+	case age <= 366*24*time.Hour:
+		period = "year"
+	default:
+		return nil, nil
+	}
+
+	apiURL := fmt.Sprintf("https://api.github.com/repos/%s/activity?actor=%s&activity_type=push&time_period=%s&per_page=100",
+		project, url.QueryEscape(user), period)
+	req, err := http.NewRequest("GET", apiURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", "go-commit-plotter")
+	req.Header.Set("Accept", "application/vnd.github+json")
+	if token := os.Getenv("GITHUB_TOKEN"); token != "" {
+		req.Header.Set("Authorization", "token "+token)
+	}
+
+	client := newHTTPClient(10 * time.Second)
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, apiStatusError("GitHub activity", resp)
+	}
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	var activity []struct {
+		Ref       string    `json:"ref"`
+		Timestamp time.Time `json:"timestamp"`
+	}
+	if err := json.Unmarshal(body, &activity); err != nil {
+		return nil, fmt.Errorf("failed to parse JSON response: %v", err)
+	}
+
+	seen := make(map[string]bool)
+	branches := []string{}
+	for _, a := range activity {
+		branch, ok := strings.CutPrefix(a.Ref, "refs/heads/")
+		if !ok || seen[branch] || a.Timestamp.Before(since) {
+			continue
+		}
+		seen[branch] = true
+		branches = append(branches, branch)
+	}
+	return branches, nil
+}
+
+// fetchBranches is a clone of just the named branches: blobless, no tags. "HEAD" stands for the
+// remote's default branch. branches the remote no longer has are skipped. returns the tips fetched,
+// in the order asked for
+func fetchBranches(storer storage.Storer, repoURL string, branches []string) (*git.Repository, []plumbing.Hash, error) {
+	lister := git.NewRemote(memory.NewStorage(), &gitconfig.RemoteConfig{Name: "origin", URLs: []string{repoURL}})
+	advertised, err := lister.List(&git.ListOptions{})
+	if err != nil {
+		return nil, nil, err
+	}
+	heads := make(map[string]plumbing.Hash)
+	var defaultBranch string
+	for _, ref := range advertised {
+		if ref.Name() == plumbing.HEAD && ref.Type() == plumbing.SymbolicReference {
+			defaultBranch = ref.Target().Short()
+		}
+		if ref.Name().IsBranch() {
+			heads[ref.Name().Short()] = ref.Hash()
+		}
+	}
+
+	var specs []gitconfig.RefSpec
+	var tips []plumbing.Hash
+	seen := make(map[string]bool)
+	for _, branch := range branches {
+		if branch == "HEAD" {
+			branch = defaultBranch
+		}
+		hash, ok := heads[branch]
+		if !ok || seen[branch] {
+			continue
+		}
+		seen[branch] = true
+		specs = append(specs, gitconfig.RefSpec(fmt.Sprintf("+refs/heads/%s:refs/remotes/origin/%s", branch, branch)))
+		tips = append(tips, hash)
+	}
+	if len(specs) == 0 {
+		return nil, nil, fmt.Errorf("none of %v exist on the remote", branches)
+	}
+
+	repo, err := fetchRefSpecs(storer, repoURL, specs)
+	if err != nil {
+		return nil, nil, err
+	}
+	return repo, tips, nil
+}
+
+// fetchRefSpecs inits a repo and fetches only what specs ask for, blobless and without tags
+func fetchRefSpecs(storer storage.Storer, repoURL string, specs []gitconfig.RefSpec) (*git.Repository, error) {
+	repo, err := git.Init(storer, nil)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := repo.CreateRemote(&gitconfig.RemoteConfig{Name: "origin", URLs: []string{repoURL}}); err != nil {
+		return nil, err
+	}
+
+	opts := &git.FetchOptions{
+		RemoteName: "origin",
+		RefSpecs:   specs,
+		Filter:     packp.FilterBlobNone(),
+		Tags:       plumbing.NoTags,
+	}
+	err = repo.Fetch(opts)
+	if errors.Is(err, git.ErrFilterNotSupported) {
+		// older servers; a few refs with blobs still beats every ref without
+		opts.Filter = ""
+		err = repo.Fetch(opts)
+	}
+	if err != nil && err != git.NoErrAlreadyUpToDate {
+		return nil, err
+	}
+	return repo, nil
+}
+
+// refSpecs is --refspec as go-git wants it
+func refSpecs() []gitconfig.RefSpec {
+	specs := make([]gitconfig.RefSpec, len(flags.RefSpecs))
+	for i, spec := range flags.RefSpecs {
+		specs[i] = gitconfig.RefSpec(spec)
+	}
+	return specs
+}
+
+// fetchedTips is every ref a fetch into a fresh repo created
+func fetchedTips(repo *git.Repository) ([]plumbing.Hash, error) {
+	refs, err := repo.References()
+	if err != nil {
+		return nil, err
+	}
+	var tips []plumbing.Hash
+	err = refs.ForEach(func(ref *plumbing.Reference) error {
+		// a stored pack's refs aren't the remote's, see packstore.go
+		if ref.Type() == plumbing.HashReference && !strings.HasPrefix(ref.Name().String(), packRefPrefix) {
+			tips = append(tips, ref.Hash())
+		}
+		return nil
+	})
+	return tips, err
+}
+
+// errEmptyRepo is a repo without a single commit to read. nothing failed, there's just nothing there
+var errEmptyRepo = errors.New("empty repository")
+
+// headTips is where a full clone's walk starts: HEAD, when it points at a branch the clone has.
+// a remote HEAD can point at a branch that's gone, or not be advertised at all (old servers, dumb
+// http), in which case go-git guesses master. then it's the forge's default branch, and failing
+// that the branch with the newest commit
+func headTips(repo *git.Repository, repoURL, defaultBranch string) ([]plumbing.Hash, error) {
+	if head, err := repo.Head(); err == nil {
+		return []plumbing.Hash{head.Hash()}, nil
+	}
+	if defaultBranch != "" {
+		for _, name := range []plumbing.ReferenceName{
+			plumbing.NewRemoteReferenceName("origin", defaultBranch),
+			plumbing.NewBranchReferenceName(defaultBranch),
+		} {
+			if ref, err := repo.Reference(name, true); err == nil {
+				log.Printf("  %s has no usable HEAD, reading its default branch %s", repoURL, defaultBranch)
+				return []plumbing.Hash{ref.Hash()}, nil
+			}
+		}
+	}
+
+	refs, err := repo.References()
+	if err != nil {
+		return nil, err
+	}
+	var newest *plumbing.Reference
+	var newestWhen time.Time
+	err = refs.ForEach(func(ref *plumbing.Reference) error {
+		if ref.Type() != plumbing.HashReference || !(ref.Name().IsRemote() || ref.Name().IsBranch()) {
+			return nil
+		}
+		c, err := repo.CommitObject(ref.Hash())
+		if err != nil {
+			return nil
+		}
+		if newest == nil || c.Committer.When.After(newestWhen) {
+			newest, newestWhen = ref, c.Committer.When
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if newest == nil {
+		return nil, errEmptyRepo
+	}
+	log.Printf("  %s has no usable HEAD, reading its most recently updated branch %s", repoURL, newest.Name().Short())
+	return []plumbing.Hash{newest.Hash()}, nil
+}
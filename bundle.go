@@ -0,0 +1,501 @@
+package main
+
+import (
+	"bufio"
+	"compress/zlib"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/format/packfile"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/storage/memory"
+)
+
+// a source can also be a file someone exported for you, for air-gapped machines or repos you can't
+// clone yourself:
+//
+//	git bundle create alice.bundle --all --since=6.months
+//	git fast-export --all --no-data > alice.fi
+//
+// bundles carry real objects and read like a clone. fast-export streams are rebuilt commit by
+// commit without their trees, so hashes differ from the original repo and --weight-by files sees
+// no changes
+
+// isExportFile is true when a source names a file on disk rather than a forge URL
+func isExportFile(source string) bool {
+	info, err := os.Stat(source)
+	return err == nil && info.Mode().IsRegular()
+}
+
+func getExportSource(path, subjectName string, emails []string, since time.Time) (*Source, map[string][]*object.Commit) {
+	log.Printf("Processing source: %s (export file)\n", path)
+	repo, tips, err := openExport(path)
+	if err != nil {
+		log.Printf("  Failed to read %s: %v", path, err)
+		return nil, nil
+	}
+	if len(tips) == 0 {
+		log.Printf("  No refs in %s", path)
+		return nil, nil
+	}
+
+	// no forge, so no username to match on
+	commits, err := newRepoWalk(repo, path, tips, since).match(audited(subjectName, path, func(c *object.Commit) (bool, string) {
+		return validateCommit(c, subjectName, "", emails)
+	}))
+	if err != nil {
+		log.Printf("  Failed to iterate commits for %s: %v", path, err)
+		return nil, nil
+	}
+	log.Printf("  Found %d commits in %s\n", len(commits), path)
+
+	source := &Source{url: path, repos: []*git.Repository{repo}}
+	return source, map[string][]*object.Commit{path: commits}
+}
+
+// openExport loads a bundle or fast-export stream into memory and returns its ref tips
+func openExport(path string) (*git.Repository, []plumbing.Hash, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer f.Close()
+
+	r := bufio.NewReader(f)
+	first, err := r.Peek(16)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return nil, nil, err
+	}
+	storage := memory.NewStorage()
+	repo, err := git.Init(storage, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	var refs map[string]plumbing.Hash
+	if strings.HasPrefix(string(first), "# v2 git bundle") || strings.HasPrefix(string(first), "# v3 git bundle") {
+		refs, err = readBundle(storage, r)
+	} else {
+		refs, err = readFastExport(storage, r)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// HEAD first so the mailmap comes from it, the rest in a stable order
+	names := make([]string, 0, len(refs))
+	for name := range refs {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		return names[i] == "HEAD" || (names[j] != "HEAD" && names[i] < names[j])
+	})
+	var tips []plumbing.Hash
+	seen := make(map[plumbing.Hash]bool)
+	for _, name := range names {
+		hash := refs[name]
+		if name != "HEAD" {
+			storage.SetReference(plumbing.NewHashReference(plumbing.ReferenceName(name), hash))
+		}
+		if !seen[hash] {
+			seen[hash] = true
+			tips = append(tips, hash)
+		}
+	}
+	return repo, tips, nil
+}
+
+// readBundle reads the bundle header (refs and prerequisites) and stores the packfile after it
+func readBundle(storage *memory.Storage, r *bufio.Reader) (map[string]plumbing.Hash, error) {
+	refs := make(map[string]plumbing.Hash)
+	var prerequisites []plumbing.Hash
+	for first := true; ; first = false {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return nil, fmt.Errorf("truncated bundle header: %w", err)
+		}
+		line = strings.TrimSuffix(line, "\n")
+		switch {
+		case first:
+			continue
+		case line == "":
+			if len(prerequisites) == 0 {
+				if err := packfile.UpdateObjectStorage(storage, r); err != nil {
+					return nil, err
+				}
+				return refs, nil
+			}
+			// history stops at the prerequisites, like a shallow clone's; fine as long as they're
+			// older than --since
+			log.Printf("  Bundle has %d prerequisite commits it doesn't include", len(prerequisites))
+			if err := storage.SetShallow(prerequisites); err != nil {
+				return nil, err
+			}
+			missing, err := readThinPack(storage, r)
+			if err != nil {
+				return nil, err
+			}
+			if missing > 0 {
+				log.Printf("  %d objects in the bundle are deltas against the prerequisites, left out", missing)
+			}
+			return refs, nil
+		case strings.HasPrefix(line, "@"):
+			if line == "@object-format=sha256" {
+				return nil, fmt.Errorf("sha256 bundles aren't supported")
+			}
+		case strings.HasPrefix(line, "-"):
+			hash, _, _ := strings.Cut(line[1:], " ")
+			if !plumbing.IsHash(hash) {
+				return nil, fmt.Errorf("bad bundle prerequisite line %q", line)
+			}
+			prerequisites = append(prerequisites, plumbing.NewHash(hash))
+		default:
+			hash, name, ok := strings.Cut(line, " ")
+			if !ok || !plumbing.IsHash(hash) {
+				return nil, fmt.Errorf("bad bundle ref line %q", line)
+			}
+			refs[name] = plumbing.NewHash(hash)
+		}
+	}
+}
+
+// readThinPack stores the objects of a thin pack, which git makes for a bundle with prerequisites:
+// its deltas can have bases the prerequisites have and it doesn't. go-git's parser fails on those,
+// so the pack is read here and the objects whose bases are missing are left out and counted.
+// they're mostly file contents, which clones leave out too; a missing tree only costs that commit
+// its --weight-by files
+func readThinPack(storage *memory.Storage, r *bufio.Reader) (int, error) {
+	pack := &countingReader{r: r}
+	header := make([]byte, 12)
+	if _, err := io.ReadFull(pack, header); err != nil {
+		return 0, fmt.Errorf("truncated pack: %w", err)
+	}
+	if string(header[:4]) != "PACK" {
+		return 0, fmt.Errorf("bundle has no pack")
+	}
+	count := binary.BigEndian.Uint32(header[8:])
+
+	type packObject struct {
+		typ        plumbing.ObjectType
+		data       []byte
+		baseOffset int64 // ofs deltas
+		baseHash   plumbing.Hash
+		done       bool
+	}
+	objects := make(map[int64]*packObject, count)
+	byHash := make(map[plumbing.Hash]*packObject)
+	store := func(o *packObject) error {
+		obj := storage.NewEncodedObject()
+		obj.SetType(o.typ)
+		obj.SetSize(int64(len(o.data)))
+		w, err := obj.Writer()
+		if err != nil {
+			return err
+		}
+		if _, err := w.Write(o.data); err != nil {
+			return err
+		}
+		w.Close()
+		hash, err := storage.SetEncodedObject(obj)
+		o.done = true
+		byHash[hash] = o
+		return err
+	}
+
+	for range count {
+		offset := pack.n
+		b, err := pack.ReadByte()
+		if err != nil {
+			return 0, fmt.Errorf("truncated pack: %w", err)
+		}
+		o := &packObject{typ: plumbing.ObjectType((b >> 4) & 7)}
+		// the rest of the size; the inflated data says it too
+		for b&0x80 != 0 {
+			if b, err = pack.ReadByte(); err != nil {
+				return 0, fmt.Errorf("truncated pack: %w", err)
+			}
+		}
+		switch o.typ {
+		case plumbing.OFSDeltaObject:
+			if b, err = pack.ReadByte(); err != nil {
+				return 0, fmt.Errorf("truncated pack: %w", err)
+			}
+			back := int64(b & 0x7f)
+			for b&0x80 != 0 {
+				if b, err = pack.ReadByte(); err != nil {
+					return 0, fmt.Errorf("truncated pack: %w", err)
+				}
+				back = (back+1)<<7 | int64(b&0x7f)
+			}
+			o.baseOffset = offset - back
+		case plumbing.REFDeltaObject:
+			if _, err := io.ReadFull(pack, o.baseHash[:]); err != nil {
+				return 0, fmt.Errorf("truncated pack: %w", err)
+			}
+		}
+		// zlib reads no further than its stream from a ByteReader, so the next object starts after
+		zr, err := zlib.NewReader(pack)
+		if err != nil {
+			return 0, err
+		}
+		if o.data, err = io.ReadAll(zr); err != nil {
+			return 0, err
+		}
+		objects[offset] = o
+		if !o.typ.IsDelta() {
+			if err := store(o); err != nil {
+				return 0, err
+			}
+		}
+	}
+
+	// deltas can be bases of other deltas, in any order; apply what can be until nothing changes
+	for progress := true; progress; {
+		progress = false
+		for _, o := range objects {
+			if o.done {
+				continue
+			}
+			base := byHash[o.baseHash]
+			if o.typ == plumbing.OFSDeltaObject {
+				base = objects[o.baseOffset]
+			}
+			if base == nil || !base.done {
+				continue
+			}
+			data, err := packfile.PatchDelta(base.data, o.data)
+			if err != nil {
+				return 0, err
+			}
+			o.typ, o.data = base.typ, data
+			if err := store(o); err != nil {
+				return 0, err
+			}
+			progress = true
+		}
+	}
+	var missing int
+	for _, o := range objects {
+		if !o.done {
+			missing++
+		}
+	}
+	return missing, nil
+}
+
+// countingReader is a pack being read, and how far in
+type countingReader struct {
+	r *bufio.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
+
+func (c *countingReader) ReadByte() (byte, error) {
+	b, err := c.r.ReadByte()
+	if err == nil {
+		c.n++
+	}
+	return b, err
+}
+
+// readFastExport replays the commits of a fast-export stream. blobs, file changes and tags are
+// skipped (inline file contents are data commands too, and skipped the same way); each commit
+// gets the empty tree
+func readFastExport(storage *memory.Storage, r *bufio.Reader) (map[string]plumbing.Hash, error) {
+	emptyTree := storage.NewEncodedObject()
+	if err := (&object.Tree{}).Encode(emptyTree); err != nil {
+		return nil, err
+	}
+	treeHash, err := storage.SetEncodedObject(emptyTree)
+	if err != nil {
+		return nil, err
+	}
+
+	refs := make(map[string]plumbing.Hash)
+	// marks and original hashes both resolve to the rebuilt commits
+	resolved := make(map[string]plumbing.Hash)
+	var unresolved int
+	resolve := func(commitish string) (plumbing.Hash, bool) {
+		if hash, ok := resolved[commitish]; ok {
+			return hash, true
+		}
+		if ref, ok := refs[commitish]; ok {
+			return ref, true
+		}
+		unresolved++
+		return plumbing.ZeroHash, false
+	}
+
+	var commit *object.Commit
+	var ref, mark, originalOID string
+	finish := func() error {
+		if commit == nil {
+			return nil
+		}
+		commit.TreeHash = treeHash
+		obj := storage.NewEncodedObject()
+		if err := commit.Encode(obj); err != nil {
+			return err
+		}
+		hash, err := storage.SetEncodedObject(obj)
+		if err != nil {
+			return err
+		}
+		for _, key := range []string{mark, originalOID} {
+			if key != "" {
+				resolved[key] = hash
+			}
+		}
+		refs[ref] = hash
+		commit, ref, mark, originalOID = nil, "", "", ""
+		return nil
+	}
+
+	for {
+		line, err := r.ReadString('\n')
+		if errors.Is(err, io.EOF) && line == "" {
+			break
+		} else if err != nil && !errors.Is(err, io.EOF) {
+			return nil, err
+		}
+		line = strings.TrimSuffix(line, "\n")
+		cmd, arg, _ := strings.Cut(line, " ")
+
+		switch cmd {
+		case "commit", "blob", "reset", "tag", "done":
+			if err := finish(); err != nil {
+				return nil, err
+			}
+			ref, mark, originalOID = "", "", ""
+		}
+
+		switch cmd {
+		case "commit":
+			commit = &object.Commit{}
+			ref = arg
+		case "reset":
+			ref = arg
+		case "mark":
+			mark = arg
+		case "original-oid":
+			originalOID = arg
+		case "author", "committer":
+			if commit == nil {
+				continue
+			}
+			sig, err := parseFastExportIdent(arg)
+			if err != nil {
+				return nil, err
+			}
+			if cmd == "author" {
+				commit.Author = sig
+			} else {
+				commit.Committer = sig
+			}
+		case "data":
+			data, err := readFastExportData(r, arg)
+			if err != nil {
+				return nil, err
+			}
+			if commit != nil && commit.Message == "" {
+				commit.Message = string(data)
+			}
+		case "from", "merge":
+			hash, ok := resolve(arg)
+			if !ok {
+				continue
+			}
+			if commit != nil {
+				commit.ParentHashes = append(commit.ParentHashes, hash)
+			} else if cmd == "from" && ref != "" {
+				// reset <ref> / from <commit>
+				refs[ref] = hash
+			}
+		}
+	}
+	if err := finish(); err != nil {
+		return nil, err
+	}
+	if unresolved > 0 {
+		log.Printf("  %d parents in the stream point at commits it doesn't include", unresolved)
+	}
+	if len(refs) == 0 {
+		return nil, fmt.Errorf("no commits in stream")
+	}
+	return refs, nil
+}
+
+// parseFastExportIdent parses `Name <email> 1700000000 +0100`
+func parseFastExportIdent(s string) (object.Signature, error) {
+	open, close := strings.IndexByte(s, '<'), strings.LastIndexByte(s, '>')
+	if open < 0 || close < open {
+		return object.Signature{}, fmt.Errorf("bad ident %q", s)
+	}
+	sig := object.Signature{
+		Name:  strings.TrimSpace(s[:open]),
+		Email: s[open+1 : close],
+	}
+	fields := strings.Fields(s[close+1:])
+	if len(fields) != 2 {
+		return object.Signature{}, fmt.Errorf("bad ident date %q", s)
+	}
+	secs, err := strconv.ParseInt(fields[0], 10, 64)
+	if err != nil {
+		return object.Signature{}, fmt.Errorf("bad ident date %q", s)
+	}
+	zone := fields[1]
+	hhmm, err := strconv.Atoi(strings.TrimLeft(zone, "+-"))
+	if err != nil || len(zone) != 5 {
+		return object.Signature{}, fmt.Errorf("bad ident zone %q", s)
+	}
+	offset := (hhmm/100*60 + hhmm%100) * 60
+	if zone[0] == '-' {
+		offset = -offset
+	}
+	sig.When = time.Unix(secs, 0).In(time.FixedZone("", offset))
+	return sig, nil
+}
+
+// readFastExportData reads the payload of a `data <count>` or `data <<DELIM` command
+func readFastExportData(r *bufio.Reader, arg string) ([]byte, error) {
+	if delim, ok := strings.CutPrefix(arg, "<<"); ok {
+		var data strings.Builder
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				return nil, fmt.Errorf("unterminated data <<%s: %w", delim, err)
+			}
+			if strings.TrimSuffix(line, "\n") == delim {
+				return []byte(data.String()), nil
+			}
+			data.WriteString(line)
+		}
+	}
+	n, err := strconv.Atoi(arg)
+	if err != nil {
+		return nil, fmt.Errorf("bad data length %q", arg)
+	}
+	data := make([]byte, n)
+	if _, err := io.ReadFull(r, data); err != nil {
+		return nil, err
+	}
+	// an optional newline follows the payload
+	if next, err := r.Peek(1); err == nil && next[0] == '\n' {
+		r.ReadByte()
+	}
+	return data, nil
+}
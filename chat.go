@@ -0,0 +1,300 @@
+package main
+
+import (
+	"archive/zip"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"os"
+	"path"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+// chat exports as sources: a slack workspace export or a discord data package, zipped or unzipped,
+// listed like any other file source. each message the subject sent is an event.
+//   - slack exports hold everyone's messages. the subject's are the ones from a user whose email is
+//     under the subject's emails, or whose name, display name or real name is the subject's name.
+//     to pick the user outright, add #name or #U0123ABC (their id) to the source
+//   - a discord data package is one person's own messages, so all of them count
+//
+// both record times in utc, so give the subject a timezone or every hour is utc
+
+// chatExport opens a chat export at path, naming its kind ("slack" or "discord"), or "" if it
+// isn't one. close is a no-op for directories
+func chatExport(path string) (fsys fs.FS, kind string, close func(), err error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, "", nil, err
+	}
+	close = func() {}
+	if info.IsDir() {
+		fsys = os.DirFS(path)
+	} else {
+		z, err := zip.OpenReader(path)
+		if err != nil {
+			// not a zip, so not ours; bundles and fast-export streams are handled elsewhere
+			return nil, "", nil, nil
+		}
+		fsys, close = z, func() { z.Close() }
+	}
+	switch {
+	case fileExists(fsys, "users.json") && fileExists(fsys, "channels.json"):
+		return fsys, "slack", close, nil
+	case len(discordMessageFiles(fsys)) > 0:
+		return fsys, "discord", close, nil
+	}
+	close()
+	return nil, "", nil, nil
+}
+
+func fileExists(fsys fs.FS, name string) bool {
+	_, err := fs.Stat(fsys, name)
+	return err == nil
+}
+
+// splitChatSource separates "export.zip#alice" into the path and the user to pick, if the path with
+// the fragment isn't a file itself
+func splitChatSource(source string) (string, string) {
+	if _, err := os.Stat(source); err == nil {
+		return source, ""
+	}
+	if i := strings.LastIndexByte(source, '#'); i > 0 {
+		return source[:i], source[i+1:]
+	}
+	return source, ""
+}
+
+// ruleChatUser is the audit rule for messages sent by the subject's chat account
+const ruleChatUser = "chat user"
+
+func getChatSource(source, subjectName string, emails []string, since time.Time) (*Source, map[string][]*object.Commit, bool) {
+	exportPath, who := splitChatSource(source)
+	fsys, kind, closeExport, err := chatExport(exportPath)
+	if err != nil || kind == "" {
+		return nil, nil, false
+	}
+	defer closeExport()
+	log.Printf("Processing source: %s (%s export)\n", source, kind)
+
+	match := audited(subjectName, source, func(c *object.Commit) (bool, string) {
+		if !c.Committer.When.After(since) {
+			return false, ruleTooOld
+		}
+		return true, ruleChatUser
+	})
+	var messages []*object.Commit
+	if kind == "slack" {
+		messages, err = slackMessages(fsys, source, subjectName, who, emails)
+	} else {
+		messages, err = discordMessages(fsys, source)
+	}
+	if err != nil {
+		log.Printf("  Failed to read %s: %v", source, err)
+		return nil, nil, true
+	}
+	var events []*object.Commit
+	for _, m := range messages {
+		if match(m) {
+			events = append(events, m)
+		}
+	}
+	log.Printf("  Found %d messages in %s\n", len(events), source)
+	return &Source{url: source}, map[string][]*object.Commit{source: events}, true
+}
+
+// activityEvent is one message (or other non-commit activity), shaped like a commit. key makes its
+// hash, unique within the source
+func activityEvent(source, key, name, email string, when time.Time) *object.Commit {
+	who := object.Signature{Name: name, Email: email, When: when}
+	hash := plumbing.ComputeHash(plumbing.BlobObject, []byte(source+"\x00"+key))
+	return &object.Commit{Hash: hash, Author: who, Committer: who}
+}
+
+type slackUser struct {
+	ID       string `json:"id"`
+	Name     string `json:"name"`
+	RealName string `json:"real_name"`
+	Profile  struct {
+		Email       string `json:"email"`
+		DisplayName string `json:"display_name"`
+		RealName    string `json:"real_name"`
+	} `json:"profile"`
+}
+
+func (u slackUser) names() []string {
+	return []string{u.ID, u.Name, u.RealName, u.Profile.DisplayName, u.Profile.RealName}
+}
+
+// slackSubtypes are the message subtypes a person sends themselves; joins, topic changes and bots
+// aren't activity
+var slackSubtypes = []string{"", "thread_broadcast", "me_message", "file_share"}
+
+func slackMessages(fsys fs.FS, source, subjectName, who string, emails []string) ([]*object.Commit, error) {
+	data, err := fs.ReadFile(fsys, "users.json")
+	if err != nil {
+		return nil, err
+	}
+	var users []slackUser
+	if err := json.Unmarshal(data, &users); err != nil {
+		return nil, fmt.Errorf("users.json: %w", err)
+	}
+	mine := make(map[string]slackUser)
+	for _, u := range users {
+		if slackUserIs(u, subjectName, who, emails) {
+			mine[u.ID] = u
+		}
+	}
+	if len(mine) == 0 {
+		return nil, fmt.Errorf("no user in the export matches %s; list their email or add #name to the source", subjectName)
+	}
+
+	// channel/2024-03-01.json, one array of messages per channel per day
+	days, err := fs.Glob(fsys, "*/*.json")
+	if err != nil {
+		return nil, err
+	}
+	var events []*object.Commit
+	for _, day := range days {
+		data, err := fs.ReadFile(fsys, day)
+		if err != nil {
+			return nil, err
+		}
+		var msgs []struct {
+			Type    string `json:"type"`
+			Subtype string `json:"subtype"`
+			User    string `json:"user"`
+			TS      string `json:"ts"`
+		}
+		if err := json.Unmarshal(data, &msgs); err != nil {
+			log.Printf("  Skipping %s: %v", day, err)
+			continue
+		}
+		for _, m := range msgs {
+			u, ok := mine[m.User]
+			if !ok || m.Type != "message" || !slices.Contains(slackSubtypes, m.Subtype) {
+				continue
+			}
+			// "1709335920.123456", seconds since the epoch
+			secs, err := strconv.ParseFloat(m.TS, 64)
+			if err != nil {
+				continue
+			}
+			when := time.Unix(int64(secs), 0).UTC()
+			events = append(events, activityEvent(source, path.Dir(day)+" "+m.TS, u.Name, u.Profile.Email, when))
+		}
+	}
+	return events, nil
+}
+
+func slackUserIs(u slackUser, subjectName, who string, emails []string) bool {
+	if who != "" {
+		return slices.ContainsFunc(u.names(), func(n string) bool { return n != "" && strings.EqualFold(n, who) })
+	}
+	if u.Profile.Email != "" && slices.ContainsFunc(emails, func(e string) bool { return strings.EqualFold(e, u.Profile.Email) }) {
+		return true
+	}
+	return slices.ContainsFunc(u.names()[1:], func(n string) bool { return n != "" && strings.EqualFold(n, subjectName) })
+}
+
+// discord packages have messages/c<channel id>/messages.json (newer) or messages.csv (older), with
+// the folder capitalized in some years
+var discordMessagesPath = regexp.MustCompile(`(?i)^messages/c?\d+/messages\.(json|csv)$`)
+
+func discordMessageFiles(fsys fs.FS) []string {
+	var files []string
+	fs.WalkDir(fsys, ".", func(p string, d fs.DirEntry, err error) error {
+		if err == nil && !d.IsDir() && discordMessagesPath.MatchString(p) {
+			files = append(files, p)
+		}
+		return nil
+	})
+	return files
+}
+
+// discordTimeLayouts have no zone or +00:00; they're all utc
+var discordTimeLayouts = []string{"2006-01-02 15:04:05.999999-07:00", "2006-01-02 15:04:05", time.RFC3339Nano}
+
+func parseDiscordTime(s string) (time.Time, error) {
+	for _, layout := range discordTimeLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t.UTC(), nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("unknown time format %q", s)
+}
+
+func discordMessages(fsys fs.FS, source string) ([]*object.Commit, error) {
+	var events []*object.Commit
+	for _, file := range discordMessageFiles(fsys) {
+		rows, err := discordRows(fsys, file)
+		if err != nil {
+			log.Printf("  Skipping %s: %v", file, err)
+			continue
+		}
+		for _, row := range rows {
+			when, err := parseDiscordTime(row[1])
+			if err != nil {
+				continue
+			}
+			events = append(events, activityEvent(source, row[0], "", "", when))
+		}
+	}
+	return events, nil
+}
+
+// discordRows is each message's id and timestamp, from either format
+func discordRows(fsys fs.FS, file string) ([][2]string, error) {
+	f, err := fsys.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var rows [][2]string
+	if strings.HasSuffix(strings.ToLower(file), ".json") {
+		var msgs []struct {
+			ID        json.Number `json:"ID"`
+			Timestamp string      `json:"Timestamp"`
+		}
+		if err := json.NewDecoder(f).Decode(&msgs); err != nil {
+			return nil, err
+		}
+		for _, m := range msgs {
+			rows = append(rows, [2]string{m.ID.String(), m.Timestamp})
+		}
+		return rows, nil
+	}
+
+	r := csv.NewReader(f)
+	r.FieldsPerRecord = -1
+	header, err := r.Read()
+	if err != nil {
+		return nil, err
+	}
+	id, ts := slices.Index(header, "ID"), slices.Index(header, "Timestamp")
+	if id < 0 || ts < 0 {
+		return nil, fmt.Errorf("no ID and Timestamp columns")
+	}
+	for {
+		record, err := r.Read()
+		if err == io.EOF {
+			return rows, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if max(id, ts) < len(record) {
+			rows = append(rows, [2]string{record[id], record[ts]})
+		}
+	}
+}
@@ -0,0 +1,46 @@
+package main
+
+import "fmt"
+
+// --clock 12 swaps every time of day shown to a person (terminal, plot ticks, window text) to AM/PM.
+// anything written for machines (snapshots, json, audit) stays 24h
+
+var clockModes = []int{24, 12}
+
+func twelveHour(h int) (int, string) {
+	suffix := "AM"
+	if h >= 12 {
+		suffix = "PM"
+	}
+	if h = h % 12; h == 0 {
+		h = 12
+	}
+	return h, suffix
+}
+
+// hourString is 23:00, or 11 PM
+func hourString(h int) string {
+	h = (h%24 + 24) % 24
+	if flags.Clock == 12 {
+		h12, suffix := twelveHour(h)
+		return fmt.Sprintf("%d %s", h12, suffix)
+	}
+	return fmt.Sprintf("%02d:00", h)
+}
+
+// hourRange is 23:00-07:00, or 11 PM – 7 AM
+func hourRange(start, end int) string {
+	if flags.Clock == 12 {
+		return hourString(start) + " – " + hourString(end)
+	}
+	return hourString(start) + "-" + hourString(end)
+}
+
+// shortHour is for axes with all 24 hours on them: 07, or 7a
+func shortHour(h int) string {
+	if flags.Clock == 12 {
+		h12, suffix := twelveHour(h)
+		return fmt.Sprintf("%d%c", h12, suffix[0]+'a'-'A')
+	}
+	return fmt.Sprintf("%02d", h)
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"os"
+)
+
+// --color: the terminal report highlights the sleep window's hours in the histogram and colors the
+// confidence green, yellow or red. auto (the default) only does so when stdout is a terminal, TERM
+// isn't dumb and NO_COLOR (https://no-color.org) isn't set; always and never override all three
+
+var colorModes = []string{"auto", "always", "never"}
+
+const (
+	ansiReset  = "\033[0m"
+	ansiBold   = "\033[1m"
+	ansiRed    = "\033[31m"
+	ansiGreen  = "\033[32m"
+	ansiYellow = "\033[33m"
+	ansiBlue   = "\033[34m"
+)
+
+var useColor bool
+
+func resolveColor(mode string) bool {
+	switch mode {
+	case "always":
+		return true
+	case "never":
+		return false
+	}
+	if os.Getenv("NO_COLOR") != "" || os.Getenv("TERM") == "dumb" {
+		return false
+	}
+	info, err := os.Stdout.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// paint wraps s in an ansi code, or leaves it alone without color
+func paint(code, s string) string {
+	if !useColor || s == "" {
+		return s
+	}
+	return code + s + ansiReset
+}
+
+// confidenceColor is green for high confidence, yellow for medium and red for low
+func (w SleepWindow) confidenceColor() string {
+	switch w.confidenceLabel() {
+	case "high":
+		return ansiGreen
+	case "medium":
+		return ansiYellow
+	default:
+		return ansiRed
+	}
+}
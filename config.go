@@ -0,0 +1,41 @@
+package main
+
+import (
+	"errors"
+	"io/fs"
+	"log"
+	"os"
+
+	"github.com/pelletier/go-toml/v2"
+)
+
+// sleep.toml holds settings that don't belong to any one subject.
+// it's optional; a missing file just means defaults everywhere
+
+const configFile = "sleep.toml"
+
+type Config struct {
+	Theme  ThemeConfig           `toml:"theme"`
+	Output OutputConfig          `toml:"output"`
+	Digest DigestConfig          `toml:"digest"`
+	Server ServerConfig          `toml:"server"`
+	Hosts  map[string]HostConfig `toml:"hosts"`
+	Priors PriorsConfig          `toml:"priors"`
+	// source plugin name to executable, see plugin.go
+	Plugins map[string]string `toml:"plugins"`
+}
+
+var config Config
+
+func loadConfig() {
+	data, err := os.ReadFile(configFile)
+	if errors.Is(err, fs.ErrNotExist) {
+		return
+	}
+	if err != nil {
+		log.Fatalf("Failed to read %s: %v", configFile, err)
+	}
+	if err := toml.Unmarshal(data, &config); err != nil {
+		log.Fatalf("Failed to unmarshal %s: %v", configFile, err)
+	}
+}
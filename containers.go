@@ -0,0 +1,367 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+// container images as sources. hub.docker.com/u/alice lists alice's docker hub repositories, and
+// each image pushed to one of their tags is an event. hub only keeps the last push of each image,
+// so an image pushed again counts once, at the later time.
+//
+// oci://registry.example.com/alice does the same for any registry with a catalog (/v2/_catalog),
+// for repositories under alice/, or all of them without a path. the registry api has no push
+// times, so there an image counts when it was built, from its config's created time; builds that
+// pin it (reproducible builds set it to 1970) are skipped. credentials for private registries go in
+// REGISTRY_USER and REGISTRY_PASSWORD. times are utc, so set the subject's timezone
+
+// ruleImagePush is the audit rule for images pushed to the subject's repositories
+const ruleImagePush = "image push"
+
+// imagePush is one image in a repository, by digest
+type imagePush struct {
+	repo, digest string
+	pushed       time.Time
+}
+
+// containerUser is the docker hub namespace in a hub url, ok false if it isn't one
+func containerUser(parsed *url.URL) (string, bool) {
+	host := strings.ToLower(parsed.Hostname())
+	if host != "hub.docker.com" {
+		return "", false
+	}
+	// /u/alice, or /r/alice for an organization
+	parts := strings.Split(strings.Trim(parsed.Path, "/"), "/")
+	if len(parts) != 2 || (parts[0] != "u" && parts[0] != "r") {
+		return "", false
+	}
+	return parts[1], true
+}
+
+func getContainerSource(rawURL, registry, namespace, subjectName string, since time.Time) (*Source, map[string][]*object.Commit) {
+	log.Printf("Processing source: %s (container images)\n", rawURL)
+	defer timed("api", registry+"/"+namespace)()
+
+	var pushes []imagePush
+	var err error
+	if registry == "docker.io" {
+		pushes, err = dockerHubPushes(namespace)
+	} else {
+		pushes, err = ociPushes(registry, namespace)
+	}
+	if err != nil {
+		log.Printf("  Failed to list images in %s: %v", rawURL, err)
+		noteFailure(err)
+		return nil, nil
+	}
+	match := audited(subjectName, rawURL, func(c *object.Commit) (bool, string) {
+		if !c.Committer.When.After(since) {
+			return false, ruleTooOld
+		}
+		return true, ruleImagePush
+	})
+	var events []*object.Commit
+	repos := make(map[string]bool)
+	for _, p := range pushes {
+		if c := activityEvent(registry, p.repo+"@"+p.digest, namespace, "", p.pushed); match(c) {
+			events = append(events, c)
+			repos[p.repo] = true
+		}
+	}
+	log.Printf("  Found %d images in %d repositories\n", len(events), len(repos))
+	return &Source{url: rawURL, host: registry, user: namespace}, map[string][]*object.Commit{rawURL: events}
+}
+
+// dockerHubPushes pages through namespace's repositories and their tags' images
+func dockerHubPushes(namespace string) ([]imagePush, error) {
+	var repos []string
+	for next := "https://hub.docker.com/v2/repositories/" + url.PathEscape(namespace) + "/?page_size=100"; next != ""; {
+		var page struct {
+			Next    string `json:"next"`
+			Results []struct {
+				Name string `json:"name"`
+			} `json:"results"`
+		}
+		if err := registryGet(next, &page); err != nil {
+			return nil, err
+		}
+		for _, r := range page.Results {
+			repos = append(repos, namespace+"/"+r.Name)
+		}
+		next = page.Next
+	}
+
+	// tags share images, and an image's last push is the same under each of them
+	pushed := make(map[[2]string]time.Time)
+	for _, repo := range repos {
+		for next := "https://hub.docker.com/v2/repositories/" + repo + "/tags?page_size=100"; next != ""; {
+			var page struct {
+				Next    string `json:"next"`
+				Results []struct {
+					Images []struct {
+						Digest     string    `json:"digest"`
+						LastPushed time.Time `json:"last_pushed"`
+					} `json:"images"`
+				} `json:"results"`
+			}
+			if err := registryGet(next, &page); err != nil {
+				log.Printf("  Skipping %s: %v", repo, err)
+				break
+			}
+			for _, tag := range page.Results {
+				for _, image := range tag.Images {
+					if image.Digest == "" || image.LastPushed.IsZero() {
+						continue
+					}
+					pushed[[2]string{repo, image.Digest}] = image.LastPushed
+				}
+			}
+			next = page.Next
+		}
+	}
+	var pushes []imagePush
+	for key, when := range pushed {
+		pushes = append(pushes, imagePush{key[0], key[1], when.UTC()})
+	}
+	return pushes, nil
+}
+
+// ociPageSize is how many repositories or tags are asked for at a time; registries may send fewer
+const ociPageSize = 100
+
+// ociPushes lists namespace's repositories from the registry's catalog, then dates each tagged
+// image by its config
+func ociPushes(registry, namespace string) ([]imagePush, error) {
+	repos, err := ociList(registry, "/v2/_catalog", "repositories")
+	if err != nil {
+		return nil, err
+	}
+	var pushes []imagePush
+	for _, repo := range repos {
+		if namespace != "" && !strings.HasPrefix(repo, namespace+"/") {
+			continue
+		}
+		tags, err := ociList(registry, "/v2/"+repo+"/tags/list", "tags")
+		if err != nil {
+			log.Printf("  Skipping %s: %v", repo, err)
+			continue
+		}
+		seen := make(map[string]bool)
+		for _, tag := range tags {
+			digest, created, err := ociImage(registry, repo, tag)
+			if err != nil {
+				log.Printf("  Skipping %s:%s: %v", repo, tag, err)
+				continue
+			}
+			if seen[digest] || created.Year() <= 1970 {
+				continue
+			}
+			seen[digest] = true
+			pushes = append(pushes, imagePush{repo, digest, created.UTC()})
+		}
+	}
+	return pushes, nil
+}
+
+// ociList pages through a catalog or tag list, returning the names under field. pages end where
+// the registry says, by leaving out the Link to the next one; they can be shorter than asked for
+func ociList(registry, path, field string) ([]string, error) {
+	var names []string
+	next := "https://" + registry + path + "?" + url.Values{"n": {fmt.Sprint(ociPageSize)}}.Encode()
+	for next != "" {
+		body, header, err := ociGet(next, "application/json")
+		if err != nil {
+			return nil, err
+		}
+		// tag lists also carry the repository's name
+		var page map[string]json.RawMessage
+		var list []string
+		if err := json.Unmarshal(body, &page); err != nil {
+			return nil, fmt.Errorf("failed to parse JSON response: %w", err)
+		}
+		// a repository with no tags left has "tags": null
+		if raw := page[field]; raw != nil {
+			if err := json.Unmarshal(raw, &list); err != nil {
+				return nil, fmt.Errorf("failed to parse JSON response: %w", err)
+			}
+		}
+		names = append(names, list...)
+
+		m := ociNextLink.FindStringSubmatch(header.Get("Link"))
+		if m == nil {
+			return names, nil
+		}
+		// usually relative to the registry
+		base, err := url.Parse(next)
+		if err != nil {
+			return nil, err
+		}
+		link, err := base.Parse(m[1])
+		if err != nil {
+			return nil, fmt.Errorf("bad Link header %q: %w", header.Get("Link"), err)
+		}
+		next = link.String()
+	}
+	return names, nil
+}
+
+// ociNextLink is the next page in a Link header: </v2/_catalog?last=b&n=100>; rel="next"
+var ociNextLink = regexp.MustCompile(`<([^>]+)>\s*;\s*rel="?next"?`)
+
+// ociManifestTypes are the manifests an image tag can point at, single images or multi-platform
+var ociManifestTypes = strings.Join([]string{
+	"application/vnd.oci.image.manifest.v1+json",
+	"application/vnd.oci.image.index.v1+json",
+	"application/vnd.docker.distribution.manifest.v2+json",
+	"application/vnd.docker.distribution.manifest.list.v2+json",
+}, ", ")
+
+// ociImage is the digest tag points at and when its image was built. a multi-platform index is
+// dated by its first image, since they're built together
+func ociImage(registry, repo, tag string) (string, time.Time, error) {
+	body, header, err := ociGet("https://"+registry+"/v2/"+repo+"/manifests/"+tag, ociManifestTypes)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	var manifest struct {
+		Config struct {
+			Digest string `json:"digest"`
+		} `json:"config"`
+		Manifests []struct {
+			Digest string `json:"digest"`
+		} `json:"manifests"`
+	}
+	if err := json.Unmarshal(body, &manifest); err != nil {
+		return "", time.Time{}, fmt.Errorf("failed to parse manifest: %w", err)
+	}
+	if manifest.Config.Digest == "" && len(manifest.Manifests) > 0 {
+		body, _, err = ociGet("https://"+registry+"/v2/"+repo+"/manifests/"+manifest.Manifests[0].Digest, ociManifestTypes)
+		if err != nil {
+			return "", time.Time{}, err
+		}
+		if err := json.Unmarshal(body, &manifest); err != nil {
+			return "", time.Time{}, fmt.Errorf("failed to parse manifest: %w", err)
+		}
+	}
+	if manifest.Config.Digest == "" {
+		return "", time.Time{}, fmt.Errorf("manifest has no config")
+	}
+	body, _, err = ociGet("https://"+registry+"/v2/"+repo+"/blobs/"+manifest.Config.Digest, "*/*")
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	var config struct {
+		Created time.Time `json:"created"`
+	}
+	if err := json.Unmarshal(body, &config); err != nil {
+		return "", time.Time{}, fmt.Errorf("failed to parse image config: %w", err)
+	}
+	digest := header.Get("Docker-Content-Digest")
+	if digest == "" {
+		digest = manifest.Config.Digest
+	}
+	return digest, config.Created, nil
+}
+
+// bearerChallenge picks apart WWW-Authenticate: Bearer realm="...",service="...",scope="..."
+var bearerChallenge = regexp.MustCompile(`(\w+)="([^"]*)"`)
+
+// ociGet fetches a registry url, answering a bearer challenge with a token from the registry's
+// auth server. it returns the body and the response headers, for Docker-Content-Digest and Link
+func ociGet(rawURL, accept string) ([]byte, http.Header, error) {
+	client := newHTTPClient(30 * time.Second)
+	do := func(token string) (*http.Response, error) {
+		req, err := http.NewRequest("GET", rawURL, nil)
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("User-Agent", "go-commit-plotter")
+		req.Header.Set("Accept", accept)
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		} else if user := os.Getenv("REGISTRY_USER"); user != "" {
+			req.SetBasicAuth(user, os.Getenv("REGISTRY_PASSWORD"))
+		}
+		return client.Do(req)
+	}
+	resp, err := do("")
+	if err != nil {
+		return nil, nil, err
+	}
+	if challenge := resp.Header.Get("WWW-Authenticate"); resp.StatusCode == http.StatusUnauthorized && strings.HasPrefix(challenge, "Bearer ") {
+		resp.Body.Close()
+		token, err := ociToken(client, challenge)
+		if err != nil {
+			return nil, nil, err
+		}
+		if resp, err = do(token); err != nil {
+			return nil, nil, err
+		}
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, nil, apiStatusError(registryHost(rawURL), resp)
+	}
+	body, err := io.ReadAll(resp.Body)
+	return body, resp.Header, err
+}
+
+func ociToken(client *http.Client, challenge string) (string, error) {
+	params := make(map[string]string)
+	for _, m := range bearerChallenge.FindAllStringSubmatch(challenge, -1) {
+		params[m[1]] = m[2]
+	}
+	if params["realm"] == "" {
+		return "", fmt.Errorf("bearer challenge has no realm: %s", challenge)
+	}
+	query := url.Values{}
+	for _, key := range []string{"service", "scope"} {
+		if params[key] != "" {
+			query.Set(key, params[key])
+		}
+	}
+	req, err := http.NewRequest("GET", params["realm"]+"?"+query.Encode(), nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("User-Agent", "go-commit-plotter")
+	if user := os.Getenv("REGISTRY_USER"); user != "" {
+		req.SetBasicAuth(user, os.Getenv("REGISTRY_PASSWORD"))
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", apiStatusError(registryHost(params["realm"]), resp)
+	}
+	var token struct {
+		Token       string `json:"token"`
+		AccessToken string `json:"access_token"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&token); err != nil {
+		return "", fmt.Errorf("failed to parse token response: %w", err)
+	}
+	if token.Token != "" {
+		return token.Token, nil
+	}
+	return token.AccessToken, nil
+}
+
+func registryHost(rawURL string) string {
+	if u, err := url.Parse(rawURL); err == nil {
+		return u.Host
+	}
+	return rawURL
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"cmp"
+	"fmt"
+	"log"
+	"net/url"
+	"slices"
+	"strings"
+
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+// --repo: everyone who committed to one repo, no subjects file needed. authors are clustered into
+// people by identity.go; --top-authors then runs the full report on the busiest of them
+
+// below this many commits an author is listed but left off the leaderboard
+const minLeaderboardCommits = 20
+
+func analyzeRepo(rawURL string) {
+	if !strings.HasPrefix(rawURL, "http://") && !strings.HasPrefix(rawURL, "https://") {
+		rawURL = "https://" + rawURL
+	}
+	parsed, err := url.Parse(rawURL)
+	if err != nil {
+		log.Fatalf("Failed to parse URL %s: %v", rawURL, err)
+	}
+	path := strings.TrimSuffix(strings.Trim(parsed.Path, "/"), ".git")
+	if strings.Count(path, "/") != 1 {
+		log.Fatalf("--repo wants a single repo like github.com/owner/name, got %s", rawURL)
+	}
+	cloneURL := fmt.Sprintf("https://%s/%s.git", parsed.Host, path)
+
+	_, commits, _ := getRepo(RepoInfo{CloneURL: cloneURL}, audited("*", cloneURL, func(c *object.Commit) (bool, string) {
+		if !c.Committer.When.After(flags.Since) {
+			return false, ruleTooOld
+		}
+		return true, ruleInWindow
+	}))
+	if len(commits) == 0 {
+		// not fatal, so a failed clone's exit code wins over this one's
+		log.Printf("No commits in %s since %s", cloneURL, flags.Since.Format("2006-01-02"))
+		noteFailure(ErrNoCommits)
+		return
+	}
+
+	authors := splitByAuthor(commits, cloneURL)
+	prepareSubjects(authors)
+	// busiest first
+	slices.SortStableFunc(authors, func(a, b Subject) int {
+		return cmp.Compare(len(b.Commits), len(a.Commits))
+	})
+	printContributors(path, authors)
+
+	if flags.TopAuthors > 0 {
+		output(authors[:min(flags.TopAuthors, len(authors))], flags)
+	}
+}
+
+// splitByAuthor makes a subject per clustered author
+func splitByAuthor(commits []*object.Commit, repoURL string) []Subject {
+	var authors []Subject
+	for _, cluster := range clusterAuthors(commits) {
+		subject := Subject{
+			Name:    clusterName(cluster),
+			Commits: make(map[plumbing.Hash]*object.Commit),
+			Origins: make(map[plumbing.Hash][]string),
+		}
+		for _, c := range cluster {
+			subject.Commits[c.Hash] = c
+			subject.Origins[c.Hash] = []string{repoURL}
+		}
+		authors = append(authors, subject)
+	}
+	return authors
+}
+
+func printContributors(repo string, authors []Subject) {
+	fmt.Printf("\n=== Contributors to %s since %s ===\n", repo, flags.Since.Format("2006-01-02"))
+	fmt.Printf("%-24s %7s  %s\n", "author", "commits", "00    06    12    18")
+	for i := range authors {
+		counts := hourCounts(authors[i].times())
+		fmt.Printf("%-24s %7d  %s\n", truncate(authors[i].Name, 24), len(authors[i].Commits), sparkline(counts))
+	}
+
+	type entry struct {
+		name   string
+		window SleepWindow
+	}
+	var board []entry
+	for i := range authors {
+		if len(authors[i].Commits) < minLeaderboardCommits {
+			continue
+		}
+		if w := estimateSleepWindow(authors[i].times()); w.Found {
+			board = append(board, entry{authors[i].Name, w})
+		}
+	}
+	// most sleep first, more confident estimates break ties
+	slices.SortStableFunc(board, func(a, b entry) int {
+		if c := cmp.Compare(b.window.Hours, a.window.Hours); c != 0 {
+			return c
+		}
+		return cmp.Compare(b.window.Confidence, a.window.Confidence)
+	})
+
+	fmt.Printf("\n=== Sleep Leaderboard (authors with %d+ commits) ===\n", minLeaderboardCommits)
+	if len(board) == 0 {
+		fmt.Printf("nobody has a clear sleep window yet\n")
+		return
+	}
+	for rank, e := range board {
+		fmt.Printf("%2d. %-24s %s\n", rank+1, truncate(e.name, 24), e.window)
+	}
+}
+
+// sparkline draws 24 hourly counts as one row of block characters, scaled to the busiest hour
+func sparkline(counts []int) string {
+	blocks := []rune(" ▁▂▃▄▅▆▇█")
+	var maxi int
+	for _, count := range counts {
+		maxi = max(maxi, count)
+	}
+	var b strings.Builder
+	for _, count := range counts {
+		level := 0
+		if maxi > 0 && count > 0 {
+			level = max(1, count*(len(blocks)-1)/maxi)
+		}
+		b.WriteRune(blocks[level])
+	}
+	return b.String()
+}
+
+func truncate(s string, n int) string {
+	if r := []rune(s); len(r) > n {
+		return string(r[:n-1]) + "…"
+	}
+	return s
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"fmt"
+	"math"
+
+	"gonum.org/v1/plot/plotter"
+	"gonum.org/v1/plot/vg"
+)
+
+// the pooled histogram mixes every commit of the day, so a long afternoon hides when mornings
+// start. the first and last commit of each day are closer proxies for waking and going to bed:
+// --plot-day-bounds draws how many days started and ended in each hour, one line each, and the
+// stats give their median and middle half
+
+// plotDayBounds draws days per hour of their first commit and of their last
+func plotDayBounds(subject *Subject, a Analysis, outputPath string) error {
+	firsts, lasts := dayBounds(subject.times(), float64(a.Stats.QuietestHour*3600))
+	if len(firsts) == 0 {
+		return fmt.Errorf("no commits")
+	}
+
+	p := newPlot(fmt.Sprintf("First and Last Commit of the Day: %s\nfirst %s\nlast %s", subject.Name, a.Stats.FirstOfDayQuartiles, a.Stats.LastOfDayQuartiles), "Time of Day", "Number of Days")
+	p.X.Tick.Marker = hourTicks{}
+	p.X.Min, p.X.Max = 0, secondsPerDay
+	p.Legend.Top, p.Legend.Left = true, true
+	p.Legend.TextStyle.Color = theme.Foreground
+
+	var ymax float64
+	series := make([]plotter.XYs, 2)
+	for i, bounds := range [][]float64{firsts, lasts} {
+		counts := make([]int, 24)
+		for _, s := range bounds {
+			counts[int(s)/3600]++
+		}
+		for hour, count := range counts {
+			// each hour's count sits in the middle of the hour
+			series[i] = append(series[i], plotter.XY{X: float64(hour*3600 + 1800), Y: float64(count)})
+			ymax = math.Max(ymax, float64(count))
+		}
+	}
+	if err := addWindowBand(p, a.Window, 3600, 0, 0, ymax, false); err != nil {
+		return err
+	}
+
+	for i, name := range []string{"first commit", "last commit"} {
+		line, err := plotter.NewLine(series[i])
+		if err != nil {
+			return fmt.Errorf("could not create %s line: %v", name, err)
+		}
+		line.Width = vg.Points(2)
+		line.Color = theme.Data
+		if i == 1 {
+			line.Color = theme.Foreground
+			line.Dashes = []vg.Length{vg.Points(6), vg.Points(3)}
+		}
+		p.Add(line)
+		p.Legend.Add(name, line)
+	}
+
+	width, height := plotSize(10*vg.Inch, 5*vg.Inch)
+	if err := savePlot(p, width, height, outputPath, metaFor(subject)); err != nil {
+		return fmt.Errorf("could not save plot: %v", err)
+	}
+	return nil
+}
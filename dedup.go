@@ -0,0 +1,90 @@
+package main
+
+import (
+	"crypto/sha1"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+// the commit map already dedups identical hashes, but cherry-picks, rebases and rewritten mirrors get new
+// hashes for the same work. git's patch-id hashes the diff text, which needs blobs we never downloaded,
+// so this approximates it with what a rewrite keeps and a blobless clone has: the author, the author date,
+// and which paths changed
+
+// errNoTree is for commits that came without their trees: imported, or listed through an api
+var errNoTree = errors.New("commit has no tree")
+
+func patchID(commit *object.Commit) (string, error) {
+	if commit.TreeHash.IsZero() {
+		return "", errNoTree
+	}
+	tree, err := commit.Tree()
+	if err != nil {
+		return "", err
+	}
+	var parentTree *object.Tree
+	if commit.NumParents() > 0 {
+		parent, err := commit.Parent(0)
+		if err != nil {
+			return "", err
+		}
+		if parentTree, err = parent.Tree(); err != nil {
+			return "", err
+		}
+	}
+	changes, err := object.DiffTree(parentTree, tree)
+	if err != nil {
+		return "", err
+	}
+
+	paths := make([]string, 0, len(changes))
+	for _, change := range changes {
+		paths = append(paths, change.From.Name+"\x00"+change.To.Name)
+	}
+	sort.Strings(paths)
+
+	h := sha1.New()
+	fmt.Fprintf(h, "%s\x00%d\x00", strings.ToLower(commit.Author.Email), commit.Author.When.Unix())
+	for _, p := range paths {
+		fmt.Fprintln(h, p)
+	}
+	return fmt.Sprintf("%x", h.Sum(nil)), nil
+}
+
+// dedupByPatchID drops commits that are rewritten copies of another, folding their origins into the survivor.
+// commits whose trees can't be read are kept as-is
+func dedupByPatchID(subject *Subject) {
+	// visit in hash order so the same survivor is picked every run
+	hashes := make([]plumbing.Hash, 0, len(subject.Commits))
+	for hash := range subject.Commits {
+		hashes = append(hashes, hash)
+	}
+	sort.Slice(hashes, func(i, j int) bool { return hashes[i].String() < hashes[j].String() })
+
+	seen := make(map[string]plumbing.Hash)
+	var dropped int
+	for _, hash := range hashes {
+		id, err := patchID(subject.Commits[hash])
+		if err != nil {
+			continue
+		}
+		survivor, ok := seen[id]
+		if !ok {
+			seen[id] = hash
+			continue
+		}
+		subject.Origins[survivor] = append(subject.Origins[survivor], subject.Origins[hash]...)
+		delete(subject.Commits, hash)
+		delete(subject.Origins, hash)
+		dropped++
+	}
+	if dropped > 0 {
+		log.Printf("Dropped %d rewritten duplicate commits for %s, %d left\n", dropped, subject.Name, len(subject.Commits))
+	}
+}
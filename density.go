@@ -0,0 +1,87 @@
+package main
+
+import (
+	"image/color"
+	"math"
+
+	"gonum.org/v1/plot"
+	"gonum.org/v1/plot/plotter"
+	"gonum.org/v1/plot/vg"
+	"gonum.org/v1/plot/vg/draw"
+)
+
+// busy subjects saturate the scatter plot. two ways out: translucent points, so overlap shows up as
+// brighter color, or --hexbin, which counts points per hexagon and shades each by its count
+
+// scatterAlpha is --scatter-alpha, or a guess that keeps a few hundred overlapping points from going opaque
+func scatterAlpha(n int) float64 {
+	if flags.ScatterAlpha > 0 {
+		return math.Min(flags.ScatterAlpha, 1)
+	}
+	return math.Max(0.1, math.Min(1, 300/float64(max(n, 1))))
+}
+
+func withAlpha(c color.Color, alpha float64) color.NRGBA {
+	n := color.NRGBAModel.Convert(c).(color.NRGBA)
+	n.A = uint8(float64(n.A) * alpha)
+	return n
+}
+
+// hexbin is a plot.Plotter that bins points into hexagons on the canvas, so they're regular whatever the axes
+type hexbin struct {
+	plotter.XYs
+	// center to corner
+	Radius vg.Length
+	Color  color.Color
+}
+
+func (h hexbin) DataRange() (xmin, xmax, ymin, ymax float64) {
+	return plotter.XYRange(h.XYs)
+}
+
+func (h hexbin) Plot(c draw.Canvas, plt *plot.Plot) {
+	trX, trY := plt.Transforms(&c)
+	r := float64(h.Radius)
+
+	type cell struct{ q, r int }
+	counts := make(map[cell]int)
+	var maxCount int
+	for _, pt := range h.XYs {
+		x, y := float64(trX(pt.X)), float64(trY(pt.Y))
+		// pointy-top axial coordinates, rounded via cube coordinates
+		fq := (math.Sqrt(3)/3*x - y/3) / r
+		fr := (2.0 / 3 * y) / r
+		q, rr, s := math.Round(fq), math.Round(fr), math.Round(-fq-fr)
+		dq, dr, ds := math.Abs(q-fq), math.Abs(rr-fr), math.Abs(s+fq+fr)
+		if dq > dr && dq > ds {
+			q = -rr - s
+		} else if dr > ds {
+			rr = -q - s
+		}
+		k := cell{int(q), int(rr)}
+		counts[k]++
+		maxCount = max(maxCount, counts[k])
+	}
+
+	for k, count := range counts {
+		cx := r * math.Sqrt(3) * (float64(k.q) + float64(k.r)/2)
+		cy := r * 1.5 * float64(k.r)
+		var hex []vg.Point
+		for i := range 6 {
+			angle := math.Pi/6 + float64(i)*math.Pi/3
+			hex = append(hex, vg.Point{X: vg.Length(cx + r*math.Cos(angle)), Y: vg.Length(cy + r*math.Sin(angle))})
+		}
+		// log scale, otherwise one hot cell washes out everything else
+		shade := 0.15 + 0.85*math.Log1p(float64(count))/math.Log1p(float64(maxCount))
+		c.FillPolygon(withAlpha(h.Color, shade), c.ClipPolygonXY(hex))
+	}
+}
+
+// plotSize scales a canvas size by --plot-scale
+func plotSize(width, height vg.Length) (vg.Length, vg.Length) {
+	if flags.PlotScale <= 0 {
+		return width, height
+	}
+	scale := vg.Length(flags.PlotScale)
+	return width * scale, height * scale
+}
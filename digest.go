@@ -0,0 +1,238 @@
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"mime/multipart"
+	"net"
+	"net/smtp"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/pelletier/go-toml/v2"
+)
+
+// in --watch mode, send a periodic summary per subject by email (with the histogram attached)
+// and/or to a webhook as JSON. what was last sent is remembered so each digest can say how the
+// sleep window moved since the previous one
+
+// DigestConfig is the [digest] table in sleep.toml
+type DigestConfig struct {
+	// how often to send, as a go duration. defaults to a week
+	Every string `toml:"every"`
+
+	SMTP        string   `toml:"smtp"` // host:port
+	Username    string   `toml:"username"`
+	PasswordEnv string   `toml:"password_env"` // name of the env var holding the smtp password
+	From        string   `toml:"from"`
+	To          []string `toml:"to"`
+
+	Webhook string `toml:"webhook"`
+}
+
+const defaultDigestEvery = 7 * 24 * time.Hour
+const digestStateFile = "digest.toml"
+
+type digestWindow struct {
+	Found bool `toml:"found" json:"found"`
+	Start int  `toml:"start" json:"start"`
+	End   int  `toml:"end" json:"end"`
+	Hours int  `toml:"hours" json:"hours"`
+}
+
+type digestState struct {
+	Sent    time.Time               `toml:"sent"`
+	Windows map[string]digestWindow `toml:"windows"`
+}
+
+func sendDueDigests(subjects []Subject) {
+	cfg := config.Digest
+	if cfg.Webhook == "" && (cfg.SMTP == "" || len(cfg.To) == 0) {
+		return
+	}
+
+	every := defaultDigestEvery
+	if cfg.Every != "" {
+		d, err := time.ParseDuration(cfg.Every)
+		if err != nil {
+			log.Printf("Invalid [digest] every %q, using %s: %v", cfg.Every, every, err)
+		} else {
+			every = d
+		}
+	}
+
+	state := loadDigestState()
+	if time.Since(state.Sent) < every {
+		return
+	}
+
+	next := digestState{Sent: time.Now(), Windows: make(map[string]digestWindow)}
+	for i := range subjects {
+		subject := &subjects[i]
+		if len(subject.Commits) == 0 {
+			continue
+		}
+		window := estimateSleepWindow(subject.times())
+		current := digestWindow{window.Found, window.Start, window.End, window.Hours}
+		previous, hadPrevious := state.Windows[subject.Name]
+		next.Windows[subject.Name] = current
+
+		text := digestText(subject, window, previous, hadPrevious)
+		if cfg.SMTP != "" && len(cfg.To) > 0 {
+			if err := emailDigest(cfg, subject, window, text); err != nil {
+				log.Printf("Failed to email digest for %s: %v", subject.Name, err)
+			}
+		}
+		if cfg.Webhook != "" {
+			var prev *digestWindow
+			if hadPrevious {
+				prev = &previous
+			}
+			if err := postDigest(cfg.Webhook, subject.Name, text, current, prev); err != nil {
+				log.Printf("Failed to post digest for %s: %v", subject.Name, err)
+			}
+		}
+	}
+	saveDigestState(next)
+}
+
+func digestText(subject *Subject, window SleepWindow, previous digestWindow, hadPrevious bool) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Sleep digest for %s, %s\n\n", subject.Name, time.Now().Format(time.DateOnly))
+	fmt.Fprintf(&b, "%s\n", window)
+	switch {
+	case !hadPrevious:
+		fmt.Fprintf(&b, "first digest, nothing to compare against yet\n")
+	case !previous.Found || !window.Found:
+		fmt.Fprintf(&b, "no window in one of the last two digests, can't compare\n")
+	default:
+		onset := (window.Start-previous.Start+36)%24 - 12
+		fmt.Fprintf(&b, "last digest: ~%s (%dh)\n", hourRange(previous.Start, previous.End), previous.Hours)
+		fmt.Fprintf(&b, "onset moved %+dh, duration changed %+dh\n", onset, window.Hours-previous.Hours)
+	}
+	fmt.Fprintf(&b, "\n%d commits since %s\n", len(subject.Commits), flags.Since.Format(time.DateOnly))
+	return b.String()
+}
+
+func emailDigest(cfg DigestConfig, subject *Subject, window SleepWindow, text string) error {
+	dir, err := os.MkdirTemp("", "sleep-digest-*")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+	pngPath := filepath.Join(dir, sanitizeFilename(subject.Name)+"_histogram.png")
+	if err := plotCommitsHistogram(subject, window, pngPath); err != nil {
+		return err
+	}
+	png, err := os.ReadFile(pngPath)
+	if err != nil {
+		return err
+	}
+
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	fmt.Fprintf(&body, "From: %s\r\n", cfg.From)
+	fmt.Fprintf(&body, "To: %s\r\n", strings.Join(cfg.To, ", "))
+	fmt.Fprintf(&body, "Subject: Sleep digest: %s\r\n", subject.Name)
+	fmt.Fprintf(&body, "MIME-Version: 1.0\r\n")
+	fmt.Fprintf(&body, "Content-Type: multipart/mixed; boundary=%s\r\n\r\n", mw.Boundary())
+
+	part, err := mw.CreatePart(textproto.MIMEHeader{"Content-Type": {"text/plain; charset=utf-8"}})
+	if err != nil {
+		return err
+	}
+	fmt.Fprint(part, strings.ReplaceAll(text, "\n", "\r\n"))
+
+	part, err = mw.CreatePart(textproto.MIMEHeader{
+		"Content-Type":              {"image/png"},
+		"Content-Transfer-Encoding": {"base64"},
+		"Content-Disposition":       {fmt.Sprintf("attachment; filename=%q", filepath.Base(pngPath))},
+	})
+	if err != nil {
+		return err
+	}
+	// base64 bodies are wrapped at 76 columns per RFC 2045
+	encoded := base64.StdEncoding.EncodeToString(png)
+	for len(encoded) > 76 {
+		fmt.Fprintf(part, "%s\r\n", encoded[:76])
+		encoded = encoded[76:]
+	}
+	fmt.Fprintf(part, "%s\r\n", encoded)
+	if err := mw.Close(); err != nil {
+		return err
+	}
+
+	var auth smtp.Auth
+	if cfg.Username != "" {
+		host, _, err := net.SplitHostPort(cfg.SMTP)
+		if err != nil {
+			return fmt.Errorf("invalid smtp address %q: %v", cfg.SMTP, err)
+		}
+		auth = smtp.PlainAuth("", cfg.Username, os.Getenv(cfg.PasswordEnv), host)
+	}
+	return smtp.SendMail(cfg.SMTP, auth, cfg.From, cfg.To, body.Bytes())
+}
+
+func postDigest(webhook, name, text string, current digestWindow, previous *digestWindow) error {
+	payload, err := json.Marshal(struct {
+		Subject  string        `json:"subject"`
+		Text     string        `json:"text"`
+		Window   digestWindow  `json:"window"`
+		Previous *digestWindow `json:"previous,omitempty"`
+	}{name, text, current, previous})
+	if err != nil {
+		return err
+	}
+
+	client := newHTTPClient(10 * time.Second)
+	resp, err := client.Post(webhook, "application/json", bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook responded %s", resp.Status)
+	}
+	return nil
+}
+
+func loadDigestState() digestState {
+	var state digestState
+	path := filepath.Join(savePath, digestStateFile)
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return state
+	}
+	if err != nil {
+		log.Printf("Failed to read %s: %v", path, err)
+		return state
+	}
+	if err := toml.Unmarshal(data, &state); err != nil {
+		log.Printf("Failed to parse %s: %v", path, err)
+	}
+	return state
+}
+
+func saveDigestState(state digestState) {
+	path := filepath.Join(savePath, digestStateFile)
+	if err := os.MkdirAll(savePath, 0o755); err != nil {
+		log.Printf("could not make dir %s: %v", savePath, err)
+		return
+	}
+	data, err := toml.Marshal(state)
+	if err != nil {
+		log.Printf("encode %s: %v", path, err)
+		return
+	}
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		log.Printf("could not write file %s: %v", path, err)
+	}
+}
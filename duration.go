@@ -0,0 +1,83 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"gonum.org/v1/plot/plotter"
+	"gonum.org/v1/plot/vg"
+)
+
+// --plot-duration draws how long each inferred night (see nights.go) was, over the whole period,
+// with a rolling average through them and the typical range (see priors.go) shaded behind. a single
+// night means little; weeks of the average sitting under the band is chronic short sleep, and the
+// line climbing back into it is recovery
+
+// nights averaged into each point of the line, by date: the night and the ones before it
+const durationRollingDays = 7
+
+// rollingDurations is the mean duration, in hours, of the nights in the durationRollingDays up to
+// and including each night
+func rollingDurations(nights []Night) plotter.XYs {
+	pts := make(plotter.XYs, 0, len(nights))
+	for i, n := range nights {
+		from := n.Date.AddDate(0, 0, -(durationRollingDays - 1))
+		var sum float64
+		var count int
+		for j := i; j >= 0 && !nights[j].Date.Before(from); j-- {
+			sum += nights[j].Duration().Hours()
+			count++
+		}
+		pts = append(pts, plotter.XY{X: float64(n.Date.Unix()), Y: sum / float64(count)})
+	}
+	return pts
+}
+
+func plotSleepDuration(subject *Subject, nights []Night, outputPath string) error {
+	if len(nights) == 0 {
+		return fmt.Errorf("no nights inferred, which needs commits on both sides of the sleep")
+	}
+	pts := make(plotter.XYs, len(nights))
+	var total time.Duration
+	for i, n := range nights {
+		pts[i] = plotter.XY{X: float64(n.Date.Unix()), Y: n.Duration().Hours()}
+		total += n.Duration()
+	}
+	mean := total / time.Duration(len(nights))
+
+	p := newPlot(fmt.Sprintf("Sleep Duration: %s\n%d nights, mean %s, %d night rolling average", subject.Name, len(nights),
+		formatSpread(mean.Minutes()), durationRollingDays), "Night", "Hours asleep")
+	p.X.Tick.Marker = dateTicks{}
+	p.Y.Min, p.Y.Max = 0, max(priors.MaxHours, priors.TypicalMax)+1
+
+	lo, hi := pts[0].X, pts[len(pts)-1].X
+	band, err := plotter.NewPolygon(plotter.XYs{{X: lo, Y: priors.TypicalMin}, {X: hi, Y: priors.TypicalMin}, {X: hi, Y: priors.TypicalMax}, {X: lo, Y: priors.TypicalMax}})
+	if err != nil {
+		return fmt.Errorf("could not create typical sleep band: %v", err)
+	}
+	band.Color = theme.Window
+	band.LineStyle.Width = 0
+	p.Add(band)
+
+	scatter, err := plotter.NewScatter(pts)
+	if err != nil {
+		return fmt.Errorf("could not create duration plot: %v", err)
+	}
+	scatter.Radius = vg.Points(2)
+	scatter.Color = theme.Data
+	p.Add(scatter)
+
+	line, err := plotter.NewLine(rollingDurations(nights))
+	if err != nil {
+		return fmt.Errorf("could not create rolling average: %v", err)
+	}
+	line.Color = theme.Foreground
+	line.Width = vg.Points(2)
+	p.Add(line)
+
+	width, height := plotSize(10*vg.Inch, 5*vg.Inch)
+	if err := savePlot(p, width, height, outputPath, metaFor(subject)); err != nil {
+		return fmt.Errorf("could not save plot: %v", err)
+	}
+	return nil
+}
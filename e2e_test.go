@@ -0,0 +1,194 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// see fixtures_test.go for the repos and forges these run against
+
+func TestGitHubSubject(t *testing.T) {
+	run := runSleep(t, map[string]string{
+		"subjects.toml": "[ann]\nsources = [\"github.com/ann\"]\n",
+	}, "--since", fixtureSince(), "--format", "tsv", "--report", "md")
+	if run.code != 0 {
+		t.Fatalf("exit code %d, stderr:\n%s", run.code, run.stderr)
+	}
+
+	// ann/thesis was last pushed in 2019
+	if !strings.Contains(run.stderr, "Skipping 1 repos of ann with no pushes since") {
+		t.Errorf("the stale repo wasn't skipped:\n%s", run.stderr)
+	}
+	ann := tsvValues(run.stdout)["ann"]
+	want := map[string]string{
+		// 8 a day for 42 days. dependabot's commits in the same repo don't count
+		"commits":     "336",
+		"active_days": "42",
+		"time_basis":  "each commit's recorded offset",
+		"sleep_found": "true",
+		"sleep_start": "23",
+		"sleep_end":   "9",
+		"estimator":   "threshold",
+	}
+	for key, value := range want {
+		if ann[key] != value {
+			t.Errorf("%s = %q, want %q", key, ann[key], value)
+		}
+	}
+
+	report, err := os.ReadFile(filepath.Join(run.dir, "ann_commits_report.md"))
+	if err != nil {
+		t.Fatalf("no report: %v", err)
+	}
+	for _, line := range []string{"# Sleep schedule: ann", "Sleep window: **23:00-09:00**", "| active days | 42 |"} {
+		if !strings.Contains(string(report), line) {
+			t.Errorf("report is missing %q:\n%s", line, report)
+		}
+	}
+}
+
+func TestGitLabAndGiteaSubject(t *testing.T) {
+	run := runSleep(t, map[string]string{
+		"subjects.toml": "[bob]\nsources = [\"gitlab.com/bob\", \"codeberg.org/bob\"]\ntimezone = \"America/New_York\"\n",
+	}, "--since", fixtureSince(), "--format", "tsv")
+	if run.code != 0 {
+		t.Fatalf("exit code %d, stderr:\n%s", run.code, run.stderr)
+	}
+
+	// bob's days alternate between the forges, and carol's commits to bob/tool don't count
+	for _, found := range []string{
+		"Found 168 commits of bob in repo https://gitlab.com/bob/tool.git",
+		"Found 168 commits of bob in repo https://codeberg.org/bob/notes.git",
+		"Skipping 1 repos of bob with no pushes since",
+	} {
+		if !strings.Contains(run.stderr, found) {
+			t.Errorf("stderr is missing %q:\n%s", found, run.stderr)
+		}
+	}
+	bob := tsvValues(run.stdout)["bob"]
+	want := map[string]string{
+		"commits":     "336",
+		"time_basis":  "America/New_York (configured)",
+		"sleep_found": "true",
+		"sleep_start": "3",
+		"sleep_end":   "13",
+	}
+	for key, value := range want {
+		if bob[key] != value {
+			t.Errorf("%s = %q, want %q", key, bob[key], value)
+		}
+	}
+}
+
+func TestRepoRun(t *testing.T) {
+	run := runSleep(t, nil, "--since", fixtureSince(), "--repo", "github.com/team/app", "--top-authors", "1")
+	if run.code != 0 {
+		t.Fatalf("exit code %d, stderr:\n%s", run.code, run.stderr)
+	}
+
+	commits := make(map[string]string)
+	for _, line := range strings.Split(run.stdout, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) >= 4 && fields[1] == "Example" {
+			commits[fields[0]] = fields[2]
+		}
+	}
+	for name, want := range map[string]string{"Ann": "112", "Bob": "112", "Carol": "84"} {
+		if commits[name] != want {
+			t.Errorf("%s has %q commits in the contributor table, want %s:\n%s", name, commits[name], want, run.stdout)
+		}
+	}
+	if !strings.Contains(run.stdout, "Sleep Leaderboard") {
+		t.Errorf("no leaderboard:\n%s", run.stdout)
+	}
+	// --top-authors 1 reports on one of the two busiest
+	if n := strings.Count(run.stdout, "=== Sleep Schedule Estimate for "); n != 1 {
+		t.Errorf("%d subject reports with --top-authors 1, want 1:\n%s", n, run.stdout)
+	}
+}
+
+func TestUnknownSubject(t *testing.T) {
+	run := runSleep(t, map[string]string{
+		"subjects.toml": "[ann]\nsources = [\"github.com/ann\"]\n",
+	}, "--subject", "nobody")
+	if run.code != 1 {
+		t.Errorf("exit code %d, want 1", run.code)
+	}
+	if !strings.Contains(run.stderr, `No subject named "nobody"`) {
+		t.Errorf("stderr doesn't name the missing subject:\n%s", run.stderr)
+	}
+}
+
+func TestOverlap(t *testing.T) {
+	subjects := map[string]string{
+		"subjects.toml": "[ann]\nsources = [\"github.com/ann\"]\n\n[bob]\nsources = [\"gitlab.com/bob\", \"codeberg.org/bob\"]\n",
+	}
+	run := runSleep(t, subjects, "--since", fixtureSince(), "overlap", "ann", "bob")
+	if run.code != 0 {
+		t.Fatalf("exit code %d, stderr:\n%s", run.code, run.stderr)
+	}
+	if !strings.Contains(run.stdout, "ann") || !strings.Contains(run.stdout, "bob") {
+		t.Errorf("overlap doesn't name both subjects:\n%s", run.stdout)
+	}
+
+	// --user builds its own subject whatever is asked for, so overlap can't find ann or bob in it
+	run = runSleep(t, subjects, "--user", "ann@github.com/ann", "overlap", "ann", "bob")
+	if run.code != 1 || !strings.Contains(run.stderr, "not --user/--local") {
+		t.Errorf("exit code %d with --user, want 1 and a reason:\n%s", run.code, run.stderr)
+	}
+}
+
+func TestSinceBundle(t *testing.T) {
+	repo := filepath.Join(fixtureRoot, "github.com", "ann", "dotfiles.git")
+	bundle := filepath.Join(t.TempDir(), "ann.bundle")
+	// --since leaves out the older history, so the bundle has prerequisites and a thin pack
+	if out, err := exec.Command("git", "-C", repo, "bundle", "create", bundle, "--all", "--since=2024-02-01").CombinedOutput(); err != nil {
+		t.Skipf("no git to bundle with: %v: %s", err, out)
+	}
+	out, err := exec.Command("git", "-C", repo, "rev-list", "--count", "--since=2024-02-01", "--author=ann@example.com", "HEAD").Output()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := strings.TrimSpace(string(out))
+
+	run := runSleep(t, map[string]string{
+		"subjects.toml": fmt.Sprintf("[ann]\nsources = [%q]\nemails = [\"ann@example.com\"]\n", bundle),
+	}, "--since", fixtureSince(), "--format", "tsv")
+	if run.code != 0 {
+		t.Fatalf("exit code %d, stderr:\n%s", run.code, run.stderr)
+	}
+	if !strings.Contains(run.stderr, "prerequisite commits") {
+		t.Errorf("the bundle had no prerequisites, so this tests nothing:\n%s", run.stderr)
+	}
+	if got := tsvValues(run.stdout)["ann"]["commits"]; got != want {
+		t.Errorf("commits = %q, want %s:\n%s", got, want, run.stderr)
+	}
+}
+
+func TestEvalIgnoresMachineZone(t *testing.T) {
+	// ann goes to bed at 23:00 and gets up at 09:00 on her recorded offset, +01:00
+	truth := "subject,date,onset,wake\n"
+	for day := 10; day < 20; day++ {
+		truth += fmt.Sprintf("ann,2024-01-%d,23:00,09:00\n", day)
+	}
+	files := map[string]string{
+		"subjects.toml": "[ann]\nsources = [\"github.com/ann\"]\n",
+		"truth.csv":     truth,
+	}
+	var outputs []string
+	for _, zone := range []string{"UTC", "America/New_York"} {
+		t.Setenv("TZ", zone)
+		run := runSleep(t, files, "--since", fixtureSince(), "--format", "tsv", "eval", "truth.csv")
+		if run.code != 0 {
+			t.Fatalf("exit code %d under TZ=%s, stderr:\n%s", run.code, zone, run.stderr)
+		}
+		outputs = append(outputs, run.stdout)
+	}
+	if outputs[0] != outputs[1] {
+		t.Errorf("eval depends on the machine's zone:\nUTC:\n%s\nAmerica/New_York:\n%s", outputs[0], outputs[1])
+	}
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+
+	"github.com/go-git/go-git/v5/plumbing/transport"
+)
+
+// one bad repo or source doesn't stop a run, it's logged and skipped. but scripts wrapping sleep
+// want to know a run was degraded and why without scraping the log, so each failure is also noted
+// by class, and the process exits with that class's code. 1 stays "fatal, nothing ran"
+
+var (
+	ErrForgeUnknown = errors.New("unknown forge")
+	ErrRateLimited  = errors.New("rate limited")
+	ErrCloneAuth    = errors.New("clone needs authentication")
+	ErrNoCommits    = errors.New("no commits found")
+	ErrBandwidth    = errors.New("over --max-bandwidth")
+)
+
+// in order of precedence: when several classes failed in one run, the first listed picks the code
+var exitCodes = []struct {
+	err  error
+	code int
+}{
+	{ErrRateLimited, 3},
+	{ErrCloneAuth, 4},
+	{ErrForgeUnknown, 5},
+	{ErrNoCommits, 6},
+	{ErrBandwidth, 7},
+}
+
+var (
+	failuresMu sync.Mutex
+	failures   = make(map[error]int)
+	// every failure noted, classed or not, see --incremental
+	failuresTotal int
+)
+
+// noteFailure records err's class, if it has one, for the exit code
+func noteFailure(err error) {
+	failuresMu.Lock()
+	failuresTotal++
+	failuresMu.Unlock()
+	for _, c := range exitCodes {
+		if errors.Is(err, c.err) {
+			failuresMu.Lock()
+			failures[c.err]++
+			failuresMu.Unlock()
+			return
+		}
+	}
+}
+
+// exitCode is 0 for a clean run, otherwise the code of the most important failure class seen
+func exitCode() int {
+	failuresMu.Lock()
+	defer failuresMu.Unlock()
+	for _, c := range exitCodes {
+		if n := failures[c.err]; n > 0 {
+			log.Printf("Exiting with %d: %v (%d times)", c.code, c.err, n)
+			return c.code
+		}
+	}
+	return 0
+}
+
+func failureTotal() int {
+	failuresMu.Lock()
+	defer failuresMu.Unlock()
+	return failuresTotal
+}
+
+func resetFailures() {
+	failuresMu.Lock()
+	defer failuresMu.Unlock()
+	clear(failures)
+	failuresTotal = 0
+}
+
+// apiStatusError is the error for a non-200 forge API response. 429s, and 403s once the quota is
+// spent, are ErrRateLimited
+func apiStatusError(forge string, resp *http.Response) error {
+	if resp.StatusCode == http.StatusTooManyRequests ||
+		(resp.StatusCode == http.StatusForbidden && resp.Header.Get("X-RateLimit-Remaining") == "0") {
+		return fmt.Errorf("%s API request failed: %s: %w", forge, resp.Status, ErrRateLimited)
+	}
+	return fmt.Errorf("%s API request failed: %s", forge, resp.Status)
+}
+
+// cloneError classes go-git's auth failures. forges answer clones of private (or missing) repos
+// with 401, which go-git reports as authentication required
+func cloneError(err error) error {
+	if errors.Is(err, transport.ErrAuthenticationRequired) || errors.Is(err, transport.ErrAuthorizationFailed) {
+		return fmt.Errorf("%w: %w", ErrCloneAuth, err)
+	}
+	return err
+}
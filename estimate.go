@@ -0,0 +1,145 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"time"
+)
+
+// find likely sleep windows: the longest run of low-activity hours, wrapping around midnight.
+// still too many assumptions i think, but it's what the plots and reports hang off of
+
+type SleepWindow struct {
+	Found bool `json:"found"`
+	Start int  `json:"start"` // hour of day
+	End   int  `json:"end"`   // hour of day, exclusive
+	Hours int  `json:"hours"`
+	// commits/hour at or below this counts as "low activity". only the threshold estimator has one
+	Threshold int `json:"threshold"`
+	// which --estimator found it
+	Estimator  string  `json:"estimator"`
+	Commits    int     `json:"commits"`
+	Confidence float64 `json:"confidence"` // 0-1, see windowConfidence
+	// how much the boundaries move under resampling, see uncertainty.go. nil if not computed
+	Uncertainty *WindowUncertainty `json:"uncertainty,omitempty"`
+	// a robust second, shorter trough, see nap.go. nil for monophasic sleep or if not computed
+	Nap *NapWindow `json:"nap,omitempty"`
+}
+
+func estimateSleepWindow(times []time.Time) SleepWindow {
+	return windowFromCounts(hourCounts(times))
+}
+
+// windowFromCounts is the estimate from commits per hour of day, for callers that already binned
+// them, by whichever --estimator is picked
+func windowFromCounts(counts []int) SleepWindow {
+	name := flags.Estimator
+	if name == "" {
+		name = "threshold"
+	}
+	w := estimators[name](counts)
+	w.Estimator = name
+	return w
+}
+
+// thresholdWindow is the longest run of hours at or under a low-activity threshold, the original
+// estimator
+func thresholdWindow(counts []int) SleepWindow {
+	var total int
+	for _, count := range counts {
+		total += count
+	}
+	if total == 0 {
+		return SleepWindow{}
+	}
+
+	// low activity = fewer than 5% of average hourly commits
+	avgPerHour := float64(total) / 24.0
+	threshold := max(int(avgPerHour*0.05), 1)
+	low := make([]bool, 24)
+	for hour, count := range counts {
+		low[hour] = count <= threshold
+	}
+	w := windowOver(counts, low)
+	w.Threshold = threshold
+	return w
+}
+
+// windowConfidence is a rough 0-1 score: how much quieter the window is than the rest of the day,
+// discounted when there's too little data to trust the contrast
+func windowConfidence(counts []int, w SleepWindow) float64 {
+	var inside, outside int
+	for hour, count := range counts {
+		if w.contains(hour) {
+			inside += count
+		} else {
+			outside += count
+		}
+	}
+	if outside == 0 || w.Hours == 24 {
+		return 0
+	}
+	insideRate := float64(inside) / float64(w.Hours)
+	outsideRate := float64(outside) / float64(24-w.Hours)
+	contrast := math.Max(0, 1-insideRate/outsideRate)
+	sample := 1 - math.Exp(-float64(w.Commits)/100)
+	return contrast * sample
+}
+
+func (w SleepWindow) contains(hour int) bool {
+	if !w.Found {
+		return false
+	}
+	return (hour-w.Start+24)%24 < w.Hours
+}
+
+func (w SleepWindow) confidenceLabel() string {
+	switch {
+	case w.Confidence >= 0.7:
+		return "high"
+	case w.Confidence >= 0.4:
+		return "medium"
+	default:
+		return "low"
+	}
+}
+
+// String is the one-line summary used in plot subtitles
+func (w SleepWindow) String() string {
+	if !w.Found {
+		return fmt.Sprintf("no clear sleep window, %d commits", w.Commits)
+	}
+	nap := ""
+	if w.Nap != nil {
+		nap = ", " + w.Nap.String()
+	}
+	return fmt.Sprintf("sleep ~%s%s, %s confidence (%.2f), %d commits",
+		hourRange(w.Start, w.End), nap, w.confidenceLabel(), w.Confidence, w.Commits)
+}
+
+func printSleepEstimate(subject *Subject, w SleepWindow) {
+	fmt.Printf("\n=== Sleep Schedule Estimate for %s ===\n", subject.Name)
+	if !w.Found {
+		fmt.Printf("Unable to identify clear sleep window (no extended low-activity period)\n")
+		fmt.Printf("This may indicate irregular sleep patterns or insufficient data\n\n")
+		return
+	}
+	fmt.Printf("Estimated sleep window: %s\n", paint(ansiBold, hourRange(w.Start, w.End)))
+	if u := w.Uncertainty; u != nil && u.Found > 0 {
+		fmt.Printf("Onset: %s ± %s, wake: %s ± %s (window found in %.0f%% of %d resamples)\n",
+			clockString(u.Onset*60), formatSpread(u.OnsetSpread), clockString(u.Wake*60), formatSpread(u.WakeSpread),
+			u.Found*100, u.Resamples)
+	}
+	fmt.Printf("Duration: ~%d hours\n", w.Hours)
+	if w.Nap != nil {
+		fmt.Printf("Biphasic: second low-activity window %s (~%d hours, found in %.0f%% of resamples)\n",
+			hourRange(w.Nap.Start, w.Nap.End), w.Nap.Hours, w.Nap.Robustness*100)
+	}
+	fmt.Printf("Confidence: %s\n", paint(w.confidenceColor(), fmt.Sprintf("%s (%.2f)", w.confidenceLabel(), w.Confidence)))
+	fmt.Printf("Based on %d commits\n", w.Commits)
+	if w.Estimator == "threshold" {
+		fmt.Printf("Low-activity threshold: ≤%d commits/hour\n\n", w.Threshold)
+	} else {
+		fmt.Printf("Estimator: %s\n\n", w.Estimator)
+	}
+}
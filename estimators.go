@@ -0,0 +1,150 @@
+package main
+
+import (
+	"math"
+	"slices"
+)
+
+// --estimator picks how commits per hour of day become a sleep window. they all fill in the same
+// SleepWindow, scored by the same windowConfidence, so they can be swapped anywhere one is used
+// (reports, weekly trends, bootstrap resamples) and compared with `sleep eval`:
+//
+//	threshold  the longest run of hours under 5% of the average hour, the original
+//	kde        the counts smoothed around the clock, then the trough around the quietest point
+//	cosinor    one 24 hour cosine fitted to the counts; the window is the third of the day around
+//	           its trough, so this one is about phase, not duration
+//	hmm        a two state (awake, asleep) hidden markov model run over the day
+//
+// adding one is writing a func([]int) SleepWindow and listing it here
+
+var estimators = map[string]func(counts []int) SleepWindow{
+	"threshold": thresholdWindow,
+	"kde":       kdeWindow,
+	"cosinor":   cosinorWindow,
+	"hmm":       hmmWindow,
+}
+
+var estimatorNames = []string{"threshold", "kde", "cosinor", "hmm"}
+
+const (
+	// kde's kernel width in hours
+	kdeBandwidth = 1.5
+	// kde's trough is the hours within this share of the way from the lowest density to the highest
+	kdeTroughShare = 0.15
+	// a cosine with amplitude under this share of its mean isn't a daily rhythm
+	minCosinorAmplitude = 0.3
+)
+
+// windowOver makes a window of the hours where low is true, the longest circular run of them
+func windowOver(counts []int, low []bool) SleepWindow {
+	w := SleepWindow{}
+	for _, count := range counts {
+		w.Commits += count
+	}
+	if w.Commits == 0 {
+		return w
+	}
+	var longestStart, longestLen, currentStart, currentLen int
+	// twice around the clock, so a run crossing midnight is counted whole
+	for i := range 48 {
+		hour := i % 24
+		if !low[hour] {
+			currentLen = 0
+			continue
+		}
+		if currentLen == 0 {
+			currentStart = hour
+		}
+		currentLen++
+		if currentLen > longestLen {
+			longestStart, longestLen = currentStart, currentLen
+		}
+	}
+	// every hour was low, the second lap would double count
+	longestLen = min(longestLen, 24)
+	if float64(longestLen) < priors.MinHours {
+		return w
+	}
+	w.Found = true
+	w.Start = longestStart
+	w.End = (longestStart + longestLen) % 24
+	w.Hours = longestLen
+	w.Confidence = windowConfidence(counts, w)
+	return w
+}
+
+// kdeWindow smooths the counts with a gaussian kernel that wraps around midnight, so one busy or
+// quiet hour can't split or invent a night, then grows the window out from the quietest hour
+func kdeWindow(counts []int) SleepWindow {
+	density := make([]float64, 24)
+	for h := range density {
+		for j, count := range counts {
+			d := math.Abs(float64(h - j))
+			d = math.Min(d, 24-d)
+			density[h] += float64(count) * math.Exp(-d*d/(2*kdeBandwidth*kdeBandwidth))
+		}
+	}
+	lowest, highest := slices.Min(density), slices.Max(density)
+	if highest-lowest <= 1e-9*highest {
+		return windowOver(counts, make([]bool, 24))
+	}
+	cut := lowest + kdeTroughShare*(highest-lowest)
+	trough := slices.Index(density, lowest)
+
+	// only the trough around the quietest hour, not every dip under the cut
+	low := make([]bool, 24)
+	for _, step := range []int{1, -1} {
+		for i := 0; i < 24; i++ {
+			h := (trough + step*i + 24) % 24
+			if density[h] > cut {
+				break
+			}
+			low[h] = true
+		}
+	}
+	return windowOver(counts, low)
+}
+
+// cosinorWindow fits counts = mesor + amplitude*cos(2π(t - acrophase)/24) by least squares, which
+// for evenly spaced hours is a pair of sums. the window is where the fit is below mesor minus half
+// the amplitude: 8 hours centered on the trough
+func cosinorWindow(counts []int) SleepWindow {
+	var mesor, a, b float64
+	for h, count := range counts {
+		theta := 2 * math.Pi * (float64(h) + 0.5) / 24
+		mesor += float64(count) / 24
+		a += float64(count) * math.Cos(theta) / 12
+		b += float64(count) * math.Sin(theta) / 12
+	}
+	amplitude := math.Hypot(a, b)
+	if mesor == 0 || amplitude/mesor < minCosinorAmplitude {
+		return windowOver(counts, make([]bool, 24))
+	}
+	acrophase := math.Atan2(b, a)
+	low := make([]bool, 24)
+	for h := range low {
+		theta := 2 * math.Pi * (float64(h) + 0.5) / 24
+		low[h] = math.Cos(theta-acrophase) < -0.5
+	}
+	return windowOver(counts, low)
+}
+
+// hmmWindow labels each hour of the day awake or asleep with a two state hidden markov model:
+// poisson counts in each state, and a state that tends to persist from one hour to the next. the
+// day is run twice over so midnight isn't an edge, and the middle lap is kept
+func hmmWindow(counts []int) SleepWindow {
+	obs := make([]float64, 48)
+	for i := range obs {
+		obs[i] = float64(counts[i%24])
+	}
+	asleep := fitHMM(obs)
+	low := make([]bool, 24)
+	for i := 12; i < 36; i++ {
+		low[i%24] = asleep[i]
+	}
+	// one state for the whole day is no rhythm, not a day of sleep
+	if !slices.Contains(low, false) {
+		low = make([]bool, 24)
+	}
+	return windowOver(counts, low)
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// repo lists rarely change between runs. the last list and its ETag are kept per URL, and the next
+// request sends If-None-Match; a 304 means reuse the list. on github a 304 doesn't count against
+// the rate limit, so re-running over a big subjects file is close to free
+
+const etagDir = "etags"
+
+func etagPaths(url string) (etagPath, bodyPath string) {
+	sum := sha256.Sum256([]byte(url))
+	base := filepath.Join(cacheDir, etagDir, hex.EncodeToString(sum[:12]))
+	return base + ".etag", base + ".body"
+}
+
+// getWithETag does req, revalidating against the last response for the same URL. a 304 comes back
+// as a 200 carrying the cached body, so callers handle both the same way
+func getWithETag(client *http.Client, req *http.Request) (*http.Response, error) {
+	// a recording has to hold every response in full, and a replay can't depend on this machine's cache
+	if flags.Record != "" || flags.Replay != "" {
+		return client.Do(req)
+	}
+	etagPath, bodyPath := etagPaths(req.URL.String())
+	etag, etagErr := os.ReadFile(etagPath)
+	cached, bodyErr := os.ReadFile(bodyPath)
+	if etagErr == nil && bodyErr == nil {
+		req.Header.Set("If-None-Match", strings.TrimSpace(string(etag)))
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case resp.StatusCode == http.StatusNotModified:
+		resp.Body.Close()
+		log.Printf("%s unchanged since last run, reusing it", req.URL.Redacted())
+		resp.StatusCode, resp.Status = http.StatusOK, "200 OK (cached)"
+		resp.Body = io.NopCloser(bytes.NewReader(cached))
+	case resp.StatusCode == http.StatusOK && resp.Header.Get("ETag") != "":
+		body, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		resp.Body = io.NopCloser(bytes.NewReader(body))
+		saveETag(etagPath, bodyPath, resp.Header.Get("ETag"), body)
+	}
+	return resp, nil
+}
+
+func saveETag(etagPath, bodyPath, etag string, body []byte) {
+	dir := filepath.Dir(etagPath)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		log.Printf("could not make dir %s: %v", dir, err)
+		return
+	}
+	// body first, so a half-written pair never has an etag pointing at a stale body
+	if err := os.WriteFile(bodyPath, body, 0o644); err != nil {
+		log.Printf("could not write file %s: %v", bodyPath, err)
+		return
+	}
+	if err := os.WriteFile(etagPath, []byte(etag), 0o644); err != nil {
+		log.Printf("could not write file %s: %v", etagPath, err)
+	}
+}
@@ -0,0 +1,322 @@
+package main
+
+import (
+	"cmp"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"os"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+)
+
+// `sleep eval <truth.csv>` scores the estimator against nights whose sleep is known: diaries,
+// trackers, people who agreed to tell you. the file has a subject column and either date, onset and
+// wake (23:30 and 07:15 on the clock; the date is the evening the night starts) or start and end
+// timestamps. every subject named is collected as usual, and each labeled night since --since is
+// compared with what the estimate says that night was: the night inferred on its own if there is
+// one (see nights.go), or else the window. the errors are in minutes, signed ones positive when the
+// estimate is later or longer, so an estimator change can be judged by numbers rather than by
+// eyeballing plots. labels are read on the report's clock: the subject's timezone if it has one,
+// or else as written, like commits on their recorded offsets
+
+// truthNight is one labeled night
+type truthNight struct {
+	Subject    string
+	Start, End time.Time
+}
+
+// nightScore is how far one night's estimate was from the truth, in minutes. missed is a labeled
+// night the estimator had nothing for
+type nightScore struct {
+	Onset, Wake, Duration float64
+	Missed                bool
+}
+
+func runEval(args []string) {
+	if len(args) != 1 {
+		log.Fatal("usage: sleep eval <truth.csv>")
+	}
+	rows, err := readTruthRows(args[0])
+	if err != nil {
+		log.Fatalf("Failed to read %s: %v", args[0], err)
+	}
+	var names []string
+	for _, row := range rows {
+		if !slices.Contains(names, row["subject"]) {
+			names = append(names, row["subject"])
+		}
+	}
+	subjects := collect(names)
+
+	scores := make(map[string][]nightScore)
+	var skipped, old int
+	for i := range subjects {
+		subject := &subjects[i]
+		// the clock the report reads: the subject's zone, or else each commit's recorded offset.
+		// recorded clock readings are put on utc as read, and the labels with them (a nil loc)
+		loc := subject.Location
+		times := subject.times()
+		if loc == nil {
+			for j := range times {
+				times[j] = clockOnUTC(times[j])
+			}
+		}
+		w := estimateSleepWindow(times)
+		inferred := make(map[string]Night)
+		for _, n := range inferNights(times, w) {
+			inferred[n.Date.Format(time.DateOnly)] = n
+		}
+		for _, row := range rows {
+			if row["subject"] != subject.Name {
+				continue
+			}
+			night, err := parseTruthNight(row, loc)
+			if err != nil {
+				skipped++
+				continue
+			}
+			if !night.Start.After(flags.Since) {
+				old++
+				continue
+			}
+			score := scoreNight(w, night)
+			if n, ok := inferred[nightOf(night.Start).Format(time.DateOnly)]; ok {
+				score = scoreInferred(n, night)
+			}
+			scores[subject.Name] = append(scores[subject.Name], score)
+		}
+	}
+	if skipped > 0 {
+		log.Printf("Skipped %d labeled nights with times we couldn't read", skipped)
+	}
+	if old > 0 {
+		log.Printf("Skipped %d labeled nights from before --since", old)
+	}
+	if len(scores) == 0 {
+		log.Fatal("No labeled nights to score")
+	}
+	printEval(scores)
+}
+
+// readTruthRows is each row of the truth file keyed by its lowercased column names
+func readTruthRows(path string) ([]map[string]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	r := csv.NewReader(f)
+	r.FieldsPerRecord = -1
+	header, err := r.Read()
+	if err != nil {
+		return nil, err
+	}
+	lowerHeader(header)
+	hasAll := func(cols ...string) bool {
+		for _, c := range cols {
+			if !slices.Contains(header, c) {
+				return false
+			}
+		}
+		return true
+	}
+	if !hasAll("subject") || !hasAll("date", "onset", "wake") && !hasAll("start", "end") {
+		return nil, fmt.Errorf("need a subject column and date, onset and wake, or start and end")
+	}
+	var rows []map[string]string
+	for {
+		record, err := r.Read()
+		if err == io.EOF {
+			return rows, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		row := make(map[string]string, len(header))
+		for i, col := range header {
+			if i < len(record) {
+				row[col] = strings.TrimSpace(record[i])
+			}
+		}
+		if row["subject"] != "" {
+			rows = append(rows, row)
+		}
+	}
+}
+
+func parseTruthNight(row map[string]string, loc *time.Location) (truthNight, error) {
+	night := truthNight{Subject: row["subject"]}
+	if row["start"] != "" {
+		var err error
+		if night.Start, err = parseSleepTime(row["start"], loc); err != nil {
+			return night, err
+		}
+		if night.End, err = parseSleepTime(row["end"], loc); err != nil {
+			return night, err
+		}
+	} else {
+		date, err := time.ParseInLocation(time.DateOnly, row["date"], cmp.Or(loc, time.UTC))
+		if err != nil {
+			return night, err
+		}
+		onset, err1 := time.Parse("15:04", row["onset"])
+		wake, err2 := time.Parse("15:04", row["wake"])
+		if err1 != nil || err2 != nil {
+			return night, fmt.Errorf("bad onset or wake in %v", row)
+		}
+		night.Start = onClock(date, onset)
+		night.End = onClock(date, wake)
+	}
+	for !night.End.After(night.Start) {
+		night.End = night.End.AddDate(0, 0, 1)
+	}
+	if night.End.Sub(night.Start) > 24*time.Hour {
+		return night, fmt.Errorf("night longer than a day in %v", row)
+	}
+	return night, nil
+}
+
+// clockOnUTC is t's clock reading, as recorded, on utc
+func clockOnUTC(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), time.UTC)
+}
+
+// onClock is clock on the night starting the evening of date: times before noon are the next morning
+func onClock(date, clock time.Time) time.Time {
+	t := time.Date(date.Year(), date.Month(), date.Day(), clock.Hour(), clock.Minute(), 0, 0, date.Location())
+	if clock.Hour() < 12 {
+		t = t.AddDate(0, 0, 1)
+	}
+	return t
+}
+
+// scoreNight compares the window, placed on the night's evening, with what was labeled
+func scoreNight(w SleepWindow, night truthNight) nightScore {
+	if !w.Found {
+		return nightScore{Missed: true}
+	}
+	evening := night.Start.Add(-12 * time.Hour)
+	date := time.Date(evening.Year(), evening.Month(), evening.Day(), 0, 0, 0, 0, night.Start.Location())
+	start := onClock(date, time.Date(0, 1, 1, w.Start, 0, 0, 0, time.UTC))
+	end := start.Add(time.Duration(w.Hours) * time.Hour)
+	return nightScore{
+		Onset:    start.Sub(night.Start).Minutes(),
+		Wake:     end.Sub(night.End).Minutes(),
+		Duration: end.Sub(start).Minutes() - night.End.Sub(night.Start).Minutes(),
+	}
+}
+
+// scoreInferred compares a night found on its own with what was labeled
+func scoreInferred(n Night, night truthNight) nightScore {
+	return nightScore{
+		Onset:    n.Start.Sub(night.Start).Minutes(),
+		Wake:     n.End.Sub(night.End).Minutes(),
+		Duration: n.Duration().Minutes() - night.End.Sub(night.Start).Minutes(),
+	}
+}
+
+// evalSummary is the errors over a set of nights, in minutes
+type evalSummary struct {
+	Nights, Missed                    int
+	OnsetMAE, WakeMAE, DurationMAE    float64
+	OnsetBias, WakeBias, DurationBias float64
+	OnsetWithinHour                   float64 // share of scored nights
+}
+
+func summarize(scores []nightScore) evalSummary {
+	s := evalSummary{Nights: len(scores)}
+	var n float64
+	for _, sc := range scores {
+		if sc.Missed {
+			s.Missed++
+			continue
+		}
+		n++
+		s.OnsetMAE += math.Abs(sc.Onset)
+		s.WakeMAE += math.Abs(sc.Wake)
+		s.DurationMAE += math.Abs(sc.Duration)
+		s.OnsetBias += sc.Onset
+		s.WakeBias += sc.Wake
+		s.DurationBias += sc.Duration
+		if math.Abs(sc.Onset) <= 60 {
+			s.OnsetWithinHour++
+		}
+	}
+	if n == 0 {
+		return s
+	}
+	for _, v := range []*float64{&s.OnsetMAE, &s.WakeMAE, &s.DurationMAE, &s.OnsetBias, &s.WakeBias, &s.DurationBias, &s.OnsetWithinHour} {
+		*v /= n
+	}
+	return s
+}
+
+func printEval(scores map[string][]nightScore) {
+	var names []string
+	var all []nightScore
+	for name, s := range scores {
+		names = append(names, name)
+		all = append(all, s...)
+	}
+	sort.Strings(names)
+
+	if flags.Format == "tsv" {
+		for _, name := range append(names, "overall") {
+			s := summarize(all)
+			if name != "overall" {
+				s = summarize(scores[name])
+			}
+			printEvalTSV(strings.NewReplacer("\t", " ", "\n", " ").Replace(name), s)
+		}
+		return
+	}
+
+	fmt.Printf("\n=== Evaluation of --estimator %s: %d subjects, %d labeled nights ===\n", flags.Estimator, len(names), len(all))
+	fmt.Printf("%-20s %6s %6s  %-15s %-15s %-15s %s\n", "subject", "nights", "missed", "onset", "wake", "duration", "onset ±1h")
+	row := func(name string, s evalSummary) {
+		if s.Missed == s.Nights {
+			fmt.Printf("%-20s %6d %6d  no estimate\n", name, s.Nights, s.Missed)
+			return
+		}
+		fmt.Printf("%-20s %6d %6d  %-15s %-15s %-15s %.0f%%\n", name, s.Nights, s.Missed,
+			evalError(s.OnsetMAE, s.OnsetBias), evalError(s.WakeMAE, s.WakeBias), evalError(s.DurationMAE, s.DurationBias),
+			100*s.OnsetWithinHour)
+	}
+	for _, name := range names {
+		row(name, summarize(scores[name]))
+	}
+	if len(names) > 1 {
+		row("overall", summarize(all))
+	}
+	fmt.Println("errors are mean absolute error (mean signed error); positive means later or longer than labeled")
+}
+
+// evalError is "45m (+20m)"
+func evalError(mae, bias float64) string {
+	sign := "+"
+	if bias < 0 {
+		sign = "-"
+	}
+	return fmt.Sprintf("%s (%s%s)", formatSpread(mae), sign, formatSpread(math.Abs(bias)))
+}
+
+func printEvalTSV(name string, s evalSummary) {
+	kv := func(key string, value any) {
+		fmt.Printf("%s\t%s=%v\n", name, key, value)
+	}
+	kv("estimator", flags.Estimator)
+	kv("nights", s.Nights)
+	kv("missed", s.Missed)
+	kv("onset_mae_minutes", fmt.Sprintf("%.0f", s.OnsetMAE))
+	kv("onset_bias_minutes", fmt.Sprintf("%.0f", s.OnsetBias))
+	kv("wake_mae_minutes", fmt.Sprintf("%.0f", s.WakeMAE))
+	kv("wake_bias_minutes", fmt.Sprintf("%.0f", s.WakeBias))
+	kv("duration_mae_minutes", fmt.Sprintf("%.0f", s.DurationMAE))
+	kv("duration_bias_minutes", fmt.Sprintf("%.0f", s.DurationBias))
+	kv("onset_within_hour", fmt.Sprintf("%.2f", s.OnsetWithinHour))
+}
@@ -0,0 +1,217 @@
+package main
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+// collecting is the slow, networked part; analysis only needs timestamps. `sleep export` writes the
+// collected events as gzipped json, `sleep import` reads them back on any machine and runs the
+// usual report on them, no forges involved:
+//
+//	sleep export --subject alice > alice.events.json.gz
+//	sleep import alice.events.json.gz --plot-heatmap
+
+const eventsVersion = 1
+
+type eventsFile struct {
+	Version  int             `json:"version"`
+	Exported time.Time       `json:"exported"`
+	Since    time.Time       `json:"since"`
+	Subjects []subjectEvents `json:"subjects"`
+}
+
+type subjectEvents struct {
+	Name        string   `json:"name"`
+	Timezone    string   `json:"timezone,omitempty"`
+	Orgs        []string `json:"orgs,omitempty"`
+	Members     []string `json:"members,omitempty"`
+	SampledFrom int      `json:"sampled_from,omitempty"`
+	Events      []event  `json:"events"`
+}
+
+// event is one commit, reduced to what the analyses read. times keep the offset they were made with
+type event struct {
+	Hash      string    `json:"hash"`
+	Author    string    `json:"author"`
+	Email     string    `json:"email"`
+	Authored  time.Time `json:"authored"`
+	Committed time.Time `json:"committed"`
+	Origins   []string  `json:"origins,omitempty"`
+	Weight    int       `json:"weight,omitempty"` // omitted means 1
+}
+
+// runExport collects --subject (or everyone) and writes their events to stdout
+func runExport(args []string) {
+	if len(args) > 0 {
+		log.Fatal("usage: sleep export [--subject name] [--research] > file.events.json.gz")
+	}
+	if flags.Research {
+		runResearchExport()
+		return
+	}
+	subjects := collect(flags.Subjects)
+
+	f := eventsFile{Version: eventsVersion, Exported: time.Now().UTC(), Since: flags.Since}
+	for i := range subjects {
+		f.Subjects = append(f.Subjects, toEvents(&subjects[i]))
+	}
+
+	data, err := json.Marshal(f)
+	if err != nil {
+		log.Fatalf("Failed to write events: %v", err)
+	}
+	gz := gzip.NewWriter(os.Stdout)
+	if signingKey != nil {
+		gz.Comment = signature(data)
+	}
+	if _, err := gz.Write(data); err != nil {
+		log.Fatalf("Failed to write events: %v", err)
+	}
+	if err := gz.Close(); err != nil {
+		log.Fatalf("Failed to write events: %v", err)
+	}
+	log.Printf("Exported %d subjects", len(f.Subjects))
+}
+
+// runImport reads events files ("-" for stdin) and reports on them like a normal run
+func runImport(paths []string) {
+	if len(paths) == 0 {
+		log.Fatal("usage: sleep import <file.events.json.gz>...")
+	}
+	var subjects []Subject
+	for _, path := range paths {
+		imported, err := readEvents(path)
+		if err != nil {
+			log.Fatalf("Failed to import %s: %v", path, err)
+		}
+		subjects = append(subjects, imported...)
+	}
+	output(expandDerived(subjects), flags)
+	if flags.Availability || flags.PlotAvailability {
+		reportAvailability(subjects)
+	}
+}
+
+func toEvents(subject *Subject) subjectEvents {
+	out := subjectEvents{
+		Name:        subject.Name,
+		Orgs:        subject.Orgs,
+		Members:     subject.Members,
+		SampledFrom: subject.SampledFrom,
+		Events:      make([]event, 0, len(subject.Commits)),
+	}
+	if subject.Location != nil {
+		out.Timezone = subject.Location.String()
+	}
+	for hash, c := range subject.Commits {
+		e := event{
+			Hash:      hash.String(),
+			Author:    c.Author.Name,
+			Email:     c.Author.Email,
+			Authored:  c.Author.When,
+			Committed: c.Committer.When,
+			Origins:   subject.Origins[hash],
+		}
+		if w, ok := subject.Weights[hash]; ok && w != 1 {
+			e.Weight = w
+		}
+		out.Events = append(out.Events, e)
+	}
+	// stable output, so exports of the same data diff cleanly
+	sort.Slice(out.Events, func(i, j int) bool {
+		return out.Events[i].Authored.Before(out.Events[j].Authored)
+	})
+	return out
+}
+
+func readEvents(path string) ([]Subject, error) {
+	var r io.Reader = os.Stdin
+	if path != "-" {
+		file, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		defer file.Close()
+		r = file
+	}
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, err
+	}
+	defer gz.Close()
+
+	data, err := io.ReadAll(gz)
+	if err != nil {
+		return nil, err
+	}
+	if err := verifySignature(data, gz.Comment, path); err != nil {
+		return nil, err
+	}
+	var f eventsFile
+	if err := json.Unmarshal(data, &f); err != nil {
+		return nil, err
+	}
+	if f.Version != eventsVersion {
+		return nil, fmt.Errorf("events version %d, this build reads %d", f.Version, eventsVersion)
+	}
+	log.Printf("Importing %d subjects exported %s (since %s)", len(f.Subjects), f.Exported.Format(time.DateOnly), f.Since.Format(time.DateOnly))
+
+	var subjects []Subject
+	for _, s := range f.Subjects {
+		subject, err := fromEvents(s)
+		if err != nil {
+			return nil, fmt.Errorf("subject %s: %w", s.Name, err)
+		}
+		subjects = append(subjects, subject)
+	}
+	return subjects, nil
+}
+
+// fromEvents rebuilds a subject. the commits carry only hash, author and dates, which is all the
+// report reads
+func fromEvents(s subjectEvents) (Subject, error) {
+	subject := Subject{
+		Name:        s.Name,
+		Commits:     make(map[plumbing.Hash]*object.Commit, len(s.Events)),
+		Origins:     make(map[plumbing.Hash][]string, len(s.Events)),
+		Orgs:        s.Orgs,
+		Members:     s.Members,
+		SampledFrom: s.SampledFrom,
+	}
+	if s.Timezone != "" {
+		loc, err := time.LoadLocation(s.Timezone)
+		if err != nil {
+			return Subject{}, err
+		}
+		subject.Location = loc
+	}
+	for _, e := range s.Events {
+		if !plumbing.IsHash(e.Hash) {
+			return Subject{}, fmt.Errorf("bad hash %q", e.Hash)
+		}
+		hash := plumbing.NewHash(e.Hash)
+		subject.Commits[hash] = &object.Commit{
+			Hash:      hash,
+			Author:    object.Signature{Name: e.Author, Email: e.Email, When: e.Authored},
+			Committer: object.Signature{Name: e.Author, Email: e.Email, When: e.Committed},
+		}
+		subject.Origins[hash] = e.Origins
+		if e.Weight > 0 {
+			if subject.Weights == nil {
+				subject.Weights = make(map[plumbing.Hash]int)
+			}
+			subject.Weights[hash] = e.Weight
+		}
+	}
+	return subject, nil
+}
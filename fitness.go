@@ -0,0 +1,239 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"os"
+	"slices"
+	"strings"
+	"time"
+)
+
+// `sleep fitness <sleep.csv> <subject>` checks the estimate against sleep you actually recorded: a
+// fitbit or oura sleep export, or any csv with start and end columns. for analyzing yourself, to
+// see how far the commits can be trusted before reading anyone else's. the nights are put on the
+// subject's clock (its timezone, or this machine's), and nights shorter than minRecordedSleep are
+// naps and left out
+
+// minRecordedSleep is the shortest record that counts as a night
+const minRecordedSleep = 3 * time.Hour
+
+// sleepColumns are the start and end columns of each export we know, lowercased
+var sleepColumns = []struct {
+	kind, start, end string
+}{
+	{"oura", "bedtime_start", "bedtime_end"},
+	{"fitbit", "start time", "end time"},
+	{"csv", "start", "end"},
+}
+
+// sleepTimeLayouts are tried in order. fitbit's have no zone, so they're on the subject's clock
+var sleepTimeLayouts = []string{time.RFC3339Nano, "2006-01-02T15:04:05.000", "2006-01-02 3:04PM", "2006-01-02 15:04:05", "2006-01-02 15:04"}
+
+type sleepRecord struct {
+	Start, End time.Time
+}
+
+func runFitness(args []string) {
+	if len(args) != 2 {
+		log.Fatal("usage: sleep fitness <sleep.csv> <subject>")
+	}
+	subjects := collect(args[1:])
+	if len(subjects) == 0 || len(subjects[0].Commits) == 0 {
+		log.Fatalf("%s has no commits to compare", args[1])
+	}
+	subject := &subjects[0]
+	loc := time.Local
+	if subject.Location != nil {
+		loc = subject.Location
+	}
+	records, kind, err := readSleepRecords(args[0], loc)
+	if err != nil {
+		log.Fatalf("Failed to read %s: %v", args[0], err)
+	}
+	if len(records) == 0 {
+		log.Fatalf("No nights in %s since %s", args[0], flags.Since.Format(time.DateOnly))
+	}
+	printFitness(subject, records, kind, loc)
+}
+
+// readSleepRecords is the nights in a sleep export since --since, in loc
+func readSleepRecords(path string, loc *time.Location) ([]sleepRecord, string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, "", err
+	}
+	defer f.Close()
+	r := csv.NewReader(f)
+	r.FieldsPerRecord = -1
+	header, err := r.Read()
+	if err != nil {
+		return nil, "", err
+	}
+	lowerHeader(header)
+	kind, start, end := "", -1, -1
+	for _, c := range sleepColumns {
+		if start, end = slices.Index(header, c.start), slices.Index(header, c.end); start >= 0 && end >= 0 {
+			kind = c.kind
+			break
+		}
+	}
+	if kind == "" {
+		return nil, "", fmt.Errorf("no start and end columns (bedtime_start, Start Time or start)")
+	}
+
+	var records []sleepRecord
+	var skipped int
+	for {
+		row, err := r.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, "", err
+		}
+		if max(start, end) >= len(row) {
+			continue
+		}
+		from, err1 := parseSleepTime(row[start], loc)
+		to, err2 := parseSleepTime(row[end], loc)
+		if err1 != nil || err2 != nil {
+			skipped++
+			continue
+		}
+		if !from.After(flags.Since) || to.Sub(from) < minRecordedSleep || to.Sub(from) > 24*time.Hour {
+			continue
+		}
+		records = append(records, sleepRecord{from, to})
+	}
+	if skipped > 0 {
+		log.Printf("Skipped %d rows of %s with times we couldn't read", skipped, path)
+	}
+	return records, kind, nil
+}
+
+// lowerHeader lowercases a csv header for matching, dropping the byte order mark excel likes to add
+func lowerHeader(header []string) {
+	for i := range header {
+		header[i] = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(header[i], "\ufeff")))
+	}
+}
+
+// parseSleepTime reads s on loc. a nil loc takes s as written instead: its clock reading, under
+// whatever offset it has, put on utc
+func parseSleepTime(s string, loc *time.Location) (time.Time, error) {
+	s = strings.TrimSpace(s)
+	for _, layout := range sleepTimeLayouts {
+		if loc == nil {
+			if t, err := time.Parse(layout, s); err == nil {
+				return clockOnUTC(t), nil
+			}
+		} else if t, err := time.ParseInLocation(layout, s, loc); err == nil {
+			return t.In(loc), nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("unknown time format %q", s)
+}
+
+func printFitness(subject *Subject, records []sleepRecord, kind string, loc *time.Location) {
+	times := subject.times()
+	for i := range times {
+		times[i] = times[i].In(loc)
+	}
+	w := estimateSleepWindow(times)
+
+	var onsets, wakes []float64
+	asleep := make([]float64, 24) // minutes asleep at each hour of the day, over all nights
+	for _, r := range records {
+		onsets = append(onsets, float64(secondsOfDay(r.Start)))
+		wakes = append(wakes, float64(secondsOfDay(r.End)))
+		for t := r.Start; t.Before(r.End); t = t.Add(time.Minute) {
+			asleep[t.Hour()]++
+		}
+	}
+	onset, _ := circularMean(onsets)
+	wake, _ := circularMean(wakes)
+	share := make([]float64, 24) // of nights asleep at each hour
+	for h := range asleep {
+		share[h] = asleep[h] / float64(len(records)*60)
+	}
+
+	fmt.Printf("\n=== Fitness check: %s (%s, %d nights, %s) ===\n", subject.Name, kind, len(records), loc)
+	fmt.Printf("recorded sleep:    %s - %s (mean onset and wake)\n", clockString(onset), clockString(wake))
+	if !w.Found {
+		fmt.Println("estimated sleep:   none found in the commits")
+	} else {
+		fmt.Printf("estimated sleep:   %s (%s confidence)\n", hourRange(w.Start, w.End), w.confidenceLabel())
+		fmt.Printf("onset error:       %s\n", clockError(float64(w.Start*3600), onset))
+		fmt.Printf("wake error:        %s\n", clockError(float64(w.End*3600), wake))
+		var agree int
+		for h := range share {
+			if (share[h] >= 0.5) == w.contains(h) {
+				agree++
+			}
+		}
+		fmt.Printf("hours agreeing:    %d of 24 (asleep on most nights vs inside the window)\n", agree)
+	}
+	counts := hourCounts(times)
+	perHour := make([]float64, 24)
+	for h, n := range counts {
+		perHour[h] = float64(n)
+	}
+	if r, ok := pearson(perHour, share); ok {
+		fmt.Printf("correlation:       %.2f between commits per hour and nights asleep at that hour (-1 is a perfect match)\n", r)
+	}
+
+	// commits made while the tracker said you were asleep are its errors or the zone's
+	var during int
+	for _, t := range times {
+		for _, r := range records {
+			if !t.Before(r.Start) && t.Before(r.End) {
+				during++
+				break
+			}
+		}
+	}
+	fmt.Printf("commits in sleep:  %d of %d made while recorded asleep", during, len(times))
+	if during > len(times)/20 {
+		fmt.Print(", check the subject's timezone")
+	}
+	fmt.Println()
+}
+
+// clockError is how far the estimate is from what was recorded, e.g. "40m later", wrapping around
+// midnight
+func clockError(estimated, recorded float64) string {
+	diff := math.Mod(estimated-recorded+1.5*secondsPerDay, secondsPerDay) - 0.5*secondsPerDay
+	minutes := math.Abs(diff) / 60
+	switch {
+	case minutes < 2.5:
+		return "none"
+	case diff > 0:
+		return formatSpread(minutes) + " later than recorded"
+	default:
+		return formatSpread(minutes) + " earlier than recorded"
+	}
+}
+
+// pearson is the correlation of xs and ys, ok false if either doesn't vary
+func pearson(xs, ys []float64) (float64, bool) {
+	n := float64(len(xs))
+	var mx, my float64
+	for i := range xs {
+		mx += xs[i] / n
+		my += ys[i] / n
+	}
+	var sxy, sxx, syy float64
+	for i := range xs {
+		sxy += (xs[i] - mx) * (ys[i] - my)
+		sxx += (xs[i] - mx) * (xs[i] - mx)
+		syy += (ys[i] - my) * (ys[i] - my)
+	}
+	if sxx == 0 || syy == 0 {
+		return 0, false
+	}
+	return sxy / math.Sqrt(sxx*syy), true
+}
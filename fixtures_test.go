@@ -0,0 +1,289 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/http/cgi"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+// the end-to-end tests run sleep itself, main() and all, against a small fixed internet: forge
+// api responses recorded under testdata/forge, and git repos TestMain builds from the authors
+// below. the test binary re-runs itself as sleep with fixtureRoot set, so flag defaults, log.Fatal
+// and exit codes behave as they do for real, and every request goes through httpTransport
+
+const (
+	// set in the environment of a re-run test binary: where the fixture repos are
+	fixtureRootEnv = "SLEEP_FIXTURE_ROOT"
+	// and where the recorded api responses are
+	fixtureForgeEnv = "SLEEP_FIXTURE_FORGE"
+)
+
+// fixtureRoot holds the fixture repos, one dir per host/owner/name.git as they're cloned
+var fixtureRoot string
+
+// fixtureStart is the first day of fixture commits, a monday
+var fixtureStart = time.Date(2024, 1, 8, 0, 0, 0, 0, time.UTC)
+
+// fixtureSince is --since for a run that sees every fixture commit but not the stale repos,
+// which were last pushed in 2019
+func fixtureSince() string {
+	return fmt.Sprint(int(time.Since(time.Date(2023, 6, 1, 0, 0, 0, 0, time.UTC)).Hours() / 24))
+}
+
+type fixtureAuthor struct {
+	name, email string
+	offset      int   // hours east of UTC their commits are recorded with
+	hours       []int // local hours they commit in, once each on the days they commit
+}
+
+var (
+	annAuthor = fixtureAuthor{"Ann Example", "ann@example.com", 1, []int{9, 10, 12, 14, 16, 18, 20, 22}}
+	// a night owl
+	bobAuthor   = fixtureAuthor{"Bob Example", "bob@example.com", -5, []int{13, 15, 17, 19, 21, 23, 0, 2}}
+	carolAuthor = fixtureAuthor{"Carol Example", "carol@example.com", 0, []int{8, 10, 11, 13, 15, 17}}
+	botAuthor   = fixtureAuthor{"dependabot[bot]", "49699333+dependabot[bot]@users.noreply.github.com", 0, []int{3}}
+)
+
+// fixtureRepos are the repos TestMain builds: who commits to each, and on which of the 42 days
+var fixtureRepos = []struct {
+	path    string
+	authors []fixtureAuthor
+	days    func(day int) bool
+}{
+	{"github.com/ann/dotfiles.git", []fixtureAuthor{annAuthor, botAuthor}, func(int) bool { return true }},
+	// bob's days are split between two forges
+	{"gitlab.com/bob/tool.git", []fixtureAuthor{bobAuthor, carolAuthor}, func(day int) bool { return day%2 == 0 }},
+	{"codeberg.org/bob/notes.git", []fixtureAuthor{bobAuthor}, func(day int) bool { return day%2 == 1 }},
+	{"github.com/team/app.git", []fixtureAuthor{annAuthor, bobAuthor, carolAuthor}, func(day int) bool { return day%3 == 0 }},
+}
+
+const fixtureDays = 42
+
+func TestMain(m *testing.M) {
+	if root := os.Getenv(fixtureRootEnv); root != "" {
+		runFixtureSleep(root, os.Getenv(fixtureForgeEnv))
+		return
+	}
+
+	var err error
+	if fixtureRoot, err = os.MkdirTemp("", "sleep-fixtures-"); err != nil {
+		log.Fatal(err)
+	}
+	for _, r := range fixtureRepos {
+		if err := buildFixtureRepo(filepath.Join(fixtureRoot, filepath.FromSlash(r.path)), r.authors, r.days); err != nil {
+			log.Fatalf("could not build fixture %s: %v", r.path, err)
+		}
+	}
+	code := m.Run()
+	os.RemoveAll(fixtureRoot)
+	os.Exit(code)
+}
+
+// buildFixtureRepo commits each author's hours on each of the days, in time order. every commit
+// appends a line to a file, so no two have the same patch and --dedup keeps them all
+func buildFixtureRepo(dir string, authors []fixtureAuthor, days func(int) bool) error {
+	type event struct {
+		when   time.Time
+		author fixtureAuthor
+	}
+	var events []event
+	for day := range fixtureDays {
+		if !days(day) {
+			continue
+		}
+		for _, a := range authors {
+			zone := time.FixedZone("", a.offset*3600)
+			date := fixtureStart.AddDate(0, 0, day)
+			for i, hour := range a.hours {
+				// minutes vary, or --automation would call every author a cron job
+				minute := (day*17 + i*23) % 60
+				when := time.Date(date.Year(), date.Month(), date.Day(), hour, minute, 0, 0, zone)
+				events = append(events, event{when, a})
+			}
+		}
+	}
+	slices.SortStableFunc(events, func(a, b event) int { return a.when.Compare(b.when) })
+
+	repo, err := git.PlainInit(dir, false)
+	if err != nil {
+		return err
+	}
+	tree, err := repo.Worktree()
+	if err != nil {
+		return err
+	}
+	var lines bytes.Buffer
+	for i, e := range events {
+		fmt.Fprintf(&lines, "%d %s %s\n", i, e.author.name, e.when.Format(time.RFC3339))
+		if err := os.WriteFile(filepath.Join(dir, "log.txt"), lines.Bytes(), 0o644); err != nil {
+			return err
+		}
+		if _, err := tree.Add("log.txt"); err != nil {
+			return err
+		}
+		sig := &object.Signature{Name: e.author.name, Email: e.author.email, When: e.when}
+		if _, err := tree.Commit(fmt.Sprintf("change %d", i), &git.CommitOptions{Author: sig, Committer: sig}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// runFixtureSleep is the re-run test binary: sleep's main() with fixtureTransport underneath
+func runFixtureSleep(root, forge string) {
+	backend, err := gitHTTPBackend()
+	if err != nil {
+		log.Fatal(err)
+	}
+	server := httptest.NewServer(&cgi.Handler{
+		Path: backend,
+		Env: []string{
+			"GIT_PROJECT_ROOT=" + root,
+			"GIT_HTTP_EXPORT_ALL=1",
+			// clones ask for blob:none, like github allows
+			"GIT_CONFIG_COUNT=1", "GIT_CONFIG_KEY_0=uploadpack.allowFilter", "GIT_CONFIG_VALUE_0=true",
+		},
+	})
+	defer server.Close()
+
+	httpTransport = &fixtureTransport{forge: forge, git: server.URL}
+	main()
+}
+
+func gitHTTPBackend() (string, error) {
+	out, err := exec.Command("git", "--exec-path").Output()
+	if err != nil {
+		return "", fmt.Errorf("no git to serve the fixtures with: %v", err)
+	}
+	return filepath.Join(strings.TrimSpace(string(out)), "git-http-backend"), nil
+}
+
+// fixtureTransport answers api calls from the recordings under forge, <host>/<path>.json with the
+// query ignored, and sends git's smart http requests to the fixture repos' server. anything else
+// gets a 404 and a line on stderr the tests look for
+type fixtureTransport struct {
+	forge string
+	git   string // base url of git-http-backend
+}
+
+func (f *fixtureTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if strings.HasSuffix(req.URL.Path, "/info/refs") || strings.HasSuffix(req.URL.Path, "/git-upload-pack") {
+		out := req.Clone(req.Context())
+		target := f.git + "/" + req.URL.Host + req.URL.Path
+		if req.URL.RawQuery != "" {
+			target += "?" + req.URL.RawQuery
+		}
+		var err error
+		if out.URL, err = out.URL.Parse(target); err != nil {
+			return nil, err
+		}
+		out.Host = ""
+		resp, err := http.DefaultTransport.RoundTrip(out)
+		if err == nil {
+			// go-git follows where a response came from, as if redirected
+			resp.Request = req
+		}
+		return resp, err
+	}
+
+	status := http.StatusOK
+	body, err := os.ReadFile(filepath.Join(f.forge, req.URL.Host, filepath.FromSlash(req.URL.Path)+".json"))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "fixture: no recording for %s %s\n", req.Method, req.URL)
+		status, body = http.StatusNotFound, []byte(`{"message": "Not Found"}`)
+	}
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", status, http.StatusText(status)),
+		StatusCode:    status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        http.Header{"Content-Type": {"application/json"}},
+		Body:          io.NopCloser(bytes.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       req,
+	}, nil
+}
+
+// sleepRun is what one run of sleep against the fixtures printed
+type sleepRun struct {
+	dir            string // working dir, where outputs and cache/ end up
+	stdout, stderr string
+	code           int
+}
+
+// runSleep runs sleep with args in a fresh working dir holding files (name to contents), e.g. a
+// subjects.toml
+func runSleep(t *testing.T, files map[string]string, args ...string) sleepRun {
+	t.Helper()
+	if _, err := gitHTTPBackend(); err != nil {
+		t.Skip(err)
+	}
+	forge, err := filepath.Abs(filepath.Join("testdata", "forge"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	run := sleepRun{dir: t.TempDir()}
+	for name, contents := range files {
+		if err := os.WriteFile(filepath.Join(run.dir, name), []byte(contents), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	cmd := exec.Command(os.Args[0], args...)
+	cmd.Dir = run.dir
+	for _, kv := range os.Environ() {
+		// tokens would send github through graphql, which isn't recorded
+		if !strings.HasPrefix(kv, "GITHUB_TOKEN=") && !strings.HasPrefix(kv, "GITLAB_TOKEN=") && !strings.HasPrefix(kv, "GITEA_TOKEN=") {
+			cmd.Env = append(cmd.Env, kv)
+		}
+	}
+	cmd.Env = append(cmd.Env, fixtureRootEnv+"="+fixtureRoot, fixtureForgeEnv+"="+forge, "NO_COLOR=1")
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	err = cmd.Run()
+	run.stdout, run.stderr = stdout.String(), stderr.String()
+	if exit, ok := err.(*exec.ExitError); ok {
+		run.code = exit.ExitCode()
+	} else if err != nil {
+		t.Fatalf("could not run sleep %v: %v", args, err)
+	}
+	if strings.Contains(run.stderr, "fixture: no recording") {
+		t.Errorf("sleep %v made requests with no recorded response:\n%s", args, run.stderr)
+	}
+	return run
+}
+
+// tsvValues is the key=value lines of --format tsv output, per subject
+func tsvValues(stdout string) map[string]map[string]string {
+	values := make(map[string]map[string]string)
+	for _, line := range strings.Split(stdout, "\n") {
+		subject, rest, ok := strings.Cut(line, "\t")
+		if !ok {
+			continue
+		}
+		key, value, ok := strings.Cut(rest, "=")
+		if !ok {
+			continue
+		}
+		if values[subject] == nil {
+			values[subject] = make(map[string]string)
+		}
+		values[subject][key] = value
+	}
+	return values
+}
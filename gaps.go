@@ -0,0 +1,182 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"slices"
+	"sort"
+	"time"
+
+	"gonum.org/v1/plot/plotter"
+	"gonum.org/v1/plot/vg"
+)
+
+// how long between one commit and the next. on a log scale the gaps usually fall into two humps:
+// minutes apart within a working session, and most of a day apart between them. the dip between
+// the humps is where a session really ends, which is what sessionGap (risk.go) guesses at.
+// burstiness sums up the cadence: -1 is clockwork, 0 is commits at random, towards 1 is bursts of
+// commits with long silences between
+
+const (
+	// bins are powers of two minutes, the first holds everything under a minute
+	gapBins = 16
+	// the dip is only looked for in this range, outside it the humps are just tailing off
+	minSessionBreak = 15 * time.Minute
+	maxSessionBreak = 24 * time.Hour
+)
+
+type GapStats struct {
+	Gaps int `json:"gaps"`
+	// Counts[0] is gaps under a minute, Counts[i] gaps of 2^(i-1) to 2^i minutes, the last bin
+	// everything longer
+	Counts []int `json:"counts"`
+	// seconds
+	Median     float64 `json:"median"`
+	Burstiness float64 `json:"burstiness"`
+	// share of gaps short enough that --risk counts both commits in one session
+	WithinSession float64 `json:"within_session"`
+	// seconds, the emptiest bin between the two humps. 0 if there weren't enough gaps or no dip
+	SessionBreak float64 `json:"session_break"`
+}
+
+// gapBinUpper is the longest gap bin i holds
+func gapBinUpper(i int) time.Duration {
+	return time.Duration(1<<i) * time.Minute
+}
+
+func gapBin(gap time.Duration) int {
+	if gap < time.Minute {
+		return 0
+	}
+	return min(int(math.Log2(gap.Minutes()))+1, gapBins-1)
+}
+
+func (g GapStats) cadence() string {
+	switch {
+	case g.Gaps == 0:
+		return "unknown"
+	case g.Burstiness >= 0.3:
+		return "bursty"
+	case g.Burstiness <= -0.1:
+		return "regular"
+	default:
+		return "steady"
+	}
+}
+
+// commitGaps is the time between each pair of consecutive commits. weights are ignored, a
+// weighted commit is still one event
+func commitGaps(subject *Subject) []time.Duration {
+	times := make([]time.Time, 0, len(subject.Commits))
+	for _, c := range subject.Commits {
+		times = append(times, c.Author.When)
+	}
+	sort.Slice(times, func(i, j int) bool { return times[i].Before(times[j]) })
+	var gaps []time.Duration
+	for i := 1; i < len(times); i++ {
+		gaps = append(gaps, times[i].Sub(times[i-1]))
+	}
+	return gaps
+}
+
+func gapStats(gaps []time.Duration) GapStats {
+	g := GapStats{Gaps: len(gaps), Counts: make([]int, gapBins)}
+	if len(gaps) == 0 {
+		return g
+	}
+
+	sorted := make([]float64, len(gaps))
+	var sum float64
+	var within int
+	for i, gap := range gaps {
+		g.Counts[gapBin(gap)]++
+		sorted[i] = gap.Seconds()
+		sum += sorted[i]
+		if gap <= sessionGap {
+			within++
+		}
+	}
+	sort.Float64s(sorted)
+	g.Median = sorted[len(sorted)/2]
+	g.WithinSession = float64(within) / float64(len(gaps))
+
+	mean := sum / float64(len(sorted))
+	var variance float64
+	for _, s := range sorted {
+		variance += (s - mean) * (s - mean)
+	}
+	stddev := math.Sqrt(variance / float64(len(sorted)))
+	if mean+stddev > 0 {
+		g.Burstiness = (stddev - mean) / (stddev + mean)
+	}
+
+	// the dip: the emptiest bin in range, earliest on ties, and only if there's a hump either side
+	// of it. a histogram that just tails off has no dip
+	best := -1
+	for i := gapBin(minSessionBreak); gapBinUpper(i) <= maxSessionBreak; i++ {
+		if best < 0 || g.Counts[i] < g.Counts[best] {
+			best = i
+		}
+	}
+	if len(gaps) >= 2*gapBins && slices.Max(g.Counts[:best]) > g.Counts[best] && slices.Max(g.Counts[best+1:]) > g.Counts[best] {
+		g.SessionBreak = gapBinUpper(best).Seconds()
+	}
+	return g
+}
+
+// gapLabel names bin i by its upper bound
+func gapLabel(i int) string {
+	switch {
+	case i == 0:
+		return "<1m"
+	case i == gapBins-1:
+		return ">" + formatGap(gapBinUpper(i-1))
+	default:
+		return formatGap(gapBinUpper(i))
+	}
+}
+
+func formatGap(d time.Duration) string {
+	switch {
+	case d < time.Hour:
+		return fmt.Sprintf("%.0fm", d.Minutes())
+	case d < 48*time.Hour:
+		return fmt.Sprintf("%.0fh", d.Hours())
+	default:
+		return fmt.Sprintf("%.0fd", d.Hours()/24)
+	}
+}
+
+// plotCommitGaps draws the gap histogram, one bar per bin, so the x axis is log scale
+func plotCommitGaps(subject *Subject, g GapStats, outputPath string) error {
+	values := make(plotter.Values, len(g.Counts))
+	for i, count := range g.Counts {
+		values[i] = float64(count)
+	}
+
+	subtitle := fmt.Sprintf("%d gaps, median %s, %s (burstiness %.2f)", g.Gaps, formatGap(time.Duration(g.Median)*time.Second), g.cadence(), g.Burstiness)
+	if g.SessionBreak > 0 {
+		subtitle += fmt.Sprintf(", sessions break around %s", formatGap(time.Duration(g.SessionBreak)*time.Second))
+	}
+	p := newPlot(fmt.Sprintf("Time Between Commits: %s\n%s", subject.Name, subtitle), "Gap (up to)", "Number of Gaps")
+
+	bars, err := plotter.NewBarChart(values, vg.Points(20))
+	if err != nil {
+		return fmt.Errorf("could not create bar chart: %v", err)
+	}
+	bars.Color = theme.Data
+	bars.LineStyle.Color = theme.Data
+	p.Add(bars)
+
+	labels := make([]string, len(g.Counts))
+	for i := range labels {
+		labels[i] = gapLabel(i)
+	}
+	p.NominalX(labels...)
+
+	width, height := plotSize(10*vg.Inch, 6*vg.Inch)
+	if err := savePlot(p, width, height, outputPath, metaFor(subject)); err != nil {
+		return fmt.Errorf("could not save plot: %v", err)
+	}
+	return nil
+}
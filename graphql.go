@@ -0,0 +1,128 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"time"
+)
+
+// one GraphQL query per github user instead of a REST call per thing we want to know. besides the
+// repos it brings back what lets us skip repos before cloning (push date) and match commits
+// better (the public profile email)
+
+const githubGraphQLURL = "https://api.github.com/graphql"
+
+const githubAccountQuery = `query($login: String!) {
+  user(login: $login) {
+    email
+    organizations(first: 100) { nodes { login } }
+    repositories(first: 100, privacy: PUBLIC, ownerAffiliations: OWNER, orderBy: {field: PUSHED_AT, direction: DESC}) {
+      nodes {
+        url
+        pushedAt
+        diskUsage
+        isFork
+        isArchived
+        defaultBranchRef { name }
+      }
+    }
+  }
+}`
+
+func fetchGitHubGraphQL(username string, flags Flags) (Account, error) {
+	payload, err := json.Marshal(map[string]any{
+		"query":     githubAccountQuery,
+		"variables": map[string]string{"login": username},
+	})
+	if err != nil {
+		return Account{}, err
+	}
+
+	req, err := http.NewRequest("POST", githubGraphQLURL, bytes.NewReader(payload))
+	if err != nil {
+		return Account{}, err
+	}
+	req.Header.Set("User-Agent", "go-commit-plotter")
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "bearer "+os.Getenv("GITHUB_TOKEN"))
+
+	client := newHTTPClient(30 * time.Second)
+	resp, err := client.Do(req)
+	if err != nil {
+		return Account{}, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return Account{}, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return Account{}, apiStatusError("GitHub GraphQL", resp)
+	}
+
+	var result struct {
+		Data struct {
+			User *struct {
+				Email         string `json:"email"`
+				Organizations struct {
+					Nodes []struct {
+						Login string `json:"login"`
+					} `json:"nodes"`
+				} `json:"organizations"`
+				Repositories struct {
+					Nodes []struct {
+						URL              string    `json:"url"`
+						PushedAt         time.Time `json:"pushedAt"`
+						DiskUsage        int64     `json:"diskUsage"` // KB
+						IsFork           bool      `json:"isFork"`
+						IsArchived       bool      `json:"isArchived"`
+						DefaultBranchRef *struct {
+							Name string `json:"name"`
+						} `json:"defaultBranchRef"`
+					} `json:"nodes"`
+				} `json:"repositories"`
+			} `json:"user"`
+		} `json:"data"`
+		Errors []struct {
+			Message string `json:"message"`
+		} `json:"errors"`
+	}
+	if err := json.Unmarshal(body, &result); err != nil {
+		return Account{}, fmt.Errorf("failed to parse JSON response: %v", err)
+	}
+	if len(result.Errors) > 0 {
+		return Account{}, fmt.Errorf("GitHub GraphQL error: %s", result.Errors[0].Message)
+	}
+	user := result.Data.User
+	if user == nil {
+		// orgs aren't users; REST handles them
+		return Account{}, fmt.Errorf("no user %s", username)
+	}
+
+	var account Account
+	if user.Email != "" {
+		account.Emails = []string{user.Email}
+	}
+	for _, org := range user.Organizations.Nodes {
+		account.Orgs = append(account.Orgs, org.Login)
+	}
+
+	for _, repo := range user.Repositories.Nodes {
+		if stale(repo.PushedAt, flags) {
+			account.Skipped = append(account.Skipped, staleRepo(repo.URL+".git", repo.PushedAt, repo.IsArchived, flags.Since))
+			continue
+		}
+		info := RepoInfo{CloneURL: repo.URL + ".git", Size: repo.DiskUsage * 1024, Fork: repo.IsFork}
+		if repo.DefaultBranchRef != nil {
+			info.DefaultBranch = repo.DefaultBranchRef.Name
+		}
+		account.Repos = append(account.Repos, info)
+	}
+	logStale(len(account.Skipped), username, flags)
+	return account, nil
+}
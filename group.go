@@ -0,0 +1,52 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"slices"
+
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+// a group is a subject made of other subjects, e.g. a whole team. it gets the full report on the union
+// of its members' commits, plus a per-member breakdown so one night owl doesn't hide in the average.
+// members keep their own zones in their own reports; the group uses its own timezone if configured,
+// otherwise each commit's recorded offset
+
+func buildGroup(name string, members []string, subjects []Subject) Subject {
+	group := Subject{
+		Name:    name,
+		Commits: make(map[plumbing.Hash]*object.Commit),
+		Origins: make(map[plumbing.Hash][]string),
+		Members: members,
+	}
+	for _, subject := range subjects {
+		if !slices.Contains(members, subject.Name) {
+			continue
+		}
+		group.Sources = append(group.Sources, subject.Sources...)
+		for hash, commit := range subject.Commits {
+			group.Commits[hash] = commit
+			group.Origins[hash] = append(group.Origins[hash], subject.Origins[hash]...)
+		}
+	}
+	log.Printf("Total unique commits for group %s: %d\n", name, len(group.Commits))
+	return group
+}
+
+func printGroupBreakdown(group *Subject, subjects []Subject) {
+	fmt.Printf("members of %s:\n", group.Name)
+	for _, member := range group.Members {
+		idx := slices.IndexFunc(subjects, func(s Subject) bool { return s.Name == member })
+		if idx < 0 {
+			continue
+		}
+		subject := &subjects[idx]
+		var share float64
+		if len(group.Commits) > 0 {
+			share = float64(len(subject.Commits)) / float64(len(group.Commits)) * 100
+		}
+		fmt.Printf("  %-20s %3.0f%%  %s\n", member, share, estimateSleepWindow(subject.times()))
+	}
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+// browser history as a source, for analyzing yourself: firefox's places.sqlite, chrome's (or any
+// chromium's) History, or safari's History.db, listed like any other file. a day you didn't commit
+// on is still a day you were up. visits come in bursts (redirects, tabs restored at once), so they
+// count as one event per historyBucket with any visit in it. read through the sqlite3 cli like the
+// sqlite sink, from a copy, since a running browser keeps the file locked. the timestamps are
+// absolute, so they're put in this machine's zone unless the subject has a timezone
+
+const historyBucket = 5 * time.Minute
+
+// ruleHistory is the audit rule for browser visits
+const ruleHistory = "browser visit"
+
+// historyBrowsers are each browser's visits table and how its timestamps count, all as an sql
+// expression in unix seconds
+var historyBrowsers = []struct {
+	name, table, seconds string
+}{
+	{"firefox", "moz_historyvisits", "visit_date / 1000000"},                // microseconds since 1970
+	{"chrome", "visits", "visit_time / 1000000 - 11644473600"},              // microseconds since 1601
+	{"safari", "history_visits", "CAST(visit_time AS INTEGER) + 978307200"}, // seconds since 2001
+}
+
+// isSQLite is whether path starts with sqlite's magic header
+func isSQLite(path string) bool {
+	f, err := os.Open(path)
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+	header := make([]byte, 16)
+	if _, err := io.ReadFull(f, header); err != nil {
+		return false
+	}
+	return string(header) == "SQLite format 3\x00"
+}
+
+func getHistorySource(path, subjectName string, since time.Time) (*Source, map[string][]*object.Commit) {
+	log.Printf("Processing source: %s (browser history)\n", path)
+	// a copy, because browsers hold the database locked while they run. recent visits may still be
+	// in the -wal file next to it
+	dir, err := os.MkdirTemp("", "sleep-history-")
+	if err != nil {
+		log.Printf("  Failed to copy %s: %v", path, err)
+		return nil, nil
+	}
+	defer os.RemoveAll(dir)
+	dbCopy := filepath.Join(dir, "history.sqlite")
+	for _, suffix := range []string{"", "-wal"} {
+		if err := copyFile(path+suffix, dbCopy+suffix); err != nil && suffix == "" {
+			log.Printf("  Failed to copy %s: %v", path, err)
+			return nil, nil
+		}
+	}
+
+	tables, err := sqliteQuery(dbCopy, "SELECT name FROM sqlite_master WHERE type = 'table';")
+	if err != nil {
+		log.Printf("  Failed to read %s: %v", path, err)
+		return nil, nil
+	}
+	var query, browser string
+	for _, b := range historyBrowsers {
+		for _, t := range tables {
+			if t == b.table {
+				browser = b.name
+				query = fmt.Sprintf("SELECT DISTINCT (%s) / %d FROM %s WHERE %s > %d;",
+					b.seconds, int(historyBucket.Seconds()), b.table, b.seconds, since.Unix())
+			}
+		}
+	}
+	if query == "" {
+		log.Printf("  %s isn't a firefox, chrome or safari history", path)
+		return nil, nil
+	}
+	buckets, err := sqliteQuery(dbCopy, query)
+	if err != nil {
+		log.Printf("  Failed to read %s: %v", path, err)
+		return nil, nil
+	}
+
+	match := audited(subjectName, path, func(c *object.Commit) (bool, string) {
+		if !c.Committer.When.After(since) {
+			return false, ruleTooOld
+		}
+		return true, ruleHistory
+	})
+	var events []*object.Commit
+	for _, b := range buckets {
+		bucket, err := strconv.ParseInt(b, 10, 64)
+		if err != nil {
+			continue
+		}
+		when := time.Unix(bucket*int64(historyBucket.Seconds()), 0).In(time.Local)
+		if c := activityEvent(path, b, "", "", when); match(c) {
+			events = append(events, c)
+		}
+	}
+	log.Printf("  Found %d active %s periods in %s history\n", len(events), historyBucket, browser)
+	return &Source{url: path}, map[string][]*object.Commit{path: events}
+}
+
+// sqliteQuery runs query read-only through the sqlite3 cli, returning the first column of each row
+func sqliteQuery(path, query string) ([]string, error) {
+	cmd := exec.Command("sqlite3", "-readonly", "-batch", path, query)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("sqlite3: %v: %s", err, bytes.TrimSpace(stderr.Bytes()))
+	}
+	return strings.Fields(string(out)), nil
+}
+
+func copyFile(from, to string) error {
+	src, err := os.Open(from)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	dst, err := os.Create(to)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(dst, src); err != nil {
+		dst.Close()
+		return err
+	}
+	return dst.Close()
+}
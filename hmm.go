@@ -0,0 +1,121 @@
+package main
+
+import (
+	"math"
+	"slices"
+)
+
+// a two state hidden markov model: each hour is awake or asleep, the counts seen in an hour are
+// poisson with a rate for each state, and the state carries over to the next hour with hmmStay
+// probability. the rates are learned from the counts themselves (hard em: label with viterbi,
+// re-estimate the rates from the labels, repeat), so nothing about how much the subject commits
+// is assumed, only that asleep is quieter than awake
+
+const (
+	// chance the state carries over from one hour to the next. 0.9 expects runs of about 10 hours
+	hmmStay = 0.9
+	// rates are kept above this, since an hour with no commits would otherwise be impossible awake
+	minHMMRate    = 0.05
+	hmmIterations = 20
+)
+
+// fitHMM is the most likely asleep/awake labeling of obs, one count per hour
+func fitHMM(obs []float64) []bool {
+	if len(obs) == 0 {
+		return nil
+	}
+	// start from the quiet quarter and the busy half
+	sorted := slices.Clone(obs)
+	slices.Sort(sorted)
+	rates := [2]float64{mean(sorted[:max(len(sorted)/4, 1)]), mean(sorted[len(sorted)/2:])}
+
+	var asleep []bool
+	for range hmmIterations {
+		rates[0] = max(rates[0], minHMMRate)
+		rates[1] = max(rates[1], rates[0]*2, minHMMRate*2)
+		next := viterbi(obs, rates)
+		if slices.Equal(next, asleep) {
+			break
+		}
+		asleep = next
+		var sums, ns [2]float64
+		for i, x := range obs {
+			s := 1
+			if asleep[i] {
+				s = 0
+			}
+			sums[s] += x
+			ns[s]++
+		}
+		for s := range rates {
+			if ns[s] > 0 {
+				rates[s] = sums[s] / ns[s]
+			}
+		}
+	}
+	return asleep
+}
+
+// viterbi is the most likely state sequence given the rates, state 0 asleep and 1 awake
+func viterbi(obs []float64, rates [2]float64) []bool {
+	stay, move := math.Log(hmmStay), math.Log(1-hmmStay)
+	return viterbiPath(len(obs), [2]float64{math.Log(0.5), math.Log(0.5)},
+		func(i, s int) float64 { return poissonLog(obs[i], rates[s]) },
+		func(i, from, to int) float64 {
+			if from == to {
+				return stay
+			}
+			return move
+		})
+}
+
+// viterbiPath is the general form: n steps, the log probability of starting in each state, of step
+// i's observation in state s, and of moving between states into step i. states are 0 asleep, 1 awake
+func viterbiPath(n int, start [2]float64, emit func(i, s int) float64, trans func(i, from, to int) float64) []bool {
+	if n == 0 {
+		return nil
+	}
+	score := [2]float64{start[0] + emit(0, 0), start[1] + emit(0, 1)}
+	from := make([][2]int, n)
+	for i := 1; i < n; i++ {
+		var next [2]float64
+		for s := range 2 {
+			keep, switched := score[s]+trans(i, s, s), score[1-s]+trans(i, 1-s, s)
+			if keep >= switched {
+				next[s], from[i][s] = keep, s
+			} else {
+				next[s], from[i][s] = switched, 1-s
+			}
+			next[s] += emit(i, s)
+		}
+		score = next
+	}
+	asleep := make([]bool, n)
+	s := 0
+	if score[1] > score[0] {
+		s = 1
+	}
+	for i := n - 1; i >= 0; i-- {
+		asleep[i] = s == 0
+		s = from[i][s]
+	}
+	return asleep
+}
+
+// poissonLog is the log probability of seeing x with rate lambda. x may be fractional (weighted or
+// averaged counts), hence lgamma
+func poissonLog(x, lambda float64) float64 {
+	lg, _ := math.Lgamma(x + 1)
+	return x*math.Log(lambda) - lambda - lg
+}
+
+func mean(xs []float64) float64 {
+	if len(xs) == 0 {
+		return 0
+	}
+	var sum float64
+	for _, x := range xs {
+		sum += x
+	}
+	return sum / float64(len(xs))
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// --mode auto picks per repo: repos big enough that cloning them is the slow part are listed through
+// the api, the rest are cloned, which keeps branches, name matching and mailmaps where they're
+// cheap. big means over --api-above-mb as the forge reports it, or over --api-above-commits on the
+// default branch, which costs a request per repo to count. like api mode, only github repos can go
+// through the api
+
+// repoMode decides how one repo is fetched under mode, and says why for the log
+func repoMode(info RepoInfo, mode string) (string, string) {
+	if mode != "auto" {
+		return mode, ""
+	}
+	repoPath, ok := githubRepoPath(info.CloneURL)
+	if !ok {
+		return "clone", "not on github"
+	}
+
+	size := info.Size
+	if size == 0 {
+		// single-repo sources skip the listing that reports sizes
+		var err error
+		if size, err = githubRepoSize(repoPath); err != nil {
+			log.Printf("  Couldn't get the size of %s, cloning: %v", info.CloneURL, err)
+			return "clone", "size unknown"
+		}
+	}
+	if limit := int64(flags.APIAboveMB) << 20; limit > 0 && size > limit {
+		return "api", fmt.Sprintf("%s > %d MB", formatBytes(size), flags.APIAboveMB)
+	}
+	if limit := flags.APIAboveCommits; limit > 0 {
+		count, err := githubCommitCount(repoPath)
+		if err != nil {
+			log.Printf("  Couldn't count the commits of %s: %v", info.CloneURL, err)
+		} else if count > limit {
+			return "api", fmt.Sprintf("%d commits > %d", count, limit)
+		}
+	}
+	return "clone", formatBytes(size)
+}
+
+// githubRepoPath is owner/repo for a github clone url
+func githubRepoPath(cloneURL string) (string, bool) {
+	repoPath := strings.TrimSuffix(strings.TrimPrefix(cloneURL, "https://github.com/"), ".git")
+	if repoPath == cloneURL || strings.Count(repoPath, "/") != 1 {
+		return "", false
+	}
+	return repoPath, true
+}
+
+func githubGet(apiURL string) (*http.Response, []byte, error) {
+	req, err := http.NewRequest("GET", apiURL, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	req.Header.Set("User-Agent", "go-commit-plotter")
+	req.Header.Set("Accept", "application/vnd.github.v3+json")
+	if token := os.Getenv("GITHUB_TOKEN"); token != "" {
+		req.Header.Set("Authorization", "token "+token)
+	}
+	resp, err := getWithETag(newHTTPClient(30*time.Second), req)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return resp, body, apiStatusError("GitHub", resp)
+	}
+	return resp, body, nil
+}
+
+func githubRepoSize(repoPath string) (int64, error) {
+	_, body, err := githubGet("https://api.github.com/repos/" + repoPath)
+	if err != nil {
+		return 0, err
+	}
+	var repo struct {
+		Size int64 `json:"size"` // KB
+	}
+	if err := json.Unmarshal(body, &repo); err != nil {
+		return 0, fmt.Errorf("failed to parse JSON response: %w", err)
+	}
+	return repo.Size * 1024, nil
+}
+
+var lastPage = regexp.MustCompile(`[?&]page=(\d+)[^>]*>;\s*rel="last"`)
+
+// githubCommitCount counts the default branch's commits: listed one per page, the last page's
+// number is the count
+func githubCommitCount(repoPath string) (int, error) {
+	resp, body, err := githubGet("https://api.github.com/repos/" + repoPath + "/commits?per_page=1")
+	if err != nil {
+		return 0, err
+	}
+	if m := lastPage.FindStringSubmatch(resp.Header.Get("Link")); m != nil {
+		return strconv.Atoi(m[1])
+	}
+	// no last page: everything fit on the first
+	var commits []json.RawMessage
+	if err := json.Unmarshal(body, &commits); err != nil {
+		return 0, fmt.Errorf("failed to parse JSON response: %w", err)
+	}
+	return len(commits), nil
+}
+
+// modeCounts tallies how a source's repos were fetched, for its summary line
+type modeCounts map[string]int
+
+func (m modeCounts) String() string {
+	var parts []string
+	if n := m["clone"]; n > 0 {
+		parts = append(parts, fmt.Sprintf("%d cloned", n))
+	}
+	if n := m["api"]; n > 0 {
+		parts = append(parts, fmt.Sprintf("%d listed through the api", n))
+	}
+	return strings.Join(parts, ", ")
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"strings"
+	"unicode"
+
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+// people commit under several name/email pairs (work laptop, noreply address, a typo'd name once).
+// clusterAuthors joins any pairs that share an email or a normalized name, transitively
+
+// names that say nothing about who made the commit, never used to join identities
+var genericNames = map[string]bool{
+	"root": true, "admin": true, "user": true, "unknown": true, "ubuntu": true,
+	"github": true, "githubactions": true, "dependabot": true, "dependabotbot": true,
+}
+
+// normalizeName folds "Jane Doe", "jane.doe" and "JaneDoe" together
+func normalizeName(name string) string {
+	var b strings.Builder
+	for _, r := range strings.ToLower(name) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// identityKeys are the handles one signature can be joined on
+func identityKeys(sig object.Signature) []string {
+	email := strings.ToLower(sig.Email)
+	keys := []string{"email:" + email}
+	if name := normalizeName(sig.Name); len(name) >= 3 && !genericNames[name] {
+		keys = append(keys, "name:"+name)
+	}
+	// 12345+someone@users.noreply.github.com is someone
+	if local, ok := strings.CutSuffix(email, "@users.noreply.github.com"); ok {
+		if _, user, ok := strings.Cut(local, "+"); ok {
+			local = user
+		}
+		if name := normalizeName(local); len(name) >= 3 && !genericNames[name] {
+			keys = append(keys, "name:"+name)
+		}
+	}
+	return keys
+}
+
+// clusterAuthors groups commits by person. each cluster is named after the name its author used most
+func clusterAuthors(commits []*object.Commit) [][]*object.Commit {
+	// union-find over identity keys
+	parent := make(map[string]string)
+	var find func(string) string
+	find = func(k string) string {
+		if p, ok := parent[k]; ok && p != k {
+			parent[k] = find(p)
+			return parent[k]
+		}
+		parent[k] = k
+		return k
+	}
+	for _, c := range commits {
+		keys := identityKeys(c.Author)
+		root := find(keys[0])
+		for _, k := range keys[1:] {
+			parent[find(k)] = root
+		}
+	}
+
+	index := make(map[string]int)
+	var clusters [][]*object.Commit
+	for _, c := range commits {
+		root := find(identityKeys(c.Author)[0])
+		i, ok := index[root]
+		if !ok {
+			i = len(clusters)
+			index[root] = i
+			clusters = append(clusters, nil)
+		}
+		clusters[i] = append(clusters[i], c)
+	}
+	return clusters
+}
+
+// clusterName picks the author name used on the most commits
+func clusterName(commits []*object.Commit) string {
+	counts := make(map[string]int)
+	var best string
+	for _, c := range commits {
+		counts[c.Author.Name]++
+		if counts[c.Author.Name] > counts[best] {
+			best = c.Author.Name
+		}
+	}
+	return best
+}
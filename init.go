@@ -0,0 +1,238 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/term"
+)
+
+// sleep init writes a starting subjects.toml (or --subjects-file) and sleep.toml, each option
+// explained in a comment, and makes the snapshots directory. in a terminal it asks for subjects one
+// by one, checking each source like `sleep lint` does; otherwise --user name@source1,source2 gives the
+// one subject, or the file gets a commented out example. existing files are left alone
+
+func runInit(args []string) {
+	if len(args) > 0 {
+		log.Fatal("usage: sleep init [--user name@source1,source2] [--subjects-file path]")
+	}
+	if format, err := subjectsFormat(flags.SubjectsFile, flags.SubjectsFormat); err != nil || format != "toml" {
+		log.Fatalf("sleep init writes toml, not %s", flags.SubjectsFile)
+	}
+	var subjects []initSubject
+	switch {
+	case flags.User != "":
+		name, sources, ok := strings.Cut(flags.User, "@")
+		if !ok || name == "" || sources == "" {
+			log.Fatalf("Invalid format, expected: name@url1,url2")
+		}
+		subjects = []initSubject{{name: name, sources: splitList(sources)}}
+	case term.IsTerminal(int(os.Stdin.Fd())) && !isLocalPath(flags.SubjectsFile):
+		subjects = promptSubjects(bufio.NewScanner(os.Stdin))
+	}
+
+	writeNew(flags.SubjectsFile, subjectsTemplate(subjects))
+	writeNew(configFile, sleepTemplate)
+	if err := os.MkdirAll(savePath, 0o755); err != nil {
+		log.Printf("Failed to create %s: %v", savePath, err)
+	}
+	log.Printf("Check it with `sleep lint`, then run `sleep` (add -w to keep snapshots in %s/)", savePath)
+}
+
+type initSubject struct {
+	name     string
+	sources  []string
+	emails   []string
+	timezone string
+}
+
+func promptSubjects(in *bufio.Scanner) []initSubject {
+	ask := func(question string) string {
+		fmt.Print(question)
+		if !in.Scan() {
+			return ""
+		}
+		return strings.TrimSpace(in.Text())
+	}
+	var subjects []initSubject
+	for {
+		s := initSubject{name: ask("Subject name (empty to finish): ")}
+		if s.name == "" {
+			return subjects
+		}
+	sources:
+		for {
+			s.sources = splitList(ask("Sources, comma separated (e.g. github.com/someone, gitlab.com/someone/project): "))
+			if len(s.sources) == 0 {
+				fmt.Println("  at least one source is needed")
+				continue
+			}
+			for _, source := range s.sources {
+				if msg, warning := lintSource(source, false); msg != "" && !warning {
+					fmt.Printf("  %s: %s\n", source, msg)
+					continue sources
+				}
+			}
+			break
+		}
+		s.emails = splitList(ask("Emails they commit from, comma separated (optional): "))
+		for {
+			s.timezone = ask("Timezone, e.g. Europe/Berlin (optional, empty trusts each commit's offset): ")
+			if _, err := time.LoadLocation(s.timezone); err == nil {
+				break
+			}
+			fmt.Printf("  unknown timezone %q\n", s.timezone)
+		}
+		subjects = append(subjects, s)
+	}
+}
+
+func splitList(s string) []string {
+	var items []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// writeNew writes a file that doesn't exist yet
+func writeNew(path, content string) {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+	if errors.Is(err, fs.ErrExist) {
+		log.Printf("%s already exists, leaving it alone", path)
+		return
+	}
+	if err != nil {
+		log.Fatalf("Failed to create %s: %v", path, err)
+	}
+	defer f.Close()
+	if _, err := f.WriteString(content); err != nil {
+		log.Fatalf("Failed to write %s: %v", path, err)
+	}
+	log.Printf("Wrote %s", path)
+}
+
+var bareKey = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+func tomlKey(s string) string {
+	if bareKey.MatchString(s) {
+		return s
+	}
+	return strconv.Quote(s)
+}
+
+func tomlList(items []string) string {
+	quoted := make([]string, len(items))
+	for i, item := range items {
+		quoted[i] = strconv.Quote(item)
+	}
+	return "[" + strings.Join(quoted, ", ") + "]"
+}
+
+func subjectsTemplate(subjects []initSubject) string {
+	var b strings.Builder
+	b.WriteString(subjectsHeader)
+	if len(subjects) == 0 {
+		b.WriteString(subjectsExample)
+	}
+	for _, s := range subjects {
+		fmt.Fprintf(&b, "\n[%s]\nsources = %s\n", tomlKey(s.name), tomlList(s.sources))
+		if len(s.emails) > 0 {
+			fmt.Fprintf(&b, "emails = %s\n", tomlList(s.emails))
+		}
+		if s.timezone != "" {
+			fmt.Fprintf(&b, "timezone = %s\n", strconv.Quote(s.timezone))
+		}
+	}
+	return b.String()
+}
+
+const subjectsHeader = `# who sleep tracks, one table per subject. check this file with ` + "`sleep lint`" + `
+#
+# sources    where their activity comes from, any mix of:
+#              a forge account, every public repo: "github.com/someone", "gitlab.com/someone",
+#                "codeberg.org/someone" or any gitea/forgejo/gitlab host
+#              one repo: "github.com/someone/project", "git.example.com/team/project"
+#              add #api, #clone or #auto to pick how a github source is read (see --mode)
+#              a git bundle or fast-export file: "exports/someone.bundle"
+#              a slack or discord export: "exports/slack.zip#someone"
+#              a browser history database: "/home/me/.mozilla/firefox/x.default/places.sqlite"
+#              stack exchange, package registries, container registries:
+#                "stackoverflow.com/users/22656", "npmjs.com/~someone", "pypi.org/user/someone",
+#                "crates.io/users/someone", "hub.docker.com/u/someone", "oci://ghcr.io/someone"
+#              a plugin: "jira:someone@acme" (see [plugins] in sleep.toml)
+# timezone   IANA name, e.g. "America/New_York"; without it each commit's own offset is trusted
+# emails     addresses they commit from; these always match, even with --strict
+# orgs       namespaces owned by their employer, for separate work and personal reports
+# members    instead of sources: other subjects, reported together as a group
+#
+# tokens aren't kept here: set GITHUB_TOKEN, GITLAB_TOKEN or GITEA_TOKEN in the environment for
+# private repos and higher rate limits, STACKEXCHANGE_KEY for stack exchange, REGISTRY_USER and
+# REGISTRY_PASSWORD for a private container registry
+`
+
+const subjectsExample = `
+# [someone]
+# sources = ["github.com/someone", "gitlab.com/someone/side-project"]
+# emails = ["someone@example.com"]
+# timezone = "Europe/Berlin"
+#
+# [team]
+# members = ["someone", "someoneelse"]
+`
+
+const sleepTemplate = `# settings that don't belong to any one subject. every section is optional; uncomment to change
+# the defaults
+
+# plot colors, for --theme custom
+# [theme]
+# background = "#ffffff"
+# foreground = "#202020"
+# data = "#3a7d1e"
+# window = "#c8d8f0"
+
+# where plots go. {subject} and {kind} are substituted, slashes make directories
+# [output]
+# dir = "plots"
+# layout = "{subject}/{kind}.png"
+
+# a digest per subject in --watch mode, by email and/or webhook
+# [digest]
+# every = "168h"
+# smtp = "smtp.example.com:587"
+# username = "me@example.com"
+# password_env = "SLEEP_SMTP_PASSWORD"  # the name of the variable holding the password, not the password
+# from = "me@example.com"
+# to = ["me@example.com"]
+# webhook = "https://hooks.example.com/sleep"
+
+# the --serve api's bearer token. SLEEP_API_TOKEN overrides it, and keeps it out of this file
+# [server]
+# token = "change-me"
+
+# politeness per host, over --qps and --max-per-host
+# [hosts."git.example.com"]
+# qps = 1
+# concurrency = 1
+
+# source plugins, name to executable; without one, sleep-source-<name> on PATH is used
+# [plugins]
+# jira = "/opt/sleep-plugins/jira.py"
+
+# what a night of sleep is assumed to be, in hours
+# [priors]
+# min_hours = 4
+# max_hours = 12
+# typical_min = 7
+# typical_max = 9
+`
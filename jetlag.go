@@ -0,0 +1,205 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"gonum.org/v1/plot/plotter"
+	"gonum.org/v1/plot/vg"
+)
+
+// a flight moves the whole night at once. each week with enough commits gets its own sleep window,
+// and when the middle of that window jumps more than a few hours from one such week to the next,
+// the jump is reported as probable travel, with the date and how far the night moved. windows are
+// on the subject's home clock, or utc without one: a laptop that follows the local zone keeps a
+// traveler's nights at the same recorded hours, so the offsets the commits carry would hide the trip.
+// whether that offset moved too is noted, since it's the best hint it really was a trip and not a
+// week of deadlines
+
+const (
+	minTrendWeekCommits = 20
+	// a move of the window's midpoint bigger than this between adjacent windows is a jump
+	minJumpMinutes = 3 * 60
+)
+
+// trendWeek is one week's sleep window
+type trendWeek struct {
+	Start    time.Time
+	Window   SleepWindow
+	Midpoint float64 // minutes since midnight
+	Offset   int     // seconds east of utc the week's commits were mostly made at
+}
+
+type PhaseJump struct {
+	Date time.Time `json:"date"`
+	// signed, positive means the night moved later
+	Minutes    float64 `json:"minutes"`
+	FromOffset string  `json:"from_offset,omitempty"` // set when the commits' utc offset changed too
+	ToOffset   string  `json:"to_offset,omitempty"`
+}
+
+// trendWeeks is every week with enough commits to find a sleep window, in order
+func trendWeeks(subject *Subject) []trendWeek {
+	counts := make(map[string][]int)
+	offsets := make(map[string]map[int]int)
+	for _, c := range subject.Commits {
+		recorded := c.Author.When
+		t := recorded.UTC()
+		if subject.Location != nil {
+			t = t.In(subject.Location)
+		}
+		key := weekKey(t)
+		if counts[key] == nil {
+			counts[key] = make([]int, 24)
+			offsets[key] = make(map[int]int)
+		}
+		counts[key][t.Hour()]++
+		_, offset := recorded.Zone()
+		offsets[key][offset]++
+	}
+
+	var weeks []trendWeek
+	for key, hours := range counts {
+		w := windowFromCounts(hours)
+		if w.Commits < minTrendWeekCommits || !w.Found {
+			continue
+		}
+		start, err := time.Parse(time.DateOnly, key)
+		if err != nil {
+			continue
+		}
+		week := trendWeek{
+			Start:    start,
+			Window:   w,
+			Midpoint: math.Mod(float64(w.Start)*60+float64(w.Hours)*30, 24*60),
+		}
+		var most int
+		for offset, n := range offsets[key] {
+			if n > most || n == most && offset < week.Offset {
+				week.Offset, most = offset, n
+			}
+		}
+		weeks = append(weeks, week)
+	}
+	sort.Slice(weeks, func(i, j int) bool { return weeks[i].Start.Before(weeks[j].Start) })
+	return weeks
+}
+
+// detectJumps compares each week's window with the previous one
+func detectJumps(weeks []trendWeek) []PhaseJump {
+	var jumps []PhaseJump
+	for i := 1; i < len(weeks); i++ {
+		prev, cur := weeks[i-1], weeks[i]
+		if clockDistance(prev.Midpoint, cur.Midpoint) <= minJumpMinutes {
+			continue
+		}
+		jump := PhaseJump{Date: cur.Start, Minutes: clockDelta(prev.Midpoint, cur.Midpoint)}
+		if prev.Offset != cur.Offset {
+			jump.FromOffset, jump.ToOffset = formatOffset(prev.Offset), formatOffset(cur.Offset)
+		}
+		jumps = append(jumps, jump)
+	}
+	return jumps
+}
+
+// clockDelta is the signed shorter way around the clock from a to b, in minutes
+func clockDelta(a, b float64) float64 {
+	d := math.Mod(b-a, 24*60)
+	if d > 12*60 {
+		d -= 24 * 60
+	} else if d < -12*60 {
+		d += 24 * 60
+	}
+	return d
+}
+
+func formatOffset(seconds int) string {
+	sign := '+'
+	if seconds < 0 {
+		sign, seconds = '-', -seconds
+	}
+	return fmt.Sprintf("%c%02d:%02d", sign, seconds/3600, (seconds%3600)/60)
+}
+
+func (j PhaseJump) String() string {
+	direction := "later"
+	if j.Minutes < 0 {
+		direction = "earlier"
+	}
+	s := fmt.Sprintf("week of %s: sleep moved %s %s", j.Date.Format(time.DateOnly), formatSpread(math.Abs(j.Minutes)), direction)
+	if j.FromOffset != "" {
+		s += fmt.Sprintf(", commit offset %s → %s", j.FromOffset, j.ToOffset)
+	}
+	return s
+}
+
+func printJumps(jumps []PhaseJump) {
+	if len(jumps) == 0 {
+		return
+	}
+	fmt.Printf("\n=== Probable Travel ===\n")
+	for _, j := range jumps {
+		fmt.Printf("%s\n", j)
+	}
+}
+
+// plotSleepTrend draws each week's sleep window midpoint over time, with a line at every jump
+func plotSleepTrend(subject *Subject, jumps []PhaseJump, outputPath string) error {
+	weeks := trendWeeks(subject)
+	if len(weeks) == 0 {
+		return fmt.Errorf("no week has the %d commits needed for a sleep window", minTrendWeekCommits)
+	}
+	pts := make(plotter.XYs, len(weeks))
+	for i, week := range weeks {
+		pts[i] = plotter.XY{X: float64(week.Start.Unix()), Y: week.Midpoint * 60}
+	}
+
+	yLabel := "Time of Day (UTC)"
+	if subject.Location != nil {
+		yLabel = fmt.Sprintf("Time of Day (%s)", subject.Location)
+	}
+	p := newPlot(fmt.Sprintf("Sleep Trend: %s\nweekly sleep window midpoint, %d probable travel events", subject.Name, len(jumps)), "Week", yLabel)
+	p.X.Tick.Marker = dateTicks{}
+	p.Y.Tick.Marker = hourTicks{}
+	p.Y.Min, p.Y.Max = 0, secondsPerDay
+
+	var labels plotter.XYLabels
+	for _, j := range jumps {
+		x := float64(j.Date.Unix())
+		line, err := plotter.NewLine(plotter.XYs{{X: x, Y: 0}, {X: x, Y: secondsPerDay}})
+		if err != nil {
+			return fmt.Errorf("could not create travel marker: %v", err)
+		}
+		line.Color = theme.Foreground
+		line.Dashes = []vg.Length{vg.Points(4), vg.Points(4)}
+		p.Add(line)
+		labels.XYs = append(labels.XYs, plotter.XY{X: x, Y: secondsPerDay * 0.95})
+		labels.Labels = append(labels.Labels, fmt.Sprintf(" %s %+.0fh", j.Date.Format("Jan 2"), j.Minutes/60))
+	}
+	if len(jumps) > 0 {
+		l, err := plotter.NewLabels(labels)
+		if err != nil {
+			return fmt.Errorf("could not label travel markers: %v", err)
+		}
+		for i := range l.TextStyle {
+			l.TextStyle[i].Color = theme.Foreground
+		}
+		p.Add(l)
+	}
+
+	scatter, err := plotter.NewScatter(pts)
+	if err != nil {
+		return fmt.Errorf("could not create trend plot: %v", err)
+	}
+	scatter.Radius = vg.Points(3)
+	scatter.Color = theme.Data
+	p.Add(scatter)
+
+	width, height := plotSize(10*vg.Inch, 5*vg.Inch)
+	if err := savePlot(p, width, height, outputPath, metaFor(subject)); err != nil {
+		return fmt.Errorf("could not save plot: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,458 @@
+package main
+
+import (
+	"bytes"
+	"cmp"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/pelletier/go-toml/v2"
+	"github.com/pelletier/go-toml/v2/unstable"
+)
+
+// sleep lint [file] checks a subjects file (--subjects-file by default) without collecting anything:
+// toml that doesn't parse, options that aren't sources, timezone, orgs, members or emails (with the
+// nearest one suggested), sources that aren't anything sleep reads, user pages on hosts with no
+// known api, the same source listed twice, groups naming subjects that don't exist, and timezones
+// go doesn't know. with --online it also asks each forge and git server whether it answers.
+// problems print as file:line: subject: message, and lint exits 1 if any is an error rather than a
+// warning. a normal run does the same offline checks up front and stops on errors, rather than
+// skipping a source halfway through the clones
+
+type lintIssue struct {
+	line    int
+	subject string
+	msg     string
+	warning bool
+}
+
+// subjectLines is where a subject's table and each of its values are in the toml
+type subjectLines struct {
+	header int
+	keys   map[string]int
+	// one line per element of a list, or the one for a string
+	values map[string][]int
+}
+
+var subjectOptions = []string{"sources", "timezone", "orgs", "members", "emails"}
+
+func runLint(args []string) {
+	if len(args) > 1 {
+		log.Fatal("usage: sleep lint [subjects file] [--online]")
+	}
+	path := flags.SubjectsFile
+	if len(args) == 1 {
+		path = args[0]
+	}
+	issues, err := lintSubjectsFile(path, flags.SubjectsFormat, flags.Online)
+	if err != nil {
+		log.Fatalf("Failed to read %s: %v", path, err)
+	}
+	var errs, warnings int
+	for _, issue := range issues {
+		fmt.Println(issue.format(path))
+		if issue.warning {
+			warnings++
+		} else {
+			errs++
+		}
+	}
+	log.Printf("%s: %d errors, %d warnings", path, errs, warnings)
+	if errs > 0 {
+		os.Exit(1)
+	}
+}
+
+func (i lintIssue) format(path string) string {
+	where := path
+	if i.line > 0 {
+		where += fmt.Sprintf(":%d", i.line)
+	}
+	if i.subject != "" {
+		where += ": " + i.subject
+	}
+	kind := "error"
+	if i.warning {
+		kind = "warning"
+	}
+	return fmt.Sprintf("%s: %s: %s", where, kind, i.msg)
+}
+
+// checkSubjectsFile is the up front check before a run: every error is logged, then it says how many
+func checkSubjectsFile(path, format string) error {
+	// stdin can only be read once, and the run needs it
+	if path == "-" {
+		return nil
+	}
+	issues, err := lintSubjectsFile(path, format, false)
+	if err != nil {
+		// reading it again for the run will say what's wrong
+		return nil
+	}
+	var errs int
+	for _, issue := range issues {
+		if !issue.warning {
+			log.Print(issue.format(path))
+			errs++
+		}
+	}
+	if errs > 0 {
+		return fmt.Errorf("%d problems in %s, fix them first (`sleep lint` also shows warnings)", errs, path)
+	}
+	return nil
+}
+
+func lintSubjectsFile(path, format string, online bool) ([]lintIssue, error) {
+	format, err := subjectsFormat(path, format)
+	if err != nil {
+		return nil, err
+	}
+	var data []byte
+	if path == "-" {
+		data, err = io.ReadAll(os.Stdin)
+	} else {
+		data, err = os.ReadFile(path)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var raw map[string]subjectEntry
+	var issues []lintIssue
+	lines := make(map[string]*subjectLines)
+	switch format {
+	case "csv":
+		raw, err = parseSubjectsCSV(data)
+	case "json":
+		raw, err = parseSubjectsJSON(data)
+	default:
+		dec := toml.NewDecoder(bytes.NewReader(data))
+		dec.DisallowUnknownFields()
+		err = dec.Decode(&raw)
+		var strict *toml.StrictMissingError
+		if errors.As(err, &strict) {
+			for _, e := range strict.Errors {
+				row, _ := e.Position()
+				issues = append(issues, unknownOption(row, e.Key()))
+			}
+			err = nil
+		}
+		var decode *toml.DecodeError
+		if errors.As(err, &decode) {
+			row, _ := decode.Position()
+			return append(issues, lintIssue{line: row, msg: strings.TrimPrefix(decode.Error(), "toml: ")}), nil
+		}
+		lines = tomlLines(data)
+	}
+	if err != nil {
+		return append(issues, lintIssue{msg: err.Error()}), nil
+	}
+	issues = append(issues, lintSubjects(raw, lines, online)...)
+	slices.SortStableFunc(issues, func(a, b lintIssue) int { return a.line - b.line })
+	return issues, nil
+}
+
+func unknownOption(line int, key toml.Key) lintIssue {
+	issue := lintIssue{line: line, msg: fmt.Sprintf("unknown option %q", strings.Join(key, "."))}
+	if len(key) == 0 {
+		return issue
+	}
+	if len(key) > 1 {
+		issue.subject = key[0]
+		issue.msg = fmt.Sprintf("unknown option %q", strings.Join(key[1:], "."))
+	}
+	if near := nearest(key[len(key)-1], subjectOptions); near != "" {
+		issue.msg += fmt.Sprintf(", did you mean %q?", near)
+	}
+	return issue
+}
+
+// nearest is the option within 2 edits of s, if any
+func nearest(s string, options []string) string {
+	best, bestDistance := "", 3
+	for _, option := range options {
+		if d := editDistance(strings.ToLower(s), option); d < bestDistance {
+			best, bestDistance = option, d
+		}
+	}
+	return best
+}
+
+func editDistance(a, b string) int {
+	prev := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur := make([]int, len(b)+1)
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev = cur
+	}
+	return prev[len(b)]
+}
+
+// tomlLines finds each subject's lines, from the toml's syntax tree since decoding loses them
+func tomlLines(data []byte) map[string]*subjectLines {
+	lines := make(map[string]*subjectLines)
+	subject := func(name string) *subjectLines {
+		if lines[name] == nil {
+			lines[name] = &subjectLines{keys: make(map[string]int), values: make(map[string][]int)}
+		}
+		return lines[name]
+	}
+	var p unstable.Parser
+	p.Reset(data)
+	lineOf := func(n *unstable.Node) int {
+		if n.Raw.Length == 0 {
+			return 0
+		}
+		return p.Shape(n.Raw).Start.Line
+	}
+	var table []string
+	for p.NextExpression() {
+		e := p.Expression()
+		switch e.Kind {
+		case unstable.Table, unstable.ArrayTable:
+			table = nil
+			line := 0
+			for it := e.Key(); it.Next(); {
+				table = append(table, string(it.Node().Data))
+				line = cmp.Or(line, lineOf(it.Node()))
+			}
+			if len(table) == 1 {
+				subject(table[0]).header = line
+			}
+		case unstable.KeyValue:
+			key := slices.Clone(table)
+			line := 0
+			for it := e.Key(); it.Next(); {
+				key = append(key, string(it.Node().Data))
+				line = cmp.Or(line, lineOf(it.Node()))
+			}
+			if len(key) != 2 {
+				continue
+			}
+			s := subject(key[0])
+			s.keys[key[1]] = line
+			if v := e.Value(); v.Kind == unstable.Array {
+				for it := v.Children(); it.Next(); {
+					s.values[key[1]] = append(s.values[key[1]], cmp.Or(lineOf(it.Node()), line))
+				}
+			} else {
+				s.values[key[1]] = []int{cmp.Or(lineOf(v), line)}
+			}
+		}
+	}
+	return lines
+}
+
+// lintSubjects checks the decoded subjects, placing each problem with lines where it can
+func lintSubjects(raw map[string]subjectEntry, lines map[string]*subjectLines, online bool) []lintIssue {
+	var issues []lintIssue
+	lineOf := func(name, option string, i int) int {
+		s := lines[name]
+		if s == nil {
+			return 0
+		}
+		if i >= 0 && i < len(s.values[option]) {
+			return s.values[option][i]
+		}
+		return cmp.Or(s.keys[option], s.header)
+	}
+
+	names := make([]string, 0, len(raw))
+	for name := range raw {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	// normalized source to the subject and line that first listed it
+	seen := make(map[string]lintIssue)
+	for _, name := range names {
+		entry := raw[name]
+		add := func(option string, i int, warning bool, format string, args ...any) {
+			issues = append(issues, lintIssue{line: lineOf(name, option, i), subject: name, msg: fmt.Sprintf(format, args...), warning: warning})
+		}
+		switch {
+		case len(entry.Sources) == 0 && len(entry.Members) == 0:
+			add("", -1, false, "no sources or members, so nothing to collect")
+		case len(entry.Sources) > 0 && len(entry.Members) > 0:
+			add("members", -1, false, "has both members and sources; a group's commits come from its members")
+		}
+		for i, member := range entry.Members {
+			if other, ok := raw[member]; !ok {
+				add("members", i, false, "member %q isn't a subject in the file", member)
+			} else if len(other.Members) > 0 {
+				add("members", i, false, "member %q is a group; groups can't contain groups", member)
+			}
+		}
+		if entry.Timezone != "" {
+			if _, err := time.LoadLocation(entry.Timezone); err != nil {
+				add("timezone", 0, false, "unknown timezone %q, expected an IANA name like Europe/Berlin", entry.Timezone)
+			}
+		}
+		for i, email := range entry.Emails {
+			if !strings.Contains(email, "@") {
+				add("emails", i, true, "%q doesn't look like an email address", email)
+			}
+		}
+		for i, source := range entry.Sources {
+			if msg, warning := lintSource(source, online); msg != "" {
+				add("sources", i, warning, "%s: %s", source, msg)
+				if !warning {
+					continue
+				}
+			}
+			key := normalizeSource(source)
+			if first, ok := seen[key]; ok && first.subject == name {
+				add("sources", i, true, "%s is listed twice (line %d)", source, first.line)
+				continue
+			} else if ok {
+				add("sources", i, true, "%s is also a source of %s (line %d)", source, first.subject, first.line)
+				continue
+			}
+			seen[key] = lintIssue{subject: name, line: lineOf(name, "sources", i)}
+			if online {
+				if msg := checkSourceOnline(source); msg != "" {
+					add("sources", i, false, "%s: %s", source, msg)
+				}
+			}
+		}
+	}
+	return issues
+}
+
+// lintSource is what's wrong with a source, going through the kinds getSource tries in its order,
+// and whether that's only a warning. online, unknown hosts are left to checkSourceOnline
+func lintSource(source string, online bool) (string, bool) {
+	if strings.TrimSpace(source) == "" {
+		return "empty source", false
+	}
+	if path, _ := splitChatSource(source); isLocalPath(path) {
+		if _, err := os.Stat(path); err != nil {
+			return "no such file or directory", false
+		}
+		return "", false
+	}
+	if m := pluginSourcePattern.FindStringSubmatch(source); m != nil && m[1] != "http" && m[1] != "https" && !startsWithDigit(m[2]) {
+		if _, ok := pluginFor(source); !ok {
+			return fmt.Sprintf("no plugin for %s: sources, add it under [plugins] in sleep.toml or put sleep-source-%s on PATH", m[1], m[1]), false
+		}
+		return "", false
+	}
+	if rest, ok := strings.CutPrefix(source, "oci://"); ok {
+		registry, namespace, _ := strings.Cut(strings.Trim(rest, "/"), "/")
+		if registry == "" || namespace == "" {
+			return "oci:// sources need a registry and a namespace, e.g. oci://ghcr.io/someone", false
+		}
+		return "", false
+	}
+	rawURL := source
+	if !strings.HasPrefix(rawURL, "http://") && !strings.HasPrefix(rawURL, "https://") {
+		rawURL = "https://" + rawURL
+	}
+	parsed, err := url.Parse(rawURL)
+	if err != nil {
+		return fmt.Sprintf("malformed URL: %v", err), false
+	}
+	host := parsed.Hostname()
+	if host == "" || !strings.Contains(host, ".") && host != "localhost" {
+		return "malformed URL: no host", false
+	}
+	if _, _, ok := stackExchangeUser(parsed); ok {
+		return "", false
+	}
+	if _, _, ok := registryUser(parsed); ok {
+		return "", false
+	}
+	if _, ok := containerUser(parsed); ok {
+		return "", false
+	}
+	if parsed.Fragment != "" && !slices.Contains(sourceModes, parsed.Fragment) {
+		return fmt.Sprintf("unknown mode #%s, expected one of %v", parsed.Fragment, sourceModes), false
+	}
+	path := strings.Trim(parsed.Path, "/")
+	if path == "" {
+		return "no user or repo in the URL", false
+	}
+	if !strings.Contains(path, "/") && knownForge(host) == "" && !online {
+		return fmt.Sprintf("%s isn't a forge sleep knows, so its api is guessed at run time (--online checks now)", host), true
+	}
+	return "", false
+}
+
+func isLocalPath(s string) bool {
+	if _, err := os.Stat(s); err == nil {
+		return true
+	}
+	return strings.HasPrefix(s, "/") || strings.HasPrefix(s, "./") || strings.HasPrefix(s, "../") || strings.HasPrefix(s, "~")
+}
+
+func startsWithDigit(s string) bool {
+	return s != "" && s[0] >= '0' && s[0] <= '9'
+}
+
+// normalizeSource makes the spellings of one source compare equal: scheme, www, case, trailing
+// slash, .git and mode
+func normalizeSource(source string) string {
+	s := strings.ToLower(strings.TrimSpace(source))
+	s, _, _ = strings.Cut(s, "#")
+	for _, prefix := range []string{"https://", "http://", "www."} {
+		s = strings.TrimPrefix(s, prefix)
+	}
+	s = strings.TrimSuffix(s, "/")
+	return strings.TrimSuffix(s, ".git")
+}
+
+// checkSourceOnline asks a forge source's host whether it answers: a user page's host for its api,
+// a repo's git server for its refs
+func checkSourceOnline(source string) string {
+	rawURL := source
+	if !strings.HasPrefix(rawURL, "http://") && !strings.HasPrefix(rawURL, "https://") {
+		rawURL = "https://" + rawURL
+	}
+	parsed, err := url.Parse(rawURL)
+	if err != nil || isLocalPath(source) || strings.HasPrefix(source, "oci://") {
+		return ""
+	}
+	if _, _, ok := stackExchangeUser(parsed); ok {
+		return ""
+	}
+	if _, _, ok := registryUser(parsed); ok {
+		return ""
+	}
+	if _, ok := containerUser(parsed); ok {
+		return ""
+	}
+	parts := strings.Split(strings.Trim(parsed.Path, "/"), "/")
+	if len(parts) < 2 {
+		if detectForge(parsed.Hostname()) == "" {
+			return fmt.Sprintf("no github, gitlab or gitea api answers at %s", parsed.Hostname())
+		}
+		return ""
+	}
+	refs := fmt.Sprintf("https://%s/%s/%s.git/info/refs?service=git-upload-pack", parsed.Host, parts[0], strings.TrimSuffix(parts[1], ".git"))
+	resp, err := newHTTPClient(10 * time.Second).Get(refs)
+	if err != nil {
+		return fmt.Sprintf("unreachable: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Sprintf("the git server answered %s", resp.Status)
+	}
+	return ""
+}
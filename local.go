@@ -0,0 +1,179 @@
+package main
+
+import (
+	"cmp"
+	"errors"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/go-git/go-git/v5"
+	gitconfig "github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+// --local ~/code: analyze yourself from the repos already on disk. no forges, no network, and
+// private work counts too. "yourself" is the identity in your global git config, plus whatever
+// user.email each repo overrides it with
+
+// dirs that never hold your repos but can be huge to walk
+var skipLocalDirs = []string{"node_modules", "vendor", ".cache", "target"}
+
+func localSubject(roots []string) (Subject, error) {
+	name, email := globalIdentity()
+	if name == "" && email == "" {
+		return Subject{}, errors.New("--local needs user.name or user.email in your global git config")
+	}
+	subjectName := cmp.Or(name, email)
+	log.Printf("--- Building Subject: %s (local) ---\n", subjectName)
+
+	subject := Subject{
+		Name:    subjectName,
+		Commits: make(map[plumbing.Hash]*object.Commit),
+		Origins: make(map[plumbing.Hash][]string),
+	}
+	for _, path := range findLocalRepos(roots) {
+		repo, err := git.PlainOpenWithOptions(path, &git.PlainOpenOptions{EnableDotGitCommonDir: true})
+		if err != nil {
+			log.Printf("  Failed to open %s: %v", path, err)
+			continue
+		}
+		tips := localTips(repo)
+		if len(tips) == 0 {
+			continue
+		}
+
+		emails := []string{}
+		if email != "" {
+			emails = append(emails, email)
+		}
+		if cfg, err := repo.Config(); err == nil && cfg.User.Email != "" && !slices.Contains(emails, cfg.User.Email) {
+			emails = append(emails, cfg.User.Email)
+		}
+		match := audited(subjectName, path, func(c *object.Commit) (bool, string) {
+			return matchLocalCommit(c, name, emails)
+		})
+
+		commits, err := walkRepo(repo, path, tips, match)
+		if err != nil {
+			log.Printf("  Failed to iterate commits for %s: %v", path, err)
+			continue
+		}
+		if len(commits) > 0 {
+			log.Printf("  Found %d commits in %s\n", len(commits), path)
+		}
+		for _, commit := range commits {
+			subject.Commits[commit.Hash] = commit
+			subject.Origins[commit.Hash] = append(subject.Origins[commit.Hash], path)
+		}
+	}
+
+	log.Printf("Total unique commits for %s: %d\n", subjectName, len(subject.Commits))
+	if len(subject.Commits) == 0 {
+		noteFailure(ErrNoCommits)
+	}
+	return subject, nil
+}
+
+// matchLocalCommit is validateCommit for a known identity: an exact email, or the exact name.
+// there's no forge username to guess from, and substring guesses on your own name would pull in
+// every coworker sharing a first name
+func matchLocalCommit(c *object.Commit, name string, emails []string) (bool, string) {
+	if !c.Committer.When.After(flags.Since) {
+		return false, ruleTooOld
+	}
+	for _, email := range emails {
+		if strings.EqualFold(c.Author.Email, email) {
+			return true, ruleEmailListed
+		}
+	}
+	if name != "" && strings.EqualFold(c.Author.Name, name) {
+		return true, ruleNameIsUsername
+	}
+	return false, ruleNoMatch
+}
+
+func globalIdentity() (name, email string) {
+	cfg, err := gitconfig.LoadConfig(gitconfig.GlobalScope)
+	if err != nil {
+		log.Printf("Failed to read global git config: %v", err)
+		return "", ""
+	}
+	return cfg.User.Name, cfg.User.Email
+}
+
+// findLocalRepos walks roots for work trees and bare repos. it doesn't descend into a repo once
+// found, so submodules and vendored checkouts are left alone
+func findLocalRepos(roots []string) []string {
+	var repos []string
+	for _, root := range roots {
+		if rest, ok := strings.CutPrefix(root, "~/"); ok {
+			if home, err := os.UserHomeDir(); err == nil {
+				root = filepath.Join(home, rest)
+			}
+		}
+		err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				log.Printf("  Skipping %s: %v", path, err)
+				if d != nil && d.IsDir() {
+					return fs.SkipDir
+				}
+				return nil
+			}
+			if !d.IsDir() {
+				return nil
+			}
+			if slices.Contains(skipLocalDirs, d.Name()) {
+				return fs.SkipDir
+			}
+			if isGitDir(filepath.Join(path, ".git")) || isGitDir(path) {
+				repos = append(repos, path)
+				return fs.SkipDir
+			}
+			return nil
+		})
+		if err != nil {
+			log.Printf("Failed to walk %s: %v", root, err)
+		}
+	}
+	log.Printf("Found %d local repos under %v", len(repos), roots)
+	return repos
+}
+
+// isGitDir is true for a .git dir, a bare repo, or a worktree's .git file
+func isGitDir(path string) bool {
+	info, err := os.Stat(path)
+	if err != nil {
+		return false
+	}
+	if !info.IsDir() {
+		return filepath.Base(path) == ".git"
+	}
+	_, headErr := os.Stat(filepath.Join(path, "HEAD"))
+	_, objectsErr := os.Stat(filepath.Join(path, "objects"))
+	return headErr == nil && objectsErr == nil
+}
+
+// localTips is every local branch, since your own unpushed and unmerged work is the point. HEAD
+// leads so the mailmap comes from the checked out branch
+func localTips(repo *git.Repository) []plumbing.Hash {
+	var tips []plumbing.Hash
+	if head, err := repo.Head(); err == nil {
+		tips = append(tips, head.Hash())
+	}
+	branches, err := repo.Branches()
+	if err != nil {
+		return tips
+	}
+	branches.ForEach(func(ref *plumbing.Reference) error {
+		if !slices.Contains(tips, ref.Hash()) {
+			tips = append(tips, ref.Hash())
+		}
+		return nil
+	})
+	return tips
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"time"
+)
+
+// rough geolocation from solar time. people mostly sleep through local solar night, so the UTC time of
+// the middle of someone's sleep says roughly how far around the globe they are: 15° of longitude per hour.
+// this is a heuristic and says nothing about latitude. civil time drifts from solar time (all of China on
+// one zone, Spain on CET) and so do people, hence the wide band
+
+// mid-sleep for a typical adult on a free schedule, in local solar hours
+const assumedSleepMidpoint = 3.5
+
+// how far a person's actual mid-sleep tends to stray from the above, in hours
+const sleepMidpointSlack = 1.5
+
+type region struct {
+	name     string
+	from, to float64 // degrees east
+}
+
+var regions = []region{
+	{"Hawaii, Alaska", -170, -140},
+	{"North American Pacific coast", -140, -115},
+	{"North American Mountain region", -115, -102},
+	{"Central North America, Mexico, Central America", -102, -85},
+	{"Eastern North America, Caribbean, Andes", -85, -65},
+	{"Eastern South America", -65, -35},
+	{"Mid-Atlantic", -35, -10},
+	{"UK, Ireland, Portugal, West Africa", -10, 5},
+	{"Central Europe, Central Africa", 5, 20},
+	{"Eastern Europe, East Africa, Levant", 20, 40},
+	{"Gulf, Caucasus, Iran", 40, 60},
+	{"Central Asia, Pakistan, India", 60, 90},
+	{"Southeast Asia", 90, 110},
+	{"China, Philippines, Western Australia", 110, 125},
+	{"Japan, Korea, Eastern Australia", 125, 155},
+	{"New Zealand, Pacific islands", 155, 180},
+}
+
+type LocationHint struct {
+	Found      bool
+	MidpointUT float64 // hours
+	Longitude  float64 // degrees east, center of the band
+	Slack      float64 // degrees either side
+	Regions    []string
+}
+
+func inferLocation(times []time.Time) LocationHint {
+	utc := make([]time.Time, len(times))
+	for i, t := range times {
+		utc[i] = t.UTC()
+	}
+	w := estimateSleepWindow(utc)
+	if !w.Found {
+		return LocationHint{}
+	}
+
+	hint := LocationHint{Found: true, Slack: sleepMidpointSlack * 15}
+	hint.MidpointUT = math.Mod(float64(w.Start)+float64(w.Hours)/2, 24)
+	hint.Longitude = wrapLongitude((assumedSleepMidpoint - hint.MidpointUT) * 15)
+
+	lo, hi := hint.Longitude-hint.Slack, hint.Longitude+hint.Slack
+	for _, r := range regions {
+		// compare against the band shifted a full turn either way so it can wrap the antimeridian
+		for _, shift := range []float64{-360, 0, 360} {
+			if r.from < hi+shift && r.to > lo+shift {
+				hint.Regions = append(hint.Regions, r.name)
+				break
+			}
+		}
+	}
+	return hint
+}
+
+func wrapLongitude(deg float64) float64 {
+	deg = math.Mod(deg+180, 360)
+	if deg < 0 {
+		deg += 360
+	}
+	return deg - 180
+}
+
+func printLocationHint(hint LocationHint) {
+	fmt.Printf("location hint (rough heuristic from solar time, not a measurement):\n")
+	if !hint.Found {
+		fmt.Printf("  no sleep window, nothing to go on\n")
+		return
+	}
+	fmt.Printf("  mid-sleep at %s UTC -> longitude %.0f° ± %.0f°\n",
+		clockString(hint.MidpointUT*3600), hint.Longitude, hint.Slack)
+	fmt.Printf("  plausible regions: %s\n", strings.Join(hint.Regions, "; "))
+}
@@ -0,0 +1,167 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+// .mailmap maps the names/emails people used to commit to the ones they go by now, see gitmailmap(5).
+// each repo's own .mailmap is applied to its commits, with --mailmap layered on top of every repo.
+// commit authors are rewritten in place before matching, so everything downstream sees one identity
+
+type mailmapEntry struct {
+	properName, properEmail string
+	// commitName is optional; when set, only commits with both this name and email match
+	commitName, commitEmail string
+}
+
+type Mailmap []mailmapEntry
+
+var globalMailmap Mailmap
+
+func loadMailmapFile(path string) Mailmap {
+	f, err := os.Open(path)
+	if err != nil {
+		log.Fatalf("Failed to read mailmap %s: %v", path, err)
+	}
+	defer f.Close()
+	return parseMailmap(f)
+}
+
+// parseMailmap understands all four forms:
+//
+//	Proper Name <commit@email>
+//	<proper@email> <commit@email>
+//	Proper Name <proper@email> <commit@email>
+//	Proper Name <proper@email> Commit Name <commit@email>
+func parseMailmap(r io.Reader) Mailmap {
+	var m Mailmap
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line, _, _ := strings.Cut(scanner.Text(), "#")
+		var names, emails []string
+		for {
+			open := strings.IndexByte(line, '<')
+			end := strings.IndexByte(line, '>')
+			if open < 0 || end < open {
+				break
+			}
+			names = append(names, strings.TrimSpace(line[:open]))
+			emails = append(emails, strings.ToLower(strings.TrimSpace(line[open+1:end])))
+			line = line[end+1:]
+		}
+		switch len(emails) {
+		case 1:
+			m = append(m, mailmapEntry{properName: names[0], commitEmail: emails[0]})
+		case 2:
+			m = append(m, mailmapEntry{properName: names[0], properEmail: emails[0], commitName: names[1], commitEmail: emails[1]})
+		}
+	}
+	return m
+}
+
+// apply rewrites a signature to its proper identity. later entries win, like git
+func (m Mailmap) apply(sig *object.Signature) {
+	email := strings.ToLower(sig.Email)
+	for i := len(m) - 1; i >= 0; i-- {
+		e := m[i]
+		if e.commitEmail != email || (e.commitName != "" && !strings.EqualFold(e.commitName, sig.Name)) {
+			continue
+		}
+		if e.properName != "" {
+			sig.Name = e.properName
+		}
+		if e.properEmail != "" {
+			sig.Email = e.properEmail
+		}
+		return
+	}
+}
+
+// repoMailmap reads .mailmap from the repo's HEAD
+func repoMailmap(repo *git.Repository, repoURL string, head plumbing.Hash) Mailmap {
+	data, err := headFile(repo, repoURL, head, ".mailmap")
+	if errors.Is(err, fs.ErrNotExist) {
+		// no mailmap, the common case
+		return nil
+	}
+	if err != nil {
+		log.Printf("  Failed to fetch .mailmap for %s: %v", repoURL, err)
+		return nil
+	}
+	return parseMailmap(bytes.NewReader(data))
+}
+
+// headFile reads a file from the tree at head. blobless clones usually don't have the blob, so it's
+// fetched from the forge's raw file endpoint when missing. fs.ErrNotExist if the tree has no such file
+func headFile(repo *git.Repository, repoURL string, head plumbing.Hash, name string) ([]byte, error) {
+	commit, err := repo.CommitObject(head)
+	if err != nil {
+		return nil, err
+	}
+	tree, err := commit.Tree()
+	if err != nil {
+		return nil, err
+	}
+	entry, err := tree.FindEntry(name)
+	if err != nil {
+		return nil, fs.ErrNotExist
+	}
+
+	if blob, err := repo.BlobObject(entry.Hash); err == nil {
+		r, err := blob.Reader()
+		if err == nil {
+			defer r.Close()
+			return io.ReadAll(r)
+		}
+	}
+	return fetchRawFile(repoURL, head, name)
+}
+
+func fetchRawFile(repoURL string, head plumbing.Hash, name string) ([]byte, error) {
+	u, err := url.Parse(repoURL)
+	if err != nil || u.Host == "" {
+		return nil, fmt.Errorf("can't get raw files for %s", repoURL)
+	}
+	host := strings.ToLower(u.Hostname())
+	project := strings.TrimSuffix(strings.Trim(u.Path, "/"), ".git")
+
+	var rawURL string
+	switch {
+	case strings.HasSuffix(host, "github.com"):
+		rawURL = fmt.Sprintf("https://raw.githubusercontent.com/%s/%s/%s", project, head, name)
+	case strings.HasSuffix(host, "gitlab.com"):
+		rawURL = fmt.Sprintf("https://%s/%s/-/raw/%s/%s", host, project, head, name)
+	case strings.HasSuffix(host, "gitea.com"),
+		strings.HasSuffix(host, "codeberg.org"),
+		strings.HasSuffix(host, "forgejo.org"):
+		rawURL = fmt.Sprintf("https://%s/%s/raw/commit/%s/%s", host, project, head, name)
+	default:
+		return nil, fmt.Errorf("unknown forge %s", host)
+	}
+
+	client := newHTTPClient(10 * time.Second)
+	resp, err := client.Get(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("raw file request failed: %s", resp.Status)
+	}
+	return io.ReadAll(resp.Body)
+}
@@ -1,11 +1,15 @@
 package main
 
 import (
+	"cmp"
+	"errors"
 	"fmt"
 	"log"
+	"maps"
 	"github.com/spf13/pflag"
 	"net/url"
 	"os"
+	"slices"
 	"strings"
 	"time"
 
@@ -13,8 +17,7 @@ import (
 	"github.com/go-git/go-git/v5/plumbing"
 	"github.com/go-git/go-git/v5/plumbing/object"
 	"github.com/go-git/go-git/v5/plumbing/protocol/packp"
-	"github.com/go-git/go-git/v5/storage/memory"
-	"github.com/pelletier/go-toml/v2"
+	"github.com/go-git/go-git/v5/plumbing/transport"
 )
 
 // main() calls parseSubjects which reads subjects.toml, loops over subjects to call getSubject
@@ -33,6 +36,8 @@ type Source struct {
 	host  string
 	user  string
 	repos []*git.Repository
+	// every repo the source listed and what became of it, see resolution.go
+	resolved []RepoResolution
 }
 
 type Subject struct {
@@ -40,56 +45,243 @@ type Subject struct {
 	Sources []Source
 	// stuff these into a hashset/map so they're deduplicated in case the sources are redundant
 	Commits map[plumbing.Hash]*object.Commit
+	// clone URLs each commit was seen in. usually one, more for forks and mirrors
+	Origins map[plumbing.Hash][]string
+	// how many times each commit counts, see --weight-by. nil means once each
+	Weights map[plumbing.Hash]int
+	// namespaces owned by the subject's employer; commits in their repos count as work
+	Orgs []string
+	// names of the subjects a group aggregates. empty for individuals
+	Members []string
+	// IANA zone the subject lives in, if known. nil means use each commit's recorded offset
+	Location *time.Location
+	// Location was inferred from the commits (--subject-tz), not configured
+	ZoneInferred bool
+	// commit count before --sample, 0 if not sampled
+	SampledFrom int
 }
 
-const subjectsFile = "subjects.toml"
 const savePath = "snapshots"
 
-func parseSubjects() []Subject {
-	data, err := os.ReadFile(subjectsFile)
-	if err != nil {
-		log.Fatalf("Failed to read %s: %v", subjectsFile, err)
+// subjects made on the fly, e.g. by `sleep org --run`, used instead of --subjects-file when set
+var generatedSubjects map[string]subjectEntry
+
+// parseSubjects builds the subjects in --subjects-file. if only is given, just those (and the members
+// of any groups among them) are built
+func parseSubjects(only []string) ([]Subject, error) {
+	raw := generatedSubjects
+	var err error
+	if raw == nil {
+		if err = checkSubjectsFile(flags.SubjectsFile, flags.SubjectsFormat); err != nil {
+			return nil, err
+		}
+		if raw, err = readSubjectsFile(flags.SubjectsFile, flags.SubjectsFormat); err != nil {
+			return nil, fmt.Errorf("Failed to read %s: %v", flags.SubjectsFile, err)
+		}
 	}
 
-	var raw map[string]struct {
-		Sources []string `toml:"sources"`
+	wanted := func(string) bool { return true }
+	if len(only) > 0 {
+		set := make(map[string]bool)
+		for _, name := range only {
+			entry, ok := raw[name]
+			if !ok {
+				return nil, fmt.Errorf("No subject named %q in %s", name, flags.SubjectsFile)
+			}
+			set[name] = true
+			for _, member := range entry.Members {
+				set[member] = true
+			}
+		}
+		wanted = func(name string) bool { return set[name] }
 	}
-	if err := toml.Unmarshal(data, &raw); err != nil {
-		log.Fatalf("Failed to unmarshal TOML: %v", err)
+
+	// groups are checked up front so a typo fails before any cloning
+	for name, entry := range raw {
+		if len(entry.Members) == 0 {
+			continue
+		}
+		if len(entry.Sources) > 0 {
+			return nil, fmt.Errorf("%s has both members and sources; a group's commits come from its members", name)
+		}
+		for _, member := range entry.Members {
+			if other, ok := raw[member]; !ok || len(other.Members) > 0 {
+				return nil, fmt.Errorf("Group %s lists %q, which is not a subject with sources", name, member)
+			}
+		}
 	}
 
-	var subjects []Subject
+	// all of them built at once, so repos they share are cloned and walked once
+	var names []string
+	var requests []subjectRequest
+	locations := make(map[string]*time.Location)
+	for _, name := range slices.Sorted(maps.Keys(raw)) {
+		entry := raw[name]
+		if len(entry.Members) > 0 || !wanted(name) {
+			continue
+		}
+		if entry.Timezone != "" {
+			if locations[name], err = time.LoadLocation(entry.Timezone); err != nil {
+				return nil, fmt.Errorf("Invalid timezone %q for %s: %v", entry.Timezone, name, err)
+			}
+		}
+		names = append(names, name)
+		requests = append(requests, subjectRequest{name, entry.Sources, entry.Emails})
+	}
+	subjects := getSubjects(requests)
+	for i, name := range names {
+		subjects[i].Location = locations[name]
+		subjects[i].Orgs = raw[name].Orgs
+	}
+
+	var groups []Subject
 	for name, entry := range raw {
-		subject := getSubject(name, entry.Sources)
-		subjects = append(subjects, subject)
+		if len(entry.Members) == 0 || !wanted(name) {
+			continue
+		}
+		group := buildGroup(name, entry.Members, subjects)
+		if entry.Timezone != "" {
+			if group.Location, err = time.LoadLocation(entry.Timezone); err != nil {
+				return nil, fmt.Errorf("Invalid timezone %q for %s: %v", entry.Timezone, name, err)
+			}
+		}
+		groups = append(groups, group)
 	}
-	return subjects
+	return append(subjects, groups...), nil
+}
+
+func getSubject(name string, sourceURLs []string, emails []string) Subject {
+	return getSubjects([]subjectRequest{{name, sourceURLs, emails}})[0]
 }
 
-func getSubject(name string, sourceURLs []string) Subject {
-	log.Printf("--- Building Subject: %s ---\n", name)
-	subject := Subject{
-		Name:    name,
-		Commits: make(map[plumbing.Hash]*object.Commit),
+// subjectRequest is a subject to build: who, where their activity is, and who they commit as
+type subjectRequest struct {
+	name    string
+	sources []string
+	emails  []string
+}
+
+// getSubjects builds subjects together. every source is resolved first, then each repo that any of
+// them lists is fetched and walked once, its commits matched against all of them in the same pass
+func getSubjects(requests []subjectRequest) []Subject {
+	plans := make([][]*sourcePlan, len(requests))
+	var all []*sourcePlan
+	for i, req := range requests {
+		log.Printf("--- Building Subject: %s ---\n", req.name)
+		for _, sourceURL := range req.sources {
+			if plan := planSource(sourceURL, req.name, req.emails); plan != nil {
+				plans[i] = append(plans[i], plan)
+				all = append(all, plan)
+			}
+		}
 	}
-	
-	for _, sourceURL := range sourceURLs {
-		source, commits := getSource(sourceURL, name)
-		if source == nil {
-			continue
+	runPlans(all)
+
+	subjects := make([]Subject, len(requests))
+	for i, req := range requests {
+		subject := Subject{
+			Name:    req.name,
+			Commits: make(map[plumbing.Hash]*object.Commit),
+			Origins: make(map[plumbing.Hash][]string),
 		}
-		subject.Sources = append(subject.Sources, *source)
-		
-		for _, commit := range commits {
-			subject.Commits[commit.Hash] = commit
+		for _, plan := range plans[i] {
+			source, commitsByRepo := finishSource(plan)
+			if source == nil {
+				continue
+			}
+			subject.Sources = append(subject.Sources, *source)
+
+			for repoURL, commits := range commitsByRepo {
+				for _, commit := range commits {
+					subject.Commits[commit.Hash] = commit
+					subject.Origins[commit.Hash] = append(subject.Origins[commit.Hash], repoURL)
+					if w, ok := sourceWeights[commit.Hash]; ok {
+						if subject.Weights == nil {
+							subject.Weights = make(map[plumbing.Hash]int)
+						}
+						subject.Weights[commit.Hash] = w
+					}
+				}
+			}
+		}
+
+		log.Printf("Total unique commits for %s: %d\n", req.name, len(subject.Commits))
+		if len(subject.Commits) == 0 {
+			noteFailure(ErrNoCommits)
 		}
+		subjects[i] = subject
 	}
-	
-	log.Printf("Total unique commits for %s: %d\n", name, len(subject.Commits))
-	return subject
+	return subjects
+}
+
+// sourcePlan is a source resolved as far as it goes without cloning: what it already collected
+// (non-git sources, repos listed through the api) and the repos left to clone, see runPlans
+type sourcePlan struct {
+	subject string
+	emails  []string
+	source  *Source
+	// the window the source is collected over: --since, or later for an --incremental source
+	since         time.Time
+	commitsByRepo map[string][]*object.Commit
+	clones        []cloneJob
+	// every repo planned so far, so --submodules doesn't read one twice
+	planned map[string]bool
+	// failures noted while resolving; see finishSource
+	failed bool
+	// --incremental's stored state, see planSource
+	url       string
+	statePath string
+	state     sourceState
+	started   time.Time
+}
+
+// cloneJob is a repo a source needs cloned, and (under --mode auto) why it wasn't listed through the api
+type cloneJob struct {
+	info RepoInfo
+	why  string
+}
+
+// plan queues a repo for cloning, unless the source already has it
+func (p *sourcePlan) plan(info RepoInfo, why string) {
+	key := strings.ToLower(displayRepo(info.CloneURL))
+	if p.planned[key] {
+		return
+	}
+	p.planned[key] = true
+	if reason := oversized(info); reason != "" {
+		p.source.resolved = append(p.source.resolved, RepoResolution{Repo: info.CloneURL, Status: repoSkipped, Reason: reason})
+		return
+	}
+	p.clones = append(p.clones, cloneJob{info, why})
 }
 
-func getSource(rawURL string, subjectName string) (*Source, []*object.Commit) {
+// resolveSource works out what a source is and collects everything since since that doesn't need a
+// clone. nil if there's nothing to collect from it
+func resolveSource(rawURL string, subjectName string, emails []string, since time.Time) *sourcePlan {
+	plan := &sourcePlan{subject: subjectName, emails: emails, since: since, planned: make(map[string]bool)}
+	direct := func(source *Source, commitsByRepo map[string][]*object.Commit) *sourcePlan {
+		if source == nil {
+			return nil
+		}
+		plan.source, plan.commitsByRepo = source, commitsByRepo
+		return plan
+	}
+	if source, commitsByRepo, ok := getChatSource(rawURL, subjectName, emails, since); ok {
+		return direct(source, commitsByRepo)
+	}
+	if isSQLite(rawURL) {
+		return direct(getHistorySource(rawURL, subjectName, since))
+	}
+	if isExportFile(rawURL) {
+		return direct(getExportSource(rawURL, subjectName, emails, since))
+	}
+	if plugin, ok := pluginFor(rawURL); ok {
+		return direct(getPluginSource(plugin, rawURL, subjectName, since))
+	}
+	if rest, ok := strings.CutPrefix(rawURL, "oci://"); ok {
+		registry, namespace, _ := strings.Cut(strings.Trim(rest, "/"), "/")
+		return direct(getContainerSource(rawURL, registry, namespace, subjectName, since))
+	}
 	if !strings.HasPrefix(rawURL, "http://") && !strings.HasPrefix(rawURL, "https://") {
 		rawURL = "https://" + rawURL
 	}
@@ -97,15 +289,34 @@ func getSource(rawURL string, subjectName string) (*Source, []*object.Commit) {
 	parsed, err := url.Parse(rawURL)
 	if err != nil {
 		log.Printf("Failed to parse URL %s: %v", rawURL, err)
-		return nil, nil
+		return nil
+	}
+
+	if site, id, ok := stackExchangeUser(parsed); ok {
+		return direct(getStackExchangeSource(rawURL, site, id, subjectName, since))
+	}
+	if registry, user, ok := registryUser(parsed); ok {
+		return direct(getRegistrySource(rawURL, registry, user, subjectName, since))
+	}
+	if namespace, ok := containerUser(parsed); ok {
+		return direct(getContainerSource(rawURL, "docker.io", namespace, subjectName, since))
 	}
 
 	host := parsed.Hostname()
 	path := strings.Trim(parsed.Path, "/")
+	mode := sourceMode(parsed)
+	if mode != "clone" && !strings.HasSuffix(strings.ToLower(host), "github.com") {
+		if mode == "api" {
+			log.Printf("Only github can be listed through the api, cloning %s", rawURL)
+		}
+		mode = "clone"
+	}
+	parsed.Fragment = ""
+	rawURL = parsed.String()
 	
 	if path == "" {
 		log.Printf("URL has no path: %s", rawURL)
-		return nil, nil
+		return nil
 	}
 	
 	parts := strings.Split(path, "/")
@@ -123,119 +334,261 @@ func getSource(rawURL string, subjectName string) (*Source, []*object.Commit) {
 
 	// if source is a repo and not a git user, we can just clone it.
 	// if it isn't, we have to call detectAPI to try to determine how to enumerate a user's repos
-	var repoURLs []string
+	var repos []RepoInfo
 	if repoName != "" {
 		cloneURL := fmt.Sprintf("https://%s/%s/%s.git", host, user, repoName)
-		repoURLs = []string{cloneURL}
+		repos = []RepoInfo{{CloneURL: cloneURL}}
 	} else {
 		fetcher := detectAPI(host)
 		if fetcher == nil {
 			log.Printf("Unknown API for host %s", host)
-			return nil, nil
+			noteFailure(ErrForgeUnknown)
+			return nil
 		}
 		// a corresponding fetcher for each git host API
-		repoURLs, err = fetcher(host, user, flags)
+		done := timed("api", host+"/"+user)
+		// the fetchers skip repos by the window they're given, which --incremental shortens
+		window := flags
+		window.Since = since
+		account, err := fetcher(host, user, window)
+		done()
 		if err != nil {
 			log.Printf("Failed to fetch repos for %s on host %s: %v", user, host, err)
-			return nil, nil
+			noteFailure(err)
+			return nil
+		}
+		repos = account.Repos
+		source.resolved = account.Skipped
+		// a public profile email is as good as one listed in subjects.toml
+		emails = append(slices.Clip(emails), account.Emails...)
+		if len(account.Orgs) > 0 {
+			log.Printf("%s belongs to orgs %v (list them under orgs to split work from personal)", user, account.Orgs)
 		}
 	}
+	plan.source, plan.emails = source, emails
 
-	log.Printf("Processing source: %s (%d repos)\n", rawURL, len(repoURLs))
+	log.Printf("Processing source: %s (%d repos)\n", rawURL, len(repos))
 	
-	var allCommits []*object.Commit
-	for _, repoURL := range repoURLs {
-		repo, commits := getRepo(repoURL, subjectName, user)
-		if repo != nil {
-			source.repos = append(source.repos, repo)
-			allCommits = append(allCommits, commits...)
+	plan.commitsByRepo = make(map[string][]*object.Commit)
+	counts := make(modeCounts)
+	for _, info := range repos {
+		how, why := repoMode(info, mode)
+		if why != "" {
+			log.Printf("  %s %s (%s)", how, info.CloneURL, why)
+		}
+		counts[how]++
+		if how == "api" {
+			commits, err := listCommitsAPI(info, user, subjectName, since)
+			if err != nil {
+				log.Printf("  Failed to list commits of %s: %v", info.CloneURL, err)
+				noteFailure(err)
+				source.resolved = append(source.resolved, RepoResolution{Repo: info.CloneURL, Status: repoFailed, Reason: err.Error()})
+				continue
+			}
+			plan.commitsByRepo[info.CloneURL] = commits
+			source.resolved = append(source.resolved, selectedRepo(info, how, why))
+			continue
+		}
+		if flags.PushedBranches && strings.HasSuffix(strings.ToLower(host), "github.com") {
+			branches, err := pushedBranches(info.CloneURL, user, since)
+			if err != nil {
+				log.Printf("  Couldn't get pushed branches for %s, cloning everything: %v", info.CloneURL, err)
+			} else if branches != nil {
+				defaultBranch := cmp.Or(info.DefaultBranch, "HEAD")
+				info.Branches = append([]string{defaultBranch}, branches...)
+			}
 		}
+		plan.plan(info, why)
+	}
+	if mode == "auto" {
+		log.Printf("Fetching %s: %s", rawURL, counts)
 	}
-	return source, allCommits
+	return plan
 }
 
-func getRepo(repoURL string, subjectName string, sourceUser string) (*git.Repository, []*object.Commit) {
-	repo, err := git.Clone(memory.NewStorage(), nil, &git.CloneOptions{
-		URL:        repoURL,
-		Filter:     packp.FilterBlobNone(),
-		NoCheckout: true,
-	})
-	if err != nil {
-		log.Printf("  Failed to clone repository %s: %v", repoURL, err)
-		return nil, nil
+// getRepo clones a repo (or reuses this collection's clone of it, see walk.go) and returns the
+// commits in the window that match. failures are logged, and returned for --resolution
+func getRepo(info RepoInfo, match func(*object.Commit) bool) (*git.Repository, []*object.Commit, error) {
+	repoURL := info.CloneURL
+	f := fetched(info)
+	if f.err != nil {
+		return nil, nil, f.err
 	}
-	
-	head, err := repo.Head()
+	f.walkFrom(repoURL, flags.Since)
+
+	defer timed("iterate", repoURL)()
+	commits, err := f.walk.match(match)
 	if err != nil {
-		log.Printf("  Failed to get HEAD for %s: %v", repoURL, err)
-		return nil, nil
+		log.Printf("  Failed to iterate commits for %s: %v", repoURL, err)
+		noteFailure(err)
+		return nil, nil, err
 	}
 
-	commitIter, err := repo.Log(&git.LogOptions{From: head.Hash()})
+	log.Printf("  Found %d commits in repo %s (%s fetched)\n", len(commits), repoURL, formatBytes(transferred(repoURL)))
+	return f.repo, commits, nil
+}
+
+// fetchRepo clones a repo, or fetches the branches or refspecs asked for, and finds where walks start
+func fetchRepo(info RepoInfo) (*git.Repository, []plumbing.Hash, error) {
+	repoURL := info.CloneURL
+	storage, err := repoStorage(info)
 	if err != nil {
-		log.Printf("  Failed to get commit log for %s: %v", repoURL, err)
-		return nil, nil
+		log.Printf("  Failed to set up storage for %s: %v", repoURL, err)
+		return nil, nil, err
 	}
+	// what the last run fetched, if it's stored, see packstore.go
+	loaded := loadPack(storage, repoURL)
 
-	var commits []*object.Commit
-	err = commitIter.ForEach(func(c *object.Commit) error {
-		if validateCommit(c, subjectName, sourceUser) {
-			commits = append(commits, c)
+	done := timed("clone", repoURL)
+	var repo *git.Repository
+	// where walks start: HEAD for a full clone, each branch otherwise
+	var tips []plumbing.Hash
+	switch {
+	case len(info.Branches) > 0:
+		log.Printf("  Fetching only %v of %s", info.Branches, repoURL)
+		repo, tips, err = fetchBranches(storage, repoURL, info.Branches)
+	case len(flags.RefSpecs) > 0:
+		repo, err = fetchRefSpecs(storage, repoURL, refSpecs())
+		if err == nil {
+			tips, err = fetchedTips(repo)
 		}
-		return nil
-	})
-
+		if err == nil && len(tips) == 0 {
+			err = fmt.Errorf("no refs matched %v", flags.RefSpecs)
+		}
+	default:
+		opts := &git.CloneOptions{
+			URL:        repoURL,
+			Filter:     packp.FilterBlobNone(),
+			NoCheckout: true,
+		}
+		if flags.SingleBranch {
+			// just the default branch, without tags. the ref is only known up front if the api said
+			opts.SingleBranch = true
+			opts.Tags = plumbing.NoTags
+			if info.DefaultBranch != "" {
+				opts.ReferenceName = plumbing.NewBranchReferenceName(info.DefaultBranch)
+			}
+		}
+		repo, err = git.Clone(storage, nil, opts)
+		// a HEAD pointing nowhere fails the clone after the branches came down, see headTips
+		if errors.Is(err, plumbing.ErrReferenceNotFound) && repo != nil {
+			err = nil
+		}
+	}
+	done()
+	if loaded {
+		dropPackRefs(storage)
+	}
+	if errors.Is(err, transport.ErrEmptyRemoteRepository) {
+		log.Printf("  %s is empty, nothing to read", repoURL)
+		return nil, nil, errEmptyRepo
+	}
 	if err != nil {
-		log.Printf("  Failed to iterate commits for %s: %v", repoURL, err)
-		return nil, nil
+		err = cloneError(err)
+		log.Printf("  Failed to clone repository %s: %v", repoURL, err)
+		noteFailure(err)
+		return nil, nil, err
 	}
 
-	log.Printf("  Found %d commits in repo %s\n", len(commits), repoURL)
-	return repo, commits
+	if tips == nil {
+		if tips, err = headTips(repo, repoURL, info.DefaultBranch); errors.Is(err, errEmptyRepo) {
+			log.Printf("  %s has no branches, nothing to read", repoURL)
+			return nil, nil, err
+		} else if err != nil {
+			log.Printf("  Failed to get HEAD for %s: %v", repoURL, err)
+			noteFailure(err)
+			return nil, nil, err
+		}
+	}
+	savePack(storage, repoURL)
+	return repo, tips, nil
+}
+
+// walkRepo walks back from each tip through the --since window and returns the commits that match,
+// after the repo's mailmap is applied. repoURL is only for logs and the mailmap fallback
+func walkRepo(repo *git.Repository, repoURL string, tips []plumbing.Hash, match func(*object.Commit) bool) ([]*object.Commit, error) {
+	return newRepoWalk(repo, repoURL, tips, flags.Since).match(match)
 }
 
+// matched counts commits kept for the subject, scanned every commit walked. scanned bounds the
+// runtime even when the subject barely appears in a repo
+var maxCommitsModes = []string{"matched", "scanned"}
+
+// committer clocks lie a little, and rebases reorder committer dates; don't stop walking the moment
+// one commit looks old
+const pruneSlack = 24 * time.Hour
+
 // i am already filtering old repos (last-pushed-at) via APIs, but not old commits
 // anything older than 1 month gets thrown out
-func validateCommit(commit *object.Commit, subjectName string, githubUsername string) bool {
+// returns whether the commit counts, and the rule that decided it (see audit.go).
+// --strict skips every substring guess: only listed emails and the exact username count
+func validateCommit(commit *object.Commit, subjectName string, githubUsername string, emails []string) (bool, string) {
 
 	if !commit.Committer.When.After(flags.Since) {
-		return false
+		return false, ruleTooOld
 	}
 
 	// TODO: slop ahead
 	authorName := strings.ToLower(commit.Author.Name)
 	authorEmail := strings.ToLower(commit.Author.Email)
+
+	// dependabot[bot], renovate[bot] and friends commit under the subject's name on their own repos
+	if strings.HasSuffix(authorName, "[bot]") || strings.Contains(authorEmail, "[bot]@") {
+		return false, ruleBot
+	}
+
+	for _, email := range emails {
+		if authorEmail == strings.ToLower(email) {
+			return true, ruleEmailListed
+		}
+	}
+
+	if flags.Strict {
+		if githubUsername == "" {
+			return false, ruleNoMatch
+		}
+		username := strings.ToLower(githubUsername)
+		if authorName == username {
+			return true, ruleNameIsUsername
+		}
+		// someone@users.noreply.github.com, or 12345+someone@ for newer accounts
+		noreply := username + "@users.noreply.github.com"
+		if authorEmail == noreply || strings.HasSuffix(authorEmail, "+"+noreply) {
+			return true, ruleNoreply
+		}
+		return false, ruleNoMatch
+	}
 	
 	if strings.Contains(authorName, strings.ToLower(subjectName)) {
-		return true
+		return true, ruleNameSubject
 	}
 	
 	if githubUsername != "" {
 		username := strings.ToLower(githubUsername)
 		
 		if strings.Contains(authorName, username) {
-			return true
+			return true, ruleNameUsername
 		}
 		if strings.Contains(authorEmail, username+"@users.noreply.github.com") {
-			return true
+			return true, ruleNoreply
 		}
 		if strings.HasPrefix(authorEmail, username+"@") {
-			return true
+			return true, ruleEmailPrefix
 		}
 	}
-	return false
+	return false, ruleNoMatch
 }
 
-func buildSubjectFromFlag(userFlag string) Subject {
+func buildSubjectFromFlag(userFlag string) (Subject, error) {
 	parts := strings.Split(userFlag, "@")
 	if len(parts) != 2 {
-		log.Fatalf("Invalid format, expected: name@url1,url2")
+		return Subject{}, errors.New("Invalid format, expected: name@url1,url2")
 	}
 	
 	name := parts[0]
 	urls := strings.Split(parts[1], ",")
 	
-	return getSubject(name, urls)
+	return getSubject(name, urls, nil), nil
 }
 
 type Flags struct {
@@ -245,6 +598,89 @@ type Flags struct {
 	StdOut		bool
 	PlotScatter bool
 	PlotHisto	bool
+	PlotMonthly bool
+	Theme       string
+	Color       string
+	InferTZ     bool
+	UTC         bool
+	SubjectTZ   bool
+	InferLocation bool
+	WeightBy    string
+	Dedup       bool
+	FirstParent bool
+	Profile     string
+	Timings     bool
+	MaxMemPerRepo int
+	MaxRepoSize   int
+	MaxBandwidth  int
+	PackCacheMB   int
+	Risk        bool
+	Watch       time.Duration
+	Serve       string
+	Repo        string
+	TopAuthors  int
+	Mailmap     string
+	Audit       string
+	Strict      bool
+	Sample      int
+	SampleBy    string
+	ScatterAlpha float64
+	Hexbin      bool
+	PlotScale   float64
+	StdOutScatter bool
+	Smooth        int
+	Clock       int
+	PlotHeatmap bool
+	PlotTrend   bool
+	PlotGaps    bool
+	PlotDayBounds bool
+	PlotSeasons   bool
+	PlotDuration bool
+	PlotPunchcard bool
+	PlotWheel   bool
+	PlotAnimate bool
+	WeekStart   string
+	Locale      string
+	Availability bool
+	PlotAvailability bool
+	Automation  string
+	QPS         float64
+	MaxPerHost  int
+	PushedBranches bool
+	SingleBranch bool
+	RefSpecs    []string
+	MaxCommitsPerRepo int
+	MaxCommitsBy string
+	Format      string
+	Local       []string
+	Subjects    []string
+	Record      string
+	Replay      string
+	Sinks       []string
+	Report      string
+	KeepLast    int
+	KeepWeekly  int
+	KeepMonthly int
+	DryRun      bool
+	Online      bool
+	SignKey     string
+	VerifyKey   string
+	Research     bool
+	MinCommits   int
+	ResearchSalt string
+	SubjectsFile   string
+	SubjectsFormat string
+	Run            bool
+	Mode           string
+	APIAboveMB      int
+	APIAboveCommits int
+	Tags            bool
+	SignatureTime   string
+	Estimator       string
+	Nights          string
+	Incremental     bool
+	Resolution      bool
+	Submodules      bool
 } 
 var flags Flags
 
@@ -252,23 +688,355 @@ func main() {
 	pflag.StringVarP(&flags.User, "user", "u", "", "manually supply e.g. user@source1,source2,source3")
 	var age int
 	pflag.IntVarP(&age, "since", "s", 90, "how many days ago to begin tracking (default 90)")
-	pflag.BoolVarP(&flags.Write, "write", "w", true, "write snapshot to disk")
+	pflag.BoolVarP(&flags.Write, "write", "w", false, "write snapshot to disk")
+	var noWrite bool
+	pflag.BoolVar(&noWrite, "no-write", false, "don't write a snapshot, the default; wins over --write, e.g. one from an alias or script")
+	pflag.BoolVar(&flags.Incremental, "incremental", false, "only collect what's new since each source's last clean run, merged with what that run stored")
 	pflag.BoolVarP(&flags.StdOut, "stdout", "o", true, "output sleep schedule estimate")
 	pflag.BoolVarP(&flags.PlotScatter, "plot-scatter", "p", false, "generate scatter plot")
-	pflag.BoolVarP(&flags.PlotHisto, "plot-histo", "h", false, "generate histogram")
+	pflag.BoolVar(&flags.PlotHisto, "plot-histo", false, "generate histogram")
+	pflag.BoolVarP(&flags.PlotMonthly, "plot-monthly", "m", false, "generate a grid of per-month histograms")
+	pflag.StringVar(&flags.Color, "color", "auto", "color the terminal report: auto (a terminal without NO_COLOR), always or never")
+	pflag.StringVar(&flags.Theme, "theme", "dark", "plot colors: dark, light, or custom (from [theme] in sleep.toml)")
+	pflag.BoolVar(&flags.InferTZ, "infer-tz", false, "same as --subject-tz")
+	pflag.BoolVar(&flags.UTC, "utc", false, "analyze every subject in UTC, ignoring configured timezones and commit offsets")
+	pflag.BoolVar(&flags.SubjectTZ, "subject-tz", false, "analyze in each subject's configured timezone, or the inferred one when none is configured")
+	pflag.BoolVar(&flags.InferLocation, "infer-location", false, "print a rough longitude band from the sleep window")
+	pflag.StringVar(&flags.WeightBy, "weight-by", "count", "what a commit is worth: count, lines, or files changed")
+	pflag.BoolVar(&flags.Dedup, "dedup", true, "drop rebased/cherry-picked copies of the same commit")
+	pflag.BoolVar(&flags.FirstParent, "first-parent", false, "only walk the first parent of merges")
+	pflag.StringVar(&flags.Profile, "profile", "", "write a cpu, mem, or trace profile of the run")
+	pflag.BoolVar(&flags.Timings, "timings", false, "print api/clone/iterate time and bytes fetched per repo")
+	pflag.IntVar(&flags.MaxMemPerRepo, "max-mem-per-repo", 500, "clone repos bigger than this many MB to a temp dir instead of memory, 0 to never")
+	pflag.IntVar(&flags.MaxRepoSize, "max-repo-size", 2000, "skip cloning repos bigger than this many MB (or ask, from a terminal), 0 for no limit")
+	pflag.IntVar(&flags.MaxBandwidth, "max-bandwidth", 0, "stop cloning once a run has downloaded this many MB, deferring the remaining repos, 0 for no limit")
+	pflag.IntVar(&flags.PackCacheMB, "pack-cache-mb", 1000, "keep what each repo's fetch brought down, up to this many MB in all, so the next fetch only transfers what's new, 0 to not")
+	pflag.BoolVar(&flags.Risk, "risk", false, "print overwork indicators")
+	pflag.DurationVar(&flags.Watch, "watch", 0, "keep running, re-collecting every interval (e.g. 24h)")
+	pflag.StringVar(&flags.Serve, "serve", "", "serve the JSON API on this address (e.g. :8080)")
+	pflag.StringVar(&flags.Repo, "repo", "", "analyze every author of one repo instead of subjects (e.g. github.com/foo/bar)")
+	pflag.IntVar(&flags.TopAuthors, "top-authors", 0, "with --repo, run the full report on the N most active authors")
+	pflag.StringVar(&flags.Mailmap, "mailmap", "", "extra mailmap file applied to every repo, on top of its own .mailmap")
+	pflag.StringVar(&flags.Audit, "audit", "", "write every commit considered, and the rule that kept or dropped it, to this file")
+	pflag.BoolVar(&flags.Strict, "strict", false, "only count exact email/username matches, no name substring guessing")
+	pflag.IntVar(&flags.Sample, "sample", 0, "keep at most N commits per subject, for very prolific subjects")
+	pflag.StringVar(&flags.SampleBy, "sample-by", "week", "how --sample picks: uniform, or week to keep each week's share")
+	pflag.Float64Var(&flags.ScatterAlpha, "scatter-alpha", 0, "scatter point opacity 0-1, 0 picks one from the commit count")
+	pflag.BoolVar(&flags.Hexbin, "hexbin", false, "draw the scatter plot as hexagonal density bins")
+	pflag.Float64Var(&flags.PlotScale, "plot-scale", 1, "scale every plot's canvas, e.g. 2 for twice the width and height")
+	pflag.BoolVar(&flags.StdOutScatter, "stdout-scatter", false, "draw the scatter plot in the terminal")
+	pflag.IntVar(&flags.Smooth, "smooth", 0, "draw the terminal histogram's bars as a moving average over this many hours (odd), 0 for raw counts")
+	pflag.IntVar(&flags.Clock, "clock", 24, "show times of day on a 24 or 12 hour clock")
+	pflag.BoolVar(&flags.PlotHeatmap, "plot-heatmap", false, "generate a weekday x hour heatmap")
+	pflag.BoolVar(&flags.PlotPunchcard, "plot-punchcard", false, "generate a weekday x hour punch card, bubbles sized by commits")
+	pflag.BoolVar(&flags.PlotTrend, "plot-trend", false, "generate each week's sleep window over time, marking probable travel")
+	pflag.BoolVar(&flags.PlotWheel, "plot-wheel", false, "generate a year wheel: day of year around the circle, time of day outwards")
+	pflag.BoolVar(&flags.PlotAnimate, "plot-animate", false, "generate a gif of the hourly histogram over a rolling four weeks")
+	pflag.BoolVar(&flags.PlotDuration, "plot-duration", false, "generate each inferred night's sleep duration over time, with a rolling average")
+	pflag.BoolVar(&flags.PlotGaps, "plot-gaps", false, "generate a log-scale histogram of the time between commits")
+	pflag.BoolVar(&flags.PlotDayBounds, "plot-day-bounds", false, "generate the hours of each day's first and last commit")
+	pflag.BoolVar(&flags.PlotSeasons, "plot-seasons", false, "generate a histogram per season with its sleep window")
+	pflag.StringVar(&flags.WeekStart, "week-start", "monday", "first day of the week: monday or sunday")
+	pflag.StringVar(&flags.Locale, "locale", "en", "language for day names: en, de, fr, es, it, pt, nl, sv, pl, ja")
+	pflag.BoolVar(&flags.Availability, "availability", false, "print a subjects x hours matrix of who's active, awake or asleep")
+	pflag.BoolVar(&flags.PlotAvailability, "plot-availability", false, "generate the availability matrix as a png")
+	pflag.StringVar(&flags.Automation, "automation", "flag", "commits that look cron-driven: flag, exclude, or off")
+	pflag.Float64Var(&flags.QPS, "qps", 5, "max requests started per second per host (api calls and clones), 0 for no limit")
+	pflag.IntVar(&flags.MaxPerHost, "max-per-host", 4, "max requests in flight per host")
+	pflag.BoolVar(&flags.Resolution, "resolution", false, "print which repos each subject's sources came down to: selected, skipped or failed, and why")
+	pflag.BoolVar(&flags.Submodules, "submodules", false, "also read the submodules of cloned repos that live under the same account (one more clone each)")
+	pflag.BoolVar(&flags.PushedBranches, "pushed-branches", false, "on github, fetch only the default branch and branches the subject pushed to")
+	pflag.StringVar(&flags.Mode, "mode", "clone", "how to get commits: clone, api to list them through github's api without cloning, or auto to pick per repo by size")
+	pflag.IntVar(&flags.APIAboveMB, "api-above-mb", 500, "with --mode auto, list repos bigger than this many MB through the api, 0 for no limit")
+	pflag.IntVar(&flags.APIAboveCommits, "api-above-commits", 0, "with --mode auto, also list repos with more commits than this through the api (one request per repo to count), 0 to not count")
+	pflag.BoolVar(&flags.Tags, "tags", false, "count annotated tags (and github releases, with --mode api) as activity alongside commits")
+	pflag.StringVar(&flags.SignatureTime, "signature-time", "check", "gpg-signed commits: check their dates against the signatures, prefer the signature times, or off")
+	pflag.StringVar(&flags.Nights, "nights", "", "print every night inferred, one per line: text, csv or json")
+	pflag.StringVar(&flags.Estimator, "estimator", "threshold", "how the sleep window is found: threshold, kde, cosinor or hmm")
+	pflag.BoolVar(&flags.SingleBranch, "single-branch", false, "clone only the default branch, without tags")
+	pflag.StringSliceVar(&flags.Sinks, "sink", []string{"stdout", "files"}, "where results go: stdout, files, http=URL, sqlite=PATH (repeatable)")
+	pflag.IntVar(&flags.KeepLast, "keep-last", 0, "prune snapshots down to the newest N per subject, 0 for no limit")
+	pflag.IntVar(&flags.KeepWeekly, "keep-weekly", 0, "when pruning, also keep the newest snapshot of each of the last N weeks")
+	pflag.IntVar(&flags.KeepMonthly, "keep-monthly", 0, "when pruning, also keep the newest snapshot of each of the last N months")
+	pflag.BoolVar(&flags.Online, "online", false, "with lint, also check that every source's forge or git server answers")
+	pflag.BoolVar(&flags.DryRun, "dry-run", false, "with snapshots prune, list what would be removed without removing it")
+	pflag.StringVar(&flags.SignKey, "sign-key", "", "sign snapshots and exports with this ed25519 key (see sleep keygen)")
+	pflag.StringVar(&flags.VerifyKey, "verify-key", "", "require snapshots and imports to be signed by this key (public or private)")
+	pflag.BoolVar(&flags.Research, "research", false, "with export, write an anonymized dataset of binned counts instead of events")
+	pflag.IntVar(&flags.MinCommits, "min-commits", 50, "with export --research, leave out subjects with fewer commits")
+	pflag.StringVar(&flags.ResearchSalt, "research-salt", "", "with export --research, salt for subject ids, to keep them stable across exports (default random)")
+	pflag.StringVar(&flags.Report, "report", "", "also write a report per subject next to the plots: md or pdf")
+	pflag.StringVar(&flags.Record, "record", "", "save every http response (api calls and clones) to this dir")
+	pflag.StringVar(&flags.Replay, "replay", "", "answer http requests from a --record dir instead of the network")
+	pflag.StringVar(&flags.SubjectsFile, "subjects-file", "subjects.toml", "where subjects are listed: a toml, csv or json file, or - for stdin")
+	pflag.StringVar(&flags.SubjectsFormat, "subjects-format", "", "toml, csv or json, when --subjects-file's extension doesn't say (stdin defaults to toml)")
+	pflag.BoolVar(&flags.Run, "run", false, "with org, analyze the members instead of printing a subjects file")
+	pflag.StringSliceVar(&flags.Subjects, "subject", nil, "only build these subjects from subjects.toml")
+	pflag.StringSliceVar(&flags.Local, "local", nil, "analyze your own commits in the git repos under these dirs, no network")
+	pflag.StringVar(&flags.Format, "format", "text", "stdout format: text, or tsv for shell pipelines")
+	pflag.IntVar(&flags.MaxCommitsPerRepo, "max-commits-per-repo", 0, "stop walking a repo after its newest N commits, 0 for no limit")
+	pflag.StringVar(&flags.MaxCommitsBy, "max-commits-by", "matched", "what --max-commits-per-repo counts: matched or scanned commits")
+	pflag.StringSliceVar(&flags.RefSpecs, "refspec", nil, "fetch only these refspecs instead of cloning (e.g. +refs/heads/main:refs/remotes/origin/main)")
 	pflag.Parse()
-	flags.Since = time.Now().AddDate(0, 0, -age)
 
+	// --no-write wins, wherever the --write came from
+	if noWrite {
+		flags.Write = false
+	}
+	if !slices.Contains(weightModes, flags.WeightBy) {
+		log.Fatalf("Invalid --weight-by %q, expected one of %v", flags.WeightBy, weightModes)
+	}
+
+	flags.WeekStart = strings.ToLower(flags.WeekStart)
+	if _, ok := weekStarts[flags.WeekStart]; !ok {
+		log.Fatalf("Invalid --week-start %q, expected monday or sunday", flags.WeekStart)
+	}
+	if _, ok := dayNames[flags.Locale]; !ok {
+		log.Fatalf("Unknown --locale %q", flags.Locale)
+	}
+	if !slices.Contains(clockModes, flags.Clock) {
+		log.Fatalf("Invalid --clock %d, expected one of %v", flags.Clock, clockModes)
+	}
+	if !slices.Contains(automationModes, flags.Automation) {
+		log.Fatalf("Invalid --automation %q, expected one of %v", flags.Automation, automationModes)
+	}
+	if !slices.Contains(sampleModes, flags.SampleBy) {
+		log.Fatalf("Invalid --sample-by %q, expected one of %v", flags.SampleBy, sampleModes)
+	}
+	if !slices.Contains(formats, flags.Format) {
+		log.Fatalf("Invalid --format %q, expected one of %v", flags.Format, formats)
+	}
+	if !slices.Contains(maxCommitsModes, flags.MaxCommitsBy) {
+		log.Fatalf("Invalid --max-commits-by %q, expected one of %v", flags.MaxCommitsBy, maxCommitsModes)
+	}
+	for _, spec := range refSpecs() {
+		if err := spec.Validate(); err != nil {
+			log.Fatalf("Invalid --refspec %q: %v", spec, err)
+		}
+	}
+	if err := validateSinks(flags.Sinks); err != nil {
+		log.Fatal(err)
+	}
+	if flags.Report != "" && !slices.Contains(reportFormats, flags.Report) {
+		log.Fatalf("Invalid --report %q, expected one of %v", flags.Report, reportFormats)
+	}
+	if flags.Nights != "" && !slices.Contains(nightLogFormats, flags.Nights) {
+		log.Fatalf("Invalid --nights %q, expected one of %v", flags.Nights, nightLogFormats)
+	}
+	if !slices.Contains(colorModes, flags.Color) {
+		log.Fatalf("Invalid --color %q, expected one of %v", flags.Color, colorModes)
+	}
+	useColor = resolveColor(flags.Color)
+	if !slices.Contains(estimatorNames, flags.Estimator) {
+		log.Fatalf("Invalid --estimator %q, expected one of %v", flags.Estimator, estimatorNames)
+	}
+	if !slices.Contains(signatureTimeModes, flags.SignatureTime) {
+		log.Fatalf("Invalid --signature-time %q, expected one of %v", flags.SignatureTime, signatureTimeModes)
+	}
+	if !slices.Contains(sourceModes, flags.Mode) {
+		log.Fatalf("Invalid --mode %q, expected one of %v", flags.Mode, sourceModes)
+	}
+	if flags.SubjectsFormat != "" && !slices.Contains(subjectsFormats, flags.SubjectsFormat) {
+		log.Fatalf("Invalid --subjects-format %q, expected one of %v", flags.SubjectsFormat, subjectsFormats)
+	}
+	if flags.MinCommits < 1 {
+		log.Fatal("--min-commits must be at least 1")
+	}
+	if flags.Smooth < 0 || flags.Smooth > 23 || (flags.Smooth > 1 && flags.Smooth%2 == 0) {
+		log.Fatalf("--smooth must be an odd number of hours under 24, got %d", flags.Smooth)
+	}
+	if flags.Record != "" && flags.Replay != "" {
+		log.Fatal("--record and --replay don't mix")
+	}
+	flags.SubjectTZ = flags.SubjectTZ || flags.InferTZ
+	if flags.UTC && flags.SubjectTZ {
+		log.Fatal("--utc and --subject-tz don't mix")
+	}
+	if flags.TopAuthors > 0 && flags.Repo == "" {
+		log.Fatal("--top-authors only makes sense with --repo")
+	}
+
+	// registered first so it runs last, after every other deferred cleanup
+	defer func() {
+		if code := exitCode(); code != 0 {
+			os.Exit(code)
+		}
+	}()
+
+	stopProfile := startProfile(flags.Profile)
+	defer stopProfile()
+
+	defer cleanupTempDirs()
+	loadConfig()
+	switch {
+	case flags.Replay != "":
+		// answered from disk, nothing to be polite to
+		httpTransport = countTransfers(newReplayTransport(flags.Replay))
+	case flags.Record != "":
+		httpTransport = countTransfers(newLimitedTransport(newRecordingTransport(flags.Record, httpTransport)))
+	default:
+		httpTransport = countTransfers(newLimitedTransport(httpTransport))
+	}
+	installTransport()
+
+	if flags.Mailmap != "" {
+		globalMailmap = loadMailmapFile(flags.Mailmap)
+	}
+	var err error
+	if theme, err = resolveTheme(flags.Theme, config.Theme); err != nil {
+		log.Fatal(err)
+	}
+	if priors, err = resolvePriors(config.Priors); err != nil {
+		log.Fatal(err)
+	}
+
+	loadKeys()
+
+	// subcommands, e.g. `sleep overlap a b`
+	if args := pflag.Args(); len(args) > 0 {
+		flags.Since = time.Now().AddDate(0, 0, -age)
+		switch args[0] {
+		case "overlap":
+			runOverlap(args[1:])
+		case "export":
+			runExport(args[1:])
+		case "import":
+			runImport(args[1:])
+		case "snapshots":
+			runSnapshots(args[1:])
+		case "org":
+			runOrg(args[1:])
+		case "keygen":
+			runKeygen(args[1:])
+		case "fitness":
+			runFitness(args[1:])
+		case "eval":
+			runEval(args[1:])
+		case "tui":
+			runTUI(args[1:])
+		case "lint":
+			runLint(args[1:])
+		case "init":
+			runInit(args[1:])
+		default:
+			log.Fatalf("Unknown command %q", args[0])
+		}
+		return
+	}
+
+	if flags.Repo != "" {
+		flags.Since = time.Now().AddDate(0, 0, -age)
+		stopAudit := startAudit(flags.Audit)
+		analyzeRepo(flags.Repo)
+		stopAudit()
+		if flags.Timings {
+			printTimings()
+		}
+		return
+	}
+
+	run := func() []Subject {
+		collectMu.Lock()
+		defer collectMu.Unlock()
+		flags.Since = time.Now().AddDate(0, 0, -age)
+		stopAudit := startAudit(flags.Audit)
+		subjects := collect(flags.Subjects)
+		stopAudit()
+		output(expandDerived(subjects), flags)
+		if flags.Availability || flags.PlotAvailability {
+			reportAvailability(subjects)
+		}
+		if flags.Timings {
+			printTimings()
+		}
+		return subjects
+	}
+
+	if flags.Watch == 0 && flags.Serve == "" {
+		run()
+		return
+	}
+	// watch mode: the cronjob, minus cron
+	var server *apiServer
+	for {
+		subjects := run()
+		if flags.Serve != "" {
+			if server == nil {
+				server = serve(flags.Serve, subjects)
+			} else {
+				server.refresh(subjects)
+			}
+			if flags.Watch == 0 {
+				select {}
+			}
+		}
+		sendDueDigests(subjects)
+		cleanupTempDirs()
+		timings = nil
+		resetFailures()
+		log.Printf("Next run in %s", flags.Watch)
+		time.Sleep(flags.Watch)
+	}
+}
+
+// collect builds every subject (or just the named ones) from scratch: resolving sources, cloning,
+// matching, dedup and weighting
+func collect(only []string) []Subject {
+	subjects, err := collectSubjects(only)
+	if err != nil {
+		log.Fatal(err)
+	}
+	return subjects
+}
+
+// collectSubjects is collect with what's wrong with the subjects returned rather than fatal, for the
+// tui, which has the terminal to put back first
+func collectSubjects(only []string) ([]Subject, error) {
+	fetchedRepos = make(map[string]*fetchedRepo)
+	defer func() { fetchedRepos = nil }()
+	startBandwidth()
 	var subjects []Subject
-	if flags.User != "" {
-		subject := buildSubjectFromFlag(flags.User)
+	var err error
+	if len(flags.Local) > 0 {
+		subject, err := localSubject(flags.Local)
+		if err != nil {
+			return nil, err
+		}
+		subjects = []Subject{subject}
+	} else if flags.User != "" {
+		subject, err := buildSubjectFromFlag(flags.User)
+		if err != nil {
+			return nil, err
+		}
 		subjects = []Subject{subject}
 	} else {
-		subjects = parseSubjects()
+		if subjects, err = parseSubjects(only); err != nil {
+			return nil, err
+		}
 		if len(subjects) == 0 {
-			log.Fatal("No subjects found")
+			return nil, errors.New("No subjects found")
+		}
+	}
+	prepareSubjects(subjects)
+	if used := bandwidthUsed(); used > 0 {
+		log.Printf("Downloaded %s this run", formatBytes(used))
+	}
+	return subjects, nil
+}
+
+// prepareSubjects is everything done to collected subjects before they're analyzed: dedup, time
+// basis, signature times, automation, sampling and weights. the cli and the server's POST /analyze
+// both go through it, so the same sources give the same answer
+func prepareSubjects(subjects []Subject) {
+	for i := range subjects {
+		if flags.Dedup {
+			dedupByPatchID(&subjects[i])
 		}
+		applyTimeBasis(&subjects[i])
+		checkSignatureTimes(&subjects[i], flags.SignatureTime)
+		handleAutomation(&subjects[i], flags.Automation)
+		sampleSubject(&subjects[i], flags.Sample, flags.SampleBy)
 	}
-	output(subjects, flags)
+	applyWeights(subjects, flags.WeightBy)
 }
 
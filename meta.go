@@ -0,0 +1,161 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"os"
+	"path/filepath"
+	"runtime/debug"
+	"strings"
+	"time"
+
+	"gonum.org/v1/plot"
+	"gonum.org/v1/plot/font"
+	"gonum.org/v1/plot/vg"
+	"gonum.org/v1/plot/vg/draw"
+	_ "gonum.org/v1/plot/vg/vgimg" // png, jpg and tiff for NewFormattedCanvas
+)
+
+// plots get passed around without the run that made them. every plot has a footer saying whose
+// commits it shows, how many, since when and which build drew it, and pngs carry the same in tEXt
+// chunks, which image viewers show and `exiftool` or `identify -verbose` read back
+
+type plotMeta struct {
+	Subject string
+	Commits int // 0 for plots that aren't one subject's commits
+	Since   time.Time
+}
+
+func metaFor(subject *Subject) plotMeta {
+	return plotMeta{Subject: subject.Name, Commits: len(subject.Commits), Since: flags.Since}
+}
+
+// buildVersion is the module version, or the vcs revision for a build from a checkout
+func buildVersion() string {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return "unknown"
+	}
+	if v := info.Main.Version; v != "" && v != "(devel)" {
+		return v
+	}
+	var revision, modified string
+	for _, setting := range info.Settings {
+		switch setting.Key {
+		case "vcs.revision":
+			revision = setting.Value
+		case "vcs.modified":
+			if setting.Value == "true" {
+				modified = "-dirty"
+			}
+		}
+	}
+	if revision == "" {
+		return "devel"
+	}
+	return revision[:min(len(revision), 12)] + modified
+}
+
+func (m plotMeta) footer() string {
+	parts := []string{m.Subject}
+	if m.Commits > 0 {
+		parts = append(parts, fmt.Sprintf("%d commits", m.Commits))
+	}
+	parts = append(parts,
+		"since "+m.Since.Format(time.DateOnly),
+		"generated "+time.Now().Format(time.DateOnly),
+		"sleep "+buildVersion())
+	return strings.Join(parts, " · ")
+}
+
+// textChunks are keyword, text pairs; the first few keywords are the png spec's own
+func (m plotMeta) textChunks() [][2]string {
+	chunks := [][2]string{
+		{"Software", "sleep " + buildVersion()},
+		{"Creation Time", time.Now().UTC().Format(time.RFC1123)},
+		{"Subject", m.Subject},
+		{"Since", m.Since.Format(time.DateOnly)},
+	}
+	if m.Commits > 0 {
+		chunks = append(chunks, [2]string{"Commits", fmt.Sprint(m.Commits)})
+	}
+	return chunks
+}
+
+const footerHeight = 14 // points
+
+// drawFooter fills c with the theme background, writes the footer along its bottom edge, and
+// returns the part of c above it for the plot
+func drawFooter(c draw.Canvas, m plotMeta) draw.Canvas {
+	c.SetColor(theme.Background)
+	c.Fill(c.Rectangle.Path())
+	sty := draw.TextStyle{
+		Color:   withAlpha(theme.Foreground, 0.6),
+		Font:    font.From(plot.DefaultFont, 8),
+		Handler: plot.DefaultTextHandler,
+		XAlign:  draw.XRight,
+	}
+	c.FillText(sty, vg.Point{X: c.Max.X - 4, Y: c.Min.Y + 4}, m.footer())
+	return draw.Crop(c, 0, 0, footerHeight, 0)
+}
+
+// savePlot is p.Save with the footer and, for pngs, the tEXt chunks
+func savePlot(p *plot.Plot, width, height vg.Length, path string, m plotMeta) error {
+	c, err := draw.NewFormattedCanvas(width, height, strings.TrimPrefix(filepath.Ext(path), "."))
+	if err != nil {
+		return err
+	}
+	p.Draw(drawFooter(draw.New(c), m))
+	return writeCanvas(c, path, m)
+}
+
+func writeCanvas(c vg.CanvasWriterTo, path string, m plotMeta) error {
+	var buf bytes.Buffer
+	if _, err := c.WriteTo(&buf); err != nil {
+		return err
+	}
+	data := buf.Bytes()
+	if strings.EqualFold(filepath.Ext(path), ".png") {
+		var err error
+		if data, err = withTextChunks(data, m.textChunks()); err != nil {
+			return err
+		}
+	}
+	return os.WriteFile(path, data, 0o644)
+}
+
+// withTextChunks inserts a tEXt chunk per pair right after the png's IHDR, which is always first
+func withTextChunks(png []byte, chunks [][2]string) ([]byte, error) {
+	const signature = 8
+	if len(png) < signature+8 || string(png[signature+4:signature+8]) != "IHDR" {
+		return nil, fmt.Errorf("not a png")
+	}
+	ihdrEnd := signature + 12 + int(binary.BigEndian.Uint32(png[signature:]))
+	if ihdrEnd > len(png) {
+		return nil, fmt.Errorf("truncated png")
+	}
+
+	var out bytes.Buffer
+	out.Write(png[:ihdrEnd])
+	for _, chunk := range chunks {
+		// tEXt is latin-1, anything beyond it becomes ?
+		text := strings.Map(func(r rune) rune {
+			if r > 0xff {
+				return '?'
+			}
+			return r
+		}, chunk[1])
+		data := []byte(chunk[0] + "\x00")
+		for _, r := range text {
+			data = append(data, byte(r))
+		}
+		binary.Write(&out, binary.BigEndian, uint32(len(data)))
+		typed := append([]byte("tEXt"), data...)
+		out.Write(typed)
+		binary.Write(&out, binary.BigEndian, crc32.ChecksumIEEE(typed))
+	}
+	out.Write(png[ihdrEnd:])
+	return out.Bytes(), nil
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"fmt"
+)
+
+// siestas and split sleep: a second, shorter trough away from the main one. a nap isn't as quiet
+// as a night, so the bar is relative, an hour at most a quarter as busy as the subject's average
+// waking hour. any afternoon can look a bit slow by chance, so it only counts as biphasic if the
+// same trough turns up in most bootstrap resamples too
+
+const (
+	minNapHours = 1
+	maxNapHours = 4
+	// share of a waking hour's average activity a nap hour may have
+	napQuietShare = 0.25
+	// share of resamples that must find the nap for it to be reported
+	minNapRobustness = 0.6
+)
+
+type NapWindow struct {
+	Start int `json:"start"`
+	End   int `json:"end"` // exclusive
+	Hours int `json:"hours"`
+	// share of bootstrap resamples that found a nap overlapping this one
+	Robustness float64 `json:"robustness"`
+}
+
+func (n NapWindow) contains(hour int) bool {
+	return (hour-n.Start+24)%24 < n.Hours
+}
+
+// detectNap looks for a robust secondary trough in counts outside the main window w
+func detectNap(counts []int, w SleepWindow) *NapWindow {
+	if !w.Found {
+		return nil
+	}
+	nap, ok := findNap(counts, w)
+	if !ok {
+		return nil
+	}
+	var agree int
+	resampleCounts(counts, func(resampled []int) {
+		other, ok := findNap(resampled, w)
+		if !ok {
+			return
+		}
+		for h := range 24 {
+			if nap.contains(h) && other.contains(h) {
+				agree++
+				return
+			}
+		}
+	})
+	nap.Robustness = float64(agree) / bootstrapResamples
+	if nap.Robustness < minNapRobustness {
+		return nil
+	}
+	return &nap
+}
+
+// findNap is the longest quiet run of hours that doesn't touch the main window. an hour of
+// activity has to separate them, otherwise it's just a longer night
+func findNap(counts []int, w SleepWindow) (NapWindow, bool) {
+	var awakeTotal int
+	for hour, count := range counts {
+		if !w.contains(hour) {
+			awakeTotal += count
+		}
+	}
+	awakeHours := 24 - w.Hours
+	if awakeHours <= 2 || awakeTotal == 0 {
+		return NapWindow{}, false
+	}
+	quiet := napQuietShare * float64(awakeTotal) / float64(awakeHours)
+
+	// walk the waking day from just after waking to just before bed, so runs never wrap into the night
+	var best, run NapWindow
+	for i := 1; i < awakeHours-1; i++ {
+		hour := (w.End + i) % 24
+		if float64(counts[hour]) <= quiet {
+			if run.Hours == 0 {
+				run.Start = hour
+			}
+			run.Hours++
+			if run.Hours > best.Hours {
+				best = run
+			}
+		} else {
+			run = NapWindow{}
+		}
+	}
+	if best.Hours < minNapHours || best.Hours > maxNapHours {
+		return NapWindow{}, false
+	}
+	best.End = (best.Start + best.Hours) % 24
+	return best, true
+}
+
+func (n NapWindow) String() string {
+	return fmt.Sprintf("nap ~%s", hourRange(n.Start, n.End))
+}
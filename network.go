@@ -0,0 +1,25 @@
+package main
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/go-git/go-git/v5/plumbing/transport"
+	githttp "github.com/go-git/go-git/v5/plumbing/transport/http"
+)
+
+// every byte sleep pulls over http, forge API calls and clones alike, goes through this one
+// RoundTripper. swapping it is how a harness feeds in recorded responses instead of the network
+
+var httpTransport http.RoundTripper = http.DefaultTransport
+
+func newHTTPClient(timeout time.Duration) *http.Client {
+	return &http.Client{Transport: httpTransport, Timeout: timeout}
+}
+
+// installTransport points go-git's http(s) clones at httpTransport. call it again after swapping
+func installTransport() {
+	client := githttp.NewClient(&http.Client{Transport: httpTransport})
+	transport.Register("http", client)
+	transport.Register("https", client)
+}
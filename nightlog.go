@@ -0,0 +1,96 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+)
+
+// --nights prints the nights inferred one by one (see nights.go) rather than the average: date,
+// sleep start and end on the subject's clock, how long, and how many commits that night's estimate
+// rests on. text is a table per subject, csv and json are one row per night across every subject,
+// for spreadsheets and scripts, and replace the stdout report so the output parses
+
+var nightLogFormats = []string{"text", "csv", "json"}
+
+// nightRow is one line of the log
+type nightRow struct {
+	Subject string `json:"subject"`
+	Date    string `json:"date"`
+	Start   string `json:"start"`
+	End     string `json:"end"`
+	Minutes int    `json:"minutes"`
+	Events  int    `json:"events"`
+}
+
+func nightRows(subject string, nights []Night) []nightRow {
+	rows := make([]nightRow, 0, len(nights))
+	for _, n := range nights {
+		rows = append(rows, nightRow{
+			Subject: subject,
+			Date:    n.Date.Format(time.DateOnly),
+			Start:   n.Start.Format(time.RFC3339),
+			End:     n.End.Format(time.RFC3339),
+			Minutes: int(n.Duration().Minutes()),
+			Events:  n.Events,
+		})
+	}
+	return rows
+}
+
+type nightLogSink struct {
+	format string
+	csv    *csv.Writer
+	rows   []nightRow
+}
+
+func (s *nightLogSink) String() string { return "nights" }
+
+func (s *nightLogSink) Write(subject *Subject, a Analysis) error {
+	switch s.format {
+	case "csv":
+		if s.csv == nil {
+			s.csv = csv.NewWriter(os.Stdout)
+			s.csv.Write([]string{"subject", "date", "start", "end", "minutes", "events"})
+		}
+		for _, r := range nightRows(subject.Name, a.Nights) {
+			s.csv.Write([]string{r.Subject, r.Date, r.Start, r.End, fmt.Sprint(r.Minutes), fmt.Sprint(r.Events)})
+		}
+		s.csv.Flush()
+		return s.csv.Error()
+	case "json":
+		// one array for the whole run, written at Close
+		s.rows = append(s.rows, nightRows(subject.Name, a.Nights)...)
+		return nil
+	}
+	printNightLog(subject.Name, a.Nights)
+	return nil
+}
+
+func (s *nightLogSink) Close() error {
+	if s.format != "json" {
+		return nil
+	}
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	if s.rows == nil {
+		s.rows = []nightRow{}
+	}
+	return enc.Encode(s.rows)
+}
+
+func printNightLog(name string, nights []Night) {
+	fmt.Printf("\n=== Nights: %s ===\n", name)
+	if len(nights) == 0 {
+		fmt.Println("No nights with commits on both sides of the sleep")
+		return
+	}
+	fmt.Printf("%-10s  %-3s  %-17s  %-8s  %s\n", "date", "day", "asleep", "duration", "commits")
+	for _, n := range nights {
+		fmt.Printf("%-10s  %-3s  %-17s  %-8s  %d\n", n.Date.Format(time.DateOnly), n.Date.Format("Mon"),
+			clockString(float64(secondsOfDay(n.Start)))+"-"+clockString(float64(secondsOfDay(n.End))),
+			formatSpread(n.Duration().Minutes()), n.Events)
+	}
+}
@@ -0,0 +1,205 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"time"
+)
+
+// the window is one average night. this finds each night: an hmm over every night's commits in 15
+// minute bins, noon to noon, with the same two states as hmm.go. awake, the subject commits at the
+// rate they usually do at that time of day; asleep, almost never. falling asleep is likely near the
+// window's onset and unlikely elsewhere, and likewise waking near its end, so a quiet hour at 3pm
+// isn't a nap but a 1am commit pushes that night's sleep later. hours without commits lean a little
+// toward what the window says, since an hour nobody ever commits in says nothing by itself. a night
+// only counts with commits on both sides of the sleep, since otherwise one of its ends is just the
+// prior
+
+const (
+	nightBin     = 15 * time.Minute
+	binsPerNight = int(24 * time.Hour / nightBin)
+	// how far from the window's onset or end falling asleep or waking up is likely
+	transitionBand = 3 * time.Hour
+	likelySwitch   = 0.2
+	unlikelySwitch = 0.002
+	// commits per bin asleep: scheduled jobs, a wrong clock, a 4am bug
+	asleepRate = 0.002
+	// commits per bin awake are never taken to be lower than this, or any quiet hour is sleep
+	minAwakeRate = 0.02
+	// how much a quiet bin leans toward whatever the window says for its time of day
+	windowAgreement = 0.6
+)
+
+// Night is one night's inferred sleep
+type Night struct {
+	Date  time.Time `json:"date"` // the evening it starts
+	Start time.Time `json:"start"`
+	End   time.Time `json:"end"`
+	// commits from the noon before to the noon after, the evidence it rests on
+	Events int `json:"events"`
+}
+
+func (n Night) Duration() time.Duration {
+	return n.End.Sub(n.Start)
+}
+
+// nightOf is the evening a time's night starts: before noon belongs to the day before
+func nightOf(t time.Time) time.Time {
+	e := t.Add(-12 * time.Hour)
+	return time.Date(e.Year(), e.Month(), e.Day(), 0, 0, 0, 0, t.Location())
+}
+
+// binOfDay is which 15 minutes of the day t falls in
+func binOfDay(t time.Time) int {
+	return (t.Hour()*60 + t.Minute()) / int(nightBin.Minutes())
+}
+
+// inferNights is each night's sleep, in order, given the times (on the subject's clock) and the
+// window they average to
+func inferNights(times []time.Time, w SleepWindow) []Night {
+	if !w.Found || len(times) == 0 {
+		return nil
+	}
+	// without a timezone the commits carry whatever offsets they were made at. their clock readings
+	// are what counts, so they're put on one clock (utc) as read
+	loc := times[0].Location()
+	for _, t := range times {
+		if t.Location() != loc {
+			loc = time.UTC
+			break
+		}
+	}
+	// the awake rate at each time of day is the subject's usual, over the days they were around
+	awake := make([]float64, binsPerNight)
+	days := make(map[time.Time]bool)
+	byNight := make(map[time.Time][]time.Time)
+	for _, t := range times {
+		t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), 0, loc)
+		awake[binOfDay(t)]++
+		days[time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())] = true
+		night := nightOf(t)
+		byNight[night] = append(byNight[night], t)
+	}
+	for i := range awake {
+		awake[i] = math.Max(awake[i]/float64(len(days)), minAwakeRate)
+	}
+	near := func(bin int, hour int) bool {
+		d := math.Abs(float64(bin)*nightBin.Minutes() - float64(hour*60))
+		return math.Min(d, 24*60-d) <= transitionBand.Minutes()
+	}
+	switchLog := func(bin, to int) float64 {
+		p := unlikelySwitch
+		if to == 0 && near(bin, w.Start) || to == 1 && near(bin, w.End) {
+			p = likelySwitch
+		}
+		return math.Log(p)
+	}
+
+	var nights []Night
+	for date, events := range byNight {
+		noon := time.Date(date.Year(), date.Month(), date.Day(), 12, 0, 0, 0, date.Location())
+		counts := make([]float64, binsPerNight)
+		clock := make([]int, binsPerNight)
+		for i := range clock {
+			clock[i] = binOfDay(noon.Add(time.Duration(i) * nightBin))
+		}
+		for _, t := range events {
+			if i := int(t.Sub(noon) / nightBin); i >= 0 && i < binsPerNight {
+				counts[i]++
+			}
+		}
+		asleep := viterbiPath(binsPerNight, [2]float64{math.Log(0.01), math.Log(0.99)},
+			func(i, s int) float64 {
+				// a quiet bin is only weak evidence either way, so the window leans it
+				inside := w.contains(clock[i] * int(nightBin.Minutes()) / 60)
+				if s == 0 {
+					return poissonLog(counts[i], asleepRate) + windowLean(inside)
+				}
+				return poissonLog(counts[i], awake[clock[i]]) + windowLean(!inside)
+			},
+			// staying costs nothing, so the commits alone decide where in the band the switch is
+			func(i, from, to int) float64 {
+				if from == to {
+					return 0
+				}
+				return switchLog(clock[i], to)
+			})
+
+		start, length := longestRun(asleep)
+		if length == 0 {
+			continue
+		}
+		n := Night{
+			Date:   date,
+			Start:  noon.Add(time.Duration(start) * nightBin),
+			End:    noon.Add(time.Duration(start+length) * nightBin),
+			Events: len(events),
+		}
+		if n.Duration() < priors.minSleep() || n.Duration() > priors.maxSleep() {
+			continue
+		}
+		var before, after bool
+		for _, t := range events {
+			before = before || t.Before(n.Start)
+			after = after || !t.Before(n.End)
+		}
+		if before && after {
+			nights = append(nights, n)
+		}
+	}
+	sort.Slice(nights, func(i, j int) bool { return nights[i].Date.Before(nights[j].Date) })
+	return nights
+}
+
+// windowLean is the log weight of a state that agrees (or not) with the window at that time of day
+func windowLean(agrees bool) float64 {
+	if agrees {
+		return math.Log(windowAgreement)
+	}
+	return math.Log(1 - windowAgreement)
+}
+
+// longestRun is where the longest run of trues starts and how long it is
+func longestRun(bs []bool) (int, int) {
+	var bestStart, bestLen, start, length int
+	for i, b := range bs {
+		if !b {
+			length = 0
+			continue
+		}
+		if length == 0 {
+			start = i
+		}
+		length++
+		if length > bestLen {
+			bestStart, bestLen = start, length
+		}
+	}
+	return bestStart, bestLen
+}
+
+// typicalNight is the mean onset and wake (circular, in seconds since midnight) and the median
+// duration
+func typicalNight(nights []Night) (onset, wake float64, duration time.Duration) {
+	var onsets, wakes []float64
+	var durations []time.Duration
+	for _, n := range nights {
+		onsets = append(onsets, float64(secondsOfDay(n.Start)))
+		wakes = append(wakes, float64(secondsOfDay(n.End)))
+		durations = append(durations, n.Duration())
+	}
+	sort.Slice(durations, func(i, j int) bool { return durations[i] < durations[j] })
+	onset, _ = circularMean(onsets)
+	wake, _ = circularMean(wakes)
+	return onset, wake, durations[len(durations)/2]
+}
+
+func printNightsSummary(nights []Night, days int) {
+	if len(nights) == 0 {
+		return
+	}
+	onset, wake, duration := typicalNight(nights)
+	fmt.Printf("Nights inferred: %d of %d (commits on both sides), typically %s-%s, median %s\n\n",
+		len(nights), days, clockString(onset), clockString(wake), formatSpread(duration.Minutes()))
+}
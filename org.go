@@ -0,0 +1,171 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pelletier/go-toml/v2"
+)
+
+// `sleep org github.com/acme` lists the org's members through the forge's api and prints a subjects
+// file with one subject per member (sources their account, orgs the org, so the work/personal split
+// works) plus a group of them all, named after the org. redirect it to subjects.toml to edit and
+// keep, or pass --run to analyze them straight away. without a token github only lists members who
+// made their membership public
+
+// the most github and gitlab allow per page; gitea caps it lower itself
+const orgMembersPerPage = 100
+
+func runOrg(args []string) {
+	if len(args) != 1 {
+		log.Fatal("usage: sleep org <host/org> [--run] [> subjects.toml]")
+	}
+	rawURL := args[0]
+	if !strings.HasPrefix(rawURL, "http://") && !strings.HasPrefix(rawURL, "https://") {
+		rawURL = "https://" + rawURL
+	}
+	parsed, err := url.Parse(rawURL)
+	if err != nil {
+		log.Fatalf("Failed to parse %s: %v", args[0], err)
+	}
+	host, org := parsed.Hostname(), strings.Trim(parsed.Path, "/")
+	if org == "" || strings.Contains(org, "/") && detectForge(host) != "gitlab" {
+		// gitlab groups nest, the others are one level
+		log.Fatalf("Expected host/org, e.g. github.com/acme, got %s", args[0])
+	}
+
+	members, err := fetchOrgMembers(host, org)
+	if err != nil {
+		log.Fatalf("Failed to list members of %s: %v", args[0], err)
+	}
+	if len(members) == 0 {
+		log.Fatalf("%s has no members we can see", args[0])
+	}
+	log.Printf("Found %d members of %s", len(members), org)
+
+	raw := orgSubjects(host, org, members)
+	if !flags.Run {
+		if err := toml.NewEncoder(os.Stdout).Encode(raw); err != nil {
+			log.Fatalf("Failed to write subjects: %v", err)
+		}
+		return
+	}
+	generatedSubjects = raw
+	subjects := collect(flags.Subjects)
+	output(expandDerived(subjects), flags)
+	if flags.Availability || flags.PlotAvailability {
+		reportAvailability(subjects)
+	}
+}
+
+// orgSubjects is the subjects file for an org's members
+func orgSubjects(host, org string, members []string) map[string]subjectEntry {
+	// the group is named after the org, unless a member already has that name
+	group := org
+	raw := make(map[string]subjectEntry, len(members)+1)
+	for _, member := range members {
+		raw[member] = subjectEntry{
+			Sources: []string{host + "/" + member},
+			Orgs:    []string{org},
+		}
+	}
+	for raw[group].Sources != nil {
+		group += "-org"
+	}
+	raw[group] = subjectEntry{Members: members}
+	return raw
+}
+
+// fetchOrgMembers lists the logins of an org's (github, gitea) or group's (gitlab) members, sorted
+func fetchOrgMembers(host, org string) ([]string, error) {
+	var pageURL func(page int) string
+	var forge string
+	header := http.Header{"User-Agent": {"go-commit-plotter"}}
+	switch detectForge(host) {
+	case "github":
+		forge = "GitHub"
+		pageURL = func(page int) string {
+			return fmt.Sprintf("https://api.github.com/orgs/%s/members?per_page=%d&page=%d", org, orgMembersPerPage, page)
+		}
+		header.Set("Accept", "application/vnd.github.v3+json")
+		if token := os.Getenv("GITHUB_TOKEN"); token != "" {
+			header.Set("Authorization", "token "+token)
+		}
+	case "gitlab":
+		forge = "GitLab"
+		pageURL = func(page int) string {
+			return fmt.Sprintf("https://%s/api/v4/groups/%s/members/all?per_page=%d&page=%d", host, url.PathEscape(org), orgMembersPerPage, page)
+		}
+		if token := os.Getenv("GITLAB_TOKEN"); token != "" {
+			header.Set("PRIVATE-TOKEN", token)
+		}
+	case "gitea":
+		forge = "gitea"
+		pageURL = func(page int) string {
+			return fmt.Sprintf("https://%s/api/v1/orgs/%s/members?limit=%d&page=%d", host, org, orgMembersPerPage, page)
+		}
+		if token := os.Getenv("GITEA_TOKEN"); token != "" {
+			header.Set("Authorization", "token "+token)
+		}
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrForgeUnknown, host)
+	}
+
+	client := newHTTPClient(30 * time.Second)
+	seen := make(map[string]bool)
+	var members []string
+	for page := 1; ; page++ {
+		req, err := http.NewRequest("GET", pageURL(page), nil)
+		if err != nil {
+			return nil, err
+		}
+		req.Header = header.Clone()
+		resp, err := getWithETag(client, req)
+		if err != nil {
+			return nil, err
+		}
+		body, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		if resp.StatusCode != http.StatusOK {
+			return nil, apiStatusError(forge, resp)
+		}
+
+		// github and gitea call it login, gitlab username
+		var users []struct {
+			Login    string `json:"login"`
+			Username string `json:"username"`
+		}
+		if err := json.Unmarshal(body, &users); err != nil {
+			return nil, fmt.Errorf("failed to parse JSON response: %w", err)
+		}
+		var fresh int
+		for _, u := range users {
+			name := u.Login
+			if name == "" {
+				name = u.Username
+			}
+			if name != "" && !seen[name] {
+				seen[name] = true
+				members = append(members, name)
+				fresh++
+			}
+		}
+		// an empty page is the last; so is one with nothing new, for servers that ignore page=
+		if len(users) == 0 || fresh == 0 {
+			break
+		}
+	}
+	sort.Strings(members)
+	return members, nil
+}
@@ -1,8 +1,8 @@
 package main
 
 import (
+	"bytes"
 	"fmt"
-	"image/color"
 	"time"
 	"log"
 	"strings"
@@ -14,6 +14,7 @@ import (
 	"gonum.org/v1/plot"
 	"gonum.org/v1/plot/plotter"
 	"gonum.org/v1/plot/vg"
+	"gonum.org/v1/plot/vg/draw"
 )
 
 func output(subjects []Subject, flags Flags) {
@@ -21,115 +22,120 @@ func output(subjects []Subject, flags Flags) {
 		log.Fatal("No subjects found")
 	}
 
+	sinks := openSinks(flags.Sinks, subjects)
 	for _, subject := range subjects {
 		if len(subject.Commits) == 0 {
 			log.Printf("No commits found for %s. Skipping output.", subject.Name)
 			continue
 		}
-
-		if flags.StdOut {
-			if err := printSleepHisto(&subject); err != nil {
-				log.Printf("Failed to print sleep histogram for %s: %v", subject.Name, err)
-			}
-		}
-		if flags.PlotScatter {
-			outputFilename := fmt.Sprintf("%s_commits_scatter.png", subject.Name)
-			if err := plotCommitsScatter(&subject, outputFilename); err != nil {
-				log.Printf("Failed to save scatter plot for %s: %v", subject.Name, err)
-			} else {
-				fmt.Printf("Saved scatter plot to %s\n", outputFilename)
+		// already done for collected subjects, not for imported ones
+		applyTimeBasis(&subject)
+		analysis := analyze(&subject)
+		for _, sink := range sinks {
+			if err := sink.Write(&subject, analysis); err != nil {
+				log.Printf("Failed to write %s to %s: %v", subject.Name, sink, err)
 			}
 		}
-		if flags.PlotHisto {
-			outputFilename := fmt.Sprintf("%s_commits_histogram.png", subject.Name)
-			if err := plotCommitsHistogram(&subject, outputFilename); err != nil {
-				log.Printf("Failed to save histogram for %s: %v", subject.Name, err)
-			} else {
-				fmt.Printf("Saved histogram to %s\n", outputFilename)
-			}
+	}
+	for _, sink := range sinks {
+		if err := sink.Close(); err != nil {
+			log.Printf("Failed to finish %s: %v", sink, err)
 		}
 	}
 }
 
-func printSleepHisto(subject *Subject) error {
+// printSleepHisto prints commits per hour as bars, the sleep window's hours highlighted with --color.
+// with --smooth the bars are the moving average and the numbers stay the real counts
+func printSleepHisto(subject *Subject, window SleepWindow) error {
 	var maxi int
-	hourCounts := make([]int, 24)
-	for _, c := range subject.Commits {
-		t := c.Author.When
-		hour := t.Hour()
-		hourCounts[hour]++
-		if hour > maxi {
-			maxi = hour
-		}
+	counts := hourCounts(subject.times())
+	for _, count := range counts {
+		maxi = max(maxi, count)
+	}
+	bars := smoothCounts(counts, flags.Smooth)
+	var maxBar float64
+	for _, bar := range bars {
+		maxBar = max(maxBar, bar)
 	}
 
 	// 0-pad according to the # of digits in max value
 	width := len(fmt.Sprintf("%d", maxi))
 
-	log.Printf("Sleep histogram for user %s:\n", subject.Name)
-
-	// assumed terminal width of 80
-	if maxi > 80 {
-		scalingFactor := float64(80) / float64(maxi)
-		for hour, count := range hourCounts {
-			hashtags := strings.Repeat("#", int(float64(count) * scalingFactor))
-			fmt.Printf("%02d:00 (%0*d): %s\n", hour, width, count, hashtags)
-		}
+	if flags.Smooth > 1 {
+		log.Printf("Sleep histogram for user %s (bars averaged over %d hours):\n", subject.Name, flags.Smooth)
 	} else {
-		for hour, count := range hourCounts {
-			hashtags := strings.Repeat("#", count)
-			fmt.Printf("%02d:00 (%0*d): %s\n", hour, width, count, hashtags)
-		}
+		log.Printf("Sleep histogram for user %s:\n", subject.Name)
 	}
 
-	if flags.Write {
-		save(subject, hourCounts)
+	// assumed terminal width of 80
+	scalingFactor := 1.0
+	if maxBar > 80 {
+		scalingFactor = 80 / maxBar
+	}
+	for hour, count := range counts {
+		hashtags := strings.Repeat("#", int(bars[hour] * scalingFactor))
+		histoLine(window, hour, fmt.Sprintf("%5s (%0*d): %s", hourString(hour), width, count, hashtags))
 	}
 
 	return nil
 }
 
+// smoothCounts is --smooth: each hour's count averaged with its neighbours, hours/2 either side,
+// around midnight. a lone commit at 4am in a sparse month stops looking like a night cut in half
+func smoothCounts(counts []int, hours int) []float64 {
+	smoothed := make([]float64, len(counts))
+	half := max(hours, 1) / 2
+	for h := range counts {
+		for d := -half; d <= half; d++ {
+			smoothed[h] += float64(counts[(h+d+len(counts))%len(counts)])
+		}
+		smoothed[h] /= float64(2*half + 1)
+	}
+	return smoothed
+}
+
+func histoLine(window SleepWindow, hour int, line string) {
+	if window.contains(hour) {
+		line = paint(ansiBlue, line)
+	}
+	fmt.Println(line)
+}
+
 // TODO: slop
 // plotCommitsScatter creates a scatter plot of commit timestamps
-func plotCommitsScatter(subject *Subject, outputPath string) error {
+func plotCommitsScatter(subject *Subject, window SleepWindow, outputPath string) error {
 	// Convert commits map to plotter points
 	pts := make(plotter.XYs, 0, len(subject.Commits))
-	for _, c := range subject.Commits {
-		t := c.Author.When
-		secondsSinceMidnight := t.Hour()*3600 + t.Minute()*60 + t.Second()
+	for _, t := range subject.times() {
 		pts = append(pts, plotter.XY{
 			X: float64(t.Unix()),
-			Y: float64(secondsSinceMidnight),
+			Y: float64(secondsOfDay(t)),
 		})
 	}
 
-	green := color.RGBA{0x95, 0xd5, 0x50, 0xff}
-	p := plot.New()
-	p.BackgroundColor = color.RGBA{0x10, 0x10, 0x10, 0xff}
-	p.Title.Text = fmt.Sprintf("Commit Schedule: %s (Scatter)", subject.Name)
-	p.Title.TextStyle.Color = green
-	p.X.Label.Text = "Commit Date"
-	p.X.Label.TextStyle.Color = green
-	p.X.Color = green
-	p.X.Tick.Color = green
-	p.X.Tick.Label.Color = green
+	p := newPlot(fmt.Sprintf("Commit Schedule: %s (Scatter)\n%s", subject.Name, window), "Commit Date", "Time of Day")
 	p.X.Tick.Marker = dateTicks{}
-	p.Y.Label.Text = "Time of Day"
-	p.Y.Label.TextStyle.Color = green
-	p.Y.Color = green
-	p.Y.Tick.Color = green
-	p.Y.Tick.Label.Color = green
 	p.Y.Tick.Marker = hourTicks{}
-	
-	scatter, err := plotter.NewScatter(pts)
-	if err != nil {
-		return fmt.Errorf("could not create scatter plot: %v", err)
+
+	xmin, xmax, _, _ := plotter.XYRange(pts)
+	if err := addWindowBand(p, window, 3600, 0, xmin, xmax, true); err != nil {
+		return err
 	}
-	scatter.Radius = vg.Points(2)
-	scatter.Color = green
-	p.Add(scatter)
-	
-	if err := p.Save(10*vg.Inch, 6*vg.Inch, outputPath); err != nil {
+
+	if flags.Hexbin {
+		p.Add(hexbin{XYs: pts, Radius: vg.Points(6), Color: theme.Data})
+	} else {
+		scatter, err := plotter.NewScatter(pts)
+		if err != nil {
+			return fmt.Errorf("could not create scatter plot: %v", err)
+		}
+		scatter.Radius = vg.Points(2)
+		scatter.Color = withAlpha(theme.Data, scatterAlpha(len(pts)))
+		p.Add(scatter)
+	}
+
+	width, height := plotSize(10*vg.Inch, 6*vg.Inch)
+	if err := savePlot(p, width, height, outputPath, metaFor(subject)); err != nil {
 		return fmt.Errorf("could not save plot: %v", err)
 	}
 	return nil
@@ -137,59 +143,167 @@ func plotCommitsScatter(subject *Subject, outputPath string) error {
 
 // TODO: slop
 // plotCommitsHistogram creates a histogram of commits by hour of day
-func plotCommitsHistogram(subject *Subject, outputPath string) error {
-	// Count commits per hour
-	hourCounts := make([]float64, 24)
-	for _, c := range subject.Commits {
-		t := c.Author.When
-		hour := t.Hour()
-		hourCounts[hour]++
-	}
-
+func plotCommitsHistogram(subject *Subject, window SleepWindow, outputPath string) error {
 	// Create bar chart values
 	values := make(plotter.Values, 24)
-	for i  := range 24 {
-		values[i] = hourCounts[i]
-	}
-
-	green := color.RGBA{0x95, 0xd5, 0x50, 0xff}
-	p := plot.New()
-	p.BackgroundColor = color.RGBA{0x10, 0x10, 0x10, 0xff}
-	p.Title.Text = fmt.Sprintf("Commit Distribution: %s (by Hour)", subject.Name)
-	p.Title.TextStyle.Color = green
-	p.X.Label.Text = "Hour of Day"
-	p.X.Label.TextStyle.Color = green
-	p.X.Color = green
-	p.X.Tick.Color = green
-	p.X.Tick.Label.Color = green
-	p.Y.Label.Text = "Number of Commits"
-	p.Y.Label.TextStyle.Color = green
-	p.Y.Color = green
-	p.Y.Tick.Color = green
-	p.Y.Tick.Label.Color = green
+	for hour, count := range hourCounts(subject.times()) {
+		values[hour] = float64(count)
+	}
+
+	p := newPlot(fmt.Sprintf("Commit Distribution: %s (by Hour)\n%s", subject.Name, window), "Hour of Day", "Number of Commits")
+
+	_, ymax := plotter.Range(values)
+	if err := addWindowBand(p, window, 1, -0.5, 0, ymax, false); err != nil {
+		return err
+	}
 
 	bars, err := plotter.NewBarChart(values, vg.Points(20))
 	if err != nil {
 		return fmt.Errorf("could not create bar chart: %v", err)
 	}
-	bars.Color = green
-	bars.LineStyle.Color = green
+	bars.Color = theme.Data
+	bars.LineStyle.Color = theme.Data
 	p.Add(bars)
 
 	// Custom X-axis labels for hours
-	p.NominalX(
-		"00", "01", "02", "03", "04", "05", 
-		"06", "07", "08", "09", "10", "11",
-		"12", "13", "14", "15", "16", "17",
-		"18", "19", "20", "21", "22", "23",
-	)
-
-	if err := p.Save(10*vg.Inch, 6*vg.Inch, outputPath); err != nil {
+	labels := make([]string, 24)
+	for h := range labels {
+		labels[h] = shortHour(h)
+	}
+	p.NominalX(labels...)
+
+	width, height := plotSize(10*vg.Inch, 6*vg.Inch)
+	if err := savePlot(p, width, height, outputPath, metaFor(subject)); err != nil {
+		return fmt.Errorf("could not save plot: %v", err)
+	}
+	return nil
+}
+
+// plotCommitsMonthly tiles one small hourly histogram per calendar month, sharing a y scale
+// so months can be compared by eye. each facet gets its own sleep window band
+func plotCommitsMonthly(subject *Subject, outputPath string) error {
+	var months []string
+	byMonth := make(map[string][]time.Time)
+	for _, t := range subject.times() {
+		m := t.Format("2006-01")
+		if _, ok := byMonth[m]; !ok {
+			months = append(months, m)
+		}
+		byMonth[m] = append(byMonth[m], t)
+	}
+
+	titles := make([]string, len(months))
+	groups := make([][]time.Time, len(months))
+	for i, month := range months {
+		titles[i] = fmt.Sprintf("%s (%d)", month, len(byMonth[month]))
+		groups[i] = byMonth[month]
+	}
+	return plotHourFacets(subject, titles, groups, outputPath)
+}
+
+// plotHourFacets draws a small histogram per group, 4 to a row, on a shared y axis, each with its
+// own sleep window
+func plotHourFacets(subject *Subject, titles []string, groups [][]time.Time, outputPath string) error {
+	var ymax float64
+	for _, times := range groups {
+		for _, count := range hourCounts(times) {
+			ymax = max(ymax, float64(count))
+		}
+	}
+
+	const cols = 4
+	rows := (len(groups) + cols - 1) / cols
+	grid := make([][]*plot.Plot, rows)
+	for r := range grid {
+		grid[r] = make([]*plot.Plot, cols)
+	}
+
+	// only label every 6th hour, the facets are too small for all 24
+	labels := make([]string, 24)
+	for h := 0; h < 24; h += 6 {
+		labels[h] = shortHour(h)
+	}
+
+	for i, times := range groups {
+		values := make(plotter.Values, 24)
+		for hour, count := range hourCounts(times) {
+			values[hour] = float64(count)
+		}
+
+		p := newPlot(titles[i], "", "")
+		p.Y.Min = 0
+		p.Y.Max = ymax
+		if err := addWindowBand(p, estimateSleepWindow(times), 1, -0.5, 0, ymax, false); err != nil {
+			return err
+		}
+		bars, err := plotter.NewBarChart(values, vg.Points(6))
+		if err != nil {
+			return fmt.Errorf("could not create bar chart for %s: %v", titles[i], err)
+		}
+		bars.Color = theme.Data
+		bars.LineStyle.Color = theme.Data
+		p.Add(bars)
+		p.NominalX(labels...)
+		grid[i/cols][i%cols] = p
+	}
+
+	width, height := plotSize(12*vg.Inch, vg.Length(rows)*3*vg.Inch)
+	c, err := draw.NewFormattedCanvas(width, height, strings.TrimPrefix(filepath.Ext(outputPath), "."))
+	if err != nil {
+		return fmt.Errorf("could not save plot: %v", err)
+	}
+	dc := drawFooter(draw.New(c), metaFor(subject))
+
+	tiles := draw.Tiles{Rows: rows, Cols: cols, PadX: vg.Millimeter, PadY: vg.Millimeter}
+	canvases := plot.Align(grid, tiles, dc)
+	for r := range grid {
+		for c, p := range grid[r] {
+			if p != nil {
+				p.Draw(canvases[r][c])
+			}
+		}
+	}
+
+	if err := writeCanvas(c, outputPath, metaFor(subject)); err != nil {
 		return fmt.Errorf("could not save plot: %v", err)
 	}
 	return nil
 }
 
+// addWindowBand shades the sleep window across the plot. hours are mapped onto the plot's time axis
+// as hour*unit+offset; the band spans lo..hi on the other axis.
+// horizontal means time of day runs along Y, like the scatter plot
+func addWindowBand(p *plot.Plot, w SleepWindow, unit, offset, lo, hi float64, horizontal bool) error {
+	if !w.Found {
+		return nil
+	}
+
+	// a window crossing midnight is drawn as two bands
+	spans := [][2]int{{w.Start, w.Start + w.Hours}}
+	if w.Start+w.Hours > 24 {
+		spans = [][2]int{{w.Start, 24}, {0, w.End}}
+	}
+
+	for _, span := range spans {
+		from := float64(span[0])*unit + offset
+		to := float64(span[1])*unit + offset
+		corners := plotter.XYs{{X: lo, Y: from}, {X: hi, Y: from}, {X: hi, Y: to}, {X: lo, Y: to}}
+		if !horizontal {
+			for i := range corners {
+				corners[i].X, corners[i].Y = corners[i].Y, corners[i].X
+			}
+		}
+		band, err := plotter.NewPolygon(corners)
+		if err != nil {
+			return fmt.Errorf("could not create sleep window band: %v", err)
+		}
+		band.Color = theme.Window
+		band.LineStyle.Width = 0
+		p.Add(band)
+	}
+	return nil
+}
+
 // hourTicks provides formatted time-of-day labels for plot Y-axis
 type hourTicks struct{}
 
@@ -199,7 +313,7 @@ func (hourTicks) Ticks(min, max float64) []plot.Tick {
 		seconds := float64(h * 3600)
 		ticks = append(ticks, plot.Tick{
 			Value: seconds,
-			Label: fmt.Sprintf("%02d:00", h),
+			Label: hourString(h),
 		})
 	}
 	return ticks
@@ -225,27 +339,28 @@ func (dateTicks) Ticks(min, max float64) []plot.Tick {
 }
 
 func save(subject *Subject, times []int) {
-	stamp := time.Now().UTC().Format("2006-01-02") + ".toml"
-	path := filepath.Join(savePath, stamp)
+	path := snapshotPath(subject.Name, time.Now())
 
 	err := os.MkdirAll(filepath.Dir(path), 0o755)
 	if err != nil {
 		log.Fatalf("could not make dir(s) %s: %v", filepath.Dir(path), err)
 	}
 
-	f, err := os.Create(path)
-	if err != nil {
-		log.Fatalf("could not write file %s: %v", path, err)
-	}
-	defer f.Close()
-
 	mappedTimes := map[string][]int{
 		subject.Name: times,
 	}
 
-	if err := toml.NewEncoder(f).Encode(mappedTimes); err != nil {
+	var buf bytes.Buffer
+	if err := toml.NewEncoder(&buf).Encode(mappedTimes); err != nil {
 		log.Fatalf("encode %s: %v", path, err)
-	}	
+	}
+	if err := os.WriteFile(path, signSnapshot(buf.Bytes()), 0o644); err != nil {
+		log.Fatalf("could not write file %s: %v", path, err)
+	}
+
+	if retentionEnabled() {
+		pruneSnapshots(filepath.Dir(path), false)
+	}
 }
 
 // maybe
@@ -260,64 +375,3 @@ func save(subject *Subject, times []int) {
 // 		
 // 	}
 // }
-
-// find likely sleep windows
-// too many assumptions i think
-// func estimateSleepSchedule(subject *Subject) {
-// 	if len(subject.Commits) == 0 {
-// 		fmt.Printf("No commits to analyze for %s\n", subject.Name)
-// 		return
-// 	}
-//
-// 	// build histogram of activity by hour
-// 	hourCounts := make([]int, 24)
-// 	for _, c := range subject.Commits {
-// 		t := c.Author.When
-// 		hour := t.Hour()
-// 		hourCounts[hour]++
-// 	}
-//
-// 	// Find the longest consecutive sequence of low-activity hours
-// 	// Low activity = fewer than 5% of average hourly commits
-// 	totalCommits := len(subject.Commits)
-// 	avgPerHour := float64(totalCommits) / 24.0
-// 	threshold := int(avgPerHour * 0.05)
-// 	if threshold < 1 {
-// 		threshold = 1
-// 	}
-//
-// 	var longestStart, longestLen int
-// 	currentStart, currentLen := -1, 0
-//
-// 	for i := 0; i < 48; i++ { // Check twice around the clock to handle wrap-around
-// 		hour := i % 24
-// 		if hourCounts[hour] <= threshold {
-// 			if currentLen == 0 {
-// 				currentStart = hour
-// 			}
-// 			currentLen++
-// 			if currentLen > longestLen {
-// 				longestLen = currentLen
-// 				longestStart = currentStart
-// 			}
-// 		} else {
-// 			currentLen = 0
-// 		}
-// 	}
-//
-// 	if longestLen >= 4 { // At least 4 hours of inactivity
-// 		sleepStart := longestStart
-// 		sleepEnd := (longestStart + longestLen) % 24
-// 		
-// 		fmt.Printf("\n=== Sleep Schedule Estimate for %s ===\n", subject.Name)
-// 		fmt.Printf("Estimated sleep window: %02d:00 - %02d:00\n", sleepStart, sleepEnd)
-// 		fmt.Printf("Duration: ~%d hours\n", longestLen)
-// 		fmt.Printf("Based on %d commits\n", totalCommits)
-// 		fmt.Printf("Low-activity threshold: ≤%d commits/hour\n\n", threshold)
-// 	} else {
-// 		fmt.Printf("\n=== Sleep Schedule Estimate for %s ===\n", subject.Name)
-// 		fmt.Printf("Unable to identify clear sleep window (no extended low-activity period)\n")
-// 		fmt.Printf("This may indicate irregular sleep patterns or insufficient data\n\n")
-// 	}
-// }
-
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"time"
+)
+
+// `sleep overlap a b` finds the hours two subjects are both around, for scheduling calls and pairing
+// across timezones. everything is worked out in UTC, then shown in each subject's own time too.
+// awake = outside their sleep window. active = an hour holding at least half its fair share of commits
+
+func runOverlap(args []string) {
+	if len(args) != 2 {
+		log.Fatal("usage: sleep overlap <subject> <subject>")
+	}
+	// collect builds the one --user/--local subject instead of the ones named
+	if len(flags.Local) > 0 || flags.User != "" {
+		log.Fatal("sleep overlap compares two subjects from the subjects file, not --user/--local")
+	}
+	subjects := collect(args)
+	byName := make(map[string]*Subject)
+	for i := range subjects {
+		byName[subjects[i].Name] = &subjects[i]
+	}
+	a, ok := byName[args[0]]
+	if !ok {
+		log.Fatalf("No subject %q was built", args[0])
+	}
+	b, ok := byName[args[1]]
+	if !ok {
+		log.Fatalf("No subject %q was built", args[1])
+	}
+	if len(a.Commits) == 0 || len(b.Commits) == 0 {
+		log.Fatal("Both subjects need commits to compare")
+	}
+	printOverlap(a, b)
+}
+
+// hourSet is a set of UTC hours
+type hourSet [24]bool
+
+func (s hourSet) and(o hourSet) hourSet {
+	var out hourSet
+	for h := range s {
+		out[h] = s[h] && o[h]
+	}
+	return out
+}
+
+func (s hourSet) count() int {
+	var n int
+	for _, in := range s {
+		if in {
+			n++
+		}
+	}
+	return n
+}
+
+// runs formats the set as ranges, shifted by offset hours, e.g. "09:00-12:00, 14:00-18:00"
+func (s hourSet) runs(offset int) string {
+	if s.count() == 24 {
+		return "all day"
+	}
+	if s.count() == 0 {
+		return "none"
+	}
+	// start scanning just after a gap so no run is split at midnight
+	start := 0
+	for s[(start+23)%24] || !s[start] {
+		start++
+	}
+	var runs []string
+	for i := 0; i < 24; {
+		h := (start + i) % 24
+		if !s[h] {
+			i++
+			continue
+		}
+		length := 0
+		for length < 24 && s[(h+length)%24] {
+			length++
+		}
+		runs = append(runs, hourRange(h+offset, h+length+offset))
+		i += length
+	}
+	return strings.Join(runs, ", ")
+}
+
+func utcTimes(subject *Subject) []time.Time {
+	times := subject.times()
+	for i := range times {
+		times[i] = times[i].UTC()
+	}
+	return times
+}
+
+func awakeHours(times []time.Time) hourSet {
+	w := estimateSleepWindow(times)
+	var s hourSet
+	for h := range s {
+		s[h] = !w.contains(h)
+	}
+	return s
+}
+
+func activeHours(times []time.Time) hourSet {
+	var s hourSet
+	for h, count := range hourCounts(times) {
+		s[h] = float64(count) >= float64(len(times))/24/2
+	}
+	return s
+}
+
+// localOffset is the subject's UTC offset in whole hours: from their configured zone if any,
+// otherwise the offset most of their commits were recorded in
+func localOffset(subject *Subject) (int, string) {
+	if subject.Location != nil {
+		_, off := time.Now().In(subject.Location).Zone()
+		return off / 3600, subject.Location.String()
+	}
+	counts := make(map[int]int)
+	var best int
+	for _, t := range subject.recordedTimes() {
+		_, off := t.Zone()
+		counts[off]++
+		if counts[off] > counts[best] {
+			best = off
+		}
+	}
+	return best / 3600, fmt.Sprintf("UTC%+d, most common commit offset", best/3600)
+}
+
+func printOverlap(a, b *Subject) {
+	aTimes, bTimes := utcTimes(a), utcTimes(b)
+	aAwake, bAwake := awakeHours(aTimes), awakeHours(bTimes)
+	aActive, bActive := activeHours(aTimes), activeHours(bTimes)
+	awake, active := aAwake.and(bAwake), aActive.and(bActive)
+
+	fmt.Printf("\n=== Overlap: %s & %s ===\n", a.Name, b.Name)
+	fmt.Printf("%s awake (UTC):  %s\n", a.Name, aAwake.runs(0))
+	fmt.Printf("%s awake (UTC):  %s\n", b.Name, bAwake.runs(0))
+	fmt.Printf("both awake:    %s UTC (%dh)\n", awake.runs(0), awake.count())
+	fmt.Printf("both active:   %s UTC (%dh)\n", active.runs(0), active.count())
+	for _, s := range []*Subject{a, b} {
+		offset, zone := localOffset(s)
+		fmt.Printf("  in %s's time (%s): awake %s, active %s\n", s.Name, zone, awake.runs(offset), active.runs(offset))
+	}
+}
@@ -0,0 +1,238 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/format/packfile"
+	"github.com/go-git/go-git/v5/storage"
+)
+
+// every clone used to start from nothing, even of a repo cloned yesterday that gained three commits
+// since. what a fetch ends up with is kept as one packfile per repo in cache/packs/, and the next
+// fetch of the repo loads it first and offers its tips to the server as haves, so only what's new
+// comes over the wire, in memory mode too. a pack is checked against the sha256 it was saved with
+// before it's used, and the least recently used ones are dropped once the store is over
+// --pack-cache-mb. off with --record and --replay, whose requests have to come out the same
+
+const packDir = "packs"
+
+// packRefPrefix is where a loaded pack's tips go, so the fetch offers them. they're dropped again
+// before the walk looks at the repo's refs
+const packRefPrefix = "refs/sleep-packs/"
+
+// how far back the encoder looks for deltas, git's default
+const packWindow = 10
+
+type storedPack struct {
+	Repo   string    `json:"repo"`
+	SHA256 string    `json:"sha256"`
+	Size   int64     `json:"size"`
+	Used   time.Time `json:"used"`
+	Tips   []string  `json:"tips"`
+}
+
+func packStoreEnabled() bool {
+	return flags.PackCacheMB > 0 && flags.Record == "" && flags.Replay == ""
+}
+
+func packPaths(repoURL string) (packPath, metaPath string) {
+	sum := sha256.Sum256([]byte(strings.ToLower(displayRepo(repoURL))))
+	base := filepath.Join(cacheDir, packDir, hex.EncodeToString(sum[:12]))
+	return base + ".pack", base + ".json"
+}
+
+// loadPack puts the objects stored for repoURL into s, with their tips under packRefPrefix. false if
+// nothing usable is stored
+func loadPack(s storage.Storer, repoURL string) bool {
+	if !packStoreEnabled() {
+		return false
+	}
+	packPath, metaPath := packPaths(repoURL)
+	meta, ok := readPackMeta(metaPath)
+	if !ok {
+		return false
+	}
+	data, err := os.ReadFile(packPath)
+	if err != nil {
+		log.Printf("  Failed to read the stored pack of %s: %v", repoURL, err)
+		removePack(packPath, metaPath)
+		return false
+	}
+	if sum := sha256.Sum256(data); hex.EncodeToString(sum[:]) != meta.SHA256 {
+		log.Printf("  The stored pack of %s doesn't match its checksum, dropping it", repoURL)
+		removePack(packPath, metaPath)
+		return false
+	}
+	if err := packfile.UpdateObjectStorage(s, bytes.NewReader(data)); err != nil {
+		log.Printf("  Failed to load the stored pack of %s, dropping it: %v", repoURL, err)
+		removePack(packPath, metaPath)
+		return false
+	}
+	for i, tip := range meta.Tips {
+		name := plumbing.ReferenceName(fmt.Sprintf("%s%d", packRefPrefix, i))
+		if err := s.SetReference(plumbing.NewHashReference(name, plumbing.NewHash(tip))); err != nil {
+			log.Printf("  Failed to set %s for %s: %v", name, repoURL, err)
+		}
+	}
+	meta.Used = time.Now()
+	writePackMeta(metaPath, meta)
+	log.Printf("  Loaded %s stored for %s, fetching what's new", formatBytes(meta.Size), repoURL)
+	return true
+}
+
+// dropPackRefs removes the refs loadPack set
+func dropPackRefs(s storage.Storer) {
+	refs, err := s.IterReferences()
+	if err != nil {
+		return
+	}
+	var names []plumbing.ReferenceName
+	refs.ForEach(func(ref *plumbing.Reference) error {
+		if strings.HasPrefix(ref.Name().String(), packRefPrefix) {
+			names = append(names, ref.Name())
+		}
+		return nil
+	})
+	for _, name := range names {
+		s.RemoveReference(name)
+	}
+}
+
+// savePack stores every object s has for the next fetch of repoURL, unless its refs are where the
+// stored pack left them
+func savePack(s storage.Storer, repoURL string) {
+	if !packStoreEnabled() {
+		return
+	}
+	refs, err := s.IterReferences()
+	if err != nil {
+		return
+	}
+	var tips []string
+	refs.ForEach(func(ref *plumbing.Reference) error {
+		if ref.Type() == plumbing.HashReference && !slices.Contains(tips, ref.Hash().String()) {
+			tips = append(tips, ref.Hash().String())
+		}
+		return nil
+	})
+	slices.Sort(tips)
+	packPath, metaPath := packPaths(repoURL)
+	if meta, ok := readPackMeta(metaPath); ok && slices.Equal(meta.Tips, tips) {
+		return
+	}
+
+	objects, err := s.IterEncodedObjects(plumbing.AnyObject)
+	if err != nil {
+		log.Printf("  Failed to list the objects of %s to store: %v", repoURL, err)
+		return
+	}
+	var hashes []plumbing.Hash
+	objects.ForEach(func(o plumbing.EncodedObject) error {
+		hashes = append(hashes, o.Hash())
+		return nil
+	})
+
+	dir := filepath.Dir(packPath)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		log.Printf("could not make dir %s: %v", dir, err)
+		return
+	}
+	sum := sha256.New()
+	err = writeFileAtomic(packPath, func(w io.Writer) error {
+		_, err := packfile.NewEncoder(io.MultiWriter(w, sum), s, false).Encode(hashes, packWindow)
+		return err
+	})
+	var info os.FileInfo
+	if err == nil {
+		info, err = os.Stat(packPath)
+	}
+	if err != nil {
+		log.Printf("  Failed to store the pack of %s: %v", repoURL, err)
+		return
+	}
+	writePackMeta(metaPath, storedPack{
+		Repo:   repoURL,
+		SHA256: hex.EncodeToString(sum.Sum(nil)),
+		Size:   info.Size(),
+		Used:   time.Now(),
+		Tips:   tips,
+	})
+	evictPacks()
+}
+
+// evictPacks drops the least recently used packs until the store fits in --pack-cache-mb
+func evictPacks() {
+	metaPaths, _ := filepath.Glob(filepath.Join(cacheDir, packDir, "*.json"))
+	type entry struct {
+		meta     storedPack
+		metaPath string
+	}
+	var entries []entry
+	var total int64
+	for _, metaPath := range metaPaths {
+		if meta, ok := readPackMeta(metaPath); ok {
+			entries = append(entries, entry{meta, metaPath})
+			total += meta.Size
+		}
+	}
+	slices.SortFunc(entries, func(a, b entry) int { return a.meta.Used.Compare(b.meta.Used) })
+	limit := int64(flags.PackCacheMB) << 20
+	for _, e := range entries {
+		if total <= limit {
+			break
+		}
+		log.Printf("  Dropping the stored pack of %s (%s, --pack-cache-mb)", e.meta.Repo, formatBytes(e.meta.Size))
+		removePack(strings.TrimSuffix(e.metaPath, ".json")+".pack", e.metaPath)
+		total -= e.meta.Size
+	}
+}
+
+func readPackMeta(metaPath string) (storedPack, bool) {
+	var meta storedPack
+	data, err := os.ReadFile(metaPath)
+	if errors.Is(err, fs.ErrNotExist) {
+		return meta, false
+	}
+	if err != nil {
+		log.Printf("Failed to read %s: %v", metaPath, err)
+		return meta, false
+	}
+	if err := json.Unmarshal(data, &meta); err != nil {
+		log.Printf("Failed to parse %s: %v", metaPath, err)
+		return storedPack{}, false
+	}
+	return meta, true
+}
+
+func writePackMeta(metaPath string, meta storedPack) {
+	data, err := json.Marshal(meta)
+	if err != nil {
+		log.Printf("encode %s: %v", metaPath, err)
+		return
+	}
+	if err := os.WriteFile(metaPath, data, 0o644); err != nil {
+		log.Printf("could not write file %s: %v", metaPath, err)
+	}
+}
+
+func removePack(packPath, metaPath string) {
+	for _, path := range []string{metaPath, packPath} {
+		if err := os.Remove(path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			log.Printf("Failed to remove %s: %v", path, err)
+		}
+	}
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// subject names come from subjects.toml keys and --user, so they can hold anything.
+// everything that ends up in a filename goes through sanitizeFilename first
+
+// OutputConfig is the [output] table in sleep.toml
+type OutputConfig struct {
+	// directory plots are written to, relative to the working dir
+	Dir string `toml:"dir"`
+	// filename template. {subject} and {kind} (scatter, histogram, ...) are substituted;
+	// forward slashes make subdirectories on every platform
+	Layout string `toml:"layout"`
+}
+
+const defaultLayout = "{subject}_commits_{kind}.png"
+
+// longest sanitized name, leaving the layout room under the usual 255 byte limit
+const maxFilenameLen = 100
+
+// names windows refuses as a file, with or without an extension
+var reservedNames = map[string]bool{
+	"CON": true, "PRN": true, "AUX": true, "NUL": true,
+	"COM1": true, "COM2": true, "COM3": true, "COM4": true, "COM5": true, "COM6": true, "COM7": true, "COM8": true, "COM9": true,
+	"LPT1": true, "LPT2": true, "LPT3": true, "LPT4": true, "LPT5": true, "LPT6": true, "LPT7": true, "LPT8": true, "LPT9": true,
+}
+
+// sanitizeFilename maps a name onto [A-Za-z0-9._-], which every filesystem we care about accepts as is
+func sanitizeFilename(name string) string {
+	var b strings.Builder
+	for _, r := range name {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '.', r == '-', r == '_':
+			b.WriteRune(r)
+		default:
+			b.WriteRune('_')
+		}
+	}
+	// windows silently strips trailing dots and spaces, and leading dots hide files elsewhere
+	clean := strings.Trim(b.String(), ". ")
+	if clean == "" {
+		return "_"
+	}
+	// most filesystems cap a name at 255 bytes, and the layout adds to it. long names keep a hash of
+	// the whole name, so two that share a prefix don't end up in the same file
+	if len(clean) > maxFilenameLen {
+		sum := sha256.Sum256([]byte(name))
+		clean = clean[:maxFilenameLen-9] + "-" + hex.EncodeToString(sum[:4])
+	}
+	stem, _, _ := strings.Cut(clean, ".")
+	if reservedNames[strings.ToUpper(stem)] {
+		clean = "_" + clean
+	}
+	return clean
+}
+
+// plotPath builds the output path for one of a subject's plots and makes sure its directory exists
+func plotPath(subjectName, kind string) string {
+	layout := config.Output.Layout
+	if layout == "" {
+		layout = defaultLayout
+	}
+
+	var parts []string
+	for _, part := range strings.Split(layout, "/") {
+		part = strings.ReplaceAll(part, "{subject}", sanitizeFilename(subjectName))
+		part = strings.ReplaceAll(part, "{kind}", sanitizeFilename(kind))
+		parts = append(parts, part)
+	}
+	path := filepath.Join(append([]string{config.Output.Dir}, parts...)...)
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		log.Printf("could not make dir(s) %s: %v", filepath.Dir(path), err)
+	}
+	return path
+}
+
+// plotPathExt is plotPath with the layout's extension swapped for ext, for outputs that aren't pngs
+func plotPathExt(subjectName, kind, ext string) string {
+	path := plotPath(subjectName, kind)
+	return strings.TrimSuffix(path, filepath.Ext(path)) + ext
+}
+
+// writeFileAtomic writes path with write, through a temp file renamed into place, so a run killed
+// halfway leaves the last version of the file rather than half of a new one
+func writeFileAtomic(path string, write func(io.Writer) error) error {
+	tmp := path + ".tmp"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	err = write(f)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp, path)
+	}
+	if err != nil {
+		os.Remove(tmp)
+	}
+	return err
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSanitizeFilename(t *testing.T) {
+	long := strings.Repeat("a", 300)
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"graevy", "graevy"},
+		{"some.one-else_2", "some.one-else_2"},
+		{"", "_"},
+
+		// reserved device names, bare, with an extension, in any case
+		{"CON", "_CON"},
+		{"nul", "_nul"},
+		{"COM1", "_COM1"},
+		{"com1.tar.gz", "_com1.tar.gz"},
+		{"LPT9", "_LPT9"},
+		{"CONSOLE", "CONSOLE"},
+		{"COM10", "COM10"},
+
+		// characters windows refuses, and separators on either platform
+		{`a<b>c:d"e|f?g*h`, "a_b_c_d_e_f_g_h"},
+		{`dir\file`, "dir_file"},
+		{"dir/file", "dir_file"},
+		{"../../etc/passwd", "_.._etc_passwd"},
+		{"C:", "C_"},
+
+		// windows strips trailing dots and spaces; leading dots hide files. spaces are replaced
+		// before the trim, so only dots are left to strip
+		{"name.", "name"},
+		{"name...", "name"},
+		{"name. . ", "name._._"},
+		{".hidden", "hidden"},
+		{"...", "_"},
+		{"   ", "___"},
+		{"two words", "two_words"},
+		{"tab\there", "tab_here"},
+
+		// one _ per rune, not per byte
+		{"josé", "jos_"},
+		{"日本語", "___"},
+		{"emoji😴", "emoji_"},
+	}
+	for _, tt := range tests {
+		if got := sanitizeFilename(tt.name); got != tt.want {
+			t.Errorf("sanitizeFilename(%q) = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+
+	got := sanitizeFilename(long)
+	if len(got) != maxFilenameLen {
+		t.Errorf("sanitizeFilename of %d bytes is %d bytes, want %d", len(long), len(got), maxFilenameLen)
+	}
+	if other := sanitizeFilename(long + "b"); other == got {
+		t.Errorf("long names sharing a prefix both sanitize to %q", got)
+	}
+	if again := sanitizeFilename(long); again != got {
+		t.Errorf("sanitizeFilename isn't stable: %q then %q", got, again)
+	}
+	if wide := sanitizeFilename(strings.Repeat("é", 300)); len(wide) > maxFilenameLen {
+		t.Errorf("sanitizeFilename of 300 runes is %d bytes", len(wide))
+	}
+}
+
+func TestPlotPath(t *testing.T) {
+	saved := config.Output
+	t.Cleanup(func() { config.Output = saved })
+
+	dir := t.TempDir()
+	tests := []struct {
+		layout  string
+		subject string
+		kind    string
+		want    []string // path elements under dir
+	}{
+		{"", "graevy", "scatter", []string{"graevy_commits_scatter.png"}},
+		{"{subject}/{kind}.png", "graevy", "histogram", []string{"graevy", "histogram.png"}},
+		{"{kind}/{subject}.svg", "a/b", "heatmap", []string{"heatmap", "a_b.svg"}},
+		// a subject can't climb out of the output dir, or name a device
+		{"{subject}/{kind}.png", "..", "trend", []string{"_", "trend.png"}},
+		{"{subject}.png", "NUL", "trend", []string{"_NUL.png"}},
+		{"{subject}.png", `C:\Windows`, "trend", []string{"C__Windows.png"}},
+		// subdirectories deeper than one level
+		{"plots/{subject}/{kind}/latest.png", "ann", "gaps", []string{"plots", "ann", "gaps", "latest.png"}},
+	}
+	for _, tt := range tests {
+		config.Output = OutputConfig{Dir: dir, Layout: tt.layout}
+		got := plotPath(tt.subject, tt.kind)
+		want := filepath.Join(append([]string{dir}, tt.want...)...)
+		if got != want {
+			t.Errorf("layout %q, subject %q: plotPath = %q, want %q", tt.layout, tt.subject, got, want)
+			continue
+		}
+		if info, err := os.Stat(filepath.Dir(got)); err != nil || !info.IsDir() {
+			t.Errorf("layout %q: plotPath didn't make %s: %v", tt.layout, filepath.Dir(got), err)
+		}
+		rel, err := filepath.Rel(dir, got)
+		if err != nil || strings.HasPrefix(rel, "..") {
+			t.Errorf("layout %q, subject %q: %s is outside %s", tt.layout, tt.subject, got, dir)
+		}
+	}
+
+	config.Output = OutputConfig{Dir: dir, Layout: "{subject}/{kind}.png"}
+	if got, want := plotPathExt("ann", "animation", ".gif"), filepath.Join(dir, "ann", "animation.gif"); got != want {
+		t.Errorf("plotPathExt = %q, want %q", got, want)
+	}
+}
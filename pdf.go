@@ -0,0 +1,185 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	_ "image/png"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"gonum.org/v1/plot"
+	"gonum.org/v1/plot/font"
+	"gonum.org/v1/plot/vg"
+	"gonum.org/v1/plot/vg/draw"
+	"gonum.org/v1/plot/vg/vgpdf"
+)
+
+// --report pdf: the markdown report's text on the first page(s), then every plot the run saved on a
+// page of its own, for sharing offline. pages are a4 and black on white whatever --theme says,
+// it's meant to be printed; the plots keep their theme
+
+// in points
+const (
+	pdfWidth  vg.Length = 595
+	pdfHeight vg.Length = 842
+	pdfMargin vg.Length = 50
+)
+
+// pdfDoc lays text out top to bottom, starting a new page when one fills up
+type pdfDoc struct {
+	pdf *vgpdf.Canvas
+	c   draw.Canvas
+	y   vg.Length // top of the next line
+}
+
+func newPDFDoc() *pdfDoc {
+	pdf := vgpdf.New(pdfWidth, pdfHeight)
+	return &pdfDoc{pdf: pdf, c: draw.New(pdf), y: pdfHeight - pdfMargin}
+}
+
+func (d *pdfDoc) newPage() {
+	d.pdf.NextPage()
+	d.y = pdfHeight - pdfMargin
+}
+
+func pdfText(size vg.Length) draw.TextStyle {
+	return draw.TextStyle{
+		Color:   color.Black,
+		Font:    font.From(plot.DefaultFont, size),
+		Handler: plot.DefaultTextHandler,
+	}
+}
+
+// text writes txt in sty, wrapped to the page width. the pdf fonts only cover cp1252, so the few
+// other symbols the reports use are spelled out
+func (d *pdfDoc) text(sty draw.TextStyle, txt string) {
+	txt = strings.NewReplacer("→", "->", "█", "#").Replace(txt)
+	for _, line := range wrapText(sty, txt, pdfWidth-2*pdfMargin) {
+		height := sty.Height(line) * 1.2
+		if d.y-height < pdfMargin {
+			d.newPage()
+		}
+		d.y -= height
+		d.c.FillText(sty, vg.Point{X: pdfMargin, Y: d.y}, line)
+	}
+}
+
+func (d *pdfDoc) space(h vg.Length) {
+	d.y -= h
+}
+
+func wrapText(sty draw.TextStyle, txt string, width vg.Length) []string {
+	var lines []string
+	var line string
+	for _, word := range strings.Fields(txt) {
+		if line != "" && sty.Width(line+" "+word) > width {
+			lines = append(lines, line)
+			line = word
+			continue
+		}
+		if line != "" {
+			line += " "
+		}
+		line += word
+	}
+	return append(lines, line)
+}
+
+// image puts img on a page of its own under a caption, scaled to fit
+func (d *pdfDoc) image(caption string, img image.Image) {
+	d.newPage()
+	d.text(pdfText(14), caption)
+	d.space(10)
+
+	b := img.Bounds()
+	w, h := pdfWidth-2*pdfMargin, d.y-pdfMargin
+	scale := min(w/vg.Length(b.Dx()), h/vg.Length(b.Dy()))
+	w, h = vg.Length(b.Dx())*scale, vg.Length(b.Dy())*scale
+	d.c.DrawImage(vg.Rectangle{Min: vg.Point{X: pdfMargin, Y: d.y - h}, Max: vg.Point{X: pdfMargin + w, Y: d.y}}, img)
+	d.y -= h
+}
+
+func writePDFReport(w io.Writer, subject *Subject, a Analysis) error {
+	d := newPDFDoc()
+	title, heading, body := pdfText(20), pdfText(14), pdfText(10)
+
+	d.text(title, "Sleep schedule: "+subject.Name)
+	d.text(body, fmt.Sprintf("%d commits since %s, generated %s. Times are on %s.", a.Commits, flags.Since.Format(time.DateOnly), time.Now().Format(time.DateOnly), a.TimeBasis))
+
+	section := func(name string, lines []string) {
+		if len(lines) == 0 {
+			return
+		}
+		d.space(12)
+		d.text(heading, name)
+		d.space(4)
+		for _, line := range lines {
+			d.text(body, "- "+strings.ReplaceAll(line, "**", ""))
+		}
+	}
+	section("Estimate", estimateLines(a))
+	section("Caveats", reportCaveats(subject, a))
+	if a.Stats.Total > 0 {
+		var stats []string
+		for _, row := range statsRows(a.Stats) {
+			stats = append(stats, row[0]+": "+row[1])
+		}
+		section("Stats", stats)
+	}
+
+	d.space(12)
+	d.text(heading, "Commits by hour")
+	d.space(4)
+	d.hourBars(a)
+
+	for _, p := range plotOutputs(subject, a) {
+		f, err := os.Open(p.path)
+		if err != nil {
+			continue // not saved this run
+		}
+		img, _, err := image.Decode(f)
+		f.Close()
+		if err != nil {
+			return fmt.Errorf("could not read %s: %v", p.path, err)
+		}
+		d.image(p.label, img)
+	}
+
+	_, err := d.pdf.WriteTo(w)
+	return err
+}
+
+// hourBars is the commits by hour table, with a bar per hour instead of the markdown's block characters
+func (d *pdfDoc) hourBars(a Analysis) {
+	sty := pdfText(9)
+	var maxi int
+	for _, count := range a.Hours {
+		maxi = max(maxi, count)
+	}
+	label := sty.Width("00:00 (sleep)  00000  ")
+	barMax := pdfWidth - 2*pdfMargin - label
+	for hour, count := range a.Hours {
+		marker := ""
+		if a.Window.contains(hour) {
+			marker = " (sleep)"
+		}
+		height := sty.Height("0") * 1.3
+		if d.y-height < pdfMargin {
+			d.newPage()
+		}
+		d.y -= height
+		d.c.FillText(sty, vg.Point{X: pdfMargin, Y: d.y}, hourString(hour)+marker)
+		d.c.FillText(sty, vg.Point{X: pdfMargin + sty.Width("00:00 (sleep)  "), Y: d.y}, fmt.Sprint(count))
+		if maxi > 0 && count > 0 {
+			bar := barMax * vg.Length(count) / vg.Length(maxi)
+			d.c.SetColor(color.Gray{0x60})
+			d.c.Fill(vg.Rectangle{
+				Min: vg.Point{X: pdfMargin + label, Y: d.y},
+				Max: vg.Point{X: pdfMargin + label + bar, Y: d.y + height*0.6},
+			}.Path())
+		}
+	}
+}
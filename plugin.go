@@ -0,0 +1,117 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"log"
+	"os"
+	"os/exec"
+	"regexp"
+	"time"
+
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+// sources sleep doesn't know can come from a plugin: any executable that prints a subject's events
+// as json lines. a source "jira:alice@acme" runs the plugin named jira, found under [plugins] in
+// sleep.toml or as sleep-source-jira on PATH (like git's subcommands), with the whole source as its
+// argument and SLEEP_SUBJECT and SLEEP_SINCE (rfc3339) in its environment. each line is one event:
+//
+//	{"time": "2024-03-01T23:12:00-05:00", "id": "PROJ-123 comment 4", "author": "Alice", "email": "alice@acme.com"}
+//
+// time is required and should keep the offset it happened at. id makes an event count once however
+// many times it's printed, and defaults to the line itself. every event printed is the subject's;
+// sleep only drops those before --since. a plugin that fails still has its events up to the failure
+
+// pluginSourcePattern is "name:rest". "host:8080/x" matches too, but there's no plugin named host
+var pluginSourcePattern = regexp.MustCompile(`^([a-z][a-z0-9-]*):([^/].*)$`)
+
+// ruleFromPlugin is the audit rule for plugin events
+const ruleFromPlugin = "from plugin"
+
+// pluginFor is the executable handling source, if it names a plugin that exists
+func pluginFor(source string) (string, bool) {
+	m := pluginSourcePattern.FindStringSubmatch(source)
+	if m == nil || m[1] == "http" || m[1] == "https" {
+		return "", false
+	}
+	if path, ok := config.Plugins[m[1]]; ok {
+		return path, true
+	}
+	path, err := exec.LookPath("sleep-source-" + m[1])
+	if err != nil {
+		return "", false
+	}
+	return path, true
+}
+
+type pluginEvent struct {
+	Time   time.Time `json:"time"`
+	ID     string    `json:"id"`
+	Author string    `json:"author"`
+	Email  string    `json:"email"`
+}
+
+func getPluginSource(plugin, source, subjectName string, since time.Time) (*Source, map[string][]*object.Commit) {
+	log.Printf("Processing source: %s (plugin %s)\n", source, plugin)
+	defer timed("plugin", source)()
+
+	cmd := exec.Command(plugin, source)
+	cmd.Env = append(os.Environ(), "SLEEP_SUBJECT="+subjectName, "SLEEP_SINCE="+since.Format(time.RFC3339))
+	cmd.Stderr = os.Stderr
+	out, err := cmd.StdoutPipe()
+	if err != nil {
+		log.Printf("  Failed to run %s: %v", plugin, err)
+		return nil, nil
+	}
+	if err := cmd.Start(); err != nil {
+		log.Printf("  Failed to run %s: %v", plugin, err)
+		return nil, nil
+	}
+
+	match := audited(subjectName, source, func(c *object.Commit) (bool, string) {
+		if !c.Committer.When.After(since) {
+			return false, ruleTooOld
+		}
+		return true, ruleFromPlugin
+	})
+	var events []*object.Commit
+	seen := make(map[plumbing.Hash]bool)
+	scanner := bufio.NewScanner(out)
+	scanner.Buffer(nil, 1<<20)
+	var line, bad int
+	for scanner.Scan() {
+		line++
+		var e pluginEvent
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil || e.Time.IsZero() {
+			bad++
+			continue
+		}
+		id := e.ID
+		if id == "" {
+			id = scanner.Text()
+		}
+		// scoped to the source so two plugins' ids can't collide
+		hash := plumbing.ComputeHash(plumbing.BlobObject, []byte(source+"\x00"+id))
+		if seen[hash] {
+			continue
+		}
+		seen[hash] = true
+		who := object.Signature{Name: e.Author, Email: e.Email, When: e.Time}
+		if c := (&object.Commit{Hash: hash, Author: who, Committer: who}); match(c) {
+			events = append(events, c)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		log.Printf("  Failed reading %s: %v", plugin, err)
+	}
+	if err := cmd.Wait(); err != nil {
+		log.Printf("  %s failed: %v", plugin, err)
+	}
+	if bad > 0 {
+		log.Printf("  Skipped %d of %d lines from %s that weren't events with a time", bad, line, plugin)
+	}
+	log.Printf("  Found %d events from %s\n", len(events), source)
+	return &Source{url: source}, map[string][]*object.Commit{source: events}
+}
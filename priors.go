@@ -0,0 +1,64 @@
+package main
+
+import (
+	"fmt"
+	"time"
+)
+
+// what the estimators assume about sleep before seeing any commits: how short a quiet stretch can be
+// and still be sleep, how long a night can be before its end is a guess, and what a normal night is.
+// the defaults fit a monophasic adult; a new parent or a polyphasic sleeper breaks them, so they're
+// settable in sleep.toml:
+//
+//	[priors]
+//	min_hours = 2.5
+//	max_hours = 14
+//	typical_min = 6
+//	typical_max = 8
+
+type PriorsConfig struct {
+	// a window or night shorter than this isn't sleep, it's lunch
+	MinHours float64 `toml:"min_hours"`
+	// a night longer than this has an end with nothing to pin it
+	MaxHours float64 `toml:"max_hours"`
+	// the range a night is expected to last, shaded on --plot-duration
+	TypicalMin float64 `toml:"typical_min"`
+	TypicalMax float64 `toml:"typical_max"`
+}
+
+var defaultPriors = PriorsConfig{MinHours: 4, MaxHours: 12, TypicalMin: 7, TypicalMax: 9}
+
+var priors = defaultPriors
+
+// resolvePriors fills in the defaults for anything left unset and checks the rest make sense
+func resolvePriors(custom PriorsConfig) (PriorsConfig, error) {
+	p := defaultPriors
+	for _, field := range []struct {
+		name string
+		v    float64
+		dst  *float64
+	}{
+		{"min_hours", custom.MinHours, &p.MinHours},
+		{"max_hours", custom.MaxHours, &p.MaxHours},
+		{"typical_min", custom.TypicalMin, &p.TypicalMin},
+		{"typical_max", custom.TypicalMax, &p.TypicalMax},
+	} {
+		if field.v == 0 {
+			continue
+		}
+		if field.v < 0 || field.v > 24 {
+			return PriorsConfig{}, fmt.Errorf("[priors] %s must be between 0 and 24 hours, got %g", field.name, field.v)
+		}
+		*field.dst = field.v
+	}
+	if p.MinHours >= p.MaxHours {
+		return PriorsConfig{}, fmt.Errorf("[priors] min_hours (%g) must be less than max_hours (%g)", p.MinHours, p.MaxHours)
+	}
+	if p.TypicalMin >= p.TypicalMax {
+		return PriorsConfig{}, fmt.Errorf("[priors] typical_min (%g) must be less than typical_max (%g)", p.TypicalMin, p.TypicalMax)
+	}
+	return p, nil
+}
+
+func (p PriorsConfig) minSleep() time.Duration { return time.Duration(p.MinHours * float64(time.Hour)) }
+func (p PriorsConfig) maxSleep() time.Duration { return time.Duration(p.MaxHours * float64(time.Hour)) }
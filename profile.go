@@ -0,0 +1,125 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"runtime"
+	"runtime/pprof"
+	"runtime/trace"
+	"sort"
+	"time"
+)
+
+// --profile writes a pprof (or execution trace) for the whole run, --timings prints where the
+// wall clock went per repo. both are for working on performance with big subjects
+
+var profileModes = []string{"cpu", "mem", "trace"}
+
+// startProfile begins the requested profile and returns a func that finishes and writes it
+func startProfile(mode string) func() {
+	switch mode {
+	case "":
+		return func() {}
+	case "cpu":
+		f := createProfile("cpu.pprof")
+		if err := pprof.StartCPUProfile(f); err != nil {
+			log.Fatalf("could not start CPU profile: %v", err)
+		}
+		return func() {
+			pprof.StopCPUProfile()
+			f.Close()
+			log.Printf("Wrote CPU profile to %s", f.Name())
+		}
+	case "mem":
+		return func() {
+			f := createProfile("mem.pprof")
+			defer f.Close()
+			runtime.GC()
+			if err := pprof.WriteHeapProfile(f); err != nil {
+				log.Printf("could not write memory profile: %v", err)
+				return
+			}
+			log.Printf("Wrote memory profile to %s", f.Name())
+		}
+	case "trace":
+		f := createProfile("trace.out")
+		if err := trace.Start(f); err != nil {
+			log.Fatalf("could not start trace: %v", err)
+		}
+		return func() {
+			trace.Stop()
+			f.Close()
+			log.Printf("Wrote execution trace to %s", f.Name())
+		}
+	}
+	log.Fatalf("Invalid --profile %q, expected one of %v", mode, profileModes)
+	return nil
+}
+
+func createProfile(path string) *os.File {
+	f, err := os.Create(path)
+	if err != nil {
+		log.Fatalf("could not create %s: %v", path, err)
+	}
+	return f
+}
+
+type timing struct {
+	phase  string // api, clone, iterate
+	target string // host/user for api calls, clone URL otherwise
+	took   time.Duration
+}
+
+var timings []timing
+
+// timed starts a stopwatch; call the result when the work is done, e.g. defer timed("clone", url)()
+func timed(phase, target string) func() {
+	start := time.Now()
+	return func() {
+		timings = append(timings, timing{phase, target, time.Since(start)})
+	}
+}
+
+func printTimings() {
+	byTarget := make(map[string]map[string]time.Duration)
+	totals := make(map[string]time.Duration)
+	for _, t := range timings {
+		if byTarget[t.target] == nil {
+			byTarget[t.target] = make(map[string]time.Duration)
+		}
+		byTarget[t.target][t.phase] += t.took
+		totals[t.phase] += t.took
+	}
+
+	targets := make([]string, 0, len(byTarget))
+	for target := range byTarget {
+		targets = append(targets, target)
+	}
+	sort.Strings(targets)
+
+	phases := []string{"api", "clone", "iterate"}
+	fmt.Printf("\n=== Timings ===\n")
+	fmt.Printf("%-10s %-10s %-10s %-10s %s\n", "api", "clone", "iterate", "fetched", "target")
+	var totalBytes int64
+	for _, target := range targets {
+		for _, phase := range phases {
+			fmt.Printf("%-10s ", formatTiming(byTarget[target][phase]))
+		}
+		// api targets are host/user, they never match a clone URL
+		n := transferred(target)
+		totalBytes += n
+		fmt.Printf("%-10s %s\n", formatBytes(n), target)
+	}
+	for _, phase := range phases {
+		fmt.Printf("%-10s ", formatTiming(totals[phase]))
+	}
+	fmt.Printf("%-10s total\n", formatBytes(totalBytes))
+}
+
+func formatTiming(d time.Duration) string {
+	if d == 0 {
+		return "-"
+	}
+	return d.Round(time.Millisecond).String()
+}
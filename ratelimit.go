@@ -0,0 +1,121 @@
+package main
+
+import (
+	"io"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// self-hosted forges are often someone's single small box. every request, API calls and clones
+// alike, waits its turn per host: at most --qps requests started per second and --max-per-host
+// in flight at once. [hosts."git.example.com"] in sleep.toml overrides either for one host
+
+// HostConfig is one [hosts."name"] table in sleep.toml
+type HostConfig struct {
+	QPS         float64 `toml:"qps"`
+	Concurrency int     `toml:"concurrency"`
+}
+
+// don't log every throttled request, once in a while per host is plenty
+const throttleLogEvery = 30 * time.Second
+
+type hostLimiter struct {
+	interval time.Duration
+	slots    chan struct{}
+
+	mu        sync.Mutex
+	next      time.Time
+	lastLog   time.Time
+	throttled int
+}
+
+type limitedTransport struct {
+	next http.RoundTripper
+
+	mu    sync.Mutex
+	hosts map[string]*hostLimiter
+}
+
+func newLimitedTransport(next http.RoundTripper) *limitedTransport {
+	return &limitedTransport{next: next, hosts: make(map[string]*hostLimiter)}
+}
+
+func (t *limitedTransport) limiter(host string) *hostLimiter {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if l, ok := t.hosts[host]; ok {
+		return l
+	}
+	qps, concurrency := flags.QPS, flags.MaxPerHost
+	if override, ok := config.Hosts[host]; ok {
+		if override.QPS > 0 {
+			qps = override.QPS
+		}
+		if override.Concurrency > 0 {
+			concurrency = override.Concurrency
+		}
+	}
+	l := &hostLimiter{slots: make(chan struct{}, max(concurrency, 1))}
+	if qps > 0 {
+		l.interval = time.Duration(float64(time.Second) / qps)
+	}
+	t.hosts[host] = l
+	return l
+}
+
+func (t *limitedTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	host := req.URL.Hostname()
+	l := t.limiter(host)
+
+	start := time.Now()
+	select {
+	case l.slots <- struct{}{}:
+	case <-req.Context().Done():
+		return nil, req.Context().Err()
+	}
+
+	l.mu.Lock()
+	now := time.Now()
+	at := now
+	if l.next.After(now) {
+		at = l.next
+	}
+	l.next = at.Add(l.interval)
+	l.mu.Unlock()
+	if wait := at.Sub(now); wait > 0 {
+		time.Sleep(wait)
+	}
+
+	if waited := time.Since(start); waited > 100*time.Millisecond {
+		l.mu.Lock()
+		l.throttled++
+		if time.Since(l.lastLog) > throttleLogEvery {
+			log.Printf("Throttling requests to %s (waited %s, %d requests held back so far)", host, waited.Round(time.Millisecond), l.throttled)
+			l.lastLog = time.Now()
+		}
+		l.mu.Unlock()
+	}
+
+	resp, err := t.next.RoundTrip(req)
+	if err != nil {
+		<-l.slots
+		return nil, err
+	}
+	// a clone streams its pack through the body, so the slot is held until it's closed
+	resp.Body = &releasingBody{ReadCloser: resp.Body, release: func() { <-l.slots }}
+	return resp, nil
+}
+
+type releasingBody struct {
+	io.ReadCloser
+	once    sync.Once
+	release func()
+}
+
+func (b *releasingBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.once.Do(b.release)
+	return err
+}
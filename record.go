@@ -0,0 +1,134 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// --record dir saves every http response (api calls and clone packfiles alike) to dir, and
+// --replay dir answers the same requests from it without touching the network. a replayed run
+// sees exactly what the recorded one saw, for regression testing and for bug reports that can be
+// rerun by someone else. responses are keyed by method, URL and request body, and repeats of the
+// same request replay in the order they were recorded
+
+type recordedResponse struct {
+	Method string      `json:"method"`
+	URL    string      `json:"url"`
+	Status int         `json:"status"`
+	Header http.Header `json:"header"`
+}
+
+type recordingTransport struct {
+	dir  string
+	next http.RoundTripper // nil when replaying
+
+	mu    sync.Mutex
+	calls map[string]int
+}
+
+func newRecordingTransport(dir string, next http.RoundTripper) *recordingTransport {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		log.Fatalf("could not make dir %s: %v", dir, err)
+	}
+	return &recordingTransport{dir: dir, next: next, calls: make(map[string]int)}
+}
+
+func newReplayTransport(dir string) *recordingTransport {
+	if _, err := os.Stat(dir); err != nil {
+		log.Fatalf("Can't replay from %s: %v", dir, err)
+	}
+	return &recordingTransport{dir: dir, calls: make(map[string]int)}
+}
+
+// recordingPath is where the nth response to a request lives, minus the extension
+func (t *recordingTransport) recordingPath(req *http.Request, body []byte) string {
+	sum := sha256.New()
+	fmt.Fprintf(sum, "%s %s\n", req.Method, req.URL.String())
+	sum.Write(body)
+	key := hex.EncodeToString(sum.Sum(nil)[:12])
+
+	t.mu.Lock()
+	n := t.calls[key]
+	t.calls[key]++
+	t.mu.Unlock()
+	return filepath.Join(t.dir, fmt.Sprintf("%s-%d", key, n))
+}
+
+func (t *recordingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	var body []byte
+	if req.Body != nil {
+		var err error
+		if body, err = io.ReadAll(req.Body); err != nil {
+			return nil, err
+		}
+		req.Body.Close()
+		req = req.Clone(req.Context())
+		req.Body = io.NopCloser(bytes.NewReader(body))
+	}
+	path := t.recordingPath(req, body)
+
+	if t.next == nil {
+		return t.replay(req, path)
+	}
+
+	resp, err := t.next.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	respBody, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(respBody))
+
+	header := resp.Header.Clone()
+	header.Del("Set-Cookie")
+	meta, err := json.MarshalIndent(recordedResponse{req.Method, req.URL.Redacted(), resp.StatusCode, header}, "", "  ")
+	if err == nil {
+		err = os.WriteFile(path+".body", respBody, 0o644)
+	}
+	// meta last, so a response only counts as recorded once its body is on disk
+	if err == nil {
+		err = os.WriteFile(path+".json", meta, 0o644)
+	}
+	if err != nil {
+		log.Printf("Failed to record %s: %v", req.URL.Redacted(), err)
+	}
+	return resp, nil
+}
+
+func (t *recordingTransport) replay(req *http.Request, path string) (*http.Response, error) {
+	data, err := os.ReadFile(path + ".json")
+	if err != nil {
+		return nil, fmt.Errorf("no recording of %s %s in %s", req.Method, req.URL.Redacted(), t.dir)
+	}
+	var meta recordedResponse
+	if err := json.Unmarshal(data, &meta); err != nil {
+		return nil, fmt.Errorf("bad recording %s.json: %w", path, err)
+	}
+	body, err := os.ReadFile(path + ".body")
+	if err != nil {
+		return nil, err
+	}
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", meta.Status, http.StatusText(meta.Status)),
+		StatusCode:    meta.Status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        meta.Header,
+		Body:          io.NopCloser(bytes.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       req,
+	}, nil
+}
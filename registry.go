@@ -0,0 +1,265 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+// package registry accounts as sources: npmjs.com/~alice, pypi.org/user/alice or
+// crates.io/users/alice. every release of the user's packages is an event at the time it was
+// published. npm and crates.io say who published each version, so only the user's own count; pypi
+// doesn't, so a project with co-maintainers brings their releases along. times are utc, so set the
+// subject's timezone
+
+// ruleRegistry is the audit rule for package releases
+const ruleRegistry = "registry publish"
+
+// registryUser is the registry (npm, pypi or crates) and username in a profile url, ok false if it
+// isn't one
+func registryUser(parsed *url.URL) (registry, user string, ok bool) {
+	host := strings.TrimPrefix(strings.ToLower(parsed.Hostname()), "www.")
+	parts := strings.Split(strings.Trim(parsed.Path, "/"), "/")
+	switch {
+	case host == "npmjs.com" && len(parts) == 1 && strings.HasPrefix(parts[0], "~") && len(parts[0]) > 1:
+		return "npm", parts[0][1:], true
+	case host == "pypi.org" && len(parts) == 2 && parts[0] == "user":
+		return "pypi", parts[1], true
+	case host == "crates.io" && len(parts) == 2 && parts[0] == "users":
+		return "crates", parts[1], true
+	}
+	return "", "", false
+}
+
+// registryReleases are the fetchers, each listing a user's releases since --since
+var registryReleases = map[string]func(user string, since time.Time) ([]registryRelease, error){
+	"npm":    npmReleases,
+	"pypi":   pypiReleases,
+	"crates": cratesReleases,
+}
+
+type registryRelease struct {
+	pkg, version string
+	published    time.Time
+}
+
+func getRegistrySource(rawURL, registry, user, subjectName string, since time.Time) (*Source, map[string][]*object.Commit) {
+	log.Printf("Processing source: %s (%s packages)\n", rawURL, registry)
+	defer timed("api", registry+"/"+user)()
+
+	releases, err := registryReleases[registry](user, since)
+	if err != nil {
+		log.Printf("  Failed to list %s releases for %s: %v", registry, user, err)
+		noteFailure(err)
+		return nil, nil
+	}
+	match := audited(subjectName, rawURL, func(c *object.Commit) (bool, string) {
+		if !c.Committer.When.After(since) {
+			return false, ruleTooOld
+		}
+		return true, ruleRegistry
+	})
+	var events []*object.Commit
+	packages := make(map[string]bool)
+	for _, r := range releases {
+		if c := activityEvent(registry, r.pkg+"@"+r.version, user, "", r.published); match(c) {
+			events = append(events, c)
+			packages[r.pkg] = true
+		}
+	}
+	log.Printf("  Found %d releases of %d packages\n", len(events), len(packages))
+	return &Source{url: rawURL, host: registry, user: user}, map[string][]*object.Commit{rawURL: events}
+}
+
+// registryGet fetches a registry api url, decoding json into v
+func registryGet(apiURL string, v any) error {
+	body, err := registryFetch(apiURL)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(body, v); err != nil {
+		return fmt.Errorf("failed to parse JSON response: %w", err)
+	}
+	return nil
+}
+
+func registryFetch(apiURL string) ([]byte, error) {
+	req, err := http.NewRequest("GET", apiURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	// crates.io turns away requests without one
+	req.Header.Set("User-Agent", "go-commit-plotter")
+	resp, err := newHTTPClient(30 * time.Second).Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, apiStatusError(registryHost(apiURL), resp)
+	}
+	return io.ReadAll(resp.Body)
+}
+
+// npmReleases searches for the user's packages, then reads each one's publish times. versions
+// record who published them, so a shared package only brings the user's own
+func npmReleases(user string, since time.Time) ([]registryRelease, error) {
+	var names []string
+	for from := 0; ; {
+		var page struct {
+			Objects []struct {
+				Package struct {
+					Name string `json:"name"`
+				} `json:"package"`
+			} `json:"objects"`
+			Total int `json:"total"`
+		}
+		query := url.Values{"text": {"maintainer:" + user}, "size": {"250"}, "from": {fmt.Sprint(from)}}
+		if err := registryGet("https://registry.npmjs.org/-/v1/search?"+query.Encode(), &page); err != nil {
+			return nil, err
+		}
+		for _, o := range page.Objects {
+			names = append(names, o.Package.Name)
+		}
+		from += len(page.Objects)
+		if len(page.Objects) == 0 || from >= page.Total {
+			break
+		}
+	}
+
+	var releases []registryRelease
+	for _, name := range names {
+		var doc struct {
+			Time     map[string]string `json:"time"`
+			Versions map[string]struct {
+				NPMUser struct {
+					Name string `json:"name"`
+				} `json:"_npmUser"`
+			} `json:"versions"`
+		}
+		// scoped names keep their @ but escape the slash
+		if err := registryGet("https://registry.npmjs.org/"+strings.Replace(name, "/", "%2F", 1), &doc); err != nil {
+			log.Printf("  Skipping npm package %s: %v", name, err)
+			continue
+		}
+		for version, v := range doc.Versions {
+			if !strings.EqualFold(v.NPMUser.Name, user) {
+				continue
+			}
+			published, err := time.Parse(time.RFC3339, doc.Time[version])
+			if err != nil || !published.After(since) {
+				continue
+			}
+			releases = append(releases, registryRelease{name, version, published.UTC()})
+		}
+	}
+	return releases, nil
+}
+
+// pypi has no api for a user's projects, so they come from the profile page
+var pypiProjectLink = regexp.MustCompile(`href="/project/([^/"]+)/"`)
+
+// pypiReleases reads each of the user's projects' releases, dated by their first uploaded file
+func pypiReleases(user string, since time.Time) ([]registryRelease, error) {
+	page, err := registryFetch("https://pypi.org/user/" + url.PathEscape(user) + "/")
+	if err != nil {
+		return nil, err
+	}
+	var releases []registryRelease
+	seen := make(map[string]bool)
+	for _, m := range pypiProjectLink.FindAllStringSubmatch(string(page), -1) {
+		name := m[1]
+		if seen[name] {
+			continue
+		}
+		seen[name] = true
+		var doc struct {
+			Releases map[string][]struct {
+				UploadTime time.Time `json:"upload_time_iso_8601"`
+			} `json:"releases"`
+		}
+		if err := registryGet("https://pypi.org/pypi/"+name+"/json", &doc); err != nil {
+			log.Printf("  Skipping pypi project %s: %v", name, err)
+			continue
+		}
+		for version, files := range doc.Releases {
+			var first time.Time
+			for _, f := range files {
+				if first.IsZero() || f.UploadTime.Before(first) {
+					first = f.UploadTime
+				}
+			}
+			if first.IsZero() || !first.After(since) {
+				continue
+			}
+			releases = append(releases, registryRelease{name, version, first.UTC()})
+		}
+	}
+	return releases, nil
+}
+
+// cratesReleases pages through the crates the user owns, then their versions. versions published
+// before crates.io recorded publishers count for the owner
+func cratesReleases(user string, since time.Time) ([]registryRelease, error) {
+	var account struct {
+		User struct {
+			ID int64 `json:"id"`
+		} `json:"user"`
+	}
+	if err := registryGet("https://crates.io/api/v1/users/"+url.PathEscape(user), &account); err != nil {
+		return nil, err
+	}
+
+	var names []string
+	for page := 1; ; page++ {
+		var list struct {
+			Crates []struct {
+				Name string `json:"name"`
+			} `json:"crates"`
+		}
+		if err := registryGet(fmt.Sprintf("https://crates.io/api/v1/crates?user_id=%d&per_page=100&page=%d", account.User.ID, page), &list); err != nil {
+			return nil, err
+		}
+		for _, c := range list.Crates {
+			names = append(names, c.Name)
+		}
+		if len(list.Crates) < 100 {
+			break
+		}
+	}
+
+	var releases []registryRelease
+	for _, name := range names {
+		var doc struct {
+			Versions []struct {
+				Num         string    `json:"num"`
+				CreatedAt   time.Time `json:"created_at"`
+				PublishedBy *struct {
+					Login string `json:"login"`
+				} `json:"published_by"`
+			} `json:"versions"`
+		}
+		if err := registryGet("https://crates.io/api/v1/crates/"+name+"/versions", &doc); err != nil {
+			log.Printf("  Skipping crate %s: %v", name, err)
+			continue
+		}
+		for _, v := range doc.Versions {
+			if v.PublishedBy != nil && !strings.EqualFold(v.PublishedBy.Login, user) {
+				continue
+			}
+			if !v.CreatedAt.After(since) {
+				continue
+			}
+			releases = append(releases, registryRelease{name, v.Num, v.CreatedAt.UTC()})
+		}
+	}
+	return releases, nil
+}
@@ -0,0 +1,200 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// --report writes a document per subject next to the plots: the estimate, the stats, whichever
+// plots this run saved, and what to be careful about before believing any of it. md is plain
+// markdown, for pasting into an issue or a wiki; pdf is the same with the plots inlined, see pdf.go
+
+var reportFormats = []string{"md", "pdf"}
+
+type reportSink struct {
+	format string
+}
+
+func (s reportSink) String() string { return "report " + s.format }
+
+func (s reportSink) Write(subject *Subject, a Analysis) error {
+	path := plotPathExt(subject.Name, "report", "."+s.format)
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	switch s.format {
+	case "md":
+		writeMarkdownReport(f, subject, a, filepath.Dir(path))
+	case "pdf":
+		if err := writePDFReport(f, subject, a); err != nil {
+			return err
+		}
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	fmt.Printf("Saved report to %s\n", path)
+	return nil
+}
+
+func (reportSink) Close() error { return nil }
+
+// writeMarkdownReport links images relative to dir, where the report is saved
+func writeMarkdownReport(w io.Writer, subject *Subject, a Analysis, dir string) {
+	fmt.Fprintf(w, "# Sleep schedule: %s\n\n", subject.Name)
+	fmt.Fprintf(w, "%d commits since %s, generated %s. Times are on %s.\n\n", a.Commits, flags.Since.Format(time.DateOnly), time.Now().Format(time.DateOnly), a.TimeBasis)
+
+	fmt.Fprintf(w, "## Estimate\n\n")
+	for _, line := range estimateLines(a) {
+		fmt.Fprintf(w, "- %s\n", line)
+	}
+	fmt.Fprintln(w)
+
+	if caveats := reportCaveats(subject, a); len(caveats) > 0 {
+		fmt.Fprintf(w, "## Caveats\n\n")
+		for _, caveat := range caveats {
+			fmt.Fprintf(w, "- %s\n", caveat)
+		}
+		fmt.Fprintln(w)
+	}
+
+	if a.Stats.Total > 0 {
+		fmt.Fprintf(w, "## Stats\n\n| | |\n|---|---|\n")
+		for _, row := range statsRows(a.Stats) {
+			fmt.Fprintf(w, "| %s | %s |\n", row[0], row[1])
+		}
+		fmt.Fprintln(w)
+	}
+
+	fmt.Fprintf(w, "## Commits by hour\n\n| hour | commits | |\n|---|--:|---|\n")
+	var maxi int
+	for _, count := range a.Hours {
+		maxi = max(maxi, count)
+	}
+	for hour, count := range a.Hours {
+		bar := ""
+		if maxi > 0 {
+			bar = strings.Repeat("█", count*20/maxi)
+		}
+		marker := ""
+		if a.Window.contains(hour) {
+			marker = " (sleep)"
+		}
+		fmt.Fprintf(w, "| %s%s | %d | %s |\n", hourString(hour), marker, count, bar)
+	}
+	fmt.Fprintln(w)
+
+	// only plots this run actually saved, the files sink may be off or a plot may have failed
+	var images []string
+	for _, p := range plotOutputs(subject, a) {
+		if _, err := os.Stat(p.path); err != nil {
+			continue
+		}
+		rel, err := filepath.Rel(dir, p.path)
+		if err != nil {
+			rel = p.path
+		}
+		images = append(images, fmt.Sprintf("![%s](%s)", p.label, filepath.ToSlash(rel)))
+	}
+	if len(images) > 0 {
+		fmt.Fprintf(w, "## Plots\n\n%s\n", strings.Join(images, "\n\n"))
+	}
+}
+
+// estimateLines is the sleep estimate as sentences, for reports
+func estimateLines(a Analysis) []string {
+	w := a.Window
+	if !w.Found {
+		return []string{"No clear sleep window: no extended low-activity period."}
+	}
+	lines := []string{fmt.Sprintf("Sleep window: **%s** (~%d hours), %s confidence (%.2f)", hourRange(w.Start, w.End), w.Hours, w.confidenceLabel(), w.Confidence)}
+	if u := w.Uncertainty; u != nil && u.Found > 0 {
+		lines = append(lines, fmt.Sprintf("Onset %s ± %s, wake %s ± %s (window found in %.0f%% of %d resamples)",
+			clockString(u.Onset*60), formatSpread(u.OnsetSpread), clockString(u.Wake*60), formatSpread(u.WakeSpread), u.Found*100, u.Resamples))
+	}
+	if w.Nap != nil {
+		lines = append(lines, fmt.Sprintf("Biphasic: second low-activity window %s (~%d hours)", hourRange(w.Nap.Start, w.Nap.End), w.Nap.Hours))
+	}
+	lines = append(lines, fmt.Sprintf("Versus round-the-clock activity: p = %.3f, effect size w = %.2f (%s)",
+		a.Significance.PValue, a.Significance.EffectSize, a.Significance.effectLabel()))
+	if a.SocialJetlag != nil {
+		lines = append(lines, "Social jetlag: "+a.SocialJetlag.String())
+	}
+	for _, j := range a.PhaseJumps {
+		lines = append(lines, "Probable travel: "+j.String())
+	}
+	for _, s := range a.Seasons {
+		if s.Significant {
+			lines = append(lines, "Seasonal shift: "+s.String())
+		}
+	}
+	return lines
+}
+
+func statsRows(st Stats) [][2]string {
+	rows := [][2]string{
+		{"active days", fmt.Sprint(st.ActiveDays)},
+		{"longest active streak", fmt.Sprintf("%d days", st.LongestStreak)},
+		{"longest gap", fmt.Sprintf("%d days", st.LongestGap)},
+		{"busiest hour", hourString(st.BusiestHour)},
+		{"quietest hour", hourString(st.QuietestHour)},
+	}
+	if st.StdDev < 0 || math.IsInf(st.StdDev, 1) {
+		rows = append(rows, [2]string{"circular mean", clockString(st.MeanTime) + " (activity is uniform)"})
+	} else {
+		rows = append(rows, [2]string{"circular mean", fmt.Sprintf("%s ± %.1fh", clockString(st.MeanTime), st.StdDev/3600)})
+	}
+	for _, p := range statsPercentiles {
+		rows = append(rows, [2]string{fmt.Sprintf("p%d", p), clockString(st.Percentiles[p])})
+	}
+	return append(rows,
+		[2]string{"avg first of day", clockString(st.FirstOfDay)},
+		[2]string{"avg last of day", clockString(st.LastOfDay)},
+		[2]string{"first of day", st.FirstOfDayQuartiles.String()},
+		[2]string{"last of day", st.LastOfDayQuartiles.String()},
+		[2]string{"quiet nights", fmt.Sprintf("%d/%d", st.QuietNights, st.Nights)},
+	)
+}
+
+// reportCaveats is everything about the data that should temper the estimate
+func reportCaveats(subject *Subject, a Analysis) []string {
+	var caveats []string
+	if !a.Significance.significant() {
+		caveats = append(caveats, fmt.Sprintf("The hours aren't distinguishable from round-the-clock activity (p = %.2f): too little data to say anything about sleep.", a.Significance.PValue))
+	}
+	if a.Window.Found && a.Window.confidenceLabel() == "low" {
+		caveats = append(caveats, "The sleep window is low confidence.")
+	}
+	if u := a.Window.Uncertainty; u != nil && (u.OnsetSpread > 60 || u.WakeSpread > 60) {
+		caveats = append(caveats, fmt.Sprintf("The window's boundaries move by more than an hour under resampling (onset ± %s, wake ± %s).", formatSpread(u.OnsetSpread), formatSpread(u.WakeSpread)))
+	}
+	if subject.SampledFrom > 0 {
+		caveats = append(caveats, fmt.Sprintf("Sampled %d of %d commits (--sample-by %s).", len(subject.Commits), subject.SampledFrom, flags.SampleBy))
+	}
+	if subject.Weights != nil && flags.WeightBy == "count" {
+		caveats = append(caveats, "Some events are weighted by their source.")
+	} else if subject.Weights != nil {
+		caveats = append(caveats, fmt.Sprintf("Commits are weighted (--weight-by %s).", flags.WeightBy))
+	}
+	if subject.Location == nil {
+		caveats = append(caveats, "No timezone configured: times are as recorded, so DST and travel blur the hours.")
+	} else if flags.UTC {
+		caveats = append(caveats, "Times are in UTC (--utc), not on the subject's own clock.")
+	}
+	if flags.Automation == "flag" {
+		for _, stream := range detectAutomation(subject) {
+			caveats = append(caveats, fmt.Sprintf("%d commits in %s look automated (%s).", len(stream.hashes), stream.repo, stream.reason))
+		}
+	}
+	if len(a.PhaseJumps) > 0 {
+		caveats = append(caveats, fmt.Sprintf("%d probable travel events: part of the data was made on another clock.", len(a.PhaseJumps)))
+	}
+	return caveats
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"golang.org/x/term"
+)
+
+// --max-repo-size: one monorepo listed by accident (a vendored fork, somebody's dataset) can be a
+// few GB, and cloning it is most of the run's memory and bandwidth. repos the forge says are bigger
+// are skipped before cloning, or, run from a terminal, cloned only if you say so. forges that don't
+// report a size (gitlab without a token, plain git servers) are cloned as usual
+
+// sizeAnswers remembers what was answered for each repo, so it's asked once per process
+var sizeAnswers = make(map[string]bool)
+
+// oversized says why a repo is too big to clone, empty if it isn't
+func oversized(info RepoInfo) string {
+	limit := int64(flags.MaxRepoSize) << 20
+	if limit <= 0 {
+		return ""
+	}
+	size := info.Size
+	if repoPath, ok := githubRepoPath(info.CloneURL); ok && size == 0 {
+		// single-repo sources skip the listing that reports sizes
+		var err error
+		if size, err = githubRepoSize(repoPath); err != nil {
+			log.Printf("  Couldn't get the size of %s: %v", info.CloneURL, err)
+			return ""
+		}
+	}
+	if size <= limit {
+		return ""
+	}
+
+	reason := fmt.Sprintf("%s, over --max-repo-size %d MB", formatBytes(size), flags.MaxRepoSize)
+	key := strings.ToLower(displayRepo(info.CloneURL))
+	clone, asked := sizeAnswers[key]
+	if !asked && term.IsTerminal(int(os.Stdin.Fd())) {
+		fmt.Fprintf(os.Stderr, "%s is %s. clone it anyway? [y/N] ", info.CloneURL, reason)
+		in := bufio.NewScanner(os.Stdin)
+		in.Scan()
+		answer := strings.ToLower(strings.TrimSpace(in.Text()))
+		clone = answer == "y" || answer == "yes"
+		sizeAnswers[key] = clone
+	}
+	if clone {
+		return ""
+	}
+	log.Printf("  Skipping %s: %s", info.CloneURL, reason)
+	return reason
+}
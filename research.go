@@ -0,0 +1,117 @@
+package main
+
+import (
+	"compress/gzip"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"log"
+	"os"
+	"sort"
+	"time"
+)
+
+// `sleep export --research` is for people studying work patterns across many developers: no names,
+// hashes, repos, emails or timestamps, only per-subject counts binned by local hour and weekday,
+// under an id that can't be reversed without the salt. local means the subject's timezone when
+// subjects.toml gives one, else the offset each commit was made with, so everyone's 09:00 lines up.
+// subjects with fewer than --min-commits commits are left out: a sparse histogram is close to a
+// list of the few times someone committed, which is what anonymizing is meant to hide
+
+const researchVersion = 1
+
+type researchFile struct {
+	Version    int               `json:"version"`
+	Exported   string            `json:"exported"` // date only
+	Days       int               `json:"days"`     // length of the observed period
+	MinCommits int               `json:"min_commits"`
+	Subjects   []researchSubject `json:"subjects"`
+}
+
+type researchSubject struct {
+	ID      string `json:"id"`
+	Commits int    `json:"commits"`
+	// both in local time. weekdays run sunday to saturday, whatever --week-start says
+	Hours    [24]int    `json:"hours"`
+	Weekdays [7][24]int `json:"weekdays"`
+	// whether hours are in a known timezone (DST-correct) or each commit's own offset
+	KnownZone bool           `json:"known_zone"`
+	Window    researchWindow `json:"window"`
+}
+
+type researchWindow struct {
+	Found      bool    `json:"found"`
+	Start      int     `json:"start,omitempty"`
+	End        int     `json:"end,omitempty"`
+	Confidence float64 `json:"confidence,omitempty"`
+}
+
+// runResearchExport is `sleep export --research`, gzipped json to stdout like a normal export
+func runResearchExport() {
+	salt := []byte(flags.ResearchSalt)
+	if len(salt) == 0 {
+		// a fresh salt per export: ids can't be matched against hashes of known names, nor across
+		// exports. pass --research-salt to follow subjects from one export to the next
+		salt = make([]byte, 32)
+		if _, err := rand.Read(salt); err != nil {
+			log.Fatalf("Failed to make salt: %v", err)
+		}
+	}
+
+	subjects := collect(flags.Subjects)
+	f := researchFile{
+		Version:    researchVersion,
+		Exported:   time.Now().UTC().Format(time.DateOnly),
+		Days:       int(time.Since(flags.Since).Hours()/24 + 0.5),
+		MinCommits: flags.MinCommits,
+	}
+	var skipped int
+	for i := range subjects {
+		subject := &subjects[i]
+		if len(subject.Commits) < flags.MinCommits {
+			skipped++
+			continue
+		}
+		f.Subjects = append(f.Subjects, toResearch(subject, salt))
+	}
+	// sorted by id, so the order says nothing about subjects.toml
+	sort.Slice(f.Subjects, func(i, j int) bool { return f.Subjects[i].ID < f.Subjects[j].ID })
+
+	data, err := json.Marshal(f)
+	if err != nil {
+		log.Fatalf("Failed to write dataset: %v", err)
+	}
+	gz := gzip.NewWriter(os.Stdout)
+	if signingKey != nil {
+		gz.Comment = signature(data)
+	}
+	if _, err := gz.Write(data); err != nil {
+		log.Fatalf("Failed to write dataset: %v", err)
+	}
+	if err := gz.Close(); err != nil {
+		log.Fatalf("Failed to write dataset: %v", err)
+	}
+	// counts only: naming who was skipped would undo the point
+	log.Printf("Exported %d subjects, left out %d with fewer than %d commits", len(f.Subjects), skipped, flags.MinCommits)
+}
+
+func toResearch(subject *Subject, salt []byte) researchSubject {
+	mac := hmac.New(sha256.New, salt)
+	mac.Write([]byte(subject.Name))
+	out := researchSubject{
+		ID:        hex.EncodeToString(mac.Sum(nil)[:8]),
+		Commits:   len(subject.Commits),
+		KnownZone: subject.Location != nil,
+	}
+	times := subject.times()
+	for _, t := range times {
+		out.Hours[t.Hour()]++
+		out.Weekdays[t.Weekday()][t.Hour()]++
+	}
+	if w := estimateSleepWindow(times); w.Found {
+		out.Window = researchWindow{Found: true, Start: w.Start, End: w.End, Confidence: w.Confidence}
+	}
+	return out
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// which repos a forge source came down to, and why. the forges list everything an account has; the
+// ones with no pushes in the window (archived ones included) are skipped before cloning, and a
+// selected one can still fail to clone. --resolution prints the lot per subject, and every json
+// analysis carries it, so coverage can be checked without reading the log
+
+const (
+	repoSelected = "selected"
+	repoFailed   = "failed"
+	repoSkipped  = "skipped"
+)
+
+// RepoResolution is what became of one repo a source listed
+type RepoResolution struct {
+	Repo   string `json:"repo"`
+	Status string `json:"status"`
+	// why it was skipped or failed, or how it was read when selected
+	Reason string `json:"reason"`
+}
+
+// staleRepo is the resolution of a repo dropped because nothing was pushed to it since --since
+func staleRepo(cloneURL string, pushed time.Time, archived bool, since time.Time) RepoResolution {
+	reason := fmt.Sprintf("last pushed %s, before --since %s", pushed.Format(time.DateOnly), since.Format(time.DateOnly))
+	if archived {
+		reason = "archived, " + reason
+	}
+	return RepoResolution{Repo: cloneURL, Status: repoSkipped, Reason: reason}
+}
+
+// selectedRepo is the resolution of a repo that was read, how and (under --mode auto) why
+func selectedRepo(info RepoInfo, how, why string) RepoResolution {
+	reason := map[string]string{"clone": "cloned", "api": "listed through the api"}[how]
+	if len(info.Branches) > 0 {
+		reason += " (branches " + strings.Join(info.Branches, ", ") + ")"
+	} else if why != "" {
+		reason += " (" + why + ")"
+	}
+	if info.Fork {
+		reason = "fork, " + reason
+	}
+	if info.SubmoduleOf != "" {
+		reason = "submodule of " + displayRepo(info.SubmoduleOf) + ", " + reason
+	}
+	return RepoResolution{Repo: info.CloneURL, Status: repoSelected, Reason: reason}
+}
+
+// resolutions is every repo the subject's sources listed, selected first
+func (s *Subject) resolutions() []RepoResolution {
+	var all []RepoResolution
+	for _, status := range []string{repoSelected, repoFailed, repoSkipped} {
+		for _, source := range s.Sources {
+			for _, r := range source.resolved {
+				if r.Status == status {
+					all = append(all, r)
+				}
+			}
+		}
+	}
+	return all
+}
+
+func printResolution(subject *Subject) {
+	all := subject.resolutions()
+	if len(all) == 0 {
+		return
+	}
+	fmt.Printf("\n=== Repos of %s ===\n", subject.Name)
+	width := 0
+	for _, r := range all {
+		width = max(width, len(displayRepo(r.Repo)))
+	}
+	counts := make(map[string]int)
+	for _, r := range all {
+		counts[r.Status]++
+		fmt.Printf("  %-8s  %-*s  %s\n", r.Status, width, displayRepo(r.Repo), r.Reason)
+	}
+	fmt.Printf("%d selected, %d failed, %d skipped\n", counts[repoSelected], counts[repoFailed], counts[repoSkipped])
+}
+
+// displayRepo is a clone url without the scheme and .git, e.g. github.com/someone/project
+func displayRepo(cloneURL string) string {
+	repo := strings.TrimSuffix(cloneURL, ".git")
+	if _, rest, ok := strings.Cut(repo, "://"); ok {
+		return rest
+	}
+	return repo
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"fmt"
+	"time"
+)
+
+// overwork indicators, for keeping an eye on yourself or a team. none of these mean anything alone;
+// the summary just counts how many trip. thresholds are guesses, loosely borrowed from shift-work guidance
+
+// commits closer together than this belong to the same working session
+const sessionGap = 2 * time.Hour
+
+type Session struct {
+	Start, End time.Time
+	Commits    int
+}
+
+func (s Session) Duration() time.Duration {
+	return s.End.Sub(s.Start)
+}
+
+// sessions splits sorted times into runs with no gap longer than gap
+func sessions(times []time.Time, gap time.Duration) []Session {
+	var out []Session
+	for _, t := range times {
+		if len(out) > 0 && t.Sub(out[len(out)-1].End) <= gap {
+			out[len(out)-1].End = t
+			out[len(out)-1].Commits++
+			continue
+		}
+		out = append(out, Session{Start: t, End: t, Commits: 1})
+	}
+	return out
+}
+
+type RiskIndicator struct {
+	Name    string
+	Value   string
+	Flagged bool
+}
+
+const (
+	// share of active days with commits between midnight and lateNightEnd
+	lateNightEnd      = 4
+	maxLateNightShare = 0.25
+	// sessions longer than this count as long
+	longSession = 10 * time.Hour
+	// share of weeks with activity on all seven days
+	maxFullWeekShare = 0.25
+	// hours the sleep window may shrink between the first and second half of the data
+	maxWindowShrink = 1
+)
+
+func assessRisk(times []time.Time) []RiskIndicator {
+	if len(times) == 0 {
+		return nil
+	}
+	var indicators []RiskIndicator
+
+	days := make(map[string]bool)
+	lateDays := make(map[string]bool)
+	for _, t := range times {
+		d := t.Format(time.DateOnly)
+		days[d] = true
+		if t.Hour() < lateNightEnd {
+			lateDays[d] = true
+		}
+	}
+	lateShare := float64(len(lateDays)) / float64(len(days))
+	indicators = append(indicators, RiskIndicator{
+		Name:    "post-midnight days",
+		Value:   fmt.Sprintf("%d/%d active days (%.0f%%) had commits %s", len(lateDays), len(days), lateShare*100, hourRange(0, lateNightEnd)),
+		Flagged: lateShare > maxLateNightShare,
+	})
+
+	weekDays := make(map[string]map[time.Weekday]bool)
+	for _, t := range times {
+		key := weekKey(t)
+		if weekDays[key] == nil {
+			weekDays[key] = make(map[time.Weekday]bool)
+		}
+		weekDays[key][t.Weekday()] = true
+	}
+	var fullWeeks int
+	for _, wd := range weekDays {
+		if len(wd) == 7 {
+			fullWeeks++
+		}
+	}
+	fullShare := float64(fullWeeks) / float64(len(weekDays))
+	indicators = append(indicators, RiskIndicator{
+		Name:    "seven-day weeks",
+		Value:   fmt.Sprintf("%d/%d active weeks had commits every day", fullWeeks, len(weekDays)),
+		Flagged: fullWeeks > 1 && fullShare > maxFullWeekShare,
+	})
+
+	var long int
+	var longest time.Duration
+	for _, s := range sessions(times, sessionGap) {
+		if s.Duration() > longSession {
+			long++
+		}
+		longest = max(longest, s.Duration())
+	}
+	indicators = append(indicators, RiskIndicator{
+		Name:    "long sessions",
+		Value:   fmt.Sprintf("%d sessions over %s, longest %s", long, longSession, longest.Round(time.Minute)),
+		Flagged: long > 1,
+	})
+
+	half := len(times) / 2
+	early := estimateSleepWindow(times[:half])
+	late := estimateSleepWindow(times[half:])
+	switch {
+	case !early.Found || !late.Found:
+		indicators = append(indicators, RiskIndicator{
+			Name:  "sleep window trend",
+			Value: "not enough data in both halves to compare",
+		})
+	default:
+		indicators = append(indicators, RiskIndicator{
+			Name:    "sleep window trend",
+			Value:   fmt.Sprintf("%dh in the first half, %dh in the second", early.Hours, late.Hours),
+			Flagged: early.Hours-late.Hours >= maxWindowShrink,
+		})
+	}
+	return indicators
+}
+
+func printRisk(subject *Subject) {
+	indicators := assessRisk(subject.times())
+	var flagged int
+	for _, ind := range indicators {
+		if ind.Flagged {
+			flagged++
+		}
+	}
+
+	level := "low"
+	switch {
+	case flagged >= 3:
+		level = "high"
+	case flagged >= 1:
+		level = "moderate"
+	}
+
+	fmt.Printf("\n=== Overwork Indicators for %s: %s (%d/%d flagged) ===\n", subject.Name, level, flagged, len(indicators))
+	for _, ind := range indicators {
+		mark := " "
+		if ind.Flagged {
+			mark = "!"
+		}
+		fmt.Printf("%s %-20s %s\n", mark, ind.Name+":", ind.Value)
+	}
+}
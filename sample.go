@@ -0,0 +1,95 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"math/rand/v2"
+	"slices"
+	"sort"
+
+	"github.com/go-git/go-git/v5/plumbing"
+)
+
+// --sample keeps tens of thousands of commits from turning the scatter plot into a solid blob and
+// every plot into a minute of rendering. uniform picks commits at random; week keeps each week's
+// share of the total, so a burst of activity stays a burst. the seed is fixed so reruns agree
+
+var sampleModes = []string{"uniform", "week"}
+
+const sampleSeed = 1
+
+func sampleSubject(subject *Subject, n int, mode string) {
+	total := len(subject.Commits)
+	if n <= 0 || total <= n {
+		return
+	}
+
+	hashes := make([]plumbing.Hash, 0, total)
+	for hash := range subject.Commits {
+		hashes = append(hashes, hash)
+	}
+	// map order is random, sort so the seed means something
+	slices.SortFunc(hashes, func(a, b plumbing.Hash) int { return slices.Compare(a[:], b[:]) })
+	rng := rand.New(rand.NewPCG(sampleSeed, sampleSeed))
+
+	var keep []plumbing.Hash
+	switch mode {
+	case "uniform":
+		rng.Shuffle(len(hashes), func(i, j int) { hashes[i], hashes[j] = hashes[j], hashes[i] })
+		keep = hashes[:n]
+	case "week":
+		keep = sampleByWeek(subject, hashes, n, rng)
+	}
+
+	kept := make(map[plumbing.Hash]bool, len(keep))
+	for _, hash := range keep {
+		kept[hash] = true
+	}
+	for hash := range subject.Commits {
+		if !kept[hash] {
+			delete(subject.Commits, hash)
+			delete(subject.Origins, hash)
+		}
+	}
+	subject.SampledFrom = total
+	log.Printf("Sampled %d of %d commits for %s (%s)", len(subject.Commits), total, subject.Name, mode)
+}
+
+// sampleByWeek gives each ISO week its proportional share of n, handing leftover slots to the
+// weeks that rounded down the most
+func sampleByWeek(subject *Subject, hashes []plumbing.Hash, n int, rng *rand.Rand) []plumbing.Hash {
+	weeks := make(map[string][]plumbing.Hash)
+	var keys []string
+	for _, hash := range hashes {
+		year, week := subject.Commits[hash].Author.When.ISOWeek()
+		key := fmt.Sprintf("%d-W%02d", year, week)
+		if weeks[key] == nil {
+			keys = append(keys, key)
+		}
+		weeks[key] = append(weeks[key], hash)
+	}
+	sort.Strings(keys)
+
+	quota := make(map[string]int, len(keys))
+	remainder := make(map[string]float64, len(keys))
+	assigned := 0
+	for _, key := range keys {
+		exact := float64(n) * float64(len(weeks[key])) / float64(len(hashes))
+		quota[key] = int(exact)
+		remainder[key] = exact - float64(quota[key])
+		assigned += quota[key]
+	}
+	byRemainder := slices.Clone(keys)
+	sort.SliceStable(byRemainder, func(i, j int) bool { return remainder[byRemainder[i]] > remainder[byRemainder[j]] })
+	for _, key := range byRemainder[:n-assigned] {
+		quota[key]++
+	}
+
+	var keep []plumbing.Hash
+	for _, key := range keys {
+		week := weeks[key]
+		rng.Shuffle(len(week), func(i, j int) { week[i], week[j] = week[j], week[i] })
+		keep = append(keep, week[:quota[key]]...)
+	}
+	return keep
+}
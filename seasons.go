@@ -0,0 +1,189 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"math/rand/v2"
+	"time"
+)
+
+// people sleep longer and later in winter, and a year of commits pools both. the months are split
+// into the four meteorological seasons, pooled across years, and each season with enough commits
+// gets its own sleep window. each is compared with the season before it by a permutation test:
+// the two seasons' commits are shuffled together and split again, and the p-value is how often the
+// shuffled midpoints are at least as far apart as the real ones. (against the rest of the year, one
+// late winter drags the rest later and every season looks shifted.) seasons are named by their
+// months rather than summer and winter, which swap south of the equator
+
+const (
+	minSeasonCommits   = 50
+	seasonPermutations = 200
+	seasonSeed         = 1
+	seasonAlpha        = 0.05
+)
+
+// seasonMonths are the meteorological seasons, each starting with its first month
+var seasonMonths = []time.Month{time.December, time.March, time.June, time.September}
+
+type SeasonWindow struct {
+	Season  string      `json:"season"` // e.g. "Dec-Feb"
+	Commits int         `json:"commits"`
+	Window  SleepWindow `json:"window"`
+	// middle of the window, minutes after midnight
+	Midpoint float64 `json:"midpoint"`
+	// the season compared with, the one before with a window. empty for the first of two
+	Versus string `json:"versus,omitempty"`
+	// minutes from Versus's midpoint to this season's, positive if later
+	Shift       float64 `json:"shift_minutes"`
+	PValue      float64 `json:"p_value"`
+	Significant bool    `json:"significant"`
+}
+
+func seasonOf(t time.Time) int {
+	return int(t.Month()) % 12 / 3
+}
+
+func seasonName(i int) string {
+	first := seasonMonths[i]
+	last := time.Month((int(first)+1)%12 + 1)
+	return first.String()[:3] + "-" + last.String()[:3]
+}
+
+// compareSeasons gives a window per season that has enough commits, nil unless at least two do
+func compareSeasons(times []time.Time) []SeasonWindow {
+	var hours [4][]int
+	for _, t := range times {
+		s := seasonOf(t)
+		hours[s] = append(hours[s], t.Hour())
+	}
+	var seasons []SeasonWindow
+	var seasonHours [][]int
+	for i, own := range hours {
+		if len(own) < minSeasonCommits {
+			continue
+		}
+		w := windowFromCounts(binHours(own))
+		s := SeasonWindow{Season: seasonName(i), Commits: len(own), Window: w, PValue: 1}
+		if w.Found {
+			s.Midpoint = windowMidpoint(w)
+		}
+		seasons = append(seasons, s)
+		seasonHours = append(seasonHours, own)
+	}
+	if len(seasons) < 2 {
+		return nil
+	}
+
+	for i := range seasons {
+		s := &seasons[i]
+		// the previous season with a window, around the year unless that's comparing two seasons twice
+		prev := -1
+		for j := 1; j < len(seasons) && prev < 0; j++ {
+			k := i - j
+			if k < 0 {
+				if len(seasons) == 2 {
+					break
+				}
+				k += len(seasons)
+			}
+			if seasons[k].Window.Found {
+				prev = k
+			}
+		}
+		if !s.Window.Found || prev < 0 {
+			continue
+		}
+		s.Versus = seasons[prev].Season
+		s.Shift = clockDelta(seasons[prev].Midpoint, s.Midpoint)
+		s.PValue = permuteShift(seasonHours[i], seasonHours[prev], math.Abs(s.Shift))
+		s.Significant = s.Shift != 0 && s.PValue < seasonAlpha
+	}
+	return seasons
+}
+
+// permuteShift is how often shuffling own and other together moves the midpoints at least observed
+// minutes apart
+func permuteShift(own, other []int, observed float64) float64 {
+	pooled := append(append([]int(nil), own...), other...)
+	rng := rand.New(rand.NewPCG(seasonSeed, seasonSeed))
+	var atLeast int
+	for range seasonPermutations {
+		rng.Shuffle(len(pooled), func(i, j int) { pooled[i], pooled[j] = pooled[j], pooled[i] })
+		a, b := windowFromCounts(binHours(pooled[:len(own)])), windowFromCounts(binHours(pooled[len(own):]))
+		// a shuffle with no window on one side didn't move anything
+		if a.Found && b.Found && math.Abs(clockDelta(windowMidpoint(b), windowMidpoint(a))) >= observed {
+			atLeast++
+		}
+	}
+	// +1s count the observed split as one of the shuffles, like testUniform
+	return float64(atLeast+1) / float64(seasonPermutations+1)
+}
+
+func binHours(hours []int) []int {
+	counts := make([]int, 24)
+	for _, h := range hours {
+		counts[h]++
+	}
+	return counts
+}
+
+func (s SeasonWindow) String() string {
+	if !s.Window.Found {
+		return fmt.Sprintf("%s: %d commits, no clear sleep window", s.Season, s.Commits)
+	}
+	str := fmt.Sprintf("%s: %s (~%d hours), %d commits", s.Season, hourRange(s.Window.Start, s.Window.End), s.Window.Hours, s.Commits)
+	if s.Versus == "" {
+		return str
+	}
+	if s.Shift == 0 {
+		return str + ", same midpoint as " + s.Versus
+	}
+	direction := "later"
+	if s.Shift < 0 {
+		direction = "earlier"
+	}
+	str += fmt.Sprintf(", midpoint %s %s than %s (p = %.3f)", formatSpread(math.Abs(s.Shift)), direction, s.Versus, s.PValue)
+	if s.Significant {
+		str += ", significant"
+	}
+	return str
+}
+
+func printSeasons(seasons []SeasonWindow) {
+	if len(seasons) == 0 {
+		return
+	}
+	fmt.Printf("\n=== Seasons ===\n")
+	for _, s := range seasons {
+		fmt.Printf("%s\n", s)
+	}
+}
+
+// plotSeasons draws a small histogram per season with its window, like --plot-monthly
+func plotSeasons(subject *Subject, seasons []SeasonWindow, outputPath string) error {
+	if len(seasons) == 0 {
+		return fmt.Errorf("fewer than 2 seasons have the %d commits needed for a sleep window", minSeasonCommits)
+	}
+	var groups [4][]time.Time
+	for _, t := range subject.times() {
+		groups[seasonOf(t)] = append(groups[seasonOf(t)], t)
+	}
+	var titles []string
+	var facets [][]time.Time
+	for _, s := range seasons {
+		title := fmt.Sprintf("%s (%d)", s.Season, s.Commits)
+		if s.Shift != 0 {
+			title += fmt.Sprintf("\n%+.0fm from %s, p = %.3f", s.Shift, s.Versus, s.PValue)
+			if s.Significant {
+				title += " *"
+			}
+		}
+		titles = append(titles, title)
+		for i := range seasonMonths {
+			if seasonName(i) == s.Season {
+				facets = append(facets, groups[i])
+			}
+		}
+	}
+	return plotHourFacets(subject, titles, facets, outputPath)
+}
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+)
+
+// --serve exposes analyses over a small JSON API so other services can ask for them:
+//   GET  /subjects                     names and commit counts from the last collection
+//   GET  /subjects/{name}/histogram    hourly counts, stats and the sleep window for one subject
+//   POST /analyze                      {"name": "...", "sources": ["github.com/..."], "emails": [...]}, collected on the spot
+// every request needs "Authorization: Bearer <token>"
+
+// ServerConfig is the [server] table in sleep.toml
+type ServerConfig struct {
+	// shared secret for clients. SLEEP_API_TOKEN overrides it
+	Token string `toml:"token"`
+}
+
+type apiServer struct {
+	token string
+
+	mu       sync.RWMutex
+	subjects []Subject
+}
+
+// collection isn't safe to run twice at once (timings, the shared fetches), and is heavy anyway.
+// held by every collection while a server is up
+var collectMu sync.Mutex
+
+func serve(addr string, subjects []Subject) *apiServer {
+	token := os.Getenv("SLEEP_API_TOKEN")
+	if token == "" {
+		token = config.Server.Token
+	}
+	if token == "" {
+		log.Fatal("--serve needs a token: set SLEEP_API_TOKEN or [server] token in sleep.toml")
+	}
+
+	s := &apiServer{token: token, subjects: expandDerived(subjects)}
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /subjects", s.listSubjects)
+	mux.HandleFunc("GET /subjects/{name}/histogram", s.subjectHistogram)
+	mux.HandleFunc("POST /analyze", s.analyzeAdHoc)
+
+	go func() {
+		log.Printf("Serving API on %s", addr)
+		log.Fatal(http.ListenAndServe(addr, s.authorize(mux)))
+	}()
+	return s
+}
+
+// refresh swaps in a new collection, e.g. from a --watch cycle
+func (s *apiServer) refresh(subjects []Subject) {
+	s.mu.Lock()
+	s.subjects = expandDerived(subjects)
+	s.mu.Unlock()
+}
+
+func (s *apiServer) authorize(next http.Handler) http.Handler {
+	want := []byte("Bearer " + s.token)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if subtle.ConstantTimeCompare([]byte(r.Header.Get("Authorization")), want) != 1 {
+			writeJSONError(w, http.StatusUnauthorized, "missing or wrong bearer token")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (s *apiServer) listSubjects(w http.ResponseWriter, r *http.Request) {
+	type entry struct {
+		Name    string `json:"name"`
+		Commits int    `json:"commits"`
+	}
+	s.mu.RLock()
+	entries := make([]entry, 0, len(s.subjects))
+	for _, subject := range s.subjects {
+		entries = append(entries, entry{subject.Name, len(subject.Commits)})
+	}
+	s.mu.RUnlock()
+	writeJSON(w, http.StatusOK, entries)
+}
+
+func (s *apiServer) subjectHistogram(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("name")
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	for i := range s.subjects {
+		if s.subjects[i].Name == name {
+			writeJSON(w, http.StatusOK, analyze(&s.subjects[i]))
+			return
+		}
+	}
+	writeJSONError(w, http.StatusNotFound, "no subject named "+name)
+}
+
+func (s *apiServer) analyzeAdHoc(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Name    string   `json:"name"`
+		Sources []string `json:"sources"`
+		Emails  []string `json:"emails"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSONError(w, http.StatusBadRequest, "invalid JSON: "+err.Error())
+		return
+	}
+	if req.Name == "" || len(req.Sources) == 0 {
+		writeJSONError(w, http.StatusBadRequest, "name and sources are required")
+		return
+	}
+
+	for _, source := range req.Sources {
+		if !remoteSource(source) {
+			writeJSONError(w, http.StatusBadRequest, "only forge and http(s) sources can be analyzed here: "+source)
+			return
+		}
+	}
+
+	collectMu.Lock()
+	defer collectMu.Unlock()
+	subjects := []Subject{getSubject(req.Name, req.Sources, req.Emails)}
+	prepareSubjects(subjects)
+
+	writeJSON(w, http.StatusOK, analyze(&subjects[0]))
+}
+
+// remoteSource is whether a POST /analyze source is on the network. callers don't get to read the
+// server's own files (bundles, browser history, chat exports) or run its plugins
+func remoteSource(source string) bool {
+	path, _ := splitChatSource(source)
+	if _, err := os.Stat(path); err == nil {
+		return false
+	}
+	if _, ok := pluginFor(source); ok {
+		return false
+	}
+	if strings.HasPrefix(source, "http://") || strings.HasPrefix(source, "https://") {
+		return true
+	}
+	return source != "" && !strings.Contains(source, "://") && !strings.ContainsAny(source[:1], "/.~\\")
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Printf("Failed to write response: %v", err)
+	}
+}
+
+func writeJSONError(w http.ResponseWriter, status int, msg string) {
+	writeJSON(w, status, map[string]string{"error": msg})
+}
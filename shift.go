@@ -0,0 +1,196 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"sort"
+	"strings"
+
+	"github.com/go-git/go-git/v5/plumbing"
+)
+
+// on-call rotations and night shifts: some weeks the subject is up by day, others by night, and
+// averaging the two finds no sleep at all. each week is reduced to the circular mean of its commit
+// times, the weeks are clustered on the clock, and if they fall into 2 or 3 well separated groups
+// that take turns, each group is reported as its own subject ("<name>-shift-1", ...), earliest
+// activity first. a single move from one phase to another (a new job, a new timezone) isn't a
+// rotation and is left alone
+
+const (
+	minShiftWeekCommits = 8
+	minShiftWeeks       = 6
+	// regime centers must be at least this far apart on the clock
+	minShiftSeparation = 6 * 60 // minutes
+	// and each must hold at least this share of the weeks
+	minShiftShare = 0.2
+)
+
+type shiftWeek struct {
+	key    string
+	center float64 // minutes since midnight
+	hashes []plumbing.Hash
+}
+
+type shiftRegime struct {
+	center float64
+	weeks  []shiftWeek
+}
+
+// expandShifts appends a subject per regime after every subject with a rotating schedule
+func expandShifts(subjects []Subject) []Subject {
+	var expanded []Subject
+	for _, subject := range subjects {
+		expanded = append(expanded, subject)
+		regimes := detectShifts(&subject)
+		if regimes == nil {
+			continue
+		}
+		var summary []string
+		for i, regime := range regimes {
+			shift := derivedSubject(subject, fmt.Sprintf("%s-shift-%d", subject.Name, i+1))
+			for _, week := range regime.weeks {
+				for _, hash := range week.hashes {
+					shift.Commits[hash] = subject.Commits[hash]
+					shift.Origins[hash] = subject.Origins[hash]
+				}
+			}
+			expanded = append(expanded, shift)
+			summary = append(summary, fmt.Sprintf("%s centered ~%s (%d weeks)", shift.Name, clockString(regime.center*60), len(regime.weeks)))
+		}
+		log.Printf("%s looks like rotating shifts: %s", subject.Name, strings.Join(summary, ", "))
+	}
+	return expanded
+}
+
+// detectShifts returns the regimes of a rotating schedule, earliest center first, or nil
+func detectShifts(subject *Subject) []shiftRegime {
+	weeks := shiftWeeks(subject)
+	if len(weeks) < minShiftWeeks {
+		return nil
+	}
+	for k := 3; k >= 2; k-- {
+		regimes := clusterWeeks(weeks, k)
+		if regimes != nil && alternates(weeks, regimes) {
+			sort.Slice(regimes, func(i, j int) bool { return regimes[i].center < regimes[j].center })
+			return regimes
+		}
+	}
+	return nil
+}
+
+// shiftWeeks is every week with enough commits to have a center, in order
+func shiftWeeks(subject *Subject) []shiftWeek {
+	byWeek := make(map[string]*shiftWeek)
+	minutes := make(map[string][]float64)
+	for hash, c := range subject.Commits {
+		t := c.Author.When
+		if subject.Location != nil {
+			t = t.In(subject.Location)
+		}
+		key := weekKey(t)
+		if byWeek[key] == nil {
+			byWeek[key] = &shiftWeek{key: key}
+		}
+		byWeek[key].hashes = append(byWeek[key].hashes, hash)
+		minutes[key] = append(minutes[key], float64(t.Hour()*60+t.Minute()))
+	}
+
+	var weeks []shiftWeek
+	for key, week := range byWeek {
+		if len(week.hashes) < minShiftWeekCommits {
+			continue
+		}
+		week.center, _ = circularMinutes(minutes[key])
+		weeks = append(weeks, *week)
+	}
+	sort.Slice(weeks, func(i, j int) bool { return weeks[i].key < weeks[j].key })
+	return weeks
+}
+
+// clusterWeeks is k-means on the clock face. nil if the clusters aren't distinct enough to call
+// regimes
+func clusterWeeks(weeks []shiftWeek, k int) []shiftRegime {
+	// start from weeks spread around the clock: the first, then repeatedly the one furthest from
+	// every center so far
+	centers := []float64{weeks[0].center}
+	for len(centers) < k {
+		best, bestDist := 0.0, -1.0
+		for _, week := range weeks {
+			nearest := math.Inf(1)
+			for _, c := range centers {
+				nearest = math.Min(nearest, clockDistance(week.center, c))
+			}
+			if nearest > bestDist {
+				best, bestDist = week.center, nearest
+			}
+		}
+		centers = append(centers, best)
+	}
+
+	assignment := make([]int, len(weeks))
+	for range 20 {
+		for i, week := range weeks {
+			nearest := 0
+			for c := range centers {
+				if clockDistance(week.center, centers[c]) < clockDistance(week.center, centers[nearest]) {
+					nearest = c
+				}
+			}
+			assignment[i] = nearest
+		}
+		for c := range centers {
+			var members []float64
+			for i, week := range weeks {
+				if assignment[i] == c {
+					members = append(members, week.center)
+				}
+			}
+			if len(members) > 0 {
+				centers[c], _ = circularMinutes(members)
+			}
+		}
+	}
+
+	regimes := make([]shiftRegime, k)
+	for c := range regimes {
+		regimes[c].center = centers[c]
+	}
+	for i, week := range weeks {
+		regimes[assignment[i]].weeks = append(regimes[assignment[i]].weeks, week)
+	}
+	for i, r := range regimes {
+		if float64(len(r.weeks)) < minShiftShare*float64(len(weeks)) || len(r.weeks) < 2 {
+			return nil
+		}
+		for _, other := range regimes[i+1:] {
+			if clockDistance(r.center, other.center) < minShiftSeparation {
+				return nil
+			}
+		}
+	}
+	return regimes
+}
+
+// alternates is true if the weeks switch regime back and forth, rather than once for good
+func alternates(weeks []shiftWeek, regimes []shiftRegime) bool {
+	regimeOf := make(map[string]int)
+	for r, regime := range regimes {
+		for _, week := range regime.weeks {
+			regimeOf[week.key] = r
+		}
+	}
+	var switches int
+	for i := 1; i < len(weeks); i++ {
+		if regimeOf[weeks[i].key] != regimeOf[weeks[i-1].key] {
+			switches++
+		}
+	}
+	return switches >= len(regimes)
+}
+
+// clockDistance is the shorter way around the clock between two times of day, in minutes
+func clockDistance(a, b float64) float64 {
+	d := math.Mod(math.Abs(a-b), 24*60)
+	return math.Min(d, 24*60-d)
+}
@@ -0,0 +1,240 @@
+package main
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+)
+
+// for studies that run for months: with --sign-key, every snapshot and export is signed with a local
+// ed25519 key, and loading one checks the signature, so a dataset can be shown to be what sleep wrote.
+// snapshots end in a toml comment carrying the signature of everything before it; exports carry it
+// in the gzip header's comment, over the uncompressed json. `sleep keygen <path>` makes a key pair
+// (path and path.pub); others verify with --verify-key path.pub
+
+const (
+	signatureMarker = "sleep-signature "
+	signaturePrefix = signatureMarker + "ed25519 "
+)
+
+var (
+	signingKey   ed25519.PrivateKey
+	verifyingKey ed25519.PublicKey
+
+	ErrBadSignature = errors.New("signature doesn't match")
+	ErrUnsigned     = errors.New("not signed")
+)
+
+// loadKeys reads --sign-key and --verify-key. the signing key verifies too, unless --verify-key says otherwise
+func loadKeys() {
+	if flags.SignKey != "" {
+		key, err := readPrivateKey(flags.SignKey)
+		if err != nil {
+			log.Fatalf("Failed to load --sign-key: %v", err)
+		}
+		signingKey = key
+		verifyingKey = key.Public().(ed25519.PublicKey)
+	}
+	if flags.VerifyKey != "" {
+		key, err := readPublicKey(flags.VerifyKey)
+		if err != nil {
+			log.Fatalf("Failed to load --verify-key: %v", err)
+		}
+		verifyingKey = key
+	}
+}
+
+func readPrivateKey(path string) (ed25519.PrivateKey, error) {
+	block, err := readPEM(path)
+	if err != nil {
+		return nil, err
+	}
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	ed, ok := key.(ed25519.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("%s isn't an ed25519 key", path)
+	}
+	return ed, nil
+}
+
+// readPublicKey also takes a private key, and uses its public half
+func readPublicKey(path string) (ed25519.PublicKey, error) {
+	block, err := readPEM(path)
+	if err != nil {
+		return nil, err
+	}
+	if block.Type == "PRIVATE KEY" {
+		key, err := readPrivateKey(path)
+		if err != nil {
+			return nil, err
+		}
+		return key.Public().(ed25519.PublicKey), nil
+	}
+	key, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	ed, ok := key.(ed25519.PublicKey)
+	if !ok {
+		return nil, fmt.Errorf("%s isn't an ed25519 key", path)
+	}
+	return ed, nil
+}
+
+func readPEM(path string) (*pem.Block, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("%s isn't pem", path)
+	}
+	return block, nil
+}
+
+// runKeygen is `sleep keygen <path>`
+func runKeygen(args []string) {
+	if len(args) != 1 {
+		log.Fatal("usage: sleep keygen <path>")
+	}
+	path := args[0]
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		log.Fatalf("Failed to generate key: %v", err)
+	}
+	privDER, err := x509.MarshalPKCS8PrivateKey(priv)
+	if err != nil {
+		log.Fatalf("Failed to encode key: %v", err)
+	}
+	pubDER, err := x509.MarshalPKIXPublicKey(pub)
+	if err != nil {
+		log.Fatalf("Failed to encode key: %v", err)
+	}
+	// O_EXCL: never overwrite a key that may have signed a study's worth of data
+	for _, file := range []struct {
+		path  string
+		block *pem.Block
+		mode  os.FileMode
+	}{
+		{path, &pem.Block{Type: "PRIVATE KEY", Bytes: privDER}, 0o600},
+		{path + ".pub", &pem.Block{Type: "PUBLIC KEY", Bytes: pubDER}, 0o644},
+	} {
+		f, err := os.OpenFile(file.path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, file.mode)
+		if err != nil {
+			log.Fatalf("Failed to write key: %v", err)
+		}
+		if err := pem.Encode(f, file.block); err != nil {
+			log.Fatalf("Failed to write key: %v", err)
+		}
+		if err := f.Close(); err != nil {
+			log.Fatalf("Failed to write key: %v", err)
+		}
+	}
+	log.Printf("Wrote %s and %s (key %s)", path, path+".pub", keyID(pub))
+}
+
+// keyID is a short fingerprint, so a signature says which key made it
+func keyID(pub ed25519.PublicKey) string {
+	sum := sha256.Sum256(pub)
+	return hex.EncodeToString(sum[:8])
+}
+
+// signature is "sleep-signature ed25519 <key id> <base64 signature>" for data
+func signature(data []byte) string {
+	pub := signingKey.Public().(ed25519.PublicKey)
+	return signaturePrefix + keyID(pub) + " " + base64.StdEncoding.EncodeToString(ed25519.Sign(signingKey, data))
+}
+
+// verifySignature checks sig (as made by signature) over data. without a key to check against, a
+// signed file is let through with a warning
+func verifySignature(data []byte, sig, name string) error {
+	if sig == "" {
+		if verifyingKey != nil {
+			return ErrUnsigned
+		}
+		return nil
+	}
+	fields := strings.Fields(strings.TrimPrefix(sig, signaturePrefix))
+	if !strings.HasPrefix(sig, signaturePrefix) || len(fields) != 2 {
+		return fmt.Errorf("malformed signature %q", sig)
+	}
+	if verifyingKey == nil {
+		log.Printf("%s is signed by key %s, but there's no --verify-key to check it", name, fields[0])
+		return nil
+	}
+	raw, err := base64.StdEncoding.DecodeString(fields[1])
+	if err != nil {
+		return fmt.Errorf("malformed signature: %v", err)
+	}
+	if fields[0] != keyID(verifyingKey) {
+		return fmt.Errorf("%w: signed by key %s, verifying with %s", ErrBadSignature, fields[0], keyID(verifyingKey))
+	}
+	if !ed25519.Verify(verifyingKey, data, raw) {
+		return ErrBadSignature
+	}
+	return nil
+}
+
+// signSnapshot appends the signature line to a snapshot's toml, if signing
+func signSnapshot(data []byte) []byte {
+	if signingKey == nil {
+		return data
+	}
+	return append(data, "# "+signature(data)+"\n"...)
+}
+
+// splitSnapshot separates a snapshot's toml from its signature line, "" if unsigned
+func splitSnapshot(data []byte) (body []byte, sig string) {
+	trimmed := bytes.TrimRight(data, "\n")
+	i := bytes.LastIndexByte(trimmed, '\n') + 1
+	last := string(trimmed[i:])
+	if !strings.HasPrefix(last, "# "+signatureMarker) {
+		return data, ""
+	}
+	return data[:i], strings.TrimPrefix(last, "# ")
+}
+
+// verifySnapshots is `sleep snapshots verify`: every snapshot's signature, against --verify-key
+func verifySnapshots(dirs []string) {
+	if verifyingKey == nil {
+		log.Fatal("sleep snapshots verify needs --verify-key or --sign-key")
+	}
+	var checked, failed int
+	for _, dir := range dirs {
+		snapshots, err := listSnapshots(dir)
+		if err != nil {
+			log.Printf("Failed to list snapshots in %s: %v", dir, err)
+			continue
+		}
+		for _, s := range snapshots {
+			data, err := os.ReadFile(s.path)
+			if err == nil {
+				body, sig := splitSnapshot(data)
+				err = verifySignature(body, sig, s.path)
+			}
+			checked++
+			if err != nil {
+				failed++
+				fmt.Printf("FAIL %s: %v\n", s.path, err)
+			}
+		}
+	}
+	log.Printf("Verified %d snapshots, %d failed", checked, failed)
+	if failed > 0 {
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"math/rand/v2"
+)
+
+// with a few dozen commits any hour histogram has gaps, and the estimator will happily call the
+// widest one sleep. this asks whether the hours differ from round-the-clock activity at all: the
+// chi-square distance from uniform, against the same distance for many uniformly random subjects
+// with the same commit count. p is how often chance alone looks at least this uneven. past a few
+// thousand commits (weighted ones count once per weight) the simulation is millions of draws per
+// subject, and the chi-square distribution it approximates is exact enough to use instead
+
+const (
+	uniformTrials = 1000
+	uniformSeed   = 1
+	// above this many commits p comes from the chi-square distribution rather than simulation.
+	// that's over 80 expected per hour, well past where the approximation holds
+	maxSimulatedCommits = 2000
+	// below this p the hours are called distinguishable from uniform
+	significanceLevel = 0.05
+)
+
+type Significance struct {
+	PValue float64 `json:"p_value"`
+	// Cohen's w, sqrt(chi2/n): about 0.1 is a small departure from uniform, 0.3 medium, 0.5 large
+	EffectSize float64 `json:"effect_size"`
+}
+
+func (s Significance) significant() bool {
+	return s.PValue < significanceLevel
+}
+
+func (s Significance) effectLabel() string {
+	switch {
+	case s.EffectSize >= 0.5:
+		return "large"
+	case s.EffectSize >= 0.3:
+		return "medium"
+	case s.EffectSize >= 0.1:
+		return "small"
+	default:
+		return "negligible"
+	}
+}
+
+// testUniform is the randomization test of counts (commits per hour) against uniform
+func testUniform(counts []int) Significance {
+	var n int
+	for _, count := range counts {
+		n += count
+	}
+	if n == 0 {
+		return Significance{PValue: 1}
+	}
+	observed := chiSquareUniform(counts, n)
+	effect := math.Sqrt(observed / float64(n))
+	if n > maxSimulatedCommits {
+		return Significance{PValue: chiSquareSurvival(observed, len(counts)-1), EffectSize: effect}
+	}
+
+	rng := rand.New(rand.NewPCG(uniformSeed, uniformSeed))
+	simulated := make([]int, len(counts))
+	var atLeast int
+	for range uniformTrials {
+		clear(simulated)
+		for range n {
+			simulated[rng.IntN(len(simulated))]++
+		}
+		if chiSquareUniform(simulated, n) >= observed {
+			atLeast++
+		}
+	}
+	return Significance{
+		// +1s count the observed data as one of the trials, so p is never exactly 0
+		PValue:     float64(atLeast+1) / float64(uniformTrials+1),
+		EffectSize: effect,
+	}
+}
+
+func chiSquareUniform(counts []int, n int) float64 {
+	expected := float64(n) / float64(len(counts))
+	var chi2 float64
+	for _, count := range counts {
+		d := float64(count) - expected
+		chi2 += d * d / expected
+	}
+	return chi2
+}
+
+// chiSquareSurvival is P(X >= x) for X chi-square with df degrees of freedom: the regularized upper
+// incomplete gamma function Q(df/2, x/2), by its series below df/2+1 and a continued fraction above
+func chiSquareSurvival(x float64, df int) float64 {
+	a, x := float64(df)/2, x/2
+	if x <= 0 {
+		return 1
+	}
+	lg, _ := math.Lgamma(a)
+	scale := math.Exp(a*math.Log(x) - x - lg)
+	const eps, tiny = 1e-15, 1e-300
+
+	if x < a+1 {
+		// the series gives the lower tail
+		term := 1 / a
+		sum := term
+		for i := 1; i < 1000 && term > sum*eps; i++ {
+			term *= x / (a + float64(i))
+			sum += term
+		}
+		return max(0, 1-scale*sum)
+	}
+	// modified Lentz
+	b := x + 1 - a
+	c, d := 1/tiny, 1/b
+	h := d
+	for i := 1; i < 1000; i++ {
+		an := -float64(i) * (float64(i) - a)
+		b += 2
+		d = an*d + b
+		if math.Abs(d) < tiny {
+			d = tiny
+		}
+		c = b + an/c
+		if math.Abs(c) < tiny {
+			c = tiny
+		}
+		d = 1 / d
+		delta := d * c
+		h *= delta
+		if math.Abs(delta-1) < eps {
+			break
+		}
+	}
+	return scale * h
+}
+
+func printSignificance(s Significance) {
+	fmt.Printf("\n=== Versus Round-the-Clock Activity ===\n")
+	fmt.Printf("p = %.3f, effect size w = %.2f (%s)\n", s.PValue, s.EffectSize, s.effectLabel())
+	if !s.significant() {
+		fmt.Printf("Not distinguishable from uniform activity: too little data to say anything about sleep\n")
+	}
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/ProtonMail/go-crypto/openpgp/armor"
+	"github.com/ProtonMail/go-crypto/openpgp/packet"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+// a gpg-signed commit carries a second timestamp: when the signature was made, which git doesn't
+// let you set. signing happens as the commit is written, so the two agree unless the commit's
+// date was set by hand (GIT_COMMITTER_DATE, --date) or history was rewritten without re-signing.
+// --signature-time:
+//
+//	check    count the signed commits whose dates disagree with their signatures (the default)
+//	prefer   also use the signature's time instead of the author date, in the author's offset
+//	off      don't look
+//
+// ssh and x509 signatures carry no time and are ignored
+
+var signatureTimeModes = []string{"check", "prefer", "off"}
+
+// signatureSkew is how far a signature may be from the committer date before they disagree.
+// signing waits on a passphrase prompt or a hardware key tap, so not zero
+const signatureSkew = 10 * time.Minute
+
+// signatureTime is when a commit's pgp signature was made, ok false if it has none we can read
+func signatureTime(c *object.Commit) (time.Time, bool) {
+	if !strings.HasPrefix(c.PGPSignature, "-----BEGIN PGP SIGNATURE-----") {
+		return time.Time{}, false
+	}
+	block, err := armor.Decode(strings.NewReader(c.PGPSignature))
+	if err != nil {
+		return time.Time{}, false
+	}
+	p, err := packet.Read(block.Body)
+	if err != nil {
+		return time.Time{}, false
+	}
+	sig, ok := p.(*packet.Signature)
+	if !ok {
+		return time.Time{}, false
+	}
+	return sig.CreationTime, true
+}
+
+// checkSignatureTimes compares signed commits' dates with their signatures, logs the disagreements
+// and, with prefer, moves the commits to their signature times
+func checkSignatureTimes(subject *Subject, mode string) {
+	if mode == "off" {
+		return
+	}
+	var signed, disagree int
+	var worst time.Duration
+	for hash, c := range subject.Commits {
+		signedAt, ok := signatureTime(c)
+		if !ok {
+			continue
+		}
+		signed++
+		skew := c.Committer.When.Sub(signedAt).Abs()
+		if skew > signatureSkew {
+			disagree++
+			worst = max(worst, skew)
+		}
+		if mode == "prefer" {
+			// a copy: commits can be shared with other subjects built from the same repos
+			moved := *c
+			moved.Author.When = signedAt.In(c.Author.When.Location())
+			subject.Commits[hash] = &moved
+		}
+	}
+	if signed == 0 {
+		return
+	}
+	if disagree == 0 {
+		log.Printf("%s: all %d signed commits agree with their signatures", subject.Name, signed)
+		return
+	}
+	log.Printf("%s: %d of %d signed commits are dated more than %s from their signatures (up to %s), rewritten history or hand-set dates%s",
+		subject.Name, disagree, signed, signatureSkew, roundSkew(worst), preferHint(mode))
+}
+
+func roundSkew(d time.Duration) string {
+	if d >= 48*time.Hour {
+		return fmt.Sprintf("%dd", int(d.Hours()/24))
+	}
+	return d.Round(time.Minute).String()
+}
+
+func preferHint(mode string) string {
+	if mode == "prefer" {
+		return "; using the signature times"
+	}
+	return "; --signature-time prefer uses the signature times"
+}
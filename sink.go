@@ -0,0 +1,273 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// where a finished analysis goes. output() works out each subject once and hands it to every
+// --sink in turn, so a new destination is a new Sink here rather than another branch in output():
+//
+//	stdout        the terminal report (or --format tsv)
+//	files         snapshot and png plots, per -w and the --plot flags
+//	http=URL      POST each subject's analysis as json
+//	sqlite=PATH   append each analysis to a table, through the sqlite3 cli
+
+type Sink interface {
+	Write(subject *Subject, analysis Analysis) error
+	// Close is called once after every subject was written
+	Close() error
+}
+
+var sinkKinds = []string{"stdout", "files", "http", "sqlite"}
+
+// openSinks builds the sinks named by --sink. subjects is the whole run, for reports that compare
+// a subject with others (group breakdowns)
+func openSinks(specs []string, subjects []Subject) []Sink {
+	var sinks []Sink
+	for _, spec := range specs {
+		kind, arg, _ := strings.Cut(spec, "=")
+		switch kind {
+		case "stdout":
+			if flags.Nights == "csv" || flags.Nights == "json" {
+				continue
+			}
+			sinks = append(sinks, &stdoutSink{subjects: subjects})
+		case "files":
+			sinks = append(sinks, fileSink{})
+		case "http":
+			sinks = append(sinks, httpSink{url: arg})
+		case "sqlite":
+			sinks = append(sinks, &sqliteSink{path: arg, run: time.Now().UTC()})
+		}
+	}
+	if flags.Nights != "" {
+		sinks = append(sinks, &nightLogSink{format: flags.Nights})
+	}
+	// last, so the report can link the plots the files sink just saved
+	if flags.Report != "" {
+		sinks = append(sinks, reportSink{format: flags.Report})
+	}
+	return sinks
+}
+
+// validateSinks is for flag parsing, so a typo fails before anything is collected
+func validateSinks(specs []string) error {
+	for _, spec := range specs {
+		kind, arg, _ := strings.Cut(spec, "=")
+		switch kind {
+		case "stdout", "files":
+		case "http", "sqlite":
+			if arg == "" {
+				return fmt.Errorf("--sink %s needs a target, e.g. %s=...", kind, kind)
+			}
+		default:
+			return fmt.Errorf("unknown --sink %q, expected one of %v", spec, sinkKinds)
+		}
+	}
+	return nil
+}
+
+type stdoutSink struct {
+	subjects []Subject
+}
+
+func (s *stdoutSink) String() string { return "stdout" }
+
+func (s *stdoutSink) Write(subject *Subject, a Analysis) error {
+	window := a.Window
+	if flags.Format == "tsv" {
+		printTSV(subject, a)
+	} else if flags.StdOut {
+		if flags.Resolution {
+			printResolution(subject)
+		}
+		fmt.Printf("Times for %s on %s\n", subject.Name, a.TimeBasis)
+		if subject.SampledFrom > 0 {
+			fmt.Printf("Sampled %d of %d commits (--sample-by %s)\n", len(subject.Commits), subject.SampledFrom, flags.SampleBy)
+		}
+		if err := printSleepHisto(subject, window); err != nil {
+			log.Printf("Failed to print sleep histogram for %s: %v", subject.Name, err)
+		}
+		printStats(subject)
+		printWeekdays(subject)
+		printSocialJetlag(a.SocialJetlag)
+		printTimezoneCandidates(inferTimezone(subject.recordedTimes()))
+		if flags.InferLocation {
+			printLocationHint(inferLocation(subject.times()))
+		}
+		printSignificance(a.Significance)
+		printSleepEstimate(subject, window)
+		printNightsSummary(a.Nights, int(time.Since(flags.Since).Hours()/24))
+		printJumps(a.PhaseJumps)
+		printSeasons(a.Seasons)
+		if len(subject.Members) > 0 {
+			printGroupBreakdown(subject, s.subjects)
+		}
+		if flags.Risk {
+			printRisk(subject)
+		}
+	}
+	if flags.StdOutScatter {
+		printScatter(subject, window)
+	}
+	return nil
+}
+
+func (s *stdoutSink) Close() error { return nil }
+
+type fileSink struct{}
+
+func (fileSink) String() string { return "files" }
+
+func (fileSink) Write(subject *Subject, a Analysis) error {
+	if flags.Write {
+		save(subject, a.Hours)
+	}
+	for _, p := range plotOutputs(subject, a) {
+		if err := p.draw(p.path); err != nil {
+			log.Printf("Failed to save %s for %s: %v", p.label, subject.Name, err)
+		} else {
+			fmt.Printf("Saved %s to %s\n", p.label, p.path)
+		}
+	}
+	return nil
+}
+
+type plotOutput struct {
+	kind  string
+	label string
+	path  string
+	draw  func(string) error
+}
+
+// plotOutputs is every plot the --plot flags ask for, in the order they're drawn
+func plotOutputs(subject *Subject, a Analysis) []plotOutput {
+	window := a.Window
+	plots := []struct {
+		enabled bool
+		kind    string
+		label   string
+		draw    func(string) error
+	}{
+		{flags.PlotScatter, "scatter", "scatter plot", func(path string) error { return plotCommitsScatter(subject, window, path) }},
+		{flags.PlotHisto, "histogram", "histogram", func(path string) error { return plotCommitsHistogram(subject, window, path) }},
+		{flags.PlotHeatmap, "heatmap", "heatmap", func(path string) error { return plotCommitsHeatmap(subject, window, path) }},
+		{flags.PlotPunchcard, "punchcard", "punch card", func(path string) error { return plotCommitsPunchcard(subject, path) }},
+		{flags.PlotMonthly, "monthly", "monthly plot", func(path string) error { return plotCommitsMonthly(subject, path) }},
+		{flags.PlotTrend, "trend", "trend plot", func(path string) error { return plotSleepTrend(subject, a.PhaseJumps, path) }},
+		{flags.PlotWheel, "wheel", "year wheel", func(path string) error { return plotYearWheel(subject, window, path) }},
+		{flags.PlotDuration, "duration", "duration plot", func(path string) error { return plotSleepDuration(subject, a.Nights, path) }},
+		{flags.PlotGaps, "gaps", "gap histogram", func(path string) error { return plotCommitGaps(subject, a.Gaps, path) }},
+		{flags.PlotDayBounds, "daybounds", "first/last of day plot", func(path string) error { return plotDayBounds(subject, a, path) }},
+		{flags.PlotSeasons, "seasons", "season plot", func(path string) error { return plotSeasons(subject, a.Seasons, path) }},
+	}
+	var out []plotOutput
+	for _, p := range plots {
+		if p.enabled {
+			out = append(out, plotOutput{p.kind, p.label, plotPath(subject.Name, p.kind), p.draw})
+		}
+	}
+	if flags.PlotAnimate {
+		out = append(out, plotOutput{"animation", "animation", plotPathExt(subject.Name, "animation", ".gif"), func(path string) error { return plotAnimation(subject, path) }})
+	}
+	return out
+}
+
+func (fileSink) Close() error { return nil }
+
+type httpSink struct {
+	url string
+}
+
+func (s httpSink) String() string { return s.url }
+
+func (s httpSink) Write(subject *Subject, a Analysis) error {
+	body, err := json.Marshal(a)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest("POST", s.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "go-commit-plotter")
+
+	resp, err := newHTTPClient(30 * time.Second).Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("sink responded %s", resp.Status)
+	}
+	return nil
+}
+
+func (httpSink) Close() error { return nil }
+
+// sqliteSink batches every analysis of the run into one transaction for the sqlite3 cli, which
+// keeps a cgo driver out of the build. one row per subject per run, hours as a json array
+type sqliteSink struct {
+	path string
+	run  time.Time
+	sql  strings.Builder
+}
+
+const sqliteSchema = `CREATE TABLE IF NOT EXISTS analyses (
+	run TEXT NOT NULL,
+	subject TEXT NOT NULL,
+	commits INTEGER NOT NULL,
+	sleep_found INTEGER NOT NULL,
+	sleep_start INTEGER,
+	sleep_end INTEGER,
+	sleep_hours INTEGER,
+	confidence REAL,
+	timezone TEXT,
+	hours TEXT NOT NULL,
+	PRIMARY KEY (run, subject)
+);
+`
+
+func (s *sqliteSink) String() string { return s.path }
+
+func (s *sqliteSink) Write(subject *Subject, a Analysis) error {
+	hours, err := json.Marshal(a.Hours)
+	if err != nil {
+		return err
+	}
+	// no window leaves its columns NULL, so a missing start isn't read as midnight
+	found, window := 0, "NULL, NULL, NULL, NULL"
+	if a.Window.Found {
+		found = 1
+		window = fmt.Sprintf("%d, %d, %d, %f", a.Window.Start, a.Window.End, a.Window.Hours, a.Window.Confidence)
+	}
+	fmt.Fprintf(&s.sql, "INSERT OR REPLACE INTO analyses VALUES (%s, %s, %d, %d, %s, %s, %s);\n",
+		sqlQuote(s.run.Format(time.RFC3339)), sqlQuote(a.Subject), a.Commits, found, window,
+		sqlQuote(a.Timezone), sqlQuote(string(hours)))
+	return nil
+}
+
+func (s *sqliteSink) Close() error {
+	if s.sql.Len() == 0 {
+		return nil
+	}
+	cmd := exec.Command("sqlite3", s.path)
+	cmd.Stdin = strings.NewReader(sqliteSchema + "BEGIN;\n" + s.sql.String() + "COMMIT;\n")
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("sqlite3: %v: %s", err, bytes.TrimSpace(out))
+	}
+	log.Printf("Wrote analyses to %s", s.path)
+	return nil
+}
+
+func sqlQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", "''") + "'"
+}
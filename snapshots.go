@@ -0,0 +1,195 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// snapshots are kept per subject, one file per run: snapshots/<subject>/<utc time>.toml. --watch
+// writes one every interval, forever, so they can be pruned, either after every save (when any
+// --keep flag is set) or on demand with `sleep snapshots prune`:
+//
+//	--keep-last N      the newest N
+//	--keep-weekly N    the newest in each of the last N weeks that have one
+//	--keep-monthly N   the newest in each of the last N months that have one
+//
+// a snapshot is kept if any rule keeps it. pruning also compacts: a snapshot identical to the one
+// before it says nothing new and goes, whatever the rules. older versions wrote one flat file per
+// day straight into snapshots/, those are pruned as one more subject
+
+const snapshotStamp = "2006-01-02T15-04-05Z"
+
+type snapshot struct {
+	path string
+	when time.Time
+}
+
+// snapshotPath is where a snapshot of subject taken at t goes
+func snapshotPath(subjectName string, t time.Time) string {
+	return filepath.Join(savePath, sanitizeFilename(subjectName), t.UTC().Format(snapshotStamp)+".toml")
+}
+
+func retentionEnabled() bool {
+	return flags.KeepLast > 0 || flags.KeepWeekly > 0 || flags.KeepMonthly > 0
+}
+
+// runSnapshots is `sleep snapshots prune` and `sleep snapshots verify`
+func runSnapshots(args []string) {
+	if len(args) == 1 && args[0] == "verify" {
+		verifySnapshots(snapshotDirs())
+		return
+	}
+	if len(args) != 1 || args[0] != "prune" {
+		log.Fatal("usage: sleep snapshots prune [--keep-last N] [--keep-weekly N] [--keep-monthly N] [--dry-run]\n       sleep snapshots verify --verify-key key.pub")
+	}
+	if !retentionEnabled() {
+		log.Printf("No --keep flags given, only compacting identical snapshots")
+	}
+	dirs := snapshotDirs()
+	var removed int
+	for _, dir := range dirs {
+		removed += pruneSnapshots(dir, flags.DryRun)
+	}
+	if flags.DryRun {
+		log.Printf("Would remove %d snapshots", removed)
+	} else {
+		log.Printf("Removed %d snapshots", removed)
+	}
+}
+
+// snapshotDirs is the snapshots root, for the old flat files, and each subject's dir under it
+func snapshotDirs() []string {
+	dirs := []string{savePath}
+	entries, err := os.ReadDir(savePath)
+	if err != nil {
+		log.Fatalf("Failed to read %s: %v", savePath, err)
+	}
+	for _, entry := range entries {
+		if entry.IsDir() {
+			dirs = append(dirs, filepath.Join(savePath, entry.Name()))
+		}
+	}
+	return dirs
+}
+
+// listSnapshots is the snapshots directly in dir, oldest first
+func listSnapshots(dir string) ([]snapshot, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var snapshots []snapshot
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasSuffix(name, ".toml") {
+			continue
+		}
+		stem := strings.TrimSuffix(name, ".toml")
+		when, err := time.Parse(snapshotStamp, stem)
+		if err != nil {
+			// the old daily layout
+			if when, err = time.Parse(time.DateOnly, stem); err != nil {
+				continue // digest state and anything else that isn't a snapshot
+			}
+		}
+		snapshots = append(snapshots, snapshot{filepath.Join(dir, name), when})
+	}
+	sort.Slice(snapshots, func(i, j int) bool { return snapshots[i].when.Before(snapshots[j].when) })
+	return snapshots, nil
+}
+
+// pruneSnapshots applies compaction and the --keep rules to the snapshots in dir, returning how many
+// went (or would go, with dryRun)
+func pruneSnapshots(dir string, dryRun bool) int {
+	snapshots, err := listSnapshots(dir)
+	if err != nil {
+		log.Printf("Failed to list snapshots in %s: %v", dir, err)
+		return 0
+	}
+	// the rules count what's left after compaction, so --keep-last 5 keeps 5 different snapshots
+	drop := compactSnapshots(snapshots)
+	if retentionEnabled() {
+		var distinct []snapshot
+		for _, s := range snapshots {
+			if drop[s.path] == "" {
+				distinct = append(distinct, s)
+			}
+		}
+		keep := retainedSnapshots(distinct, flags.KeepLast, flags.KeepWeekly, flags.KeepMonthly)
+		for _, s := range distinct {
+			if !keep[s.path] {
+				drop[s.path] = "past retention"
+			}
+		}
+	}
+
+	var removed int
+	for _, s := range snapshots {
+		reason := drop[s.path]
+		if reason == "" {
+			continue
+		}
+		if dryRun {
+			fmt.Printf("would remove %s (%s)\n", s.path, reason)
+			removed++
+			continue
+		}
+		if err := os.Remove(s.path); err != nil {
+			log.Printf("Failed to remove %s: %v", s.path, err)
+			continue
+		}
+		removed++
+	}
+	return removed
+}
+
+// compactSnapshots marks each snapshot whose contents equal the last one kept before it
+func compactSnapshots(snapshots []snapshot) map[string]string {
+	drop := make(map[string]string)
+	var previous []byte
+	for _, s := range snapshots {
+		data, err := os.ReadFile(s.path)
+		if err != nil {
+			log.Printf("Failed to read %s: %v", s.path, err)
+			previous = nil
+			continue
+		}
+		if previous != nil && bytes.Equal(data, previous) {
+			drop[s.path] = "same as the one before"
+			continue
+		}
+		previous = data
+	}
+	return drop
+}
+
+// retainedSnapshots is which snapshots (oldest first) the --keep rules hold on to
+func retainedSnapshots(snapshots []snapshot, last, weekly, monthly int) map[string]bool {
+	keep := make(map[string]bool)
+	for i := len(snapshots) - 1; i >= 0 && i >= len(snapshots)-last; i-- {
+		keep[snapshots[i].path] = true
+	}
+	for _, rule := range []struct {
+		n      int
+		bucket func(time.Time) string
+	}{
+		{weekly, weekKey},
+		{monthly, func(t time.Time) string { return t.Format("2006-01") }},
+	} {
+		seen := make(map[string]bool)
+		for i := len(snapshots) - 1; i >= 0 && len(seen) < rule.n; i-- {
+			bucket := rule.bucket(snapshots[i].when)
+			if !seen[bucket] {
+				seen[bucket] = true
+				keep[snapshots[i].path] = true
+			}
+		}
+	}
+	return keep
+}
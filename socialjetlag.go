@@ -0,0 +1,85 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"time"
+)
+
+// social jetlag is sleep science's name for the gap between when people sleep on free days and
+// before workdays: the middle of the night on free days minus the middle before workdays. a night
+// belongs to the day it leads into, so friday and saturday nights are free and sunday night is a
+// workday's. commits are split that way, noon to noon, and each half gets its own sleep window
+
+// each half needs this many commits for its window to mean anything
+const minSocialJetlagCommits = 40
+
+type SocialJetlag struct {
+	// middle of each half's sleep window, minutes after midnight
+	WorkMidpoint float64 `json:"work_midpoint"`
+	FreeMidpoint float64 `json:"free_midpoint"`
+	// free minus work, positive if sleep runs later on free days
+	Minutes     float64 `json:"minutes"`
+	WorkCommits int     `json:"work_commits"`
+	FreeCommits int     `json:"free_commits"`
+}
+
+// freeNight says whether t falls in a night before a weekend day, counting from the noon before
+func freeNight(t time.Time) bool {
+	d := t.Add(12 * time.Hour).Weekday()
+	return d == time.Saturday || d == time.Sunday
+}
+
+// socialJetlag compares the sleep windows of free nights and work nights. nil without enough
+// commits on either side or without a window on both
+func socialJetlag(times []time.Time) *SocialJetlag {
+	var work, free []time.Time
+	for _, t := range times {
+		if freeNight(t) {
+			free = append(free, t)
+		} else {
+			work = append(work, t)
+		}
+	}
+	if len(work) < minSocialJetlagCommits || len(free) < minSocialJetlagCommits {
+		return nil
+	}
+	workWindow, freeWindow := estimateSleepWindow(work), estimateSleepWindow(free)
+	if !workWindow.Found || !freeWindow.Found {
+		return nil
+	}
+	j := &SocialJetlag{
+		WorkMidpoint: windowMidpoint(workWindow),
+		FreeMidpoint: windowMidpoint(freeWindow),
+		WorkCommits:  len(work),
+		FreeCommits:  len(free),
+	}
+	j.Minutes = clockDelta(j.WorkMidpoint, j.FreeMidpoint)
+	return j
+}
+
+// windowMidpoint is the middle of w in minutes after midnight
+func windowMidpoint(w SleepWindow) float64 {
+	return math.Mod(float64(w.Start)*60+float64(w.Hours)*30, 24*60)
+}
+
+func (j SocialJetlag) String() string {
+	direction := "later"
+	if j.Minutes < 0 {
+		direction = "earlier"
+	}
+	s := fmt.Sprintf("%s %s on free days", formatSpread(math.Abs(j.Minutes)), direction)
+	if math.Round(j.Minutes/5) == 0 {
+		s = "none, same on free days"
+	}
+	return fmt.Sprintf("%s (sleep midpoint %s before workdays, %s before free days)",
+		s, clockString(j.WorkMidpoint*60), clockString(j.FreeMidpoint*60))
+}
+
+func printSocialJetlag(j *SocialJetlag) {
+	if j == nil {
+		fmt.Printf("social jetlag:     not enough commits on both free and work nights (%d each)\n", minSocialJetlagCommits)
+		return
+	}
+	fmt.Printf("social jetlag:     %s\n", j)
+}
@@ -0,0 +1,158 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+// stack exchange profiles as sources: stackoverflow.com/users/22656 (or any other site's users/<id>
+// url) lists that user's answers and comments through the api as events. an answer takes a while
+// to write and a comment doesn't, so answers count for more, whatever --weight-by is. the api gives
+// utc, so set the subject's timezone. STACKEXCHANGE_KEY raises the daily quota from 300 requests
+
+const stackExchangeAPI = "https://api.stackexchange.com/2.3"
+
+// ruleStackExchange is the audit rule for a profile's posts
+const ruleStackExchange = "stack exchange user"
+
+// stackExchangeKinds are what's listed per user, and what each is worth
+var stackExchangeKinds = []struct {
+	path, id string
+	weight   int
+}{
+	{"answers", "answer_id", 3},
+	{"comments", "comment_id", 1},
+}
+
+// sourceWeights is what sources say their events are worth, for the ones that aren't commits.
+// they stand in for --weight-by, which can't size an answer
+var sourceWeights = make(map[plumbing.Hash]int)
+
+// stackExchangeUser is the site and user id of a profile url, ok false if it isn't one
+func stackExchangeUser(parsed *url.URL) (site, id string, ok bool) {
+	host := strings.TrimPrefix(strings.ToLower(parsed.Hostname()), "www.")
+	switch {
+	case host == "stackoverflow.com", host == "superuser.com", host == "serverfault.com",
+		host == "askubuntu.com", host == "mathoverflow.net", host == "stackapps.com",
+		strings.HasSuffix(host, ".stackexchange.com"), strings.HasSuffix(host, ".stackoverflow.com"):
+	default:
+		return "", "", false
+	}
+	// /users/22656/jon-skeet
+	parts := strings.Split(strings.Trim(parsed.Path, "/"), "/")
+	if len(parts) < 2 || parts[0] != "users" {
+		return "", "", false
+	}
+	if _, err := strconv.Atoi(parts[1]); err != nil {
+		return "", "", false
+	}
+	// the api takes a site's domain as well as its short name
+	return host, parts[1], true
+}
+
+func getStackExchangeSource(rawURL, site, id, subjectName string, since time.Time) (*Source, map[string][]*object.Commit) {
+	log.Printf("Processing source: %s (stack exchange)\n", rawURL)
+	defer timed("api", site+"/users/"+id)()
+
+	match := audited(subjectName, rawURL, func(c *object.Commit) (bool, string) {
+		if !c.Committer.When.After(since) {
+			return false, ruleTooOld
+		}
+		return true, ruleStackExchange
+	})
+	var events []*object.Commit
+	for _, kind := range stackExchangeKinds {
+		posts, err := stackExchangePosts(site, id, kind.path, kind.id, since)
+		if err != nil {
+			log.Printf("  Failed to list %s for %s: %v", kind.path, rawURL, err)
+			noteFailure(err)
+			continue
+		}
+		for postID, when := range posts {
+			c := activityEvent(site, kind.path+" "+postID, "", "", when)
+			if match(c) {
+				sourceWeights[c.Hash] = kind.weight
+				events = append(events, c)
+			}
+		}
+		log.Printf("  Found %d %s\n", len(posts), kind.path)
+	}
+	return &Source{url: rawURL, host: site, user: id}, map[string][]*object.Commit{rawURL: events}
+}
+
+// stackExchangePosts is when each of a user's answers or comments since --since was posted, by id
+func stackExchangePosts(site, id, kind, idField string, since time.Time) (map[string]time.Time, error) {
+	client := newHTTPClient(30 * time.Second)
+	posts := make(map[string]time.Time)
+	for page := 1; ; page++ {
+		query := url.Values{
+			"site":     {site},
+			"fromdate": {strconv.FormatInt(since.Unix(), 10)},
+			"pagesize": {"100"},
+			"page":     {strconv.Itoa(page)},
+			"sort":     {"creation"},
+		}
+		if key := os.Getenv("STACKEXCHANGE_KEY"); key != "" {
+			query.Set("key", key)
+		}
+		req, err := http.NewRequest("GET", stackExchangeAPI+"/users/"+id+"/"+kind+"?"+query.Encode(), nil)
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("User-Agent", "go-commit-plotter")
+		resp, err := client.Do(req)
+		if err != nil {
+			return nil, err
+		}
+		body, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		// errors come back as json too; a throttle is 502 in the body and 400 on the wire
+		var result struct {
+			Items        []map[string]json.RawMessage `json:"items"`
+			HasMore      bool                         `json:"has_more"`
+			Backoff      int                          `json:"backoff"`
+			ErrorID      int                          `json:"error_id"`
+			ErrorMessage string                       `json:"error_message"`
+		}
+		if err := json.Unmarshal(body, &result); err != nil {
+			if resp.StatusCode != http.StatusOK {
+				return nil, apiStatusError("Stack Exchange", resp)
+			}
+			return nil, fmt.Errorf("failed to parse JSON response: %w", err)
+		}
+		if result.ErrorID == 502 {
+			return nil, fmt.Errorf("Stack Exchange API request failed: %s: %w", result.ErrorMessage, ErrRateLimited)
+		}
+		if result.ErrorID != 0 {
+			return nil, fmt.Errorf("Stack Exchange API request failed: %s", result.ErrorMessage)
+		}
+		for _, item := range result.Items {
+			var created int64
+			if err := json.Unmarshal(item["creation_date"], &created); err != nil {
+				continue
+			}
+			posts[string(item[idField])] = time.Unix(created, 0).UTC()
+		}
+		if !result.HasMore {
+			return posts, nil
+		}
+		// the api asks for a pause when it's busy, and blocks clients that don't take it
+		if result.Backoff > 0 {
+			time.Sleep(time.Duration(result.Backoff) * time.Second)
+		}
+	}
+}
@@ -0,0 +1,198 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+// --incremental: each source remembers when it was last collected without a failure, and what it
+// matched, in cache/state/. the next run only asks for commits since then (so the forges skip repos
+// nobody pushed to, and walks stop early) and merges the stored ones back in for the analysis.
+// commits older than --since fall out of the stored history as the window moves on. the state is
+// kept per subject, source and emails, so changing who a source matches starts it over
+
+const stateDir = "state"
+
+// incrementalOverlap is how far before the last run an incremental one starts looking. commits are
+// pushed a while after they're made, and clocks are off; overlapping a bit catches them, and the
+// repeats are the same hashes, so they merge away
+const incrementalOverlap = 24 * time.Hour
+
+type sourceState struct {
+	Source string `json:"source"`
+	// when the last clean collection started
+	Collected time.Time `json:"collected"`
+	// the --since that history goes back to; a longer window than this needs a full collection
+	From    time.Time     `json:"from"`
+	History subjectEvents `json:"history"`
+}
+
+func sourceStatePath(subjectName, sourceURL string, emails []string) string {
+	emails = slices.Clone(emails)
+	slices.Sort(emails)
+	key := strings.Join([]string{subjectName, sourceURL, strings.Join(emails, ","), strconv.FormatBool(flags.Strict)}, "\n")
+	sum := sha256.Sum256([]byte(key))
+	return filepath.Join(cacheDir, stateDir, hex.EncodeToString(sum[:12])+".json")
+}
+
+// planSource is resolveSource, made incremental with --incremental: the source is resolved, and
+// later cloned, over the window since its last clean collection
+func planSource(sourceURL, subjectName string, emails []string) *sourcePlan {
+	if !flags.Incremental {
+		return resolveSource(sourceURL, subjectName, emails, flags.Since)
+	}
+	path := sourceStatePath(subjectName, sourceURL, emails)
+	state, ok := loadSourceState(path)
+	since := flags.Since
+	if ok && !state.From.After(since) && state.Collected.After(since) {
+		log.Printf("%s last collected %s, only looking for newer commits (%d stored)",
+			sourceURL, state.Collected.Format(time.DateTime), len(state.History.Events))
+		since = state.Collected.Add(-incrementalOverlap)
+	} else {
+		state = sourceState{}
+	}
+
+	started := time.Now()
+	failed := failureTotal()
+	plan := resolveSource(sourceURL, subjectName, emails, since)
+	if plan == nil {
+		// nothing new, but the stored history can still count
+		plan = &sourcePlan{subject: subjectName, emails: emails, since: since}
+	}
+	plan.url, plan.statePath, plan.state, plan.started = sourceURL, path, state, started
+	plan.failed = failureTotal() != failed
+	return plan
+}
+
+// finishSource is a source's commits once its clones are done, merged with its stored history and
+// stored for next time with --incremental
+func finishSource(p *sourcePlan) (*Source, map[string][]*object.Commit) {
+	if p.statePath == "" {
+		return p.source, p.commitsByRepo
+	}
+	source, commitsByRepo := p.source, p.commitsByRepo
+	clean := source != nil && !p.failed && !slices.ContainsFunc(source.resolved, func(r RepoResolution) bool {
+		return r.Status == repoFailed
+	})
+
+	stored, err := fromEvents(p.state.History)
+	if err != nil {
+		log.Printf("Ignoring the stored history of %s: %v", p.url, err)
+		stored = Subject{}
+	}
+	if source == nil {
+		if len(stored.Commits) == 0 {
+			return nil, nil
+		}
+		log.Printf("Using the %d stored commits of %s", len(stored.Commits), p.url)
+		source = &Source{url: p.url}
+	}
+	if commitsByRepo == nil {
+		commitsByRepo = make(map[string][]*object.Commit)
+	}
+	mergeStored(commitsByRepo, stored, flags.Since)
+
+	if clean {
+		saveSourceState(p.statePath, sourceState{
+			Source:    p.url,
+			Collected: p.started,
+			From:      flags.Since,
+			History:   toEvents(sourceHistory(p.subject, commitsByRepo)),
+		})
+	} else {
+		log.Printf("%s didn't collect cleanly, its next --incremental run starts from the last clean one", p.url)
+	}
+	return source, commitsByRepo
+}
+
+// mergeStored adds the stored commits still inside the window to what was just collected
+func mergeStored(commitsByRepo map[string][]*object.Commit, stored Subject, since time.Time) {
+	for hash, c := range stored.Commits {
+		if !c.Committer.When.After(since) {
+			continue
+		}
+		if w, ok := stored.Weights[hash]; ok {
+			sourceWeights[hash] = w
+		}
+		for _, origin := range stored.Origins[hash] {
+			if !slices.ContainsFunc(commitsByRepo[origin], func(fresh *object.Commit) bool { return fresh.Hash == hash }) {
+				commitsByRepo[origin] = append(commitsByRepo[origin], c)
+			}
+		}
+	}
+}
+
+// sourceHistory is one source's commits as a subject, for toEvents
+func sourceHistory(subjectName string, commitsByRepo map[string][]*object.Commit) *Subject {
+	history := &Subject{
+		Name:    subjectName,
+		Commits: make(map[plumbing.Hash]*object.Commit),
+		Origins: make(map[plumbing.Hash][]string),
+	}
+	for repoURL, commits := range commitsByRepo {
+		for _, c := range commits {
+			history.Commits[c.Hash] = c
+			history.Origins[c.Hash] = append(history.Origins[c.Hash], repoURL)
+			if w, ok := sourceWeights[c.Hash]; ok {
+				if history.Weights == nil {
+					history.Weights = make(map[plumbing.Hash]int)
+				}
+				history.Weights[c.Hash] = w
+			}
+		}
+	}
+	for _, origins := range history.Origins {
+		slices.Sort(origins)
+	}
+	return history
+}
+
+func loadSourceState(path string) (sourceState, bool) {
+	var state sourceState
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return state, false
+	}
+	if err != nil {
+		log.Printf("Failed to read %s: %v", path, err)
+		return state, false
+	}
+	if err := json.Unmarshal(data, &state); err != nil {
+		log.Printf("Failed to parse %s, collecting from scratch: %v", path, err)
+		return sourceState{}, false
+	}
+	return state, true
+}
+
+func saveSourceState(path string, state sourceState) {
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		log.Printf("could not make dir %s: %v", dir, err)
+		return
+	}
+	data, err := json.Marshal(state)
+	if err != nil {
+		log.Printf("encode %s: %v", path, err)
+		return
+	}
+	if err := writeFileAtomic(path, func(w io.Writer) error {
+		_, err := w.Write(data)
+		return err
+	}); err != nil {
+		log.Printf("could not write file %s: %v", path, err)
+	}
+}
@@ -0,0 +1,312 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"time"
+)
+
+// everything in here works on a flat, sorted slice of commit timestamps rather than the commit map,
+// so the analysis doesn't care where the events came from
+
+const secondsPerDay = 24 * 60 * 60
+
+// times flattens a subject's commits into sorted author timestamps.
+// commits carry a fixed offset, which is wrong for half the year anywhere with DST;
+// if we know the subject's zone, convert so 09:00 stays 09:00 across the switch
+func (s *Subject) times() []time.Time {
+	times := s.recordedTimes()
+	if s.Location != nil {
+		for i := range times {
+			times[i] = times[i].In(s.Location)
+		}
+	}
+	return times
+}
+
+// recordedTimes is times() without zone conversion, i.e. with the offsets the commits were made with.
+// weighted commits are repeated, which keeps every analysis downstream weighted for free
+func (s *Subject) recordedTimes() []time.Time {
+	times := make([]time.Time, 0, len(s.Commits))
+	for hash, c := range s.Commits {
+		weight := 1
+		if w, ok := s.Weights[hash]; ok {
+			weight = w
+		}
+		for range weight {
+			times = append(times, c.Author.When)
+		}
+	}
+	sort.Slice(times, func(i, j int) bool { return times[i].Before(times[j]) })
+	return times
+}
+
+func secondsOfDay(t time.Time) int {
+	return t.Hour()*3600 + t.Minute()*60 + t.Second()
+}
+
+// clockString formats seconds since midnight as HH:MM (or H:MM AM, see --clock), wrapping around the day
+func clockString(seconds float64) string {
+	s := int(math.Round(seconds)) % secondsPerDay
+	if s < 0 {
+		s += secondsPerDay
+	}
+	if flags.Clock == 12 {
+		h12, suffix := twelveHour(s / 3600)
+		return fmt.Sprintf("%d:%02d %s", h12, (s%3600)/60, suffix)
+	}
+	return fmt.Sprintf("%02d:%02d", s/3600, (s%3600)/60)
+}
+
+func hourCounts(times []time.Time) []int {
+	counts := make([]int, 24)
+	for _, t := range times {
+		counts[t.Hour()]++
+	}
+	return counts
+}
+
+// circularMean treats time of day as an angle so 23:00 and 01:00 average to midnight instead of noon.
+// returns the mean in seconds since midnight and the mean resultant length R in [0, 1]
+func circularMean(seconds []float64) (float64, float64) {
+	if len(seconds) == 0 {
+		return 0, 0
+	}
+	var sumSin, sumCos float64
+	for _, s := range seconds {
+		theta := 2 * math.Pi * s / secondsPerDay
+		sumSin += math.Sin(theta)
+		sumCos += math.Cos(theta)
+	}
+	n := float64(len(seconds))
+	mean := math.Atan2(sumSin/n, sumCos/n)
+	if mean < 0 {
+		mean += 2 * math.Pi
+	}
+	r := math.Hypot(sumSin/n, sumCos/n)
+	return mean / (2 * math.Pi) * secondsPerDay, r
+}
+
+// circularStdDev converts R into a spread in seconds. sqrt(-2 ln R) is the usual definition;
+// it blows up as R approaches 0, i.e. activity spread evenly around the clock
+func circularStdDev(r float64) float64 {
+	if r <= 0 {
+		return math.Inf(1)
+	}
+	return math.Sqrt(-2*math.Log(r)) / (2 * math.Pi) * secondsPerDay
+}
+
+type Stats struct {
+	Total        int     `json:"total"`
+	BusiestHour  int     `json:"busiest_hour"`
+	QuietestHour int     `json:"quietest_hour"`
+	MeanTime     float64 `json:"mean_time"` // seconds since midnight
+	StdDev       float64 `json:"std_dev"`   // seconds
+	// percentiles are taken on time of day unwrapped at the quietest hour,
+	// otherwise a night owl's 01:00 commits would land at the "start" of the day
+	Percentiles map[int]float64 `json:"percentiles"`
+	FirstOfDay  float64         `json:"first_of_day"`
+	LastOfDay   float64         `json:"last_of_day"`
+	ActiveDays  int             `json:"active_days"`
+	Nights      int             `json:"nights"`
+	QuietNights int             `json:"quiet_nights"`
+	// the longest runs of consecutive days with and without commits, between the first and last
+	// active day
+	LongestStreak int `json:"longest_streak"`
+	LongestGap    int `json:"longest_gap"`
+	// the spread of each day's first and last commit, closer to wake and bedtime than the pooled
+	// hours are
+	FirstOfDayQuartiles Quartiles `json:"first_of_day_quartiles"`
+	LastOfDayQuartiles  Quartiles `json:"last_of_day_quartiles"`
+}
+
+// Quartiles of times of day, in seconds since midnight
+type Quartiles struct {
+	P25    float64 `json:"p25"`
+	Median float64 `json:"median"`
+	P75    float64 `json:"p75"`
+}
+
+var statsPercentiles = []int{10, 25, 50, 75, 90}
+
+// nights are considered quiet if nothing happened between midnight and this hour
+const quietNightEnd = 6
+
+func computeStats(times []time.Time) Stats {
+	var st Stats
+	st.Total = len(times)
+	if st.Total == 0 {
+		return st
+	}
+
+	counts := hourCounts(times)
+	for hour, count := range counts {
+		if count > counts[st.BusiestHour] {
+			st.BusiestHour = hour
+		}
+		if count < counts[st.QuietestHour] {
+			st.QuietestHour = hour
+		}
+	}
+
+	seconds := make([]float64, len(times))
+	for i, t := range times {
+		seconds[i] = float64(secondsOfDay(t))
+	}
+	var r float64
+	st.MeanTime, r = circularMean(seconds)
+	st.StdDev = circularStdDev(r)
+
+	origin := float64(st.QuietestHour * 3600)
+	unwrapped := make([]float64, len(seconds))
+	for i, s := range seconds {
+		unwrapped[i] = math.Mod(s-origin+secondsPerDay, secondsPerDay)
+	}
+	sort.Float64s(unwrapped)
+	st.Percentiles = make(map[int]float64)
+	for _, p := range statsPercentiles {
+		idx := int(math.Round(float64(p) / 100 * float64(len(unwrapped)-1)))
+		st.Percentiles[p] = unwrapped[idx] + origin
+	}
+
+	firsts, lasts := dayBounds(times, origin)
+	activeDays := make(map[string]bool)
+	nightActive := make(map[string]bool)
+	for _, t := range times {
+		activeDays[t.Format(time.DateOnly)] = true
+		if t.Hour() < quietNightEnd {
+			nightActive[t.Format(time.DateOnly)] = true
+		}
+	}
+	st.ActiveDays = len(activeDays)
+	st.LongestStreak, st.LongestGap = streaks(times)
+	st.FirstOfDay, _ = circularMean(firsts)
+	st.LastOfDay, _ = circularMean(lasts)
+	st.FirstOfDayQuartiles = dayQuartiles(firsts, origin)
+	st.LastOfDayQuartiles = dayQuartiles(lasts, origin)
+
+	first := times[0]
+	last := times[len(times)-1]
+	start := time.Date(first.Year(), first.Month(), first.Day(), 0, 0, 0, 0, first.Location())
+	for d := start; !d.After(last); d = d.AddDate(0, 0, 1) {
+		st.Nights++
+		if !nightActive[d.Format(time.DateOnly)] {
+			st.QuietNights++
+		}
+	}
+	return st
+}
+
+// dayQuartiles is the quartiles of times of day, unwrapped at origin like the percentiles
+func dayQuartiles(seconds []float64, origin float64) Quartiles {
+	unwrapped := make([]float64, len(seconds))
+	for i, s := range seconds {
+		unwrapped[i] = math.Mod(s-origin+secondsPerDay, secondsPerDay)
+	}
+	sort.Float64s(unwrapped)
+	at := func(p float64) float64 {
+		idx := int(math.Round(p * float64(len(unwrapped)-1)))
+		return math.Mod(unwrapped[idx]+origin, secondsPerDay)
+	}
+	return Quartiles{P25: at(0.25), Median: at(0.5), P75: at(0.75)}
+}
+
+// dayBounds is each day's first and last commit, in seconds since midnight. days start at origin
+// (seconds since midnight, the quietest hour) rather than at midnight, so someone who goes to bed
+// at 02:00 has their 01:00 commits end the day before instead of starting the next one
+func dayBounds(times []time.Time, origin float64) (firsts, lasts []float64) {
+	// by date rather than by run, since commits on mixed offsets can come back to a day
+	index := make(map[string]int)
+	shift := time.Duration(origin) * time.Second
+	for _, t := range times {
+		d := t.Add(-shift).Format(time.DateOnly)
+		s := math.Mod(float64(secondsOfDay(t))-origin+secondsPerDay, secondsPerDay)
+		i, ok := index[d]
+		if !ok {
+			i = len(firsts)
+			index[d] = i
+			firsts = append(firsts, s)
+			lasts = append(lasts, s)
+		}
+		firsts[i] = min(firsts[i], s)
+		lasts[i] = max(lasts[i], s)
+	}
+	for i := range firsts {
+		firsts[i] = math.Mod(firsts[i]+origin, secondsPerDay)
+		lasts[i] = math.Mod(lasts[i]+origin, secondsPerDay)
+	}
+	return firsts, lasts
+}
+
+func (q Quartiles) String() string {
+	return fmt.Sprintf("median %s, middle half %s-%s", clockString(q.Median), clockString(q.P25), clockString(q.P75))
+}
+
+// streaks is the longest run of consecutive calendar days in days and the longest run of days
+// missing between two of them. days can repeat and be out of order: without a configured timezone
+// each commit is on its own recorded offset, so a day can come back after the next has started
+func streaks(days []time.Time) (longest, gap int) {
+	// each date once, as a UTC midnight so stepping a day is always 24 hours
+	seen := make(map[string]bool)
+	var dates []time.Time
+	for _, d := range days {
+		if key := d.Format(time.DateOnly); !seen[key] {
+			seen[key] = true
+			dates = append(dates, time.Date(d.Year(), d.Month(), d.Day(), 0, 0, 0, 0, time.UTC))
+		}
+	}
+	sort.Slice(dates, func(i, j int) bool { return dates[i].Before(dates[j]) })
+
+	run := 0
+	for i, d := range dates {
+		missing := 0
+		if i > 0 {
+			for next := dates[i-1].AddDate(0, 0, 1); next.Before(d); next = next.AddDate(0, 0, 1) {
+				missing++
+			}
+		}
+		if i > 0 && missing == 0 {
+			run++
+		} else {
+			run = 1
+		}
+		longest = max(longest, run)
+		gap = max(gap, missing)
+	}
+	return longest, gap
+}
+
+func printStats(subject *Subject) {
+	st := computeStats(subject.times())
+	if st.Total == 0 {
+		return
+	}
+
+	fmt.Printf("total commits:     %d over %d active days\n", len(subject.Commits), st.ActiveDays)
+	fmt.Printf("longest streaks:   %d days active, %d days without commits\n", st.LongestStreak, st.LongestGap)
+	if subject.Weights != nil {
+		if flags.WeightBy == "count" {
+			fmt.Printf("weighted total:    %d (weighted by source)\n", st.Total)
+		} else {
+			fmt.Printf("weighted total:    %d (--weight-by %s)\n", st.Total, flags.WeightBy)
+		}
+	}
+	fmt.Printf("busiest hour:      %s\n", hourString(st.BusiestHour))
+	fmt.Printf("quietest hour:     %s\n", hourString(st.QuietestHour))
+	if math.IsInf(st.StdDev, 1) {
+		fmt.Printf("circular mean:     %s (activity is uniform, mean is meaningless)\n", clockString(st.MeanTime))
+	} else {
+		fmt.Printf("circular mean:     %s ± %.1fh\n", clockString(st.MeanTime), st.StdDev/3600)
+	}
+	fmt.Printf("percentiles:      ")
+	for _, p := range statsPercentiles {
+		fmt.Printf(" p%d=%s", p, clockString(st.Percentiles[p]))
+	}
+	fmt.Println()
+	fmt.Printf("avg first of day:  %s\n", clockString(st.FirstOfDay))
+	fmt.Printf("avg last of day:   %s\n", clockString(st.LastOfDay))
+	fmt.Printf("first of day:      %s\n", st.FirstOfDayQuartiles)
+	fmt.Printf("last of day:       %s\n", st.LastOfDayQuartiles)
+	fmt.Printf("quiet nights:      %d/%d (no commits %s)\n", st.QuietNights, st.Nights, hourRange(0, quietNightEnd))
+}
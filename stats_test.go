@@ -0,0 +1,33 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+// someone asleep 02:00-09:00: their 00:30 and 01:30 commits end the day before, they don't start
+// the next one
+func TestDayBoundsLateSleeper(t *testing.T) {
+	var times []time.Time
+	start := time.Date(2024, 3, 4, 0, 0, 0, 0, time.UTC)
+	for day := range 14 {
+		date := start.AddDate(0, 0, day)
+		for _, at := range []time.Duration{10 * time.Hour, 13 * time.Hour, 16 * time.Hour, 19 * time.Hour, 22 * time.Hour, 24*time.Hour + 30*time.Minute, 25*time.Hour + 30*time.Minute} {
+			times = append(times, date.Add(at))
+		}
+	}
+
+	st := computeStats(times)
+	if got, want := clockString(st.FirstOfDayQuartiles.Median), "10:00"; got != want {
+		t.Errorf("first of day median %s, want %s", got, want)
+	}
+	if got, want := clockString(st.LastOfDayQuartiles.Median), "01:30"; got != want {
+		t.Errorf("last of day median %s, want %s", got, want)
+	}
+	if got, want := clockString(st.FirstOfDay), "10:00"; got != want {
+		t.Errorf("average first of day %s, want %s", got, want)
+	}
+	if got, want := clockString(st.LastOfDay), "01:30"; got != want {
+		t.Errorf("average last of day %s, want %s", got, want)
+	}
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"log"
+	"os"
+
+	"github.com/go-git/go-billy/v5/osfs"
+	"github.com/go-git/go-git/v5/plumbing/cache"
+	"github.com/go-git/go-git/v5/storage"
+	"github.com/go-git/go-git/v5/storage/filesystem"
+	"github.com/go-git/go-git/v5/storage/memory"
+)
+
+// clones live in memory by default, which is fast but means one giant repo can OOM the whole run.
+// repos the forge says are bigger than --max-mem-per-repo go to a temp dir instead.
+// commits keep reading trees from their storage after the clone (dedup, weights), so the temp dirs
+// stick around until the end of the run
+
+var tempDirs []string
+
+func repoStorage(info RepoInfo) (storage.Storer, error) {
+	limit := int64(flags.MaxMemPerRepo) << 20
+	if limit <= 0 || info.Size <= limit {
+		return memory.NewStorage(), nil
+	}
+
+	dir, err := os.MkdirTemp("", "sleep-clone-*")
+	if err != nil {
+		return nil, err
+	}
+	tempDirs = append(tempDirs, dir)
+	log.Printf("  %s is %d MB, cloning to disk at %s", info.CloneURL, info.Size>>20, dir)
+	return filesystem.NewStorage(osfs.New(dir), cache.NewObjectLRUDefault()), nil
+}
+
+func cleanupTempDirs() {
+	for _, dir := range tempDirs {
+		if err := os.RemoveAll(dir); err != nil {
+			log.Printf("Failed to remove %s: %v", dir, err)
+		}
+	}
+	tempDirs = nil
+}
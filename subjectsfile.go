@@ -0,0 +1,156 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/pelletier/go-toml/v2"
+)
+
+// subjects usually live in subjects.toml, but long lists tend to come out of other tools, so
+// --subjects-file also takes csv and json, and "-" reads stdin:
+//
+//	gh api orgs/acme/members --paginate --jq '[.[] | {name: .login, sources: ["github.com/" + .login]}]' \
+//	  | sleep --subjects-file - --subjects-format json
+//
+// json is either an object keyed by name, shaped like the toml, or an array of objects with a
+// "name". csv needs a header row naming its columns: name, sources, timezone, orgs, members, emails,
+// any others are ignored. list columns hold several values separated by spaces or semicolons
+
+var subjectsFormats = []string{"toml", "csv", "json"}
+
+type subjectEntry struct {
+	Sources  []string `toml:"sources,omitempty" json:"sources,omitempty"`
+	Timezone string   `toml:"timezone,omitempty" json:"timezone,omitempty"`
+	Orgs     []string `toml:"orgs,omitempty" json:"orgs,omitempty"`
+	Members  []string `toml:"members,omitempty" json:"members,omitempty"`
+	// addresses the subject commits from; these always match, even with --strict
+	Emails []string `toml:"emails,omitempty" json:"emails,omitempty"`
+}
+
+// subjectsFormat is --subjects-format, or what the file's extension says
+func subjectsFormat(path, format string) (string, error) {
+	if format != "" {
+		return format, nil
+	}
+	if path == "-" {
+		return "toml", nil
+	}
+	ext := strings.TrimPrefix(strings.ToLower(filepath.Ext(path)), ".")
+	if !slices.Contains(subjectsFormats, ext) {
+		return "", fmt.Errorf("can't tell the format of %s, pass --subjects-format", path)
+	}
+	return ext, nil
+}
+
+func readSubjectsFile(path, format string) (map[string]subjectEntry, error) {
+	format, err := subjectsFormat(path, format)
+	if err != nil {
+		return nil, err
+	}
+	var data []byte
+	if path == "-" {
+		data, err = io.ReadAll(os.Stdin)
+	} else {
+		data, err = os.ReadFile(path)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	switch format {
+	case "csv":
+		return parseSubjectsCSV(data)
+	case "json":
+		return parseSubjectsJSON(data)
+	}
+	var raw map[string]subjectEntry
+	if err := toml.Unmarshal(data, &raw); err != nil {
+		return nil, err
+	}
+	return raw, nil
+}
+
+func parseSubjectsJSON(data []byte) (map[string]subjectEntry, error) {
+	var keyed map[string]subjectEntry
+	if err := json.Unmarshal(data, &keyed); err == nil {
+		return keyed, nil
+	}
+	var list []struct {
+		Name string `json:"name"`
+		subjectEntry
+	}
+	if err := json.Unmarshal(data, &list); err != nil {
+		return nil, errors.New("expected an object keyed by subject name or an array of subjects")
+	}
+	raw := make(map[string]subjectEntry, len(list))
+	for i, item := range list {
+		if err := addSubjectEntry(raw, item.Name, item.subjectEntry); err != nil {
+			return nil, fmt.Errorf("subject %d: %w", i+1, err)
+		}
+	}
+	return raw, nil
+}
+
+func parseSubjectsCSV(data []byte) (map[string]subjectEntry, error) {
+	r := csv.NewReader(strings.NewReader(string(data)))
+	r.FieldsPerRecord = -1
+	r.TrimLeadingSpace = true
+	records, err := r.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	if len(records) == 0 {
+		return nil, errors.New("empty csv")
+	}
+	column := make(map[string]int)
+	for i, name := range records[0] {
+		column[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	if _, ok := column["name"]; !ok {
+		return nil, errors.New(`csv header has no "name" column`)
+	}
+	field := func(record []string, name string) string {
+		i, ok := column[name]
+		if !ok || i >= len(record) {
+			return ""
+		}
+		return strings.TrimSpace(record[i])
+	}
+	list := func(record []string, name string) []string {
+		return strings.FieldsFunc(field(record, name), func(r rune) bool { return r == ';' || r == ' ' || r == '\t' })
+	}
+
+	raw := make(map[string]subjectEntry, len(records)-1)
+	for i, record := range records[1:] {
+		entry := subjectEntry{
+			Sources:  list(record, "sources"),
+			Timezone: field(record, "timezone"),
+			Orgs:     list(record, "orgs"),
+			Members:  list(record, "members"),
+			Emails:   list(record, "emails"),
+		}
+		if err := addSubjectEntry(raw, field(record, "name"), entry); err != nil {
+			return nil, fmt.Errorf("line %d: %w", i+2, err)
+		}
+	}
+	return raw, nil
+}
+
+func addSubjectEntry(raw map[string]subjectEntry, name string, entry subjectEntry) error {
+	if name == "" {
+		return errors.New("no name")
+	}
+	if _, ok := raw[name]; ok {
+		return fmt.Errorf("%s listed twice", name)
+	}
+	raw[name] = entry
+	return nil
+}
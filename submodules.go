@@ -0,0 +1,92 @@
+package main
+
+import (
+	"errors"
+	"io/fs"
+	"log"
+	"net/url"
+	"path"
+	"strings"
+
+	"github.com/go-git/go-git/v5"
+	gitconfig "github.com/go-git/go-git/v5/config"
+)
+
+// --submodules: a repo's submodules are other repos, and work done only in them is invisible from
+// the superproject, which just moves a pointer now and then. with the flag, the .gitmodules at each
+// cloned repo's HEAD is read and the submodules under the source's own account are read too, as if
+// they were listed; nested ones as well. submodules elsewhere are somebody else's code and skipped.
+// off by default, since every submodule is one more clone
+
+// submoduleRepos is the submodules at repo's HEAD, as repos to read. the ones outside host/user come
+// back as skipped resolutions
+func submoduleRepos(repo *git.Repository, parentURL, host, user string) ([]RepoInfo, []RepoResolution) {
+	head, err := repo.Head()
+	if err != nil {
+		return nil, nil
+	}
+	data, err := headFile(repo, parentURL, head.Hash(), ".gitmodules")
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		log.Printf("  Failed to fetch .gitmodules for %s: %v", parentURL, err)
+		return nil, nil
+	}
+	modules := gitconfig.NewModules()
+	if err := modules.Unmarshal(data); err != nil {
+		log.Printf("  Failed to parse .gitmodules of %s: %v", parentURL, err)
+		return nil, nil
+	}
+
+	var infos []RepoInfo
+	var skipped []RepoResolution
+	for _, sub := range modules.Submodules {
+		cloneURL, ok := submoduleURL(parentURL, sub.URL)
+		if !ok {
+			skipped = append(skipped, RepoResolution{Repo: sub.URL, Status: repoSkipped, Reason: "submodule of " + displayRepo(parentURL) + " with an unreadable url"})
+			continue
+		}
+		u, _ := url.Parse(cloneURL)
+		owner, _, _ := strings.Cut(strings.Trim(u.Path, "/"), "/")
+		if !strings.EqualFold(u.Hostname(), host) || !strings.EqualFold(owner, user) {
+			skipped = append(skipped, RepoResolution{Repo: cloneURL, Status: repoSkipped, Reason: "submodule of " + displayRepo(parentURL) + ", not under " + host + "/" + user})
+			continue
+		}
+		infos = append(infos, RepoInfo{CloneURL: cloneURL, DefaultBranch: sub.Branch, SubmoduleOf: parentURL})
+	}
+	return infos, skipped
+}
+
+// submoduleURL is a .gitmodules url as an https clone url. relative ones are relative to the
+// superproject's own url, ssh ones are assumed to be served over https too
+func submoduleURL(parentURL, raw string) (string, bool) {
+	if strings.HasPrefix(raw, "./") || strings.HasPrefix(raw, "../") {
+		u, err := url.Parse(parentURL)
+		if err != nil {
+			return "", false
+		}
+		u.Path = path.Join(u.Path, raw)
+		raw = u.String()
+	}
+	// scp-like: git@github.com:someone/project.git
+	if host, repoPath, ok := strings.Cut(raw, ":"); ok && !strings.Contains(raw, "://") {
+		host = host[strings.LastIndex(host, "@")+1:]
+		raw = "https://" + host + "/" + strings.TrimPrefix(repoPath, "/")
+	}
+	u, err := url.Parse(raw)
+	if err != nil || u.Host == "" || strings.Trim(u.Path, "/") == "" {
+		return "", false
+	}
+	switch u.Scheme {
+	case "ssh", "git", "http":
+		u.Scheme, u.User = "https", nil
+	case "https":
+	default:
+		return "", false
+	}
+	if !strings.HasSuffix(u.Path, ".git") {
+		u.Path += ".git"
+	}
+	return u.String(), true
+}
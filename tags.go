@@ -0,0 +1,73 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+// --tags counts tagging as activity too: cutting a release is as much a sign of being awake as a
+// commit, and release managers do a lot of it. annotated tags in cloned repos, and github releases
+// in repos listed through the api, go in with the commits as events whose author and committer are
+// the tagger, matched against the subject like any commit. lightweight tags have no tagger or time
+// of their own and are skipped, and --single-branch and --refspec clones fetch no tags at all
+
+// tagEvents is the annotated tags in repo that match, as commit-shaped events
+func tagEvents(repo *git.Repository, mailmap Mailmap, match func(*object.Commit) bool) []*object.Commit {
+	iter, err := repo.TagObjects()
+	if err != nil {
+		log.Printf("  Failed to list tags: %v", err)
+		return nil
+	}
+	var events []*object.Commit
+	iter.ForEach(func(tag *object.Tag) error {
+		c := tagEvent(tag.Hash, tag.Tagger)
+		mailmap.apply(&c.Author)
+		if match(c) {
+			events = append(events, c)
+		}
+		return nil
+	})
+	return events
+}
+
+// tagEvent is a tag (or release) made by who, shaped like a commit for the analyses. it has no tree,
+// so --dedup and --weight-by files pass it over
+func tagEvent(hash plumbing.Hash, who object.Signature) *object.Commit {
+	return &object.Commit{Hash: hash, Author: who, Committer: who}
+}
+
+// releaseEvents is github's releases for repoPath published by user within --since
+func releaseEvents(repoPath, user string, since time.Time) ([]*object.Commit, error) {
+	_, body, err := githubGet("https://api.github.com/repos/" + repoPath + "/releases?per_page=100")
+	if err != nil {
+		return nil, err
+	}
+	var releases []struct {
+		ID          int64     `json:"id"`
+		PublishedAt time.Time `json:"published_at"`
+		Author      struct {
+			Login string `json:"login"`
+		} `json:"author"`
+	}
+	if err := json.Unmarshal(body, &releases); err != nil {
+		return nil, fmt.Errorf("failed to parse JSON response: %w", err)
+	}
+	var events []*object.Commit
+	for _, r := range releases {
+		// drafts aren't published
+		if r.PublishedAt.IsZero() || !r.PublishedAt.After(since) || !strings.EqualFold(r.Author.Login, user) {
+			continue
+		}
+		// releases have no hash of their own; this one is stable across runs
+		hash := plumbing.ComputeHash(plumbing.TagObject, []byte(fmt.Sprintf("release %s %d", repoPath, r.ID)))
+		events = append(events, tagEvent(hash, object.Signature{Name: r.Author.Login, When: r.PublishedAt}))
+	}
+	return events, nil
+}
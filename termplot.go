@@ -0,0 +1,69 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// --stdout-scatter draws the scatter plot in the terminal with braille characters, for a quick look
+// over ssh. each character is a 2x4 grid of dots, so 72x12 characters give 144 columns of dates
+// by 48 rows of half hours
+
+const (
+	termPlotCols = 72
+	termPlotRows = 12
+)
+
+// braille dot bits, indexed [x][y] within one character
+var brailleDots = [2][4]rune{
+	{0x01, 0x02, 0x04, 0x40},
+	{0x08, 0x10, 0x20, 0x80},
+}
+
+func printScatter(subject *Subject, window SleepWindow) {
+	times := subject.times()
+	if len(times) == 0 {
+		return
+	}
+	first, last := times[0], times[0]
+	for _, t := range times {
+		if t.Before(first) {
+			first = t
+		}
+		if t.After(last) {
+			last = t
+		}
+	}
+	span := last.Sub(first)
+	if span <= 0 {
+		span = time.Second
+	}
+
+	dotCols, dotRows := termPlotCols*2, termPlotRows*4
+	var grid [termPlotRows][termPlotCols]rune
+	for _, t := range times {
+		x := int(float64(t.Sub(first)) / float64(span) * float64(dotCols-1))
+		// midnight at the bottom, like the png
+		y := dotRows - 1 - secondsOfDay(t)*dotRows/86400
+		grid[y/4][x/2] |= brailleDots[x%2][y%4]
+	}
+
+	fmt.Printf("\n=== Commit Schedule for %s ===\n", subject.Name)
+	for row := range termPlotRows {
+		// each row is two hours; label the hour at its bottom edge every third row
+		label := ""
+		if hour := (termPlotRows - 1 - row) * 2; hour%6 == 0 {
+			label = hourString(hour)
+		}
+		var line strings.Builder
+		for _, cell := range grid[row] {
+			line.WriteRune(0x2800 + cell)
+		}
+		fmt.Printf("%5s ┤%s\n", label, line.String())
+	}
+	from, to := first.Format(time.DateOnly), last.Format(time.DateOnly)
+	fmt.Printf("      └%s\n", strings.Repeat("─", termPlotCols))
+	fmt.Printf("       %s%*s\n", from, termPlotCols-len(from), to)
+	fmt.Printf("       %s\n", window)
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"fmt"
+	"image/color"
+	"strconv"
+	"strings"
+
+	"gonum.org/v1/plot"
+)
+
+// colors for every plot type. Foreground covers titles, axes, ticks and labels; Data is the points/bars;
+// Window shades the estimated sleep window and should be translucent
+
+type Theme struct {
+	Background color.Color
+	Foreground color.Color
+	Data       color.Color
+	Window     color.Color
+}
+
+var themes = map[string]Theme{
+	"dark": {
+		Background: color.RGBA{0x10, 0x10, 0x10, 0xff},
+		Foreground: color.RGBA{0x95, 0xd5, 0x50, 0xff},
+		Data:       color.RGBA{0x95, 0xd5, 0x50, 0xff},
+		Window:     color.NRGBA{0x50, 0x70, 0xd5, 0x50},
+	},
+	"light": {
+		Background: color.RGBA{0xff, 0xff, 0xff, 0xff},
+		Foreground: color.RGBA{0x20, 0x20, 0x20, 0xff},
+		Data:       color.RGBA{0x3a, 0x7d, 0x1e, 0xff},
+		Window:     color.NRGBA{0x40, 0x60, 0xc0, 0x30},
+	},
+}
+
+// ThemeConfig is the [theme] table in sleep.toml, used by --theme custom.
+// values are hex like "#95d550"; anything left out falls back to the dark theme
+type ThemeConfig struct {
+	Background string `toml:"background"`
+	Foreground string `toml:"foreground"`
+	Data       string `toml:"data"`
+	Window     string `toml:"window"`
+}
+
+var theme = themes["dark"]
+
+func resolveTheme(name string, custom ThemeConfig) (Theme, error) {
+	if name != "custom" {
+		t, ok := themes[name]
+		if !ok {
+			return Theme{}, fmt.Errorf("unknown theme %q (expected dark, light or custom)", name)
+		}
+		return t, nil
+	}
+
+	t := themes["dark"]
+	for _, field := range []struct {
+		hex string
+		dst *color.Color
+	}{
+		{custom.Background, &t.Background},
+		{custom.Foreground, &t.Foreground},
+		{custom.Data, &t.Data},
+		{custom.Window, &t.Window},
+	} {
+		if field.hex == "" {
+			continue
+		}
+		c, err := parseHexColor(field.hex)
+		if err != nil {
+			return Theme{}, err
+		}
+		*field.dst = c
+	}
+	return t, nil
+}
+
+// alpha is straight, not premultiplied, hence NRGBA
+func parseHexColor(s string) (color.NRGBA, error) {
+	hex := strings.TrimPrefix(s, "#")
+	if len(hex) == 6 {
+		hex += "ff"
+	}
+	if len(hex) != 8 {
+		return color.NRGBA{}, fmt.Errorf("invalid color %q: expected #rrggbb or #rrggbbaa", s)
+	}
+	v, err := strconv.ParseUint(hex, 16, 32)
+	if err != nil {
+		return color.NRGBA{}, fmt.Errorf("invalid color %q: %v", s, err)
+	}
+	return color.NRGBA{uint8(v >> 24), uint8(v >> 16), uint8(v >> 8), uint8(v)}, nil
+}
+
+// newPlot builds an empty plot styled with the current theme
+func newPlot(title, xLabel, yLabel string) *plot.Plot {
+	p := plot.New()
+	p.BackgroundColor = theme.Background
+	p.Title.Text = title
+	p.Title.TextStyle.Color = theme.Foreground
+	for _, axis := range []*plot.Axis{&p.X, &p.Y} {
+		axis.Label.TextStyle.Color = theme.Foreground
+		axis.Color = theme.Foreground
+		axis.Tick.Color = theme.Foreground
+		axis.Tick.Label.Color = theme.Foreground
+	}
+	p.X.Label.Text = xLabel
+	p.Y.Label.Text = yLabel
+	return p
+}
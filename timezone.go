@@ -0,0 +1,193 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"time"
+	// embedded so zone lookups work on machines without a zoneinfo database
+	_ "time/tzdata"
+)
+
+// every commit records the committer's UTC offset at the time. a single offset narrows things to a band
+// of zones; the dates where it flips (or doesn't) narrow it further, since DST rules differ by region.
+// go can't enumerate the IANA database portably, so candidates come from a list with one or two
+// representatives per distinct offset/DST rule. zones sharing rules are indistinguishable anyway
+
+var candidateZones = []string{
+	"UTC",
+	"Atlantic/Reykjavik",
+	"Europe/London",
+	"Europe/Lisbon",
+	"Africa/Lagos",
+	"Europe/Berlin",
+	"Europe/Paris",
+	"Europe/Madrid",
+	"Europe/Warsaw",
+	"Africa/Johannesburg",
+	"Africa/Cairo",
+	"Europe/Helsinki",
+	"Europe/Athens",
+	"Europe/Kyiv",
+	"Asia/Jerusalem",
+	"Europe/Istanbul",
+	"Europe/Moscow",
+	"Asia/Tehran",
+	"Asia/Dubai",
+	"Asia/Karachi",
+	"Asia/Kolkata",
+	"Asia/Kathmandu",
+	"Asia/Dhaka",
+	"Asia/Bangkok",
+	"Asia/Jakarta",
+	"Asia/Shanghai",
+	"Asia/Singapore",
+	"Australia/Perth",
+	"Asia/Tokyo",
+	"Asia/Seoul",
+	"Australia/Darwin",
+	"Australia/Adelaide",
+	"Australia/Brisbane",
+	"Australia/Sydney",
+	"Pacific/Auckland",
+	"Pacific/Honolulu",
+	"America/Anchorage",
+	"America/Los_Angeles",
+	"America/Phoenix",
+	"America/Denver",
+	"America/Chicago",
+	"America/Mexico_City",
+	"America/New_York",
+	"America/Bogota",
+	"America/Halifax",
+	"America/St_Johns",
+	"America/Santiago",
+	"America/Sao_Paulo",
+	"America/Argentina/Buenos_Aires",
+}
+
+type ZoneCandidate struct {
+	// zones that scored identically on this data
+	Names []string
+	// fraction of observed days whose offset matches the zone's offset on that day
+	Score float64
+}
+
+// below this, don't let --infer-tz override the commit offsets
+const minZoneScore = 0.8
+
+// inferTimezone ranks candidate zones against the per-day offset series.
+// each day contributes once, with its most common offset, so one busy day can't outvote a month
+func inferTimezone(times []time.Time) []ZoneCandidate {
+	type day struct {
+		offsets map[int]int
+		instant time.Time
+	}
+	days := make(map[string]*day)
+	for _, t := range times {
+		key := t.UTC().Format(time.DateOnly)
+		d, ok := days[key]
+		if !ok {
+			d = &day{offsets: make(map[int]int), instant: t}
+			days[key] = d
+		}
+		_, offset := t.Zone()
+		d.offsets[offset]++
+	}
+	if len(days) == 0 {
+		return nil
+	}
+
+	type observation struct {
+		instant time.Time
+		offset  int
+	}
+	observed := make([]observation, 0, len(days))
+	for _, d := range days {
+		best, bestCount := 0, -1
+		for offset, count := range d.offsets {
+			if count > bestCount || (count == bestCount && offset < best) {
+				best, bestCount = offset, count
+			}
+		}
+		observed = append(observed, observation{d.instant, best})
+	}
+
+	byScore := make(map[float64][]string)
+	for _, name := range candidateZones {
+		loc, err := time.LoadLocation(name)
+		if err != nil {
+			continue
+		}
+		var matched int
+		for _, o := range observed {
+			if _, offset := o.instant.In(loc).Zone(); offset == o.offset {
+				matched++
+			}
+		}
+		if matched == 0 {
+			continue
+		}
+		score := float64(matched) / float64(len(observed))
+		byScore[score] = append(byScore[score], name)
+	}
+
+	var candidates []ZoneCandidate
+	for score, names := range byScore {
+		candidates = append(candidates, ZoneCandidate{Names: names, Score: score})
+	}
+	sort.Slice(candidates, func(i, j int) bool { return candidates[i].Score > candidates[j].Score })
+	return candidates
+}
+
+// adoptInferredZone sets the subject's zone from the best candidate when nothing was configured.
+// ties are fine here since tied zones agreed on every observed day
+func adoptInferredZone(subject *Subject, candidates []ZoneCandidate) {
+	if subject.Location != nil || len(candidates) == 0 || candidates[0].Score < minZoneScore {
+		return
+	}
+	loc, err := time.LoadLocation(candidates[0].Names[0])
+	if err != nil {
+		return
+	}
+	subject.Location, subject.ZoneInferred = loc, true
+	log.Printf("Using inferred timezone %s for %s", loc, subject.Name)
+}
+
+// the clock a subject's hours are read on. by default that's the subject's configured timezone, or
+// else the offset each commit was recorded with. --utc reads everything in UTC; --subject-tz falls
+// back to the inferred zone instead of the recorded offsets, where the inference is good enough
+
+// applyTimeBasis sets the subject's zone for --utc and --subject-tz
+func applyTimeBasis(subject *Subject) {
+	switch {
+	case flags.UTC:
+		subject.Location, subject.ZoneInferred = time.UTC, false
+	case flags.SubjectTZ:
+		adoptInferredZone(subject, inferTimezone(subject.recordedTimes()))
+	}
+}
+
+// timeBasis says which clock the subject's hours are on, for report headers
+func timeBasis(subject *Subject) string {
+	switch {
+	case flags.UTC:
+		return "UTC (--utc)"
+	case subject.Location == nil:
+		return "each commit's recorded offset"
+	case subject.ZoneInferred:
+		return subject.Location.String() + " (inferred)"
+	}
+	return subject.Location.String() + " (configured)"
+}
+
+func printTimezoneCandidates(candidates []ZoneCandidate) {
+	fmt.Printf("likely timezones:\n")
+	for i, c := range candidates {
+		if i == 3 {
+			break
+		}
+		fmt.Printf("  %3.0f%%  %s\n", c.Score*100, strings.Join(c.Names, ", "))
+	}
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// what a clone costs on the wire. smart http fetches go to <repo>/info/refs and
+// <repo>/git-upload-pack, so response bytes are tallied under the repo URL in front of those.
+// everything else (forge apis, exports) only counts toward the run's total, for --max-bandwidth
+
+type countingTransport struct {
+	next http.RoundTripper
+
+	mu    sync.Mutex
+	bytes map[string]*atomic.Int64
+	total atomic.Int64
+}
+
+var transfers = &countingTransport{bytes: make(map[string]*atomic.Int64)}
+
+// countTransfers wraps next so every response body is counted. there's one tally for the whole
+// run, see transferred
+func countTransfers(next http.RoundTripper) http.RoundTripper {
+	transfers.next = next
+	return transfers
+}
+
+func (t *countingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.next.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	body := &countingBody{ReadCloser: resp.Body, total: &t.total}
+	if repo, ok := gitRepoURL(req); ok {
+		body.n = t.counter(repo)
+	}
+	resp.Body = body
+	return resp, nil
+}
+
+func (t *countingTransport) counter(repo string) *atomic.Int64 {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	n, ok := t.bytes[repo]
+	if !ok {
+		n = new(atomic.Int64)
+		t.bytes[repo] = n
+	}
+	return n
+}
+
+// transferred is how many bytes have come back for repoURL's clones and fetches so far
+func transferred(repoURL string) int64 {
+	transfers.mu.Lock()
+	defer transfers.mu.Unlock()
+	if n, ok := transfers.bytes[strings.TrimSuffix(repoURL, "/")]; ok {
+		return n.Load()
+	}
+	return 0
+}
+
+// gitRepoURL is the repo a smart http request is for, if it's one
+func gitRepoURL(req *http.Request) (string, bool) {
+	u := *req.URL
+	u.RawQuery = ""
+	for _, suffix := range []string{"/info/refs", "/git-upload-pack"} {
+		if repo, ok := strings.CutSuffix(u.String(), suffix); ok {
+			return repo, true
+		}
+	}
+	return "", false
+}
+
+type countingBody struct {
+	io.ReadCloser
+	// n is nil for responses that aren't a repo's
+	n, total *atomic.Int64
+}
+
+func (b *countingBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if b.n != nil {
+		b.n.Add(int64(n))
+	}
+	b.total.Add(int64(n))
+	return n, err
+}
+
+func formatBytes(n int64) string {
+	switch {
+	case n == 0:
+		return "-"
+	case n < 1<<10:
+		return fmt.Sprintf("%dB", n)
+	case n < 1<<20:
+		return fmt.Sprintf("%.1fKB", float64(n)/(1<<10))
+	case n < 1<<30:
+		return fmt.Sprintf("%.1fMB", float64(n)/(1<<20))
+	}
+	return fmt.Sprintf("%.1fGB", float64(n)/(1<<30))
+}
+
+// --max-bandwidth: on a metered connection a run can be told how much it may download. the clone
+// that crosses the cap still finishes (a half clone is wasted bytes), then every repo after it is
+// deferred: skipped this run, noted as a failure, and with --incremental left for the next run
+
+// bandwidthStart is the total when the current collection started, so --watch gets the cap per run
+var bandwidthStart int64
+
+func startBandwidth() {
+	bandwidthStart = transfers.total.Load()
+}
+
+// bandwidthUsed is how much this collection has downloaded so far
+func bandwidthUsed() int64 {
+	return transfers.total.Load() - bandwidthStart
+}
+
+// checkBandwidth is ErrBandwidth once the collection is over --max-bandwidth
+func checkBandwidth() error {
+	limit := int64(flags.MaxBandwidth) << 20
+	if limit <= 0 || bandwidthUsed() < limit {
+		return nil
+	}
+	return fmt.Errorf("%w (%s of %d MB), deferred", ErrBandwidth, formatBytes(bandwidthUsed()), flags.MaxBandwidth)
+}
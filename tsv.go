@@ -0,0 +1,71 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// --format tsv is for awk and shell loops where json is overkill. per subject, 24 rows of
+//
+//	subject<TAB>hour<TAB>count
+//
+// then the sleep estimate as subject<TAB>key=value lines. hours are always 0-23, whatever --clock
+// says. tabs and newlines in subject names become spaces so rows stay rows
+
+var formats = []string{"text", "tsv"}
+
+func printTSV(subject *Subject, a Analysis) {
+	w := a.Window
+	name := strings.NewReplacer("\t", " ", "\n", " ").Replace(subject.Name)
+	for hour, count := range hourCounts(subject.times()) {
+		fmt.Printf("%s\t%d\t%d\n", name, hour, count)
+	}
+
+	kv := func(key string, value any) {
+		fmt.Printf("%s\t%s=%v\n", name, key, value)
+	}
+	kv("commits", w.Commits)
+	kv("time_basis", a.TimeBasis)
+	kv("active_days", a.Stats.ActiveDays)
+	kv("longest_streak", a.Stats.LongestStreak)
+	kv("longest_gap", a.Stats.LongestGap)
+	if a.Stats.Total > 0 {
+		kv("first_of_day_median", clockString(a.Stats.FirstOfDayQuartiles.Median))
+		kv("first_of_day_iqr", clockString(a.Stats.FirstOfDayQuartiles.P25)+"-"+clockString(a.Stats.FirstOfDayQuartiles.P75))
+		kv("last_of_day_median", clockString(a.Stats.LastOfDayQuartiles.Median))
+		kv("last_of_day_iqr", clockString(a.Stats.LastOfDayQuartiles.P25)+"-"+clockString(a.Stats.LastOfDayQuartiles.P75))
+	}
+	kv("sleep_found", w.Found)
+	if w.Found {
+		kv("sleep_start", w.Start)
+		kv("sleep_end", w.End)
+		kv("sleep_hours", w.Hours)
+		kv("confidence", fmt.Sprintf("%.2f", w.Confidence))
+		if u := w.Uncertainty; u != nil && u.Found > 0 {
+			kv("onset_spread_minutes", fmt.Sprintf("%.0f", u.OnsetSpread))
+			kv("wake_spread_minutes", fmt.Sprintf("%.0f", u.WakeSpread))
+		}
+		if w.Nap != nil {
+			kv("nap_start", w.Nap.Start)
+			kv("nap_end", w.Nap.End)
+			kv("nap_hours", w.Nap.Hours)
+		}
+	}
+	kv("estimator", w.Estimator)
+	if w.Estimator == "threshold" {
+		kv("threshold", w.Threshold)
+	}
+	if j := a.SocialJetlag; j != nil {
+		kv("social_jetlag_minutes", fmt.Sprintf("%.0f", j.Minutes))
+	}
+	for _, s := range a.Seasons {
+		key := "season_" + strings.ToLower(strings.ReplaceAll(s.Season, "-", "_"))
+		if s.Window.Found {
+			kv(key+"_sleep", hourRange(s.Window.Start, s.Window.End))
+			kv(key+"_shift_minutes", fmt.Sprintf("%.0f", s.Shift))
+			kv(key+"_p_value", fmt.Sprintf("%.3f", s.PValue))
+		}
+	}
+	kv("p_value", fmt.Sprintf("%.3f", a.Significance.PValue))
+	kv("effect_size", fmt.Sprintf("%.2f", a.Significance.EffectSize))
+}
@@ -0,0 +1,507 @@
+package main
+
+import (
+	"cmp"
+	"fmt"
+	"log"
+	"os"
+	"slices"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"golang.org/x/term"
+)
+
+// sleep tui [subject...] is the report as a full screen app: subjects down the left, collected one
+// after another in the background with each one's latest log line as its progress, and the selected
+// one's histogram or heatmap on the right. it's a model, an update per message (key, log line,
+// collection done, tick) and a view redrawn after each, bubbletea style. keys:
+//
+//	↑ ↓ j k   pick a subject
+//	tab       histogram / heatmap
+//	e         next estimator
+//	[ ]       move the window's start an hour earlier or later
+//	{ }       the same for its end
+//	0         back to the estimate
+//	r         collect the selected subject again
+//	q         quit
+//
+// subjects are everything in --subjects-file, the ones named, or the one --user or --local gives
+
+const tuiListWidth = 26
+
+var tuiSpinner = []rune("⠋⠙⠹⠸⠼⠴⠦⠧⠇⠏")
+
+type tuiState int
+
+const (
+	tuiQueued tuiState = iota
+	tuiCollecting
+	tuiDone
+	tuiFailed
+)
+
+type tuiSubject struct {
+	name    string
+	state   tuiState
+	status  string // the latest log line while collecting, or why it failed
+	subject *Subject
+	counts  []int
+	window  SleepWindow
+	// the window was moved by hand rather than estimated
+	adjusted bool
+}
+
+type tuiModel struct {
+	subjects  []*tuiSubject
+	selected  int
+	heatmap   bool
+	estimator string
+	frame     int
+	// subjects waiting to be collected, by index
+	queue chan<- int
+}
+
+// messages the model updates on
+type (
+	tuiKey     string
+	tuiLogLine string
+	tuiTick    struct{}
+	tuiStarted int
+	tuiResult  struct {
+		i       int
+		subject *Subject
+		err     error
+	}
+)
+
+func runTUI(args []string) {
+	if !term.IsTerminal(int(os.Stdin.Fd())) || !term.IsTerminal(int(os.Stdout.Fd())) {
+		log.Fatal("sleep tui needs a terminal")
+	}
+	names := tuiNames(args)
+
+	fd := int(os.Stdin.Fd())
+	saved, err := term.MakeRaw(fd)
+	if err != nil {
+		log.Fatalf("Failed to set up the terminal: %v", err)
+	}
+	msgs := make(chan any, 256)
+	// anything logged while the screen is up would scribble over it, so it becomes progress instead.
+	// nothing may log.Fatal from here on: that exits without putting the terminal back
+	logOutput, logFlags := log.Writer(), log.Flags()
+	log.SetOutput(&tuiLog{msgs: msgs})
+	log.SetFlags(0)
+	fmt.Print("\033[?1049h\033[?25l")
+	defer func() {
+		fmt.Print("\033[?25h\033[?1049l")
+		term.Restore(fd, saved)
+		log.SetOutput(logOutput)
+		log.SetFlags(logFlags)
+	}()
+
+	queue := make(chan int, len(names))
+	m := &tuiModel{estimator: cmp.Or(flags.Estimator, "threshold"), queue: queue}
+	for i, name := range names {
+		m.subjects = append(m.subjects, &tuiSubject{name: name})
+		queue <- i
+	}
+	go tuiCollect(names, queue, msgs)
+	go tuiReadKeys(msgs)
+	ticker := time.NewTicker(150 * time.Millisecond)
+	defer ticker.Stop()
+
+	for {
+		width, height, err := term.GetSize(int(os.Stdout.Fd()))
+		if err != nil {
+			width, height = 80, 24
+		}
+		fmt.Print("\033[H\033[2J" + m.view(width, height))
+		var msg any
+		select {
+		case msg = <-msgs:
+		case <-ticker.C:
+			msg = tuiTick{}
+		}
+		if m.update(msg) {
+			return
+		}
+	}
+}
+
+// tuiNames is who the tui lists, checked up front so a typo fails before the screen is taken over
+func tuiNames(args []string) []string {
+	if len(flags.Local) > 0 || flags.User != "" {
+		if len(args) > 0 {
+			log.Fatal("sleep tui takes subject names or --user/--local, not both")
+		}
+		return []string{cmp.Or(flags.User, "local")}
+	}
+	raw := generatedSubjects
+	if raw == nil {
+		if err := checkSubjectsFile(flags.SubjectsFile, flags.SubjectsFormat); err != nil {
+			log.Fatal(err)
+		}
+		var err error
+		if raw, err = readSubjectsFile(flags.SubjectsFile, flags.SubjectsFormat); err != nil {
+			log.Fatalf("Failed to read %s: %v", flags.SubjectsFile, err)
+		}
+	}
+	for _, name := range args {
+		if _, ok := raw[name]; !ok {
+			log.Fatalf("No subject named %q in %s", name, flags.SubjectsFile)
+		}
+	}
+	if len(args) > 0 {
+		return args
+	}
+	names := make([]string, 0, len(raw))
+	for name := range raw {
+		names = append(names, name)
+	}
+	if len(names) == 0 {
+		log.Fatal("No subjects found")
+	}
+	slices.Sort(names)
+	return names
+}
+
+// tuiCollect collects the queued subjects one at a time, since collection isn't safe to run twice
+// at once. what would stop a run fails just the subject instead
+func tuiCollect(names []string, queue <-chan int, msgs chan<- any) {
+	for i := range queue {
+		msgs <- tuiStarted(i)
+		collectMu.Lock()
+		var only []string
+		if len(flags.Local) == 0 && flags.User == "" {
+			only = []string{names[i]}
+		}
+		subjects, err := collectSubjects(only)
+		var found *Subject
+		for _, subject := range subjects {
+			// a group comes back with its members
+			if only == nil || subject.Name == names[i] {
+				found = &subject
+				break
+			}
+		}
+		collectMu.Unlock()
+		msgs <- tuiResult{i, found, err}
+	}
+}
+
+func tuiReadKeys(msgs chan<- any) {
+	buf := make([]byte, 64)
+	for {
+		n, err := os.Stdin.Read(buf)
+		if err != nil {
+			msgs <- tuiKey("q")
+			return
+		}
+		for _, key := range parseKeys(buf[:n]) {
+			msgs <- key
+		}
+	}
+}
+
+// parseKeys splits what one read returned into keys, arrows named up and down
+func parseKeys(b []byte) []tuiKey {
+	var keys []tuiKey
+	for len(b) > 0 {
+		switch {
+		case len(b) >= 3 && b[0] == 0x1b && b[1] == '[':
+			switch b[2] {
+			case 'A':
+				keys = append(keys, "up")
+			case 'B':
+				keys = append(keys, "down")
+			}
+			b = b[3:]
+			continue
+		case b[0] == 0x03:
+			keys = append(keys, "q")
+		case b[0] == '\t':
+			keys = append(keys, "tab")
+		default:
+			r, size := utf8.DecodeRune(b)
+			keys = append(keys, tuiKey(string(r)))
+			b = b[size:]
+			continue
+		}
+		b = b[1:]
+	}
+	return keys
+}
+
+// tuiLog turns log lines into progress messages
+type tuiLog struct {
+	msgs chan<- any
+}
+
+func (l *tuiLog) Write(p []byte) (int, error) {
+	lines := strings.Split(strings.TrimSpace(string(p)), "\n")
+	select {
+	case l.msgs <- tuiLogLine(lines[len(lines)-1]):
+	default:
+		// the screen is behind; the next line will do
+	}
+	return len(p), nil
+}
+
+// update applies one message, true to quit
+func (m *tuiModel) update(msg any) bool {
+	switch msg := msg.(type) {
+	case tuiTick:
+		m.frame++
+	case tuiStarted:
+		m.subjects[msg].state = tuiCollecting
+		m.subjects[msg].status = ""
+	case tuiLogLine:
+		for _, s := range m.subjects {
+			if s.state == tuiCollecting {
+				s.status = string(msg)
+			}
+		}
+	case tuiResult:
+		s := m.subjects[msg.i]
+		if msg.err != nil {
+			s.state, s.status = tuiFailed, msg.err.Error()
+			return false
+		}
+		if msg.subject == nil || len(msg.subject.Commits) == 0 {
+			s.state, s.status = tuiFailed, cmp.Or(s.status, "no commits found")
+			return false
+		}
+		s.state, s.subject, s.status = tuiDone, msg.subject, ""
+		s.counts = hourCounts(msg.subject.times())
+		s.estimate(m.estimator)
+	case tuiKey:
+		return m.key(msg)
+	}
+	return false
+}
+
+func (m *tuiModel) key(key tuiKey) bool {
+	s := m.subjects[m.selected]
+	switch key {
+	case "q":
+		return true
+	case "up", "k":
+		m.selected = max(m.selected-1, 0)
+	case "down", "j":
+		m.selected = min(m.selected+1, len(m.subjects)-1)
+	case "tab":
+		m.heatmap = !m.heatmap
+	case "e":
+		m.estimator = estimatorNames[(slices.Index(estimatorNames, m.estimator)+1)%len(estimatorNames)]
+		for _, s := range m.subjects {
+			if s.state == tuiDone {
+				s.estimate(m.estimator)
+			}
+		}
+	case "[", "]", "{", "}":
+		if s.state == tuiDone {
+			s.move(map[tuiKey][2]int{"[": {-1, 0}, "]": {1, 0}, "{": {0, -1}, "}": {0, 1}}[key])
+		}
+	case "0":
+		if s.state == tuiDone {
+			s.estimate(m.estimator)
+		}
+	case "r":
+		// one at a time per subject, which also keeps the queue from filling
+		if s.state == tuiDone || s.state == tuiFailed {
+			s.state, s.status = tuiQueued, ""
+			m.queue <- m.selected
+		}
+	}
+	return false
+}
+
+func (s *tuiSubject) estimate(estimator string) {
+	s.window = estimators[estimator](s.counts)
+	s.window.Estimator = estimator
+	s.adjusted = false
+}
+
+// move shifts the window's start and end by the given hours, keeping at least an hour of it
+func (s *tuiSubject) move(by [2]int) {
+	w := s.window
+	if !w.Found {
+		w = SleepWindow{Found: true, Estimator: w.Estimator, Commits: w.Commits, Hours: int(priors.MinHours)}
+		w.End = w.Hours
+	}
+	start, end := (w.Start+by[0]+24)%24, (w.End+by[1]+24)%24
+	hours := (end - start + 24) % 24
+	if hours == 0 {
+		return
+	}
+	w.Start, w.End, w.Hours = start, end, hours
+	w.Confidence = windowConfidence(s.counts, w)
+	w.Uncertainty, w.Nap = nil, nil
+	s.window, s.adjusted = w, true
+}
+
+func (m *tuiModel) view(width, height int) string {
+	left := make([]string, 0, len(m.subjects)+2)
+	left = append(left, paint(ansiBold, "Subjects"), "")
+	for i, s := range m.subjects {
+		mark := map[tuiState]string{tuiQueued: "·", tuiDone: "✓", tuiFailed: "✗"}[s.state]
+		if s.state == tuiCollecting {
+			mark = string(tuiSpinner[m.frame%len(tuiSpinner)])
+		}
+		line := fmt.Sprintf(" %s %s", mark, s.name)
+		if s.subject != nil {
+			line += fmt.Sprintf(" (%d)", len(s.subject.Commits))
+		}
+		line = truncate(line, tuiListWidth-1)
+		if i == m.selected {
+			line = ">" + line[1:]
+		}
+		left = append(left, line)
+	}
+
+	s := m.subjects[m.selected]
+	paneWidth := max(width-tuiListWidth-2, 20)
+	var right []string
+	switch s.state {
+	case tuiQueued:
+		right = []string{"waiting to be collected"}
+	case tuiCollecting:
+		right = []string{"collecting..."}
+	case tuiFailed:
+		right = []string{paint(ansiRed, "collection failed"), s.status}
+	default:
+		right = m.pane(s, paneWidth)
+	}
+
+	var b strings.Builder
+	rows := max(len(left), len(right))
+	for i := range min(rows, height-2) {
+		var l, r string
+		if i < len(left) {
+			l = left[i]
+		}
+		if i < len(right) {
+			r = right[i]
+		}
+		b.WriteString(l + strings.Repeat(" ", max(tuiListWidth-visibleWidth(l), 0)) + "│ " + r + "\r\n")
+	}
+	for range height - 2 - min(rows, height-2) {
+		b.WriteString("\r\n")
+	}
+	status := "↑↓ select  tab histogram/heatmap  e estimator  [ ] start  { } end  0 reset  r re-run  q quit"
+	for _, s := range m.subjects {
+		if s.state == tuiCollecting && s.status != "" {
+			status = s.name + ": " + s.status
+		}
+	}
+	b.WriteString(truncate(status, max(width-1, 2)))
+	return b.String()
+}
+
+func (m *tuiModel) pane(s *tuiSubject, width int) []string {
+	w := s.window
+	title := fmt.Sprintf("%s, %d commits", s.name, len(s.subject.Commits))
+	if m.heatmap {
+		title += " by weekday and hour"
+	}
+	lines := []string{paint(ansiBold, title), ""}
+	if m.heatmap {
+		lines = append(lines, tuiHeatmap(s.subject, w)...)
+	} else {
+		lines = append(lines, tuiHistogram(s.counts, w, width)...)
+	}
+	lines = append(lines, "")
+	if !w.Found {
+		return append(lines, fmt.Sprintf("no clear sleep window (%s)", w.Estimator))
+	}
+	how := w.Estimator
+	if s.adjusted {
+		how = "adjusted by hand from " + how
+	}
+	var inside int
+	for h, count := range s.counts {
+		if w.contains(h) {
+			inside += count
+		}
+	}
+	return append(lines,
+		fmt.Sprintf("sleep %s (~%d hours), %s, %s", paint(ansiBold, hourRange(w.Start, w.End)), w.Hours,
+			paint(w.confidenceColor(), fmt.Sprintf("%s confidence (%.2f)", w.confidenceLabel(), w.Confidence)), how),
+		fmt.Sprintf("%d commits inside the window (%.1f%%)", inside, 100*float64(inside)/float64(max(w.Commits, 1))))
+}
+
+// tuiHistogram is commits per hour as bars scaled to width, the window's hours marked
+func tuiHistogram(counts []int, w SleepWindow, width int) []string {
+	maxi := max(slices.Max(counts), 1)
+	digits := len(fmt.Sprint(maxi))
+	barWidth := max(width-digits-12, 1)
+	var lines []string
+	for h, count := range counts {
+		mark := " "
+		if w.contains(h) {
+			mark = "z"
+		}
+		line := fmt.Sprintf("%5s %s %*d %s", hourString(h), mark, digits, count, strings.Repeat("█", count*barWidth/maxi))
+		if w.contains(h) {
+			line = paint(ansiBlue, line)
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+// tuiHeatmap is the weekday by hour grid in shades, two characters an hour, with the window's hours
+// marked underneath
+func tuiHeatmap(subject *Subject, w SleepWindow) []string {
+	grid := weekHourCounts(subject)
+	var maxi float64
+	for _, row := range grid {
+		maxi = max(maxi, slices.Max(row[:]))
+	}
+	shades := []rune(" ░▒▓█")
+	header := "     "
+	for h := 0; h < 24; h += 6 {
+		header += fmt.Sprintf("%-12s", hourString(h))
+	}
+	lines := []string{header}
+	for i, d := range weekdayOrder() {
+		row := grid[6-i]
+		var b strings.Builder
+		for _, count := range row {
+			shade := shades[0]
+			if count > 0 && maxi > 0 {
+				shade = shades[1+int(count/maxi*float64(len(shades)-2)+0.5)]
+			}
+			b.WriteString(strings.Repeat(string(shade), 2))
+		}
+		lines = append(lines, fmt.Sprintf("%-4s %s", dayName(d), b.String()))
+	}
+	marks := "     "
+	for h := range 24 {
+		if w.contains(h) {
+			marks += "zz"
+		} else {
+			marks += "  "
+		}
+	}
+	return append(lines, paint(ansiBlue, strings.TrimRight(marks, " ")))
+}
+
+// visibleWidth is how many columns s takes, ansi codes aside
+func visibleWidth(s string) int {
+	var n int
+	escape := false
+	for _, r := range s {
+		switch {
+		case r == 0x1b:
+			escape = true
+		case escape:
+			escape = r != 'm'
+		default:
+			n++
+		}
+	}
+	return n
+}
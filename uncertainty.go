@@ -0,0 +1,125 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"math/rand/v2"
+	"slices"
+)
+
+// the estimate snaps to whole hours, which reads as more certain than a few hundred commits can
+// be. resampling the commits with replacement and re-estimating shows how far the boundaries
+// move: a window that shifts by an hour on every resample isn't known to the hour
+
+const (
+	bootstrapResamples = 200
+	bootstrapSeed      = 1
+)
+
+type WindowUncertainty struct {
+	Resamples int     `json:"resamples"`
+	Found     float64 `json:"found"` // share of resamples that found a window at all
+	// circular means and standard deviations, in minutes since midnight / minutes
+	Onset       float64 `json:"onset_minutes"`
+	OnsetSpread float64 `json:"onset_spread_minutes"`
+	Wake        float64 `json:"wake_minutes"`
+	WakeSpread  float64 `json:"wake_spread_minutes"`
+}
+
+// bootstrapWindow re-estimates the window on resamples of the hour counts
+func bootstrapWindow(counts []int) *WindowUncertainty {
+	var total int
+	for _, count := range counts {
+		total += count
+	}
+	if total == 0 {
+		return nil
+	}
+	var onsets, wakes []float64
+	resampleCounts(counts, func(resampled []int) {
+		if w := windowFromCounts(resampled); w.Found {
+			onsets = append(onsets, float64(w.Start*60))
+			wakes = append(wakes, float64(w.End*60))
+		}
+	})
+
+	u := &WindowUncertainty{
+		Resamples: bootstrapResamples,
+		Found:     float64(len(onsets)) / bootstrapResamples,
+	}
+	if len(onsets) > 0 {
+		u.Onset, u.OnsetSpread = circularMinutes(onsets)
+		u.Wake, u.WakeSpread = circularMinutes(wakes)
+	}
+	return u
+}
+
+// resampleCounts calls f with bootstrapResamples resamples of counts, each drawn with replacement
+// from the same commits. seeded, so every caller sees the same resamples. f mustn't keep the slice
+func resampleCounts(counts []int, f func(resampled []int)) {
+	var total int
+	for _, count := range counts {
+		total += count
+	}
+	// cumulative counts, so a draw is a binary search instead of a pass over the commits
+	cumulative := make([]int, len(counts))
+	running := 0
+	for hour, count := range counts {
+		running += count
+		cumulative[hour] = running
+	}
+
+	rng := rand.New(rand.NewPCG(bootstrapSeed, bootstrapSeed))
+	resampled := make([]int, len(counts))
+	for range bootstrapResamples {
+		clear(resampled)
+		for range total {
+			draw := rng.IntN(total)
+			hour, _ := slices.BinarySearchFunc(cumulative, draw, func(c, d int) int {
+				// the first hour whose cumulative count passes the draw
+				if c > d {
+					return 1
+				}
+				return -1
+			})
+			resampled[hour]++
+		}
+		f(resampled)
+	}
+}
+
+// circularMinutes is the circular mean and standard deviation of times of day in minutes, so
+// 23:00 and 01:00 average to midnight rather than noon
+func circularMinutes(minutes []float64) (mean, spread float64) {
+	const perDay = 24 * 60
+	var sin, cos float64
+	for _, m := range minutes {
+		angle := 2 * math.Pi * m / perDay
+		sin += math.Sin(angle)
+		cos += math.Cos(angle)
+	}
+	n := float64(len(minutes))
+	r := math.Hypot(sin/n, cos/n)
+	mean = math.Mod(math.Atan2(sin, cos)*perDay/(2*math.Pi)+perDay, perDay)
+	switch {
+	case r <= 0:
+		return mean, perDay / 2
+	case r >= 1:
+		// every resample agreed, give or take float rounding
+		return mean, 0
+	}
+	spread = math.Min(math.Sqrt(-2*math.Log(r))*perDay/(2*math.Pi), perDay/2)
+	return mean, spread
+}
+
+// formatSpread is 45m, or 1h30m, to the nearest 5 minutes
+func formatSpread(minutes float64) string {
+	m := int(math.Round(minutes/5)) * 5
+	if m < 60 {
+		return fmt.Sprintf("%dm", m)
+	}
+	if m%60 == 0 {
+		return fmt.Sprintf("%dh", m/60)
+	}
+	return fmt.Sprintf("%dh%02dm", m/60, m%60)
+}
@@ -0,0 +1,359 @@
+package main
+
+import (
+	"errors"
+	"io"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+// teammates on a shared project list the same repo, and each of them used to clone and walk it.
+// subjects are built together instead: every source is resolved to the repos it needs (getSubjects),
+// then runPlans fetches each repo once and walks it once, every commit matched against each subject
+// that wants the repo as it's visited. the walk keeps what it visited, in order, so a later pass over
+// the same repo (a submodule someone else already read) only walks further if it needs to.
+// --max-commits-per-repo stops each subject where its own walk would have stopped
+
+// fetchedRepos is every repo fetched in the current collection, by fetchKey. nil outside of one, so
+// one-off fetches (--repo, the api's ad hoc subjects) aren't kept around
+var fetchedRepos map[string]*fetchedRepo
+
+type fetchedRepo struct {
+	repo *git.Repository
+	tips []plumbing.Hash
+	walk *repoWalk
+	err  error
+}
+
+// fetched is this collection's fetch of a repo, fetching it the first time
+func fetched(info RepoInfo) *fetchedRepo {
+	key := fetchKey(info)
+	if f, ok := fetchedRepos[key]; ok {
+		log.Printf("  Reusing %s, already fetched this run", info.CloneURL)
+		return f
+	}
+	f := &fetchedRepo{}
+	if f.err = checkBandwidth(); f.err != nil {
+		log.Printf("  Skipping %s: %v", info.CloneURL, f.err)
+		noteFailure(f.err)
+	} else {
+		f.repo, f.tips, f.err = fetchRepo(info)
+	}
+	if fetchedRepos != nil {
+		fetchedRepos[key] = f
+	}
+	return f
+}
+
+// walkFrom makes sure the repo's walk reaches back to since. one that started at a later since (an
+// --incremental source) doesn't, and is started over
+func (f *fetchedRepo) walkFrom(repoURL string, since time.Time) {
+	if f.walk == nil || f.walk.since.After(since) {
+		f.walk = newRepoWalk(f.repo, repoURL, f.tips, since)
+	}
+}
+
+// fetchKey tells fetches apart: the same repo under any spelling, unless --pushed-branches fetched
+// different branches of it for different subjects
+func fetchKey(info RepoInfo) string {
+	return strings.ToLower(displayRepo(info.CloneURL)) + "#" + strings.Join(info.Branches, ",")
+}
+
+// repoWalk walks back from each tip in turn through the --since window, as far as it's been asked
+// to. branches share history, so each commit is visited once, with the repo's mailmap applied
+type repoWalk struct {
+	repo    *git.Repository
+	url     string
+	tips    []plumbing.Hash
+	mailmap Mailmap
+	since   time.Time
+	// commits the repo doesn't have, like a bundle's prerequisites. the walk stops at them
+	boundary map[plumbing.Hash]bool
+
+	visited []*object.Commit
+	seen    map[plumbing.Hash]bool
+	// tips[next] is the next tip to start from; iter (or parent, with --first-parent) is the
+	// current one's walk so far
+	next   int
+	iter   object.CommitIter
+	parent *object.Commit
+	done   bool
+	err    error
+}
+
+func newRepoWalk(repo *git.Repository, repoURL string, tips []plumbing.Hash, since time.Time) *repoWalk {
+	w := &repoWalk{
+		repo: repo,
+		url:  repoURL,
+		tips: tips,
+		// --mailmap goes last so it overrides the repo's own
+		mailmap: append(repoMailmap(repo, repoURL, tips[0]), globalMailmap...),
+		since:   since,
+		seen:    make(map[plumbing.Hash]bool),
+	}
+	if shallow, err := repo.Storer.Shallow(); err == nil && len(shallow) > 0 {
+		w.boundary = make(map[plumbing.Hash]bool, len(shallow))
+		for _, hash := range shallow {
+			w.boundary[hash] = true
+		}
+	}
+	return w
+}
+
+// advance visits one more commit, false once every tip is walked past the window
+func (w *repoWalk) advance() bool {
+	for !w.done {
+		c, err := w.step()
+		if err != nil {
+			w.err, w.done = err, true
+			return false
+		}
+		// walking newest first, so once we're past the window (plus slack for skewed clocks) this
+		// tip is done
+		if c == nil || c.Committer.When.Before(w.since.Add(-pruneSlack)) {
+			w.endTip()
+			continue
+		}
+		if w.seen[c.Hash] {
+			continue
+		}
+		w.seen[c.Hash] = true
+		w.mailmap.apply(&c.Author)
+		w.visited = append(w.visited, c)
+		return true
+	}
+	return false
+}
+
+// step is the current tip's next commit, starting the next tip when there's no current one. nil
+// when the current tip has no more
+func (w *repoWalk) step() (*object.Commit, error) {
+	if w.iter == nil && w.parent == nil {
+		if w.next == len(w.tips) {
+			w.done = true
+			return nil, nil
+		}
+		tip := w.tips[w.next]
+		w.next++
+		if !flags.FirstParent && w.boundary != nil {
+			// Log can't be told where history ends, the iterator under it can
+			c, err := w.repo.CommitObject(tip)
+			if err != nil {
+				return nil, err
+			}
+			w.iter = object.NewCommitIterCTime(c, w.boundary, nil)
+		} else if !flags.FirstParent {
+			iter, err := w.repo.Log(&git.LogOptions{From: tip, Order: git.LogOrderCommitterTime})
+			if err != nil {
+				return nil, err
+			}
+			w.iter = iter
+		} else {
+			c, err := w.repo.CommitObject(tip)
+			if err != nil {
+				return nil, err
+			}
+			// --first-parent follows only the first parent of each commit, i.e. the mainline as
+			// merged, skipping the side branches of every merge. huge shared repos are mostly other
+			// people's side branches
+			return w.firstParent(c)
+		}
+	}
+	if w.iter != nil {
+		c, err := w.iter.Next()
+		if err == io.EOF {
+			return nil, nil
+		}
+		return c, err
+	}
+	c := w.parent
+	return w.firstParent(c)
+}
+
+// firstParent returns c and remembers its first parent as the next step
+func (w *repoWalk) firstParent(c *object.Commit) (*object.Commit, error) {
+	w.parent = nil
+	if c.NumParents() > 0 && !w.boundary[c.ParentHashes[0]] {
+		parent, err := c.Parent(0)
+		if err != nil {
+			return nil, err
+		}
+		w.parent = parent
+	}
+	return c, nil
+}
+
+func (w *repoWalk) endTip() {
+	if w.iter != nil {
+		w.iter.Close()
+	}
+	w.iter, w.parent = nil, nil
+}
+
+// repoMatcher is one subject's pass over a walk
+type repoMatcher struct {
+	since   time.Time
+	match   func(*object.Commit) bool
+	commits []*object.Commit
+	scanned int
+	capped  bool
+}
+
+// matchAll runs every matcher over the walk at once, each getting what it would from a walk of its
+// own: the commits since its since that match, up to --max-commits-per-repo. the walk goes only as
+// far as the last matcher still going needs
+func (w *repoWalk) matchAll(matchers []*repoMatcher) error {
+	active := len(matchers)
+	for i := 0; active > 0 && (i < len(w.visited) || w.advance()); i++ {
+		c := w.visited[i]
+		for _, m := range matchers {
+			// an --incremental source can have a later since than the walk
+			if m.capped || c.Committer.When.Before(m.since.Add(-pruneSlack)) {
+				continue
+			}
+			m.scanned++
+			if m.match(c) {
+				m.commits = append(m.commits, c)
+			}
+			// --max-commits-per-repo: the newest N are kept and the rest of the repo isn't walked
+			if limit := flags.MaxCommitsPerRepo; limit > 0 {
+				count := len(m.commits)
+				if flags.MaxCommitsBy == "scanned" {
+					count = m.scanned
+				}
+				if count >= limit {
+					log.Printf("  Stopped at %d %s commits in %s (--max-commits-per-repo)", limit, flags.MaxCommitsBy, w.url)
+					m.capped = true
+					active--
+				}
+			}
+		}
+	}
+	if w.err != nil {
+		return w.err
+	}
+	if flags.Tags {
+		for _, m := range matchers {
+			m.commits = append(m.commits, tagEvents(w.repo, w.mailmap, m.match)...)
+		}
+	}
+	return nil
+}
+
+// match is the commits that match, as if the repo were walked just for them
+func (w *repoWalk) match(match func(*object.Commit) bool) ([]*object.Commit, error) {
+	m := &repoMatcher{since: flags.Since, match: match}
+	if err := w.matchAll([]*repoMatcher{m}); err != nil {
+		return nil, err
+	}
+	return m.commits, nil
+}
+
+// runPlans does every clone the plans are waiting on, each repo once for all the plans that want
+// it. --submodules can queue more as it goes, which are done in another round
+func runPlans(plans []*sourcePlan) {
+	for {
+		type want struct {
+			plan *sourcePlan
+			job  cloneJob
+		}
+		wants := make(map[string][]want)
+		var order []string
+		for _, plan := range plans {
+			for _, job := range plan.clones {
+				key := fetchKey(job.info)
+				if wants[key] == nil {
+					order = append(order, key)
+				}
+				wants[key] = append(wants[key], want{plan, job})
+			}
+			plan.clones = nil
+		}
+		if len(order) == 0 {
+			return
+		}
+
+		for _, key := range order {
+			first := wants[key][0].job.info
+			f := fetched(first)
+			if f.err != nil {
+				for _, w := range wants[key] {
+					w.plan.cloneFailed(w.job.info, f.err)
+				}
+				continue
+			}
+
+			since := wants[key][0].plan.since
+			matchers := make([]*repoMatcher, len(wants[key]))
+			for i, w := range wants[key] {
+				if w.plan.since.Before(since) {
+					since = w.plan.since
+				}
+				matchers[i] = w.plan.matcher(w.job.info.CloneURL)
+			}
+			f.walkFrom(first.CloneURL, since)
+			done := timed("iterate", first.CloneURL)
+			err := f.walk.matchAll(matchers)
+			done()
+			if err != nil {
+				log.Printf("  Failed to iterate commits for %s: %v", first.CloneURL, err)
+				noteFailure(err)
+				for _, w := range wants[key] {
+					w.plan.cloneFailed(w.job.info, err)
+				}
+				continue
+			}
+
+			for i, w := range wants[key] {
+				w.plan.cloned(f.repo, w.job, matchers[i].commits)
+			}
+		}
+	}
+}
+
+// matcher is what counts as the plan's subject's commit in one of its repos
+func (p *sourcePlan) matcher(repoURL string) *repoMatcher {
+	since := p.since
+	return &repoMatcher{since: since, match: audited(p.subject, repoURL, func(c *object.Commit) (bool, string) {
+		if !c.Committer.When.After(since) {
+			return false, ruleTooOld
+		}
+		return validateCommit(c, p.subject, p.source.user, p.emails)
+	})}
+}
+
+func (p *sourcePlan) cloned(repo *git.Repository, job cloneJob, commits []*object.Commit) {
+	log.Printf("  Found %d commits of %s in repo %s (%s fetched)\n", len(commits), p.subject, job.info.CloneURL, formatBytes(transferred(job.info.CloneURL)))
+	p.source.repos = append(p.source.repos, repo)
+	p.commitsByRepo[job.info.CloneURL] = commits
+	p.source.resolved = append(p.source.resolved, selectedRepo(job.info, "clone", job.why))
+	if flags.Submodules {
+		subs, skipped := submoduleRepos(repo, job.info.CloneURL, p.source.host, p.source.user)
+		p.source.resolved = append(p.source.resolved, skipped...)
+		for _, sub := range subs {
+			if !p.planned[strings.ToLower(displayRepo(sub.CloneURL))] {
+				log.Printf("  Following submodule %s of %s", sub.CloneURL, job.info.CloneURL)
+			}
+			p.plan(sub, "")
+		}
+	}
+}
+
+func (p *sourcePlan) cloneFailed(info RepoInfo, err error) {
+	if errors.Is(err, errEmptyRepo) {
+		p.source.resolved = append(p.source.resolved, RepoResolution{Repo: info.CloneURL, Status: repoSkipped, Reason: "empty, no commits"})
+		return
+	}
+	if errors.Is(err, ErrBandwidth) {
+		// not collected, so --incremental has to come back for it
+		p.failed = true
+		p.source.resolved = append(p.source.resolved, RepoResolution{Repo: info.CloneURL, Status: repoSkipped, Reason: err.Error()})
+		return
+	}
+	p.source.resolved = append(p.source.resolved, RepoResolution{Repo: info.CloneURL, Status: repoFailed, Reason: err.Error()})
+}
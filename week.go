@@ -0,0 +1,209 @@
+package main
+
+import (
+	"fmt"
+	"image/color"
+	"math"
+	"strings"
+	"time"
+
+	"gonum.org/v1/plot"
+	"gonum.org/v1/plot/plotter"
+	"gonum.org/v1/plot/vg"
+	"gonum.org/v1/plot/vg/draw"
+)
+
+// day-of-week views: the weekday breakdown in the report, the weekday x hour heatmap and punch
+// card, and the weekly buckets the overwork indicators count in. --week-start picks which day leads,
+// --locale what the days are called
+
+var weekStarts = map[string]time.Weekday{
+	"monday": time.Monday,
+	"sunday": time.Sunday,
+}
+
+// short day names indexed by time.Weekday, so sunday first
+var dayNames = map[string][7]string{
+	"en": {"Sun", "Mon", "Tue", "Wed", "Thu", "Fri", "Sat"},
+	"de": {"So", "Mo", "Di", "Mi", "Do", "Fr", "Sa"},
+	"fr": {"dim", "lun", "mar", "mer", "jeu", "ven", "sam"},
+	"es": {"dom", "lun", "mar", "mié", "jue", "vie", "sáb"},
+	"it": {"dom", "lun", "mar", "mer", "gio", "ven", "sab"},
+	"pt": {"dom", "seg", "ter", "qua", "qui", "sex", "sáb"},
+	"nl": {"zo", "ma", "di", "wo", "do", "vr", "za"},
+	"sv": {"sön", "mån", "tis", "ons", "tor", "fre", "lör"},
+	"pl": {"nie", "pon", "wto", "śro", "czw", "pią", "sob"},
+	"ja": {"日", "月", "火", "水", "木", "金", "土"},
+}
+
+func dayName(d time.Weekday) string {
+	names, ok := dayNames[flags.Locale]
+	if !ok {
+		names = dayNames["en"]
+	}
+	return names[d]
+}
+
+// weekdayOrder is the seven days starting from --week-start
+func weekdayOrder() []time.Weekday {
+	first := weekStarts[flags.WeekStart]
+	order := make([]time.Weekday, 7)
+	for i := range order {
+		order[i] = (first + time.Weekday(i)) % 7
+	}
+	return order
+}
+
+// weekKey names the week t falls in by the date it starts on
+func weekKey(t time.Time) string {
+	back := (int(t.Weekday()) - int(weekStarts[flags.WeekStart]) + 7) % 7
+	return t.AddDate(0, 0, -back).Format(time.DateOnly)
+}
+
+func weekdayCounts(times []time.Time) [7]int {
+	var counts [7]int
+	for _, t := range times {
+		counts[t.Weekday()]++
+	}
+	return counts
+}
+
+func printWeekdays(subject *Subject) {
+	counts := weekdayCounts(subject.times())
+	var maxi int
+	for _, count := range counts {
+		maxi = max(maxi, count)
+	}
+	fmt.Printf("commits by weekday:\n")
+	for _, d := range weekdayOrder() {
+		bar := 0
+		if maxi > 0 {
+			bar = counts[d] * 40 / maxi
+		}
+		fmt.Printf("  %-4s %5d %s\n", dayName(d), counts[d], strings.Repeat("#", bar))
+	}
+}
+
+// weekHourGrid is the heatmap's data: rows are days in weekdayOrder, top to bottom
+type weekHourGrid [7][24]float64
+
+func weekHourCounts(subject *Subject) *weekHourGrid {
+	var grid weekHourGrid
+	row := make(map[time.Weekday]int, 7)
+	for i, d := range weekdayOrder() {
+		row[d] = 6 - i
+	}
+	for _, t := range subject.times() {
+		grid[row[t.Weekday()]][t.Hour()]++
+	}
+	return &grid
+}
+
+func (g *weekHourGrid) Dims() (c, r int)   { return 24, 7 }
+func (g *weekHourGrid) Z(c, r int) float64 { return g[r][c] }
+func (g *weekHourGrid) X(c int) float64    { return float64(c) }
+func (g *weekHourGrid) Y(r int) float64    { return float64(r) }
+
+// themePalette runs from the background to the data color
+type themePalette int
+
+func (n themePalette) Colors() []color.Color {
+	from := color.NRGBAModel.Convert(theme.Background).(color.NRGBA)
+	to := color.NRGBAModel.Convert(theme.Data).(color.NRGBA)
+	lerp := func(a, b uint8, f float64) uint8 { return uint8(float64(a) + (float64(b)-float64(a))*f) }
+	colors := make([]color.Color, n)
+	for i := range colors {
+		f := float64(i) / float64(n-1)
+		colors[i] = color.NRGBA{lerp(from.R, to.R, f), lerp(from.G, to.G, f), lerp(from.B, to.B, f), 0xff}
+	}
+	return colors
+}
+
+type weekdayTicks struct{}
+
+func (weekdayTicks) Ticks(min, max float64) []plot.Tick {
+	var ticks []plot.Tick
+	for i, d := range weekdayOrder() {
+		ticks = append(ticks, plot.Tick{Value: float64(6 - i), Label: dayName(d)})
+	}
+	return ticks
+}
+
+type heatHourTicks struct{}
+
+func (heatHourTicks) Ticks(min, max float64) []plot.Tick {
+	var ticks []plot.Tick
+	for h := 0; h < 24; h += 3 {
+		ticks = append(ticks, plot.Tick{Value: float64(h), Label: shortHour(h)})
+	}
+	return ticks
+}
+
+// plotCommitsHeatmap shades each weekday x hour cell by its commit count. the sleep window's hour
+// columns are shaded over it, translucent so the cells still show through
+func plotCommitsHeatmap(subject *Subject, window SleepWindow, outputPath string) error {
+	grid := weekHourCounts(subject)
+
+	p := newPlot(fmt.Sprintf("Commit Heatmap: %s\n%s", subject.Name, window), "Hour of Day", "")
+	p.X.Tick.Marker = heatHourTicks{}
+	p.Y.Tick.Marker = weekdayTicks{}
+	p.Add(plotter.NewHeatMap(grid, themePalette(32)))
+	if err := addWindowBand(p, window, 1, -0.5, -0.5, 6.5, false); err != nil {
+		return err
+	}
+
+	width, height := plotSize(10*vg.Inch, 4*vg.Inch)
+	if err := savePlot(p, width, height, outputPath, metaFor(subject)); err != nil {
+		return fmt.Errorf("could not save plot: %v", err)
+	}
+	return nil
+}
+
+// plotCommitsPunchcard is the heatmap as bubbles, like github's old punch card: each weekday x hour
+// cell gets a circle whose area grows with its commit count
+func plotCommitsPunchcard(subject *Subject, outputPath string) error {
+	grid := weekHourCounts(subject)
+	var pts plotter.XYs
+	var counts []float64
+	var maxCount float64
+	for r, row := range grid {
+		for c, count := range row {
+			if count == 0 {
+				continue
+			}
+			pts = append(pts, plotter.XY{X: float64(c), Y: float64(r)})
+			counts = append(counts, count)
+			maxCount = max(maxCount, count)
+		}
+	}
+	if len(pts) == 0 {
+		return fmt.Errorf("no commits to plot")
+	}
+
+	p := newPlot(fmt.Sprintf("Punch Card: %s", subject.Name), "Hour of Day", "")
+	p.X.Tick.Marker = heatHourTicks{}
+	p.Y.Tick.Marker = weekdayTicks{}
+	p.X.Min, p.X.Max = -0.5, 23.5
+	p.Y.Min, p.Y.Max = -0.5, 6.5
+
+	bubbles, err := plotter.NewScatter(pts)
+	if err != nil {
+		return fmt.Errorf("could not create punch card: %v", err)
+	}
+	// area, not radius, tracks the count, so a cell with 4x the commits doesn't look 16x as busy
+	maxRadius := vg.Points(12)
+	bubbles.GlyphStyleFunc = func(i int) draw.GlyphStyle {
+		return draw.GlyphStyle{
+			Color:  theme.Data,
+			Shape:  draw.CircleGlyph{},
+			Radius: vg.Length(math.Sqrt(counts[i]/maxCount)) * maxRadius,
+		}
+	}
+	p.Add(bubbles)
+
+	width, height := plotSize(10*vg.Inch, 4*vg.Inch)
+	if err := savePlot(p, width, height, outputPath, metaFor(subject)); err != nil {
+		return fmt.Errorf("could not save plot: %v", err)
+	}
+	return nil
+}
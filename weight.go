@@ -0,0 +1,211 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"math"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/pelletier/go-toml/v2"
+)
+
+// --weight-by lets big commits count for more than typo fixes.
+// files changed comes straight from tree diffs, which blobless clones have.
+// lines changed needs blob contents we deliberately didn't download, so it's asked of the forge instead,
+// one request per commit, and cached on disk since that burns through rate limits fast.
+// weights are log-scaled so a 2000 line vendoring commit doesn't drown out a week of normal work
+
+const cacheDir = "cache"
+const weightCacheFile = "weights.toml"
+
+var weightModes = []string{"count", "lines", "files"}
+
+func sizeWeight(n int) int {
+	return 1 + int(math.Log2(1+float64(n)))
+}
+
+func applyWeights(subjects []Subject, mode string) {
+	if mode == "count" {
+		return
+	}
+
+	cache := loadWeightCache()
+	defer saveWeightCache(cache)
+
+	for i := range subjects {
+		subject := &subjects[i]
+		subject.Weights = make(map[plumbing.Hash]int, len(subject.Commits))
+		var failed int
+		for hash, commit := range subject.Commits {
+			if w, ok := sourceWeights[hash]; ok {
+				subject.Weights[hash] = w
+				continue
+			}
+			key := mode + ":" + hash.String()
+			size, ok := cache[key]
+			if !ok {
+				var err error
+				if mode == "files" {
+					size, err = filesChanged(commit)
+				} else {
+					size, err = linesChanged(subject.Origins[hash], hash)
+				}
+				if err != nil {
+					// unweighted rather than dropped, so a flaky API can't shrink the dataset
+					failed++
+					subject.Weights[hash] = 1
+					continue
+				}
+				cache[key] = size
+			}
+			subject.Weights[hash] = sizeWeight(size)
+		}
+		if failed > 0 {
+			log.Printf("Could not get %s changed for %d/%d commits of %s, counting those once", mode, failed, len(subject.Commits), subject.Name)
+		}
+	}
+}
+
+// filesChanged diffs a commit's tree against its first parent, or an empty tree for root commits
+func filesChanged(commit *object.Commit) (int, error) {
+	if commit.TreeHash.IsZero() {
+		return 0, errNoTree
+	}
+	tree, err := commit.Tree()
+	if err != nil {
+		return 0, err
+	}
+	var parentTree *object.Tree
+	if commit.NumParents() > 0 {
+		parent, err := commit.Parent(0)
+		if err != nil {
+			return 0, err
+		}
+		if parentTree, err = parent.Tree(); err != nil {
+			return 0, err
+		}
+	}
+	changes, err := object.DiffTree(parentTree, tree)
+	if err != nil {
+		return 0, err
+	}
+	return len(changes), nil
+}
+
+// linesChanged asks the forge of the first origin it recognizes for additions+deletions.
+// github, gitlab and gitea all answer with {"stats": {"total": n}}
+func linesChanged(origins []string, hash plumbing.Hash) (int, error) {
+	for _, origin := range origins {
+		req, err := commitStatsRequest(origin, hash)
+		if err != nil {
+			continue
+		}
+
+		client := newHTTPClient(10 * time.Second)
+		resp, err := client.Do(req)
+		if err != nil {
+			return 0, err
+		}
+		defer resp.Body.Close()
+
+		if resp.StatusCode != http.StatusOK {
+			return 0, fmt.Errorf("commit stats request failed: %s", resp.Status)
+		}
+
+		body, err := io.ReadAll(resp.Body)
+		if err != nil {
+			return 0, err
+		}
+
+		var stats struct {
+			Stats struct {
+				Total int `json:"total"`
+			} `json:"stats"`
+		}
+		if err := json.Unmarshal(body, &stats); err != nil {
+			return 0, fmt.Errorf("failed to parse JSON: %w", err)
+		}
+		return stats.Stats.Total, nil
+	}
+	return 0, fmt.Errorf("no known forge among %v", origins)
+}
+
+func commitStatsRequest(repoURL string, hash plumbing.Hash) (*http.Request, error) {
+	u, err := url.Parse(repoURL)
+	if err != nil || u.Host == "" {
+		return nil, fmt.Errorf("can't get commit stats for %s", repoURL)
+	}
+	host := strings.ToLower(u.Hostname())
+	project := strings.TrimSuffix(strings.Trim(u.Path, "/"), ".git")
+
+	var apiURL, header, prefix, tokenEnv string
+	switch {
+	case strings.HasSuffix(host, "github.com"):
+		apiURL = fmt.Sprintf("https://api.github.com/repos/%s/commits/%s", project, hash)
+		header, prefix, tokenEnv = "Authorization", "token ", "GITHUB_TOKEN"
+	case strings.HasSuffix(host, "gitlab.com"):
+		apiURL = fmt.Sprintf("https://%s/api/v4/projects/%s/repository/commits/%s", host, url.PathEscape(project), hash)
+		header, prefix, tokenEnv = "PRIVATE-TOKEN", "", "GITLAB_TOKEN"
+	case strings.HasSuffix(host, "gitea.com"),
+		strings.HasSuffix(host, "codeberg.org"),
+		strings.HasSuffix(host, "forgejo.org"):
+		apiURL = fmt.Sprintf("https://%s/api/v1/repos/%s/git/commits/%s?stat=true", host, project, hash)
+		header, prefix, tokenEnv = "Authorization", "token ", "GITEA_TOKEN"
+	default:
+		return nil, fmt.Errorf("unknown forge %s", host)
+	}
+
+	req, err := http.NewRequest("GET", apiURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", "go-commit-plotter")
+	if token := os.Getenv(tokenEnv); token != "" {
+		req.Header.Set(header, prefix+token)
+	}
+	return req, nil
+}
+
+func loadWeightCache() map[string]int {
+	cache := make(map[string]int)
+	path := filepath.Join(cacheDir, weightCacheFile)
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return cache
+	}
+	if err != nil {
+		log.Printf("Failed to read %s, starting with an empty cache: %v", path, err)
+		return cache
+	}
+	if err := toml.Unmarshal(data, &cache); err != nil {
+		log.Printf("Failed to parse %s, starting with an empty cache: %v", path, err)
+	}
+	return cache
+}
+
+func saveWeightCache(cache map[string]int) {
+	path := filepath.Join(cacheDir, weightCacheFile)
+	if err := os.MkdirAll(cacheDir, 0o755); err != nil {
+		log.Printf("could not make dir %s: %v", cacheDir, err)
+		return
+	}
+	data, err := toml.Marshal(cache)
+	if err != nil {
+		log.Printf("encode %s: %v", path, err)
+		return
+	}
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		log.Printf("could not write file %s: %v", path, err)
+	}
+}
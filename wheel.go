@@ -0,0 +1,114 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	"gonum.org/v1/plot/plotter"
+	"gonum.org/v1/plot/vg"
+)
+
+// the year wheel: day of year runs clockwise around the circle from january at the top, time of day
+// runs outwards from midnight at the inner ring to midnight again at the outer one. a schedule that
+// keeps the same hours all year draws a clean band; one that moves with the daylight bulges in and
+// out with the seasons. it wants a year of data, --since 365
+
+const (
+	// radius of midnight at the start of the day; the hole keeps january's mornings from piling up
+	// in the middle
+	wheelInner = 0.3
+	wheelOuter = 1.0
+)
+
+// wheelPoint places a time on the wheel
+func wheelPoint(t time.Time) plotter.XY {
+	daysInYear := 365.0
+	if time.Date(t.Year(), time.December, 31, 0, 0, 0, 0, time.UTC).YearDay() == 366 {
+		daysInYear = 366
+	}
+	angle := 2 * math.Pi * float64(t.YearDay()-1) / daysInYear
+	return polarPoint(angle, wheelRadius(float64(secondsOfDay(t))))
+}
+
+func wheelRadius(seconds float64) float64 {
+	return wheelInner + (wheelOuter-wheelInner)*seconds/secondsPerDay
+}
+
+// polarPoint is clockwise from 12 o'clock
+func polarPoint(angle, radius float64) plotter.XY {
+	return plotter.XY{X: radius * math.Sin(angle), Y: radius * math.Cos(angle)}
+}
+
+func plotYearWheel(subject *Subject, window SleepWindow, outputPath string) error {
+	times := subject.times()
+	if len(times) == 0 {
+		return fmt.Errorf("no commits to plot")
+	}
+	pts := make(plotter.XYs, len(times))
+	for i, t := range times {
+		pts[i] = wheelPoint(t)
+	}
+
+	p := newPlot(fmt.Sprintf("Year Wheel: %s\n%s", subject.Name, window), "", "")
+	p.HideAxes()
+	p.X.Min, p.X.Max = -1.15, 1.15
+	p.Y.Min, p.Y.Max = -1.15, 1.15
+
+	// rings every 6 hours, labelled along the top spoke
+	var labels plotter.XYLabels
+	for h := 0; h <= 24; h += 6 {
+		r := wheelRadius(float64(h * 3600))
+		ring := make(plotter.XYs, 0, 181)
+		for i := 0; i <= 180; i++ {
+			ring = append(ring, polarPoint(2*math.Pi*float64(i)/180, r))
+		}
+		line, err := plotter.NewLine(ring)
+		if err != nil {
+			return fmt.Errorf("could not draw wheel: %v", err)
+		}
+		line.Color = withAlpha(theme.Foreground, 0.4)
+		p.Add(line)
+		labels.XYs = append(labels.XYs, polarPoint(0, r))
+		labels.Labels = append(labels.Labels, shortHour(h%24))
+	}
+
+	// a spoke and a name per month
+	for m := time.January; m <= time.December; m++ {
+		angle := 2 * math.Pi * float64(time.Date(2001, m, 1, 0, 0, 0, 0, time.UTC).YearDay()-1) / 365
+		spoke, err := plotter.NewLine(plotter.XYs{polarPoint(angle, wheelInner), polarPoint(angle, wheelOuter)})
+		if err != nil {
+			return fmt.Errorf("could not draw wheel: %v", err)
+		}
+		spoke.Color = withAlpha(theme.Foreground, 0.4)
+		p.Add(spoke)
+		// names sit mid-month, just outside the wheel
+		labels.XYs = append(labels.XYs, polarPoint(angle+math.Pi/12, wheelOuter+0.08))
+		labels.Labels = append(labels.Labels, m.String()[:3])
+	}
+
+	scatter, err := plotter.NewScatter(pts)
+	if err != nil {
+		return fmt.Errorf("could not create year wheel: %v", err)
+	}
+	scatter.Radius = vg.Points(1.5)
+	scatter.Color = withAlpha(theme.Data, scatterAlpha(len(pts)))
+	p.Add(scatter)
+
+	l, err := plotter.NewLabels(labels)
+	if err != nil {
+		return fmt.Errorf("could not label wheel: %v", err)
+	}
+	for i := range l.TextStyle {
+		l.TextStyle[i].Color = theme.Foreground
+		l.TextStyle[i].XAlign = -0.5
+		l.TextStyle[i].YAlign = -0.5
+	}
+	p.Add(l)
+
+	width, height := plotSize(8*vg.Inch, 8*vg.Inch)
+	if err := savePlot(p, width, height, outputPath, metaFor(subject)); err != nil {
+		return fmt.Errorf("could not save plot: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"net/url"
+	"strings"
+
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+// work vs personal activity. a commit counts as work if any repo it was seen in is owned by one of the
+// subject's orgs; everything else is personal. the two halves are reported as their own subjects,
+// so every histogram, plot and estimate applies to them unchanged
+
+// expandDerived adds every subject derived from another: rotating shift regimes, then the
+// work/personal split of subjects with orgs
+func expandDerived(subjects []Subject) []Subject {
+	return expandWorkSplit(expandShifts(subjects))
+}
+
+// expandWorkSplit appends "<name>-work" and "<name>-personal" after every subject that has orgs configured
+func expandWorkSplit(subjects []Subject) []Subject {
+	var expanded []Subject
+	for _, subject := range subjects {
+		expanded = append(expanded, subject)
+		if len(subject.Orgs) == 0 {
+			continue
+		}
+		work, personal := splitWorkPersonal(subject)
+		expanded = append(expanded, work, personal)
+	}
+	return expanded
+}
+
+func splitWorkPersonal(subject Subject) (Subject, Subject) {
+	orgs := make(map[string]bool)
+	for _, org := range subject.Orgs {
+		orgs[strings.ToLower(org)] = true
+	}
+
+	work := derivedSubject(subject, subject.Name+"-work")
+	personal := derivedSubject(subject, subject.Name+"-personal")
+	for hash, commit := range subject.Commits {
+		dst := personal
+		for _, repoURL := range subject.Origins[hash] {
+			if orgs[repoOwner(repoURL)] {
+				dst = work
+				break
+			}
+		}
+		dst.Commits[hash] = commit
+		dst.Origins[hash] = subject.Origins[hash]
+	}
+	return work, personal
+}
+
+// derivedSubject is an empty copy of subject under a new name, for reporting on a subset of its commits
+func derivedSubject(subject Subject, name string) Subject {
+	return Subject{
+		Name:     name,
+		Sources:  subject.Sources,
+		Commits:  make(map[plumbing.Hash]*object.Commit),
+		Origins:  make(map[plumbing.Hash][]string),
+		Weights:  subject.Weights,
+		Location: subject.Location,
+
+		ZoneInferred: subject.ZoneInferred,
+	}
+}
+
+// repoOwner pulls the lowercased namespace out of a clone URL,
+// e.g. https://github.com/graevy/sleep.git or git@github.com:graevy/sleep.git
+func repoOwner(repoURL string) string {
+	var path string
+	if u, err := url.Parse(repoURL); err == nil && u.Host != "" {
+		path = u.Path
+	} else if _, after, ok := strings.Cut(repoURL, ":"); ok {
+		path = after
+	}
+	owner, _, _ := strings.Cut(strings.Trim(path, "/"), "/")
+	return strings.ToLower(owner)
+}